@@ -0,0 +1,633 @@
+package batch
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============== Message Batches 模拟 ==============
+//
+// 批处理持久化在目录中，每个批处理对应三个文件：
+//   {id}.json           批处理元数据
+//   {id}.requests.jsonl 创建时提交的请求（只写一次）
+//   {id}.results.jsonl  逐条追加的结果
+// 重启后根据结果文件恢复未完成的请求，计数也由结果文件重新统计
+
+const (
+	batchExpiry      = 24 * time.Hour      // 创建后超过该时间仍未执行的请求标记为 expired
+	batchRetention   = 29 * 24 * time.Hour // 结束后保留结果的时间
+	cleanupInterval  = 1 * time.Hour
+	MaxBatchRequests = 100000
+)
+
+// 批处理状态
+const (
+	StatusInProgress = "in_progress"
+	StatusCanceling  = "canceling"
+	StatusEnded      = "ended"
+)
+
+// 单个请求的结果类型
+const (
+	ResultSucceeded = "succeeded"
+	ResultErrored   = "errored"
+	ResultCanceled  = "canceled"
+	ResultExpired   = "expired"
+)
+
+var (
+	ErrNotFound  = errors.New("批处理不存在")
+	ErrNotEnded  = errors.New("批处理尚未结束")
+	customIDRule = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+)
+
+// RequestCounts 各状态的请求数
+type RequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// Batch 批处理（与 Anthropic MessageBatch 对象一致）
+type Batch struct {
+	ID                string        `json:"id"`
+	Type              string        `json:"type"`
+	ProcessingStatus  string        `json:"processing_status"`
+	RequestCounts     RequestCounts `json:"request_counts"`
+	CreatedAt         time.Time     `json:"created_at"`
+	ExpiresAt         time.Time     `json:"expires_at"`
+	EndedAt           *time.Time    `json:"ended_at"`
+	CancelInitiatedAt *time.Time    `json:"cancel_initiated_at"`
+	ArchivedAt        *time.Time    `json:"archived_at"`
+	ResultsURL        *string       `json:"results_url"` // 由调用方按请求地址填写
+}
+
+// Request 批处理中的单个请求
+type Request struct {
+	CustomID string          `json:"custom_id"`
+	Params   json.RawMessage `json:"params"`
+}
+
+// Executor 执行单个 Messages 请求，返回 HTTP 状态码和响应体
+type Executor func(params []byte, headers http.Header) (int, []byte)
+
+// batchFile 元数据文件结构
+type batchFile struct {
+	Batch
+	Headers http.Header `json:"headers,omitempty"` // 执行请求时附带的请求头（anthropic-version 等）
+}
+
+// job 未结束批处理的执行状态
+type job struct {
+	batch    *Batch
+	headers  http.Header
+	pending  []Request
+	inFlight int
+}
+
+// Manager 批处理管理器
+type Manager struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	dir         string
+	batches     map[string]*Batch
+	jobs        map[string]*job
+	order       []string // 未结束批处理的创建顺序（先创建的先执行）
+	running     int
+	executor    Executor
+	concurrency func() int
+}
+
+// NewManager 创建批处理管理器，加载持久化的批处理并启动执行
+// concurrency 返回当前允许同时执行的请求数（每次调度时读取，支持配置热重载）
+func NewManager(dir string, executor Executor, concurrency func() int) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建批处理目录失败: %w", err)
+	}
+
+	m := &Manager{
+		dir:         dir,
+		batches:     make(map[string]*Batch),
+		jobs:        make(map[string]*job),
+		executor:    executor,
+		concurrency: concurrency,
+	}
+	m.cond = sync.NewCond(&m.mu)
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	go m.dispatch()
+	go m.cleanupLoop()
+	return m, nil
+}
+
+// Create 创建批处理
+func (m *Manager) Create(requests []Request, headers http.Header) (Batch, error) {
+	if len(requests) == 0 {
+		return Batch{}, fmt.Errorf("requests 不能为空")
+	}
+	if len(requests) > MaxBatchRequests {
+		return Batch{}, fmt.Errorf("requests 数量不能超过 %d", MaxBatchRequests)
+	}
+
+	seen := make(map[string]bool, len(requests))
+	prepared := make([]Request, len(requests))
+	for i, req := range requests {
+		if !customIDRule.MatchString(req.CustomID) {
+			return Batch{}, fmt.Errorf("requests[%d].custom_id 无效: 需为 1-64 位字母、数字、下划线或连字符", i)
+		}
+		if seen[req.CustomID] {
+			return Batch{}, fmt.Errorf("requests[%d].custom_id 重复: %s", i, req.CustomID)
+		}
+		seen[req.CustomID] = true
+
+		var params map[string]interface{}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params == nil {
+			return Batch{}, fmt.Errorf("requests[%d].params 必须是 JSON 对象", i)
+		}
+		// 批处理请求统一以非流式执行
+		delete(params, "stream")
+		data, _ := json.Marshal(params)
+		prepared[i] = Request{CustomID: req.CustomID, Params: data}
+	}
+
+	now := time.Now().UTC()
+	batch := &Batch{
+		ID:               generateID(),
+		Type:             "message_batch",
+		ProcessingStatus: StatusInProgress,
+		RequestCounts:    RequestCounts{Processing: len(prepared)},
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(batchExpiry),
+	}
+
+	if err := m.writeRequests(batch.ID, prepared); err != nil {
+		return Batch{}, err
+	}
+	if err := os.WriteFile(m.resultsPath(batch.ID), nil, 0600); err != nil {
+		return Batch{}, fmt.Errorf("创建结果文件失败: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j := &job{batch: batch, headers: headers, pending: prepared}
+	if err := m.saveLocked(j.batch, j.headers); err != nil {
+		return Batch{}, err
+	}
+	m.batches[batch.ID] = batch
+	m.jobs[batch.ID] = j
+	m.order = append(m.order, batch.ID)
+	m.cond.Broadcast()
+
+	log.Printf("📦 创建批处理 %s: %d 个请求", batch.ID, len(prepared))
+	return *batch, nil
+}
+
+// Get 获取批处理
+func (m *Manager) Get(id string) (Batch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, exists := m.batches[id]
+	if !exists {
+		return Batch{}, false
+	}
+	return *batch, true
+}
+
+// List 获取所有批处理（按创建时间倒序）
+func (m *Manager) List() []Batch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]Batch, 0, len(m.batches))
+	for _, batch := range m.batches {
+		result = append(result, *batch)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].ID > result[j].ID
+		}
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Cancel 取消批处理：尚未执行的请求标记为 canceled，执行中的请求完成后批处理结束
+func (m *Manager) Cancel(id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, exists := m.batches[id]
+	if !exists {
+		return Batch{}, ErrNotFound
+	}
+	j, running := m.jobs[id]
+	if !running || batch.ProcessingStatus != StatusInProgress {
+		return *batch, nil
+	}
+
+	now := time.Now().UTC()
+	batch.ProcessingStatus = StatusCanceling
+	batch.CancelInitiatedAt = &now
+	m.dropPendingLocked(j, ResultCanceled)
+	if err := m.saveLocked(j.batch, j.headers); err != nil {
+		log.Printf("⚠️ 保存批处理 %s 失败: %v", id, err)
+	}
+	m.finishIfDoneLocked(j)
+
+	log.Printf("🛑 取消批处理 %s", id)
+	return *batch, nil
+}
+
+// Delete 删除已结束的批处理及其结果
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, exists := m.batches[id]
+	if !exists {
+		return ErrNotFound
+	}
+	if batch.ProcessingStatus != StatusEnded {
+		return ErrNotEnded
+	}
+	m.removeLocked(id)
+	return nil
+}
+
+// ResultsPath 获取已结束批处理的结果文件路径
+func (m *Manager) ResultsPath(id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, exists := m.batches[id]
+	if !exists {
+		return "", ErrNotFound
+	}
+	if batch.ProcessingStatus != StatusEnded {
+		return "", ErrNotEnded
+	}
+	return m.resultsPath(id), nil
+}
+
+// Wake 唤醒调度循环，使其按最新的并发上限重新调度（配置热重载后调用）
+func (m *Manager) Wake() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cond.Broadcast()
+}
+
+// dispatch 调度循环：按并发上限从未结束的批处理中依次取出请求执行
+func (m *Manager) dispatch() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		if m.running >= m.concurrency() {
+			m.cond.Wait()
+			continue
+		}
+
+		j, req, ok := m.nextLocked()
+		if !ok {
+			m.cond.Wait()
+			continue
+		}
+
+		m.running++
+		j.inFlight++
+		go m.execute(j, req)
+	}
+}
+
+// nextLocked 取出下一个待执行的请求，顺带将已过期批处理的剩余请求标记为 expired（需持有锁）
+func (m *Manager) nextLocked() (*job, Request, bool) {
+	now := time.Now()
+	for _, id := range m.order {
+		j := m.jobs[id]
+		if len(j.pending) == 0 {
+			continue
+		}
+		if now.After(j.batch.ExpiresAt) {
+			log.Printf("⏰ 批处理 %s 已过期，剩余 %d 个请求未执行", id, len(j.pending))
+			m.dropPendingLocked(j, ResultExpired)
+			m.finishIfDoneLocked(j)
+			return m.nextLocked()
+		}
+
+		req := j.pending[0]
+		j.pending = j.pending[1:]
+		return j, req, true
+	}
+	return nil, Request{}, false
+}
+
+// execute 执行单个请求并记录结果
+func (m *Manager) execute(j *job, req Request) {
+	status, body := m.executor(req.Params, j.headers)
+	resultType, result := buildResult(status, body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.running--
+	j.inFlight--
+	m.recordResultLocked(j, req.CustomID, resultType, result)
+	m.finishIfDoneLocked(j)
+	m.cond.Broadcast()
+}
+
+// buildResult 将执行结果转换为结果行中的 result 对象
+func buildResult(status int, body []byte) (string, map[string]interface{}) {
+	if status >= 200 && status < 300 {
+		var message interface{}
+		if err := json.Unmarshal(body, &message); err == nil {
+			return ResultSucceeded, map[string]interface{}{"type": ResultSucceeded, "message": message}
+		}
+	}
+
+	// 上游错误为 Anthropic 格式时原样保留，否则包装为 api_error
+	var errBody map[string]interface{}
+	if json.Unmarshal(body, &errBody) != nil || errBody["type"] != "error" {
+		message := strings.TrimSpace(string(body))
+		if message == "" {
+			message = http.StatusText(status)
+		}
+		errBody = map[string]interface{}{
+			"type": "error",
+			"error": map[string]interface{}{
+				"type":    "api_error",
+				"message": fmt.Sprintf("上游返回 %d: %s", status, message),
+			},
+		}
+	}
+	return ResultErrored, map[string]interface{}{"type": ResultErrored, "error": errBody}
+}
+
+// dropPendingLocked 将尚未执行的请求全部标记为指定结果（需持有锁）
+func (m *Manager) dropPendingLocked(j *job, resultType string) {
+	for _, req := range j.pending {
+		m.recordResultLocked(j, req.CustomID, resultType, map[string]interface{}{"type": resultType})
+	}
+	j.pending = nil
+}
+
+// recordResultLocked 追加结果行并更新计数（需持有锁）
+func (m *Manager) recordResultLocked(j *job, customID, resultType string, result map[string]interface{}) {
+	if err := m.appendResult(j.batch.ID, customID, result); err != nil {
+		log.Printf("⚠️ 写入批处理 %s 结果失败: %v", j.batch.ID, err)
+	}
+
+	counts := &j.batch.RequestCounts
+	counts.Processing--
+	addCount(counts, resultType)
+}
+
+// finishIfDoneLocked 所有请求均有结果时结束批处理（需持有锁）
+func (m *Manager) finishIfDoneLocked(j *job) {
+	if len(j.pending) > 0 || j.inFlight > 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	j.batch.ProcessingStatus = StatusEnded
+	j.batch.EndedAt = &now
+	if err := m.saveLocked(j.batch, nil); err != nil {
+		log.Printf("⚠️ 保存批处理 %s 失败: %v", j.batch.ID, err)
+	}
+
+	delete(m.jobs, j.batch.ID)
+	for i, id := range m.order {
+		if id == j.batch.ID {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+
+	counts := j.batch.RequestCounts
+	log.Printf("✅ 批处理 %s 已结束: 成功 %d, 失败 %d, 取消 %d, 过期 %d",
+		j.batch.ID, counts.Succeeded, counts.Errored, counts.Canceled, counts.Expired)
+}
+
+// cleanupLoop 定期删除超过保留时间的批处理
+func (m *Manager) cleanupLoop() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.mu.Lock()
+		for id, batch := range m.batches {
+			if batch.EndedAt != nil && time.Since(*batch.EndedAt) > batchRetention {
+				m.removeLocked(id)
+				log.Printf("🧹 清理过期批处理: %s", id)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// removeLocked 删除批处理文件（需持有锁）
+func (m *Manager) removeLocked(id string) {
+	delete(m.batches, id)
+	for _, path := range []string{m.metaPath(id), m.requestsPath(id), m.resultsPath(id)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ 删除批处理文件失败: %v", err)
+		}
+	}
+}
+
+// ============== 持久化 ==============
+
+func (m *Manager) metaPath(id string) string     { return filepath.Join(m.dir, id+".json") }
+func (m *Manager) requestsPath(id string) string { return filepath.Join(m.dir, id+".requests.jsonl") }
+func (m *Manager) resultsPath(id string) string  { return filepath.Join(m.dir, id+".results.jsonl") }
+
+// saveLocked 保存批处理元数据（需持有锁）
+func (m *Manager) saveLocked(batch *Batch, headers http.Header) error {
+	data, err := json.MarshalIndent(batchFile{Batch: *batch, Headers: headers}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.metaPath(batch.ID), data, 0600)
+}
+
+// writeRequests 写入请求文件
+func (m *Manager) writeRequests(id string, requests []Request) error {
+	f, err := os.OpenFile(m.requestsPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建请求文件失败: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, req := range requests {
+		if err := encoder.Encode(req); err != nil {
+			return fmt.Errorf("写入请求文件失败: %w", err)
+		}
+	}
+	return w.Flush()
+}
+
+// appendResult 追加一行结果
+func (m *Manager) appendResult(id, customID string, result map[string]interface{}) error {
+	line, err := json.Marshal(map[string]interface{}{"custom_id": customID, "result": result})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(m.resultsPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// load 加载持久化的批处理，未结束的批处理从结果文件恢复进度后继续执行
+func (m *Manager) load() error {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return fmt.Errorf("读取批处理目录失败: %w", err)
+	}
+
+	var resumed []*job
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(m.dir, name))
+		if err != nil {
+			return err
+		}
+		var file batchFile
+		if err := json.Unmarshal(data, &file); err != nil || file.ID == "" {
+			log.Printf("⚠️ 跳过无效的批处理文件 %s: %v", name, err)
+			continue
+		}
+
+		batch := file.Batch
+		m.batches[batch.ID] = &batch
+		if batch.ProcessingStatus == StatusEnded {
+			continue
+		}
+
+		j, err := m.resumeJob(&batch, file.Headers)
+		if err != nil {
+			log.Printf("⚠️ 恢复批处理 %s 失败: %v", batch.ID, err)
+			continue
+		}
+		resumed = append(resumed, j)
+	}
+
+	sort.Slice(resumed, func(a, b int) bool {
+		return resumed[a].batch.CreatedAt.Before(resumed[b].batch.CreatedAt)
+	})
+	for _, j := range resumed {
+		m.jobs[j.batch.ID] = j
+		m.order = append(m.order, j.batch.ID)
+		if j.batch.ProcessingStatus == StatusCanceling {
+			m.dropPendingLocked(j, ResultCanceled)
+		}
+		m.finishIfDoneLocked(j)
+	}
+	if len(m.order) > 0 {
+		log.Printf("📦 已恢复 %d 个未完成的批处理", len(m.order))
+	}
+	return nil
+}
+
+// resumeJob 根据请求文件和结果文件恢复未完成的请求及计数
+func (m *Manager) resumeJob(batch *Batch, headers http.Header) (*job, error) {
+	done := make(map[string]bool)
+	counts := RequestCounts{}
+	if err := readJSONLines(m.resultsPath(batch.ID), func(line []byte) {
+		var result struct {
+			CustomID string `json:"custom_id"`
+			Result   struct {
+				Type string `json:"type"`
+			} `json:"result"`
+		}
+		if json.Unmarshal(line, &result) == nil && !done[result.CustomID] {
+			done[result.CustomID] = true
+			addCount(&counts, result.Result.Type)
+		}
+	}); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	j := &job{batch: batch, headers: headers}
+	if err := readJSONLines(m.requestsPath(batch.ID), func(line []byte) {
+		var req Request
+		if json.Unmarshal(line, &req) == nil && !done[req.CustomID] {
+			j.pending = append(j.pending, req)
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	counts.Processing = len(j.pending)
+	batch.RequestCounts = counts
+	return j, nil
+}
+
+// readJSONLines 逐行读取 JSONL 文件
+func readJSONLines(path string, fn func(line []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// addCount 按结果类型累加计数
+func addCount(counts *RequestCounts, resultType string) {
+	switch resultType {
+	case ResultSucceeded:
+		counts.Succeeded++
+	case ResultErrored:
+		counts.Errored++
+	case ResultCanceled:
+		counts.Canceled++
+	case ResultExpired:
+		counts.Expired++
+	}
+}
+
+// generateID 生成批处理 ID
+func generateID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "msgbatch_" + hex.EncodeToString(b)
+}
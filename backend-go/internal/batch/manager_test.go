@@ -0,0 +1,262 @@
+package batch
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForStatus 等待批处理进入指定状态
+func waitForStatus(t *testing.T, m *Manager, id, status string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if batch, ok := m.Get(id); ok && batch.ProcessingStatus == status {
+			return batch
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	batch, _ := m.Get(id)
+	t.Fatalf("批处理 %s 未进入 %s 状态，当前: %+v", id, status, batch)
+	return Batch{}
+}
+
+// readResults 读取结果文件，按 custom_id 索引结果类型
+func readResults(t *testing.T, m *Manager, id string) map[string]string {
+	t.Helper()
+	path, err := m.ResultsPath(id)
+	if err != nil {
+		t.Fatalf("获取结果文件失败: %v", err)
+	}
+	results := make(map[string]string)
+	if err := readJSONLines(path, func(line []byte) {
+		var result struct {
+			CustomID string `json:"custom_id"`
+			Result   struct {
+				Type string `json:"type"`
+			} `json:"result"`
+		}
+		json.Unmarshal(line, &result)
+		results[result.CustomID] = result.Result.Type
+	}); err != nil {
+		t.Fatalf("读取结果文件失败: %v", err)
+	}
+	return results
+}
+
+func request(customID, params string) Request {
+	return Request{CustomID: customID, Params: json.RawMessage(params)}
+}
+
+func TestManager_ProcessBatch(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	executor := func(params []byte, headers http.Header) (int, []byte) {
+		mu.Lock()
+		received = append(received, string(params))
+		mu.Unlock()
+		if strings.Contains(string(params), "fail") {
+			return 400, []byte(`{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`)
+		}
+		return 200, []byte(`{"id":"msg_1","type":"message"}`)
+	}
+
+	m, err := NewManager(t.TempDir(), executor, func() int { return 2 })
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+
+	batch, err := m.Create([]Request{
+		request("a", `{"model":"m","stream":true}`),
+		request("b", `{"model":"fail"}`),
+		request("c", `{"model":"m"}`),
+	}, nil)
+	if err != nil {
+		t.Fatalf("创建批处理失败: %v", err)
+	}
+	if !strings.HasPrefix(batch.ID, "msgbatch_") || batch.RequestCounts.Processing != 3 {
+		t.Errorf("批处理 = %+v", batch)
+	}
+
+	ended := waitForStatus(t, m, batch.ID, StatusEnded)
+	if ended.RequestCounts.Succeeded != 2 || ended.RequestCounts.Errored != 1 || ended.RequestCounts.Processing != 0 {
+		t.Errorf("计数 = %+v", ended.RequestCounts)
+	}
+	if ended.EndedAt == nil {
+		t.Error("结束时间未设置")
+	}
+
+	results := readResults(t, m, batch.ID)
+	if results["a"] != ResultSucceeded || results["b"] != ResultErrored || results["c"] != ResultSucceeded {
+		t.Errorf("结果 = %v", results)
+	}
+
+	mu.Lock()
+	for _, params := range received {
+		if strings.Contains(params, "stream") {
+			t.Errorf("批处理请求应以非流式执行: %s", params)
+		}
+	}
+	mu.Unlock()
+
+	if err := m.Delete(batch.ID); err != nil {
+		t.Errorf("删除批处理失败: %v", err)
+	}
+	if _, ok := m.Get(batch.ID); ok {
+		t.Error("删除后仍能获取批处理")
+	}
+}
+
+func TestManager_CreateInvalid(t *testing.T) {
+	m, err := NewManager(t.TempDir(), func([]byte, http.Header) (int, []byte) { return 200, []byte(`{}`) }, func() int { return 1 })
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		requests []Request
+	}{
+		{"空请求", nil},
+		{"custom_id 无效", []Request{request("a b", `{}`)}},
+		{"custom_id 重复", []Request{request("a", `{}`), request("a", `{}`)}},
+		{"params 非对象", []Request{request("a", `[]`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Create(tt.requests, nil); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	executor := func([]byte, http.Header) (int, []byte) {
+		<-release
+		return 200, []byte(`{"id":"msg_1"}`)
+	}
+
+	m, err := NewManager(t.TempDir(), executor, func() int { return 1 })
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+
+	batch, err := m.Create([]Request{request("a", `{}`), request("b", `{}`), request("c", `{}`)}, nil)
+	if err != nil {
+		t.Fatalf("创建批处理失败: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond) // 等待第一个请求开始执行
+
+	canceled, err := m.Cancel(batch.ID)
+	if err != nil {
+		t.Fatalf("取消失败: %v", err)
+	}
+	if canceled.ProcessingStatus != StatusCanceling || canceled.CancelInitiatedAt == nil {
+		t.Errorf("取消后状态 = %+v", canceled)
+	}
+	if _, err := m.ResultsPath(batch.ID); err != ErrNotEnded {
+		t.Errorf("执行中的批处理不应返回结果文件: %v", err)
+	}
+	if err := m.Delete(batch.ID); err != ErrNotEnded {
+		t.Errorf("执行中的批处理不应允许删除: %v", err)
+	}
+
+	close(release)
+	ended := waitForStatus(t, m, batch.ID, StatusEnded)
+	if ended.RequestCounts.Succeeded != 1 || ended.RequestCounts.Canceled != 2 {
+		t.Errorf("计数 = %+v", ended.RequestCounts)
+	}
+}
+
+func TestManager_ResumeAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	block := make(chan struct{})
+	m, err := NewManager(dir, func([]byte, http.Header) (int, []byte) {
+		<-block
+		return 200, []byte(`{}`)
+	}, func() int { return 0 }) // 并发为 0：请求不会被执行，模拟进程在执行前退出
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+
+	batch, err := m.Create([]Request{request("a", `{}`), request("b", `{}`)}, http.Header{"Anthropic-Version": {"2023-06-01"}})
+	if err != nil {
+		t.Fatalf("创建批处理失败: %v", err)
+	}
+	// 模拟重启前已完成一个请求
+	if err := m.appendResult(batch.ID, "a", map[string]interface{}{"type": ResultSucceeded}); err != nil {
+		t.Fatalf("写入结果失败: %v", err)
+	}
+
+	var gotHeader string
+	restarted, err := NewManager(dir, func(_ []byte, headers http.Header) (int, []byte) {
+		gotHeader = headers.Get("Anthropic-Version")
+		return 200, []byte(`{}`)
+	}, func() int { return 1 })
+	if err != nil {
+		t.Fatalf("重新创建管理器失败: %v", err)
+	}
+
+	ended := waitForStatus(t, restarted, batch.ID, StatusEnded)
+	if ended.RequestCounts.Succeeded != 2 {
+		t.Errorf("恢复后计数 = %+v", ended.RequestCounts)
+	}
+	if gotHeader != "2023-06-01" {
+		t.Errorf("恢复后请求头 = %q", gotHeader)
+	}
+	if results := readResults(t, restarted, batch.ID); len(results) != 2 {
+		t.Errorf("结果 = %v", results)
+	}
+
+	if _, err := os.Stat(restarted.metaPath(batch.ID)); err != nil {
+		t.Errorf("元数据文件不存在: %v", err)
+	}
+}
+
+func TestManager_WakeAppliesRaisedConcurrency(t *testing.T) {
+	var limit int32 = 1
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	executor := func(params []byte, headers http.Header) (int, []byte) {
+		started <- struct{}{}
+		<-release
+		return 200, []byte(`{"id":"msg_1","type":"message"}`)
+	}
+
+	m, err := NewManager(t.TempDir(), executor, func() int { return int(atomic.LoadInt32(&limit)) })
+	if err != nil {
+		t.Fatalf("创建管理器失败: %v", err)
+	}
+	defer close(release)
+
+	if _, err := m.Create([]Request{
+		request("a", `{"model":"m"}`),
+		request("b", `{"model":"m"}`),
+	}, nil); err != nil {
+		t.Fatalf("创建批处理失败: %v", err)
+	}
+
+	<-started
+	select {
+	case <-started:
+		t.Fatal("并发上限为 1 时不应同时执行两个请求")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 提高并发上限后唤醒调度循环，第二个请求应在第一个完成前开始执行
+	atomic.StoreInt32(&limit, 2)
+	m.Wake()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("提高并发上限并唤醒后第二个请求仍未开始执行")
+	}
+}
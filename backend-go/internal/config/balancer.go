@@ -0,0 +1,269 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============== 渠道级负载均衡 ==============
+
+// 渠道负载均衡策略
+const (
+	ChannelStrategyFailover = "failover" // 按故障转移链顺序（当前渠道优先）
+	ChannelStrategyWeighted = "weighted" // 按权重随机分配
+	ChannelStrategyLatency  = "latency"  // 按权重、首字节延迟和成功率综合分配
+)
+
+const (
+	ttfbAlpha      = 0.3  // 首字节时间 EWMA 平滑系数
+	successAlpha   = 0.1  // 成功率 EWMA 平滑系数
+	defaultTTFBMs  = 1000 // 无样本渠道的默认首字节时间
+	minTTFBMs      = 50   // 首字节时间下限，避免个别极快样本独占流量
+	minSuccessRate = 0.05 // 成功率下限，保证不健康渠道仍有少量探测流量
+)
+
+// ChannelMetrics 渠道运行指标
+type ChannelMetrics struct {
+	TTFBMs      float64   `json:"ttfbMs"`      // 首字节时间 EWMA（毫秒）
+	SuccessRate float64   `json:"successRate"` // 成功率 EWMA（0-1）
+	Requests    int64     `json:"requests"`
+	Failures    int64     `json:"failures"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// LoadBalanceUpdate 负载均衡配置的部分更新
+type LoadBalanceUpdate struct {
+	Strategy         *string     `json:"strategy"`         // 渠道内密钥策略
+	ChannelStrategy  *string     `json:"channelStrategy"`  // 渠道间策略
+	Weights          map[int]int `json:"weights"`          // Messages 渠道索引 → 权重
+	ResponsesWeights map[int]int `json:"responsesWeights"` // Responses 渠道索引 → 权重
+}
+
+// channelBalancer 渠道负载均衡器，记录各渠道的运行指标
+type channelBalancer struct {
+	mu      sync.Mutex
+	metrics map[string]*ChannelMetrics
+	rng     *rand.Rand
+}
+
+func newChannelBalancer() *channelBalancer {
+	return &channelBalancer{
+		metrics: make(map[string]*ChannelMetrics),
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// channelKey 渠道运行时状态的标识（索引会因删除渠道而变化，不适合作为标识）
+func channelKey(upstream *UpstreamConfig) string {
+	return upstream.ServiceType + "|" + upstream.BaseURL + "|" + upstream.Name
+}
+
+// record 记录一次请求结果
+func (b *channelBalancer) record(key string, ttfb time.Duration, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, exists := b.metrics[key]
+	if !exists {
+		m = &ChannelMetrics{SuccessRate: 1}
+		b.metrics[key] = m
+	}
+
+	m.Requests++
+	m.LastUpdated = time.Now()
+
+	if success {
+		m.SuccessRate = m.SuccessRate*(1-successAlpha) + successAlpha
+		ms := float64(ttfb.Milliseconds())
+		if m.TTFBMs == 0 {
+			m.TTFBMs = ms
+		} else {
+			m.TTFBMs = m.TTFBMs*(1-ttfbAlpha) + ms*ttfbAlpha
+		}
+	} else {
+		m.Failures++
+		m.SuccessRate = m.SuccessRate * (1 - successAlpha)
+	}
+}
+
+// snapshot 获取渠道指标副本
+func (b *channelBalancer) snapshot(key string) (ChannelMetrics, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, exists := b.metrics[key]
+	if !exists {
+		return ChannelMetrics{}, false
+	}
+	return *m, true
+}
+
+// effectiveWeight 计算渠道在指定策略下的有效权重
+func (b *channelBalancer) effectiveWeight(upstream *UpstreamConfig, strategy string) float64 {
+	weight := float64(upstream.Weight)
+	if weight <= 0 {
+		// 未设置权重时为 1（显式的 0 和负数在校验时被拒绝）
+		weight = 1
+	}
+
+	if strategy != ChannelStrategyLatency {
+		return weight
+	}
+
+	ttfb := float64(defaultTTFBMs)
+	successRate := 1.0
+	if m, ok := b.snapshot(channelKey(upstream)); ok {
+		if m.TTFBMs > 0 {
+			ttfb = m.TTFBMs
+		}
+		successRate = m.SuccessRate
+	}
+
+	ttfb = math.Max(ttfb, minTTFBMs)
+	successRate = math.Max(successRate, minSuccessRate)
+
+	// 成功率按平方放大，使频繁失败的渠道迅速让出流量
+	return weight * successRate * successRate * 1000 / ttfb
+}
+
+// order 按策略对同一优先级内的渠道重新排序
+// 使用加权随机无放回抽样：首选渠道的概率与有效权重成正比，其余渠道保留为故障转移候选
+func (b *channelBalancer) order(chain []UpstreamCandidate, strategy string) {
+	if len(chain) < 2 {
+		return
+	}
+
+	scores := make(map[int]float64, len(chain))
+	for _, candidate := range chain {
+		w := b.effectiveWeight(candidate.Upstream, strategy)
+		b.mu.Lock()
+		u := b.rng.Float64()
+		b.mu.Unlock()
+		scores[candidate.Index] = math.Pow(u, 1/w)
+	}
+
+	sort.SliceStable(chain, func(i, j int) bool {
+		return scores[chain[i].Index] > scores[chain[j].Index]
+	})
+}
+
+// balanceChain 按渠道负载均衡策略调整故障转移链顺序
+// pinned 为会话绑定的渠道标识：该渠道固定排在最前，其余渠道再按策略重排；
+// 模型路由命中的渠道与兜底渠道分属不同优先级，仅在各自优先级内部重排
+func (b *channelBalancer) balanceChain(chain []UpstreamCandidate, model, strategy, pinned string) {
+	if strategy != ChannelStrategyWeighted && strategy != ChannelStrategyLatency {
+		return
+	}
+
+	if pinned != "" {
+		for i, candidate := range chain {
+			if channelKey(candidate.Upstream) == pinned {
+				copy(chain[1:i+1], chain[:i])
+				chain[0] = candidate
+				chain = chain[1:]
+				break
+			}
+		}
+	}
+
+	split := 0
+	for split < len(chain) && len(chain[split].Upstream.Models) > 0 && model != "" &&
+		UpstreamServesModel(chain[split].Upstream, model) {
+		split++
+	}
+
+	b.order(chain[:split], strategy)
+	b.order(chain[split:], strategy)
+}
+
+// RecordChannelResult 记录渠道请求结果（用于延迟感知负载均衡和熔断器）
+// 仅应记录渠道级结果：成功响应、连接错误和 5xx；ttfb 为发出请求到收到响应头的时间，失败时忽略
+func (cm *ConfigManager) RecordChannelResult(upstream *UpstreamConfig, ttfb time.Duration, success bool) {
+	cm.balancer.record(channelKey(upstream), ttfb, success)
+	cm.recordCircuitResult(upstream, success)
+}
+
+// GetChannelMetrics 获取渠道运行指标
+func (cm *ConfigManager) GetChannelMetrics(upstream *UpstreamConfig) (ChannelMetrics, bool) {
+	return cm.balancer.snapshot(channelKey(upstream))
+}
+
+// isValidChannelStrategy 校验渠道负载均衡策略
+func isValidChannelStrategy(strategy string) bool {
+	return strategy == ChannelStrategyFailover || strategy == ChannelStrategyWeighted || strategy == ChannelStrategyLatency
+}
+
+// UpdateLoadBalance 更新负载均衡配置（密钥策略、渠道策略、渠道权重）
+func (cm *ConfigManager) UpdateLoadBalance(update LoadBalanceUpdate) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	// 先完整校验，避免部分生效
+	if update.Strategy != nil && *update.Strategy != "round-robin" && *update.Strategy != "random" && *update.Strategy != "failover" {
+		return fmt.Errorf("无效的负载均衡策略: %s", *update.Strategy)
+	}
+	if update.ChannelStrategy != nil && !isValidChannelStrategy(*update.ChannelStrategy) {
+		return fmt.Errorf("无效的负载均衡策略: %s", *update.ChannelStrategy)
+	}
+	if err := validateWeights(update.Weights, len(cm.config.Upstream)); err != nil {
+		return err
+	}
+	if err := validateWeights(update.ResponsesWeights, len(cm.config.ResponsesUpstream)); err != nil {
+		return err
+	}
+
+	if update.Strategy != nil {
+		cm.config.LoadBalance = *update.Strategy
+	}
+	if update.ChannelStrategy != nil {
+		cm.config.ChannelLoadBalance = *update.ChannelStrategy
+	}
+	for index, weight := range update.Weights {
+		cm.config.Upstream[index].Weight = weight
+	}
+	for index, weight := range update.ResponsesWeights {
+		cm.config.ResponsesUpstream[index].Weight = weight
+	}
+
+	if err := cm.saveConfigLocked(cm.config); err != nil {
+		return err
+	}
+
+	log.Printf("已更新负载均衡配置: 密钥策略=%s, 渠道策略=%s", cm.config.LoadBalance, cm.getChannelLoadBalanceLocked())
+	return nil
+}
+
+// validateWeights 校验渠道权重
+func validateWeights(weights map[int]int, count int) error {
+	for index, weight := range weights {
+		if index < 0 || index >= count {
+			return fmt.Errorf("无效的上游索引: %d", index)
+		}
+		if err := validateWeight(weight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateWeight 校验显式设置的渠道权重
+// 权重必须至少为 1：配置中 0 与未设置无法区分（均按默认权重 1 处理），不能用于停用渠道
+func validateWeight(weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("无效的渠道权重: %d（权重必须至少为 1）", weight)
+	}
+	return nil
+}
+
+// getChannelLoadBalanceLocked 获取渠道负载均衡策略（已加锁），未配置时为 failover
+func (cm *ConfigManager) getChannelLoadBalanceLocked() string {
+	if cm.config.ChannelLoadBalance == "" {
+		return ChannelStrategyFailover
+	}
+	return cm.config.ChannelLoadBalance
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEffectiveWeight_Latency(t *testing.T) {
+	b := newChannelBalancer()
+	fast := &UpstreamConfig{Name: "fast", BaseURL: "https://fast.example.com"}
+	slow := &UpstreamConfig{Name: "slow", BaseURL: "https://slow.example.com"}
+	flaky := &UpstreamConfig{Name: "flaky", BaseURL: "https://flaky.example.com"}
+
+	for i := 0; i < 10; i++ {
+		b.record(channelKey(fast), 200*time.Millisecond, true)
+		b.record(channelKey(slow), 2*time.Second, true)
+		b.record(channelKey(flaky), 200*time.Millisecond, i%2 == 0)
+	}
+
+	wFast := b.effectiveWeight(fast, ChannelStrategyLatency)
+	wSlow := b.effectiveWeight(slow, ChannelStrategyLatency)
+	wFlaky := b.effectiveWeight(flaky, ChannelStrategyLatency)
+
+	if wFast <= wSlow {
+		t.Errorf("期望低延迟渠道权重更高: fast=%.3f, slow=%.3f", wFast, wSlow)
+	}
+	if wFast <= wFlaky {
+		t.Errorf("期望高成功率渠道权重更高: fast=%.3f, flaky=%.3f", wFast, wFlaky)
+	}
+
+	// weighted 策略只看配置权重
+	weighted := &UpstreamConfig{Name: "weighted", Weight: 3}
+	if got := b.effectiveWeight(weighted, ChannelStrategyWeighted); got != 3 {
+		t.Errorf("weighted 权重 = %v, want 3", got)
+	}
+	if got := b.effectiveWeight(slow, ChannelStrategyWeighted); got != 1 {
+		t.Errorf("未设置权重时应为 1, got %v", got)
+	}
+}
+
+func TestBalanceChain_KeepsRoutedChannelsFirst(t *testing.T) {
+	b := newChannelBalancer()
+	upstreams := []UpstreamConfig{
+		{Name: "generic-a", Weight: 100},
+		{Name: "haiku-a", Models: []string{"*haiku*"}},
+		{Name: "generic-b", Weight: 100},
+		{Name: "haiku-b", Models: []string{"*haiku*"}},
+	}
+
+	for i := 0; i < 20; i++ {
+		chain := buildFailoverChain(upstreams, 0, "claude-3-5-haiku")
+		b.balanceChain(chain, "claude-3-5-haiku", ChannelStrategyWeighted, "")
+
+		if len(chain) != 4 {
+			t.Fatalf("链长度 = %d, want 4", len(chain))
+		}
+		for pos, candidate := range chain[:2] {
+			if len(candidate.Upstream.Models) == 0 {
+				t.Fatalf("第 %d 位应为模型路由命中的渠道, got %s", pos, candidate.Upstream.Name)
+			}
+		}
+	}
+}
+
+func TestBalanceChain_WeightedDistribution(t *testing.T) {
+	b := newChannelBalancer()
+	upstreams := []UpstreamConfig{
+		{Name: "heavy", Weight: 9},
+		{Name: "light", Weight: 1},
+	}
+
+	first := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		chain := buildFailoverChain(upstreams, 0, "")
+		b.balanceChain(chain, "", ChannelStrategyWeighted, "")
+		first[chain[0].Upstream.Name]++
+	}
+
+	// 期望约 90% 的请求首选 heavy，留出足够的随机误差
+	if first["heavy"] < 1600 || first["heavy"] > 1950 {
+		t.Errorf("heavy 首选次数 = %d, 期望约 1800", first["heavy"])
+	}
+}
+
+func TestWeightValidation_RejectsZero(t *testing.T) {
+	cm := newTestConfigManager(UpstreamConfig{Name: "a", Weight: 2}, UpstreamConfig{Name: "b"})
+
+	// 0 与未设置无法区分（都会按权重 1 分配流量），显式设置时拒绝
+	if err := cm.UpdateLoadBalance(LoadBalanceUpdate{Weights: map[int]int{1: 0}}); err == nil || !strings.Contains(err.Error(), "无效的渠道权重") {
+		t.Errorf("weights 中的 0 应被拒绝, err = %v", err)
+	}
+	zero := 0
+	if err := cm.UpdateUpstream(0, UpstreamUpdate{Weight: &zero}); err == nil || !strings.Contains(err.Error(), "无效的渠道权重") {
+		t.Errorf("更新渠道权重为 0 应被拒绝, err = %v", err)
+	}
+	if cm.config.Upstream[0].Weight != 2 {
+		t.Errorf("校验失败时不应修改权重: %d", cm.config.Upstream[0].Weight)
+	}
+	if err := cm.AddUpstream(UpstreamConfig{Name: "c", Weight: -1}); err == nil {
+		t.Error("负数权重应被拒绝")
+	}
+	if err := cm.AddResponsesUpstream(UpstreamConfig{Name: "c", Weight: -1}); err == nil {
+		t.Error("Responses 渠道的负数权重应被拒绝")
+	}
+
+	// 添加渠道时 0 即未设置，按默认权重处理
+	cm.configFile = filepath.Join(t.TempDir(), "config.json")
+	if err := cm.AddUpstream(UpstreamConfig{Name: "c"}); err != nil {
+		t.Errorf("未设置权重的渠道应可添加, err = %v", err)
+	}
+	if err := cm.AddResponsesUpstream(UpstreamConfig{Name: "c"}); err != nil {
+		t.Errorf("未设置权重的 Responses 渠道应可添加, err = %v", err)
+	}
+}
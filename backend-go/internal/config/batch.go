@@ -0,0 +1,29 @@
+package config
+
+// ============== 批处理 ==============
+
+const defaultBatchConcurrency = 4
+
+// BatchConfig 批处理配置（config.json 中的 batch 字段）
+type BatchConfig struct {
+	Concurrency int `json:"concurrency,omitempty"` // 同时执行的批处理请求数，默认 4
+}
+
+// withDefaults 补全批处理配置默认值
+func (b BatchConfig) withDefaults() BatchConfig {
+	if b.Concurrency <= 0 {
+		b.Concurrency = defaultBatchConcurrency
+	}
+	return b
+}
+
+// GetBatchConfig 获取批处理配置（含默认值）
+func (cm *ConfigManager) GetBatchConfig() BatchConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.config.Batch == nil {
+		return BatchConfig{}.withDefaults()
+	}
+	return cm.config.Batch.withDefaults()
+}
@@ -0,0 +1,343 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ============== 渠道熔断器 ==============
+
+// 熔断器状态
+const (
+	CircuitClosed   = "closed"    // 正常放行
+	CircuitOpen     = "open"      // 熔断中，拒绝流量
+	CircuitHalfOpen = "half-open" // 半开，仅放行少量探测请求
+)
+
+const (
+	defaultFailureThreshold = 5                // 连续失败多少次后熔断
+	defaultOpenTimeout      = 30 * time.Second // 熔断后多久进入半开状态
+	maxOpenTimeout          = 5 * time.Minute  // 连续探测失败时熔断时长的上限
+	defaultHalfOpenProbes   = 1                // 半开状态下同时放行的探测请求数
+	probeTimeout            = 60 * time.Second // 探测请求未上报结果时，多久后释放探测名额
+)
+
+// CircuitBreakerConfig 熔断器配置（config.json 中的 circuitBreaker 字段）
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failureThreshold,omitempty"` // 连续失败阈值，默认 5
+	OpenSeconds      int `json:"openSeconds,omitempty"`      // 熔断时长（秒），默认 30，探测失败时翻倍
+	HalfOpenProbes   int `json:"halfOpenProbes,omitempty"`   // 半开状态并发探测数，默认 1
+}
+
+// CircuitState 熔断器状态快照
+type CircuitState struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	NextProbeAt         *time.Time `json:"nextProbeAt,omitempty"`
+}
+
+// circuitBreaker 单个渠道的熔断器
+type circuitBreaker struct {
+	state               string
+	consecutiveFailures int
+	openedAt            time.Time
+	openTimeout         time.Duration
+	probes              []circuitProbe // 半开状态下正在进行的探测请求
+}
+
+// circuitProbe 半开状态下占用的探测名额
+type circuitProbe struct {
+	id      uint64
+	started time.Time
+}
+
+// circuitBreakers 渠道熔断器集合
+type circuitBreakers struct {
+	mu          sync.Mutex
+	breakers    map[string]*circuitBreaker
+	nextProbeID uint64
+}
+
+func newCircuitBreakers() *circuitBreakers {
+	return &circuitBreakers{
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// withDefaults 补全熔断器配置默认值
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultFailureThreshold
+	}
+	if c.OpenSeconds <= 0 {
+		c.OpenSeconds = int(defaultOpenTimeout / time.Second)
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = defaultHalfOpenProbes
+	}
+	return c
+}
+
+// get 获取渠道熔断器（不存在则创建，需持有锁）
+func (cbs *circuitBreakers) get(key string) *circuitBreaker {
+	cb, exists := cbs.breakers[key]
+	if !exists {
+		cb = &circuitBreaker{state: CircuitClosed}
+		cbs.breakers[key] = cb
+	}
+	return cb
+}
+
+// advance 根据时间推进熔断器状态（open 超时后进入 half-open，需持有锁）
+func (cb *circuitBreaker) advance(now time.Time) {
+	if cb.state == CircuitOpen && now.Sub(cb.openedAt) >= cb.openTimeout {
+		cb.state = CircuitHalfOpen
+		cb.probes = nil
+	}
+
+	if cb.state == CircuitHalfOpen {
+		// 释放超时未上报结果的探测名额
+		active := cb.probes[:0]
+		for _, probe := range cb.probes {
+			if now.Sub(probe.started) < probeTimeout {
+				active = append(active, probe)
+			}
+		}
+		cb.probes = active
+	}
+}
+
+// available 判断渠道当前是否可以接收请求（不占用探测名额）
+func (cbs *circuitBreakers) available(key string, cfg CircuitBreakerConfig) bool {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb, exists := cbs.breakers[key]
+	if !exists {
+		return true
+	}
+	cb.advance(time.Now())
+
+	switch cb.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		return len(cb.probes) < cfg.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+// acquire 判断是否放行请求；半开状态下放行时占用一个探测名额并返回其 ID（关闭状态返回 0）
+func (cbs *circuitBreakers) acquire(key string, cfg CircuitBreakerConfig) (uint64, bool) {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb := cbs.get(key)
+	now := time.Now()
+	cb.advance(now)
+
+	switch cb.state {
+	case CircuitOpen:
+		return 0, false
+	case CircuitHalfOpen:
+		if len(cb.probes) >= cfg.HalfOpenProbes {
+			return 0, false
+		}
+		cbs.nextProbeID++
+		cb.probes = append(cb.probes, circuitProbe{id: cbs.nextProbeID, started: now})
+		return cbs.nextProbeID, true
+	default:
+		return 0, true
+	}
+}
+
+// release 释放未上报结果的探测名额（名额已随结果上报释放时为空操作）
+func (cbs *circuitBreakers) release(key string, id uint64) {
+	if id == 0 {
+		return
+	}
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb, exists := cbs.breakers[key]
+	if !exists {
+		return
+	}
+	for i, probe := range cb.probes {
+		if probe.id == id {
+			cb.probes = append(cb.probes[:i], cb.probes[i+1:]...)
+			return
+		}
+	}
+}
+
+// record 记录请求结果，返回状态是否发生变化
+func (cbs *circuitBreakers) record(key string, success bool, cfg CircuitBreakerConfig) (string, bool) {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb := cbs.get(key)
+	now := time.Now()
+	cb.advance(now)
+	previous := cb.state
+
+	if success {
+		cb.consecutiveFailures = 0
+		cb.state = CircuitClosed
+		cb.openTimeout = 0
+		cb.probes = nil
+		return cb.state, previous != cb.state
+	}
+
+	cb.consecutiveFailures++
+
+	switch cb.state {
+	case CircuitHalfOpen:
+		// 探测失败，重新熔断并延长熔断时长
+		cb.openTimeout *= 2
+		if cb.openTimeout > maxOpenTimeout {
+			cb.openTimeout = maxOpenTimeout
+		}
+		cb.state = CircuitOpen
+		cb.openedAt = now
+		cb.probes = nil
+	case CircuitClosed:
+		if cb.consecutiveFailures >= cfg.FailureThreshold {
+			cb.state = CircuitOpen
+			cb.openedAt = now
+			cb.openTimeout = time.Duration(cfg.OpenSeconds) * time.Second
+		}
+	}
+
+	return cb.state, previous != cb.state
+}
+
+// snapshot 获取熔断器状态快照
+func (cbs *circuitBreakers) snapshot(key string) CircuitState {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	cb, exists := cbs.breakers[key]
+	if !exists {
+		return CircuitState{State: CircuitClosed}
+	}
+	cb.advance(time.Now())
+
+	state := CircuitState{
+		State:               cb.state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+	}
+	if cb.state != CircuitClosed {
+		openedAt := cb.openedAt
+		nextProbeAt := cb.openedAt.Add(cb.openTimeout)
+		state.OpenedAt = &openedAt
+		state.NextProbeAt = &nextProbeAt
+	}
+	return state
+}
+
+// reset 重置熔断器
+func (cbs *circuitBreakers) reset(key string) {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+	delete(cbs.breakers, key)
+}
+
+// getCircuitBreakerConfig 获取熔断器配置（含默认值）
+func (cm *ConfigManager) getCircuitBreakerConfig() CircuitBreakerConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.config.CircuitBreaker == nil {
+		return CircuitBreakerConfig{}.withDefaults()
+	}
+	return cm.config.CircuitBreaker.withDefaults()
+}
+
+// FilterAvailableChannels 按熔断器状态过滤故障转移链（只检查状态，不占用探测名额）
+// 熔断中的渠道和探测名额已满的半开渠道被跳过；
+// 若所有渠道都不可用，则原样返回整条链并标记 BypassCircuit，避免直接拒绝请求
+func (cm *ConfigManager) FilterAvailableChannels(chain []UpstreamCandidate) []UpstreamCandidate {
+	cfg := cm.getCircuitBreakerConfig()
+
+	available := make([]UpstreamCandidate, 0, len(chain))
+	for _, candidate := range chain {
+		if cm.breakers.available(channelKey(candidate.Upstream), cfg) {
+			available = append(available, candidate)
+		}
+	}
+
+	if len(available) == 0 && len(chain) > 0 {
+		log.Printf("⚠️ 所有渠道均处于熔断状态，仍按原顺序尝试")
+		bypass := make([]UpstreamCandidate, len(chain))
+		for i, candidate := range chain {
+			candidate.BypassCircuit = true
+			bypass[i] = candidate
+		}
+		return bypass
+	}
+	return available
+}
+
+// ChannelProbe 向渠道发送请求时持有的熔断器探测名额
+type ChannelProbe struct {
+	cm  *ConfigManager
+	key string
+	id  uint64
+}
+
+// AcquireChannel 即将向渠道发送请求时调用：半开渠道占用一个探测名额，
+// 名额已被其他请求占用或渠道已熔断时返回 false（BypassCircuit 的候选始终放行）。
+// 离开该渠道时必须调用 Release；已通过 RecordChannelResult / RecordChannelReachable 上报结果时 Release 为空操作
+func (cm *ConfigManager) AcquireChannel(candidate UpstreamCandidate) (*ChannelProbe, bool) {
+	key := channelKey(candidate.Upstream)
+	id, ok := cm.breakers.acquire(key, cm.getCircuitBreakerConfig())
+	if !ok && !candidate.BypassCircuit {
+		return nil, false
+	}
+	return &ChannelProbe{cm: cm, key: key, id: id}, true
+}
+
+// Release 释放未上报结果的探测名额，可重复调用
+func (p *ChannelProbe) Release() {
+	if p == nil || p.id == 0 {
+		return
+	}
+	p.cm.breakers.release(p.key, p.id)
+	p.id = 0
+}
+
+// GetCircuitState 获取渠道熔断器状态
+func (cm *ConfigManager) GetCircuitState(upstream *UpstreamConfig) CircuitState {
+	return cm.breakers.snapshot(channelKey(upstream))
+}
+
+// ResetCircuitBreaker 手动重置渠道熔断器
+func (cm *ConfigManager) ResetCircuitBreaker(upstream *UpstreamConfig) {
+	cm.breakers.reset(channelKey(upstream))
+	log.Printf("🔌 渠道 %s 的熔断器已重置", upstream.Name)
+}
+
+// RecordChannelReachable 渠道返回了非渠道级错误（4xx、密钥级故障等）时调用：渠道本身可达，按成功计入熔断器，
+// 半开状态的探测据此关闭熔断器（不计入负载均衡的延迟和成功率统计）
+func (cm *ConfigManager) RecordChannelReachable(upstream *UpstreamConfig) {
+	cm.recordCircuitResult(upstream, true)
+}
+
+// recordCircuitResult 记录渠道请求结果到熔断器
+func (cm *ConfigManager) recordCircuitResult(upstream *UpstreamConfig, success bool) {
+	state, changed := cm.breakers.record(channelKey(upstream), success, cm.getCircuitBreakerConfig())
+	if !changed {
+		return
+	}
+
+	switch state {
+	case CircuitOpen:
+		log.Printf("🔴 渠道 %s 熔断器打开，暂停向该渠道转发请求", upstream.Name)
+	case CircuitClosed:
+		log.Printf("🟢 渠道 %s 熔断器关闭，恢复正常转发", upstream.Name)
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	cbs := newCircuitBreakers()
+	cfg := CircuitBreakerConfig{FailureThreshold: 3, OpenSeconds: 30, HalfOpenProbes: 1}.withDefaults()
+	key := "claude|https://relay.example.com|relay"
+	allow := func() bool {
+		_, ok := cbs.acquire(key, cfg)
+		return ok
+	}
+
+	// 未达到阈值前保持关闭
+	for i := 0; i < 2; i++ {
+		cbs.record(key, false, cfg)
+	}
+	if !allow() {
+		t.Fatal("未达到失败阈值时应放行")
+	}
+
+	// 成功会清零连续失败计数
+	cbs.record(key, true, cfg)
+	for i := 0; i < 2; i++ {
+		cbs.record(key, false, cfg)
+	}
+	if got := cbs.snapshot(key).State; got != CircuitClosed {
+		t.Fatalf("state = %s, want %s", got, CircuitClosed)
+	}
+
+	// 连续失败达到阈值后熔断
+	state, changed := cbs.record(key, false, cfg)
+	if state != CircuitOpen || !changed {
+		t.Fatalf("state = %s (changed=%v), want %s", state, changed, CircuitOpen)
+	}
+	if allow() {
+		t.Fatal("熔断状态下不应放行")
+	}
+
+	// 模拟熔断超时，进入半开状态后仅放行一个探测请求
+	cbs.mu.Lock()
+	cbs.breakers[key].openedAt = time.Now().Add(-31 * time.Second)
+	cbs.mu.Unlock()
+
+	if !allow() {
+		t.Fatal("半开状态应放行探测请求")
+	}
+	if allow() {
+		t.Fatal("半开状态探测名额已用尽，不应继续放行")
+	}
+
+	// 探测失败：重新熔断且熔断时长翻倍
+	cbs.record(key, false, cfg)
+	snapshot := cbs.snapshot(key)
+	if snapshot.State != CircuitOpen {
+		t.Fatalf("state = %s, want %s", snapshot.State, CircuitOpen)
+	}
+	if got := snapshot.NextProbeAt.Sub(*snapshot.OpenedAt); got != 60*time.Second {
+		t.Errorf("熔断时长 = %v, want 60s", got)
+	}
+
+	// 再次进入半开，探测成功后关闭
+	cbs.mu.Lock()
+	cbs.breakers[key].openedAt = time.Now().Add(-61 * time.Second)
+	cbs.mu.Unlock()
+
+	if !allow() {
+		t.Fatal("半开状态应放行探测请求")
+	}
+	state, changed = cbs.record(key, true, cfg)
+	if state != CircuitClosed || !changed {
+		t.Fatalf("state = %s (changed=%v), want %s", state, changed, CircuitClosed)
+	}
+	if !allow() {
+		t.Fatal("关闭状态应放行")
+	}
+}
+
+func TestFilterAvailableChannels_DoesNotLeakProbes(t *testing.T) {
+	cm := newTestConfigManager()
+	cm.config.CircuitBreaker = &CircuitBreakerConfig{FailureThreshold: 1, HalfOpenProbes: 1}
+	upstream := &UpstreamConfig{Name: "relay", BaseURL: "https://relay.example.com", ServiceType: "claude"}
+	chain := []UpstreamCandidate{{Index: 0, Upstream: upstream}}
+
+	// 熔断后进入半开状态
+	cm.recordCircuitResult(upstream, false)
+	key := channelKey(upstream)
+	cm.breakers.mu.Lock()
+	cm.breakers.breakers[key].openedAt = time.Now().Add(-time.Minute)
+	cm.breakers.mu.Unlock()
+
+	// 过滤后未发送请求也未上报结果：不应占用探测名额
+	for i := 0; i < 3; i++ {
+		filtered := cm.FilterAvailableChannels(chain)
+		if len(filtered) != 1 || filtered[0].BypassCircuit {
+			t.Fatalf("半开渠道应保留在链中: %+v", filtered)
+		}
+	}
+
+	// 获取探测名额后，其他请求不能再探测，过滤时会回退到整条链
+	probe, ok := cm.AcquireChannel(chain[0])
+	if !ok {
+		t.Fatal("半开渠道应放行探测请求")
+	}
+	if _, ok := cm.AcquireChannel(chain[0]); ok {
+		t.Fatal("探测名额已被占用，不应继续放行")
+	}
+	if filtered := cm.FilterAvailableChannels(chain); !filtered[0].BypassCircuit {
+		t.Errorf("探测名额已满时应回退到整条链: %+v", filtered)
+	}
+
+	// 未上报结果就离开渠道（如客户端断开）：释放名额后可再次探测
+	probe.Release()
+	probe.Release()
+	probe, ok = cm.AcquireChannel(chain[0])
+	if !ok {
+		t.Fatal("释放后应可再次获取探测名额")
+	}
+
+	// 上报结果后 Release 为空操作
+	cm.RecordChannelResult(upstream, 0, true)
+	probe.Release()
+	if state := cm.GetCircuitState(upstream).State; state != CircuitClosed {
+		t.Errorf("state = %s, want %s", state, CircuitClosed)
+	}
+}
@@ -24,6 +24,12 @@ type UpstreamConfig struct {
 	Website            string            `json:"website,omitempty"`
 	InsecureSkipVerify bool              `json:"insecureSkipVerify,omitempty"`
 	ModelMapping       map[string]string `json:"modelMapping,omitempty"`
+	Models             []string          `json:"models,omitempty"` // 渠道服务的模型（精确名称、通配符 * ? 或 re: 前缀的正则）
+	Weight             int               `json:"weight,omitempty"` // 渠道负载均衡权重，未设置时为 1
+	FailoverRules      []FailoverRule    `json:"failoverRules,omitempty"` // 自定义故障转移规则，优先于内置规则匹配
+	KeyBudgets         map[string]KeyBudget `json:"keyBudgets,omitempty"` // 密钥用量预算，键为 API 密钥，"*" 为渠道内所有密钥的默认预算
+	DiscoverModels     bool              `json:"discoverModels,omitempty"` // 在 /v1/models 中合并上游自身的模型列表
+	ReasoningEffort    bool              `json:"reasoningEffort,omitempty"` // 对所有模型发送 reasoning_effort（默认仅 o 系列和 gpt-5 系列）
 }
 
 // UpstreamUpdate 用于部分更新 UpstreamConfig
@@ -36,6 +42,12 @@ type UpstreamUpdate struct {
 	Website            *string           `json:"website"`
 	InsecureSkipVerify *bool             `json:"insecureSkipVerify"`
 	ModelMapping       map[string]string `json:"modelMapping"`
+	Models             []string          `json:"models"`
+	Weight             *int              `json:"weight"`
+	FailoverRules      []FailoverRule    `json:"failoverRules"`
+	KeyBudgets         map[string]KeyBudget `json:"keyBudgets"`
+	DiscoverModels     *bool             `json:"discoverModels"`
+	ReasoningEffort    *bool             `json:"reasoningEffort"`
 }
 
 // Config 配置结构
@@ -43,6 +55,16 @@ type Config struct {
 	Upstream        []UpstreamConfig `json:"upstream"`
 	CurrentUpstream int              `json:"currentUpstream"`
 	LoadBalance     string           `json:"loadBalance"` // round-robin, random, failover
+	// 渠道间负载均衡策略：failover（默认）、weighted、latency
+	ChannelLoadBalance string `json:"channelLoadBalance,omitempty"`
+	// 渠道熔断器配置，未设置时使用默认值
+	CircuitBreaker *CircuitBreakerConfig `json:"circuitBreaker,omitempty"`
+	// 会话粘性路由配置，未设置时默认启用
+	StickySession *StickySessionConfig `json:"stickySession,omitempty"`
+	// 连续认证失败多少次后自动隔离密钥，默认 3
+	QuarantineAfterAuthFailures int `json:"quarantineAfterAuthFailures,omitempty"`
+	// 批处理配置，未设置时使用默认值
+	Batch *BatchConfig `json:"batch,omitempty"`
 
 	// Responses 接口专用配置（独立于 /v1/messages）
 	ResponsesUpstream        []UpstreamConfig `json:"responsesUpstream"`
@@ -51,8 +73,9 @@ type Config struct {
 
 // FailedKey 失败密钥记录
 type FailedKey struct {
-	Timestamp    time.Time
-	FailureCount int
+	Timestamp     time.Time
+	FailureCount  int
+	CooldownUntil time.Time // 上游通过 Retry-After 等指定的冷却截止时间，为零值时使用默认恢复时间
 }
 
 // ConfigManager 配置管理器
@@ -63,8 +86,18 @@ type ConfigManager struct {
 	requestCount      int
 	watcher           *fsnotify.Watcher
 	failedKeysCache   map[string]*FailedKey
+	keyStates         map[string]*keyStateRecord // 隔离/禁用的密钥，按密钥指纹索引
+	authFailures      map[string]int             // 密钥连续认证失败次数
 	keyRecoveryTime   time.Duration
 	maxFailureCount   int
+	balancer          *channelBalancer
+	breakers          *circuitBreakers
+	rateLimits        *keyRateLimits
+	stickyPins        *stickySessions
+	usage             *keyUsages
+
+	reloadMu    sync.Mutex
+	reloadHooks []func()
 }
 
 const (
@@ -78,8 +111,15 @@ func NewConfigManager(configFile string) (*ConfigManager, error) {
 	cm := &ConfigManager{
 		configFile:       configFile,
 		failedKeysCache:  make(map[string]*FailedKey),
+		keyStates:        make(map[string]*keyStateRecord),
+		authFailures:     make(map[string]int),
 		keyRecoveryTime:  keyRecoveryTime,
 		maxFailureCount:  maxFailureCount,
+		balancer:         newChannelBalancer(),
+		breakers:         newCircuitBreakers(),
+		rateLimits:       newKeyRateLimits(),
+		stickyPins:       newStickySessions(),
+		usage:            newKeyUsages(),
 	}
 
 	// 加载配置
@@ -87,6 +127,16 @@ func NewConfigManager(configFile string) (*ConfigManager, error) {
 		return nil, err
 	}
 
+	// 加载持久化的密钥状态（隔离/禁用）
+	if err := cm.loadKeyStates(); err != nil {
+		log.Printf("加载密钥状态失败: %v", err)
+	}
+
+	// 加载持久化的密钥用量计数
+	if err := cm.loadKeyUsage(); err != nil {
+		log.Printf("加载密钥用量失败: %v", err)
+	}
+
 	// 启动文件监听
 	if err := cm.startWatcher(); err != nil {
 		log.Printf("启动配置文件监听失败: %v", err)
@@ -94,6 +144,7 @@ func NewConfigManager(configFile string) (*ConfigManager, error) {
 
 	// 启动定期清理
 	go cm.cleanupExpiredFailures()
+	go cm.persistKeyUsage()
 
 	return cm, nil
 }
@@ -232,6 +283,7 @@ func (cm *ConfigManager) startWatcher() error {
 						log.Printf("配置重载失败: %v", err)
 					} else {
 						log.Printf("配置已重载")
+						cm.notifyReload()
 					}
 				}
 			case err, ok := <-watcher.Errors:
@@ -246,6 +298,24 @@ func (cm *ConfigManager) startWatcher() error {
 	return watcher.Add(cm.configFile)
 }
 
+// OnReload 注册配置文件重载后的回调（如批处理并发上限变化时唤醒调度循环）
+func (cm *ConfigManager) OnReload(fn func()) {
+	cm.reloadMu.Lock()
+	defer cm.reloadMu.Unlock()
+	cm.reloadHooks = append(cm.reloadHooks, fn)
+}
+
+// notifyReload 依次调用已注册的重载回调
+func (cm *ConfigManager) notifyReload() {
+	cm.reloadMu.Lock()
+	hooks := append([]func(){}, cm.reloadHooks...)
+	cm.reloadMu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
 // GetConfig 获取配置
 func (cm *ConfigManager) GetConfig() Config {
 	cm.mu.RLock()
@@ -270,15 +340,57 @@ func (cm *ConfigManager) GetCurrentUpstream() (*UpstreamConfig, error) {
 	return &upstream, nil
 }
 
+// UpstreamCandidate 故障转移链中的候选渠道
+type UpstreamCandidate struct {
+	Index         int             // 渠道在配置中的索引
+	Upstream      *UpstreamConfig // 渠道配置副本
+	BypassCircuit bool            // 所有渠道均处于熔断状态时仍尝试该渠道
+}
+
+// GetUpstreamFailoverChain 获取 Messages 渠道故障转移链
+// 声明了 models 且匹配请求模型的渠道优先；若无渠道匹配，则回退到当前渠道
+// 未声明 models 的渠道视为通用渠道，排在匹配渠道之后作为兜底
+// 启用渠道负载均衡（weighted/latency）时，会话绑定的渠道排在最前，其余渠道在同一优先级内按权重重新排序
+func (cm *ConfigManager) GetUpstreamFailoverChain(model, sessionID string) ([]UpstreamCandidate, error) {
+	pinned := cm.sessionChannel(model, sessionID)
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if len(cm.config.Upstream) == 0 {
+		return nil, fmt.Errorf("未配置任何上游渠道")
+	}
+
+	if cm.config.CurrentUpstream >= len(cm.config.Upstream) {
+		return nil, fmt.Errorf("当前渠道索引 %d 无效", cm.config.CurrentUpstream)
+	}
+
+	chain := buildFailoverChain(cm.config.Upstream, cm.config.CurrentUpstream, model)
+	cm.balancer.balanceChain(chain, model, cm.getChannelLoadBalanceLocked(), pinned)
+	return chain, nil
+}
+
 // GetNextAPIKey 获取下一个 API 密钥
 func (cm *ConfigManager) GetNextAPIKey(upstream *UpstreamConfig, failedKeys map[string]bool) (string, error) {
 	if len(upstream.APIKeys) == 0 {
 		return "", fmt.Errorf("上游 %s 没有可用的API密钥", upstream.Name)
 	}
 
+	// 排除被隔离或禁用的密钥
+	keys := cm.filterUsableKeys(upstream.APIKeys)
+	if len(keys) == 0 {
+		return "", fmt.Errorf("上游 %s 的所有API密钥都已被隔离或禁用", upstream.Name)
+	}
+
+	// 排除已达到用量预算的密钥（窗口重置后自动恢复）
+	keys = cm.filterKeysWithinBudget(upstream, keys)
+	if len(keys) == 0 {
+		return "", fmt.Errorf("上游 %s 的所有API密钥都已达到用量预算", upstream.Name)
+	}
+
 	// 综合考虑临时失败密钥和内存中的失败密钥
 	availableKeys := []string{}
-	for _, key := range upstream.APIKeys {
+	for _, key := range keys {
 		if !failedKeys[key] && !cm.isKeyFailed(key) {
 			availableKeys = append(availableKeys, key)
 		}
@@ -287,23 +399,24 @@ func (cm *ConfigManager) GetNextAPIKey(upstream *UpstreamConfig, failedKeys map[
 	if len(availableKeys) == 0 {
 		// 如果所有密钥都失效,检查是否有可以恢复的密钥
 		allFailedKeys := []string{}
-		for _, key := range upstream.APIKeys {
+		for _, key := range keys {
 			if failedKeys[key] || cm.isKeyFailed(key) {
 				allFailedKeys = append(allFailedKeys, key)
 			}
 		}
 
-		if len(allFailedKeys) == len(upstream.APIKeys) {
-			// 如果所有密钥都在内存失败缓存中,尝试选择失败时间最早的密钥
+		if len(allFailedKeys) == len(keys) {
+			// 如果所有密钥都在内存失败缓存中,尝试选择最早恢复的密钥
 			var oldestFailedKey string
-			oldestTime := time.Now()
+			var earliestRecovery time.Time
 
 			cm.mu.RLock()
-			for _, key := range upstream.APIKeys {
+			for _, key := range keys {
 				if !failedKeys[key] { // 排除本次请求已经尝试过的密钥
 					if failure, exists := cm.failedKeysCache[key]; exists {
-						if failure.Timestamp.Before(oldestTime) {
-							oldestTime = failure.Timestamp
+						recoveryAt := cm.keyRecoveryAt(failure)
+						if oldestFailedKey == "" || recoveryAt.Before(earliestRecovery) {
+							earliestRecovery = recoveryAt
 							oldestFailedKey = key
 						}
 					}
@@ -320,6 +433,9 @@ func (cm *ConfigManager) GetNextAPIKey(upstream *UpstreamConfig, failedKeys map[
 		return "", fmt.Errorf("上游 %s 的所有API密钥都暂时不可用", upstream.Name)
 	}
 
+	// 剩余额度即将耗尽的密钥降低优先级
+	availableKeys = cm.preferKeysWithBudget(availableKeys)
+
 	// 根据负载均衡策略选择密钥
 	switch cm.config.LoadBalance {
 	case "round-robin":
@@ -356,27 +472,49 @@ func (cm *ConfigManager) GetNextAPIKey(upstream *UpstreamConfig, failedKeys map[
 
 // MarkKeyAsFailed 标记密钥失败
 func (cm *ConfigManager) MarkKeyAsFailed(apiKey string) {
+	cm.MarkKeyAsFailedWithCooldown(apiKey, 0)
+}
+
+// MarkKeyAsFailedWithCooldown 标记密钥失败，并按上游要求的时长冷却（cooldown 为 0 时使用默认恢复时间）
+func (cm *ConfigManager) MarkKeyAsFailedWithCooldown(apiKey string, cooldown time.Duration) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	now := time.Now()
 	if failure, exists := cm.failedKeysCache[apiKey]; exists {
 		failure.FailureCount++
-		failure.Timestamp = time.Now()
+		failure.Timestamp = now
 	} else {
 		cm.failedKeysCache[apiKey] = &FailedKey{
-			Timestamp:    time.Now(),
+			Timestamp:    now,
 			FailureCount: 1,
 		}
 	}
 
 	failure := cm.failedKeysCache[apiKey]
+	if cooldown > 0 {
+		failure.CooldownUntil = now.Add(cooldown)
+		log.Printf("标记API密钥失败: %s (失败次数: %d, 上游要求冷却: %v)",
+			maskAPIKey(apiKey), failure.FailureCount, cooldown)
+		return
+	}
+
+	failure.CooldownUntil = time.Time{}
+	log.Printf("标记API密钥失败: %s (失败次数: %d, 恢复时间: %v)",
+		maskAPIKey(apiKey), failure.FailureCount, cm.keyRecoveryAt(failure).Sub(now))
+}
+
+// keyRecoveryAt 计算失败密钥的恢复时间（需持有锁）
+func (cm *ConfigManager) keyRecoveryAt(failure *FailedKey) time.Time {
+	if !failure.CooldownUntil.IsZero() {
+		return failure.CooldownUntil
+	}
+
 	recoveryTime := cm.keyRecoveryTime
 	if failure.FailureCount > cm.maxFailureCount {
 		recoveryTime = cm.keyRecoveryTime * 2
 	}
-
-	log.Printf("标记API密钥失败: %s (失败次数: %d, 恢复时间: %v)",
-		maskAPIKey(apiKey), failure.FailureCount, recoveryTime)
+	return failure.Timestamp.Add(recoveryTime)
 }
 
 // isKeyFailed 检查密钥是否失败
@@ -389,12 +527,7 @@ func (cm *ConfigManager) isKeyFailed(apiKey string) bool {
 		return false
 	}
 
-	recoveryTime := cm.keyRecoveryTime
-	if failure.FailureCount > cm.maxFailureCount {
-		recoveryTime = cm.keyRecoveryTime * 2
-	}
-
-	return time.Since(failure.Timestamp) < recoveryTime
+	return time.Now().Before(cm.keyRecoveryAt(failure))
 }
 
 // cleanupExpiredFailures 清理过期的失败记录
@@ -406,12 +539,7 @@ func (cm *ConfigManager) cleanupExpiredFailures() {
 		cm.mu.Lock()
 		now := time.Now()
 		for key, failure := range cm.failedKeysCache {
-			recoveryTime := cm.keyRecoveryTime
-			if failure.FailureCount > cm.maxFailureCount {
-				recoveryTime = cm.keyRecoveryTime * 2
-			}
-
-			if now.Sub(failure.Timestamp) > recoveryTime {
+			if now.After(cm.keyRecoveryAt(failure)) {
 				delete(cm.failedKeysCache, key)
 				log.Printf("API密钥 %s 已从失败列表中恢复", maskAPIKey(key))
 			}
@@ -441,6 +569,22 @@ func (cm *ConfigManager) SetCurrentUpstream(index int) error {
 
 // AddUpstream 添加上游
 func (cm *ConfigManager) AddUpstream(upstream UpstreamConfig) error {
+	if err := validateModelPatterns(upstream.Models); err != nil {
+		return err
+	}
+	// 权重 0 表示未设置，按默认权重 1 处理；显式设置的权重需通过校验
+	if upstream.Weight != 0 {
+		if err := validateWeight(upstream.Weight); err != nil {
+			return err
+		}
+	}
+	if err := validateFailoverRules(upstream.FailoverRules); err != nil {
+		return err
+	}
+	if err := validateKeyBudgets(upstream.KeyBudgets); err != nil {
+		return err
+	}
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -462,6 +606,21 @@ func (cm *ConfigManager) AddUpstream(upstream UpstreamConfig) error {
 
 // UpdateUpstream 更新上游
 func (cm *ConfigManager) UpdateUpstream(index int, updates UpstreamUpdate) error {
+	if err := validateModelPatterns(updates.Models); err != nil {
+		return err
+	}
+	if updates.Weight != nil {
+		if err := validateWeight(*updates.Weight); err != nil {
+			return err
+		}
+	}
+	if err := validateFailoverRules(updates.FailoverRules); err != nil {
+		return err
+	}
+	if err := validateKeyBudgets(updates.KeyBudgets); err != nil {
+		return err
+	}
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -492,6 +651,24 @@ func (cm *ConfigManager) UpdateUpstream(index int, updates UpstreamUpdate) error
 	if updates.ModelMapping != nil {
 		upstream.ModelMapping = updates.ModelMapping
 	}
+	if updates.FailoverRules != nil {
+		upstream.FailoverRules = updates.FailoverRules
+	}
+	if updates.KeyBudgets != nil {
+		upstream.KeyBudgets = updates.KeyBudgets
+	}
+	if updates.Models != nil {
+		upstream.Models = updates.Models
+	}
+	if updates.Weight != nil {
+		upstream.Weight = *updates.Weight
+	}
+	if updates.DiscoverModels != nil {
+		upstream.DiscoverModels = *updates.DiscoverModels
+	}
+	if updates.ReasoningEffort != nil {
+		upstream.ReasoningEffort = *updates.ReasoningEffort
+	}
 	if updates.InsecureSkipVerify != nil {
 		upstream.InsecureSkipVerify = *updates.InsecureSkipVerify
 	}
@@ -597,33 +774,34 @@ func (cm *ConfigManager) RemoveAPIKey(index int, apiKey string) error {
 	return nil
 }
 
-// SetLoadBalance 设置负载均衡策略
-func (cm *ConfigManager) SetLoadBalance(strategy string) error {
+// DeprioritizeAPIKey 降低API密钥优先级
+// 跨渠道故障转移后失败密钥不一定属于当前渠道，因此在所有渠道中查找
+func (cm *ConfigManager) DeprioritizeAPIKey(apiKey string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	// 验证策略
-	if strategy != "round-robin" && strategy != "random" && strategy != "failover" {
-		return fmt.Errorf("无效的负载均衡策略: %s", strategy)
+	moved := false
+	for i := range cm.config.Upstream {
+		if moveKeyToEnd(&cm.config.Upstream[i], apiKey) {
+			moved = true
+		}
+	}
+	for i := range cm.config.ResponsesUpstream {
+		if moveKeyToEnd(&cm.config.ResponsesUpstream[i], apiKey) {
+			moved = true
+		}
 	}
 
-	cm.config.LoadBalance = strategy
-
-	if err := cm.saveConfigLocked(cm.config); err != nil {
-		return err
+	if !moved {
+		return nil
 	}
 
-	log.Printf("已设置负载均衡策略: %s", strategy)
-	return nil
+	log.Printf("已将API密钥移动到末尾以降低优先级: %s", maskAPIKey(apiKey))
+	return cm.saveConfigLocked(cm.config)
 }
 
-// DeprioritizeAPIKey 降低API密钥优先级
-func (cm *ConfigManager) DeprioritizeAPIKey(apiKey string) error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-
-	upstream := &cm.config.Upstream[cm.config.CurrentUpstream]
-
+// moveKeyToEnd 将密钥移动到渠道密钥列表末尾，返回是否发生了移动
+func moveKeyToEnd(upstream *UpstreamConfig, apiKey string) bool {
 	// 查找密钥索引
 	index := -1
 	for i, key := range upstream.APIKeys {
@@ -634,15 +812,15 @@ func (cm *ConfigManager) DeprioritizeAPIKey(apiKey string) error {
 	}
 
 	if index == -1 || index == len(upstream.APIKeys)-1 {
-		return nil
+		return false
 	}
 
-	// 移动到末尾
-	upstream.APIKeys = append(upstream.APIKeys[:index], upstream.APIKeys[index+1:]...)
-	upstream.APIKeys = append(upstream.APIKeys, apiKey)
-
-	log.Printf("已将API密钥移动到末尾以降低优先级: %s", maskAPIKey(apiKey))
-	return cm.saveConfigLocked(cm.config)
+	// 移动到末尾（重新分配切片，避免影响已分发出去的渠道副本）
+	keys := make([]string, 0, len(upstream.APIKeys))
+	keys = append(keys, upstream.APIKeys[:index]...)
+	keys = append(keys, upstream.APIKeys[index+1:]...)
+	upstream.APIKeys = append(keys, apiKey)
+	return true
 }
 
 // RedirectModel 模型重定向
@@ -687,6 +865,9 @@ func maskAPIKey(key string) string {
 
 // Close 关闭配置管理器
 func (cm *ConfigManager) Close() error {
+	if err := cm.flushKeyUsage(); err != nil {
+		log.Printf("⚠️ 保存密钥用量失败: %v", err)
+	}
 	if cm.watcher != nil {
 		return cm.watcher.Close()
 	}
@@ -712,6 +893,33 @@ func (cm *ConfigManager) GetCurrentResponsesUpstream() (*UpstreamConfig, error)
 	return &upstream, nil
 }
 
+// GetResponsesFailoverChain 获取 Responses 渠道故障转移链（规则与 GetUpstreamFailoverChain 相同）
+func (cm *ConfigManager) GetResponsesFailoverChain(model, sessionID string) ([]UpstreamCandidate, error) {
+	pinned := cm.sessionChannel(model, sessionID)
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if len(cm.config.ResponsesUpstream) == 0 {
+		return nil, fmt.Errorf("未配置任何 Responses 渠道")
+	}
+
+	if cm.config.CurrentResponsesUpstream >= len(cm.config.ResponsesUpstream) {
+		return nil, fmt.Errorf("当前 Responses 渠道索引 %d 无效", cm.config.CurrentResponsesUpstream)
+	}
+
+	chain := buildFailoverChain(cm.config.ResponsesUpstream, cm.config.CurrentResponsesUpstream, model)
+	cm.balancer.balanceChain(chain, model, cm.getChannelLoadBalanceLocked(), pinned)
+	return chain, nil
+}
+
+// GetResponsesRoutingTable 获取 Responses 渠道的路由表
+func (cm *ConfigManager) GetResponsesRoutingTable() RoutingTable {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return buildRoutingTable(cm.config.ResponsesUpstream, cm.config.CurrentResponsesUpstream)
+}
+
 // SetCurrentResponsesUpstream 设置当前 Responses 上游
 func (cm *ConfigManager) SetCurrentResponsesUpstream(index int) error {
 	cm.mu.Lock()
@@ -733,6 +941,22 @@ func (cm *ConfigManager) SetCurrentResponsesUpstream(index int) error {
 
 // AddResponsesUpstream 添加 Responses 上游
 func (cm *ConfigManager) AddResponsesUpstream(upstream UpstreamConfig) error {
+	if err := validateModelPatterns(upstream.Models); err != nil {
+		return err
+	}
+	// 权重 0 表示未设置，按默认权重 1 处理；显式设置的权重需通过校验
+	if upstream.Weight != 0 {
+		if err := validateWeight(upstream.Weight); err != nil {
+			return err
+		}
+	}
+	if err := validateFailoverRules(upstream.FailoverRules); err != nil {
+		return err
+	}
+	if err := validateKeyBudgets(upstream.KeyBudgets); err != nil {
+		return err
+	}
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -754,6 +978,21 @@ func (cm *ConfigManager) AddResponsesUpstream(upstream UpstreamConfig) error {
 
 // UpdateResponsesUpstream 更新 Responses 上游
 func (cm *ConfigManager) UpdateResponsesUpstream(index int, updates UpstreamUpdate) error {
+	if err := validateModelPatterns(updates.Models); err != nil {
+		return err
+	}
+	if updates.Weight != nil {
+		if err := validateWeight(*updates.Weight); err != nil {
+			return err
+		}
+	}
+	if err := validateFailoverRules(updates.FailoverRules); err != nil {
+		return err
+	}
+	if err := validateKeyBudgets(updates.KeyBudgets); err != nil {
+		return err
+	}
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -784,6 +1023,24 @@ func (cm *ConfigManager) UpdateResponsesUpstream(index int, updates UpstreamUpda
 	if updates.ModelMapping != nil {
 		upstream.ModelMapping = updates.ModelMapping
 	}
+	if updates.FailoverRules != nil {
+		upstream.FailoverRules = updates.FailoverRules
+	}
+	if updates.KeyBudgets != nil {
+		upstream.KeyBudgets = updates.KeyBudgets
+	}
+	if updates.Models != nil {
+		upstream.Models = updates.Models
+	}
+	if updates.Weight != nil {
+		upstream.Weight = *updates.Weight
+	}
+	if updates.DiscoverModels != nil {
+		upstream.DiscoverModels = *updates.DiscoverModels
+	}
+	if updates.ReasoningEffort != nil {
+		upstream.ReasoningEffort = *updates.ReasoningEffort
+	}
 	if updates.InsecureSkipVerify != nil {
 		upstream.InsecureSkipVerify = *updates.InsecureSkipVerify
 	}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============== 故障转移规则 ==============
+
+// 故障转移动作
+const (
+	FailoverRetrySameKey = "retry"        // 使用同一密钥重试
+	FailoverNextKey      = "next_key"     // 标记密钥失败，切换到下一个密钥
+	FailoverNextChannel  = "next_channel" // 渠道级故障，直接切换到下一个渠道
+	FailoverReturn       = "return"       // 不重试，将错误返回给客户端
+	FailoverQuarantine   = "quarantine"   // 隔离密钥（不再自动恢复），切换到下一个密钥
+)
+
+// defaultSameKeyRetries retry 动作未指定次数时，同一密钥的最大重试次数
+const defaultSameKeyRetries = 1
+
+// FailoverRule 故障转移规则
+// 所有已配置的条件同时满足时规则命中；按顺序匹配，先命中者生效
+type FailoverRule struct {
+	Name       string   `json:"name,omitempty"`
+	Status     []string `json:"status,omitempty"`     // 状态码匹配：401、5xx、400-499，为空时匹配任意状态码
+	Path       string   `json:"path,omitempty"`       // 错误体中的 JSON 路径，如 error.message、error.details.0.reason
+	Pattern    string   `json:"pattern,omitempty"`    // 正则，匹配 path 对应的值；未设置 path 时匹配整个错误体
+	Action     string   `json:"action"`               // retry、next_key、next_channel、return、quarantine
+	Quota      bool     `json:"quota,omitempty"`      // 是否为额度/余额相关错误（请求最终成功后将该密钥移到列表末尾）
+	MaxRetries int      `json:"maxRetries,omitempty"` // retry 动作的最大重试次数，默认 1
+}
+
+// FailoverDecision 上游错误的分类结果
+type FailoverDecision struct {
+	Action       string
+	QuotaRelated bool
+	MaxRetries   int
+	Rule         string // 命中的规则名称，用于日志
+}
+
+// ShouldFailover 是否需要重试（非直接返回客户端）
+func (d FailoverDecision) ShouldFailover() bool {
+	return d.Action != FailoverReturn
+}
+
+// DefaultFailoverRules 内置故障转移规则，在渠道自定义规则之后匹配
+// 顺序与原先的硬编码判断一致：401/403 切换密钥；5xx 切换渠道（错误含额度关键字时同时标记降级）；
+// 其他状态码先看错误信息、再看错误类型，命中关键字时切换密钥；最后 429 一律切换密钥（按 Retry-After 等冷却）
+var DefaultFailoverRules = []FailoverRule{
+	{Name: "auth", Status: []string{"401", "403"}, Action: FailoverNextKey},
+	{Name: "server-quota-message", Status: []string{"5xx"}, Path: "error.message", Pattern: quotaMessagePattern, Action: FailoverNextChannel, Quota: true},
+	{Name: "server-key-message", Status: []string{"5xx"}, Path: "error.message", Pattern: keyMessagePattern, Action: FailoverNextChannel},
+	{Name: "server-quota-type", Status: []string{"5xx"}, Path: "error.type", Pattern: quotaTypePattern, Action: FailoverNextChannel, Quota: true},
+	{Name: "server-error", Status: []string{"5xx"}, Action: FailoverNextChannel},
+	{Name: "quota-message", Path: "error.message", Pattern: quotaMessagePattern, Action: FailoverNextKey, Quota: true},
+	{Name: "key-message", Path: "error.message", Pattern: keyMessagePattern, Action: FailoverNextKey},
+	{Name: "quota-type", Path: "error.type", Pattern: quotaTypePattern, Action: FailoverNextKey, Quota: true},
+	{Name: "permission-type", Path: "error.type", Pattern: `(?i)permission`, Action: FailoverNextKey},
+	{Name: "rate-limit", Status: []string{"429"}, Action: FailoverNextKey},
+}
+
+// 内置规则使用的关键字
+const (
+	quotaMessagePattern = `(?i)insufficient|credit|balance|quota`
+	keyMessagePattern   = `(?i)invalid|unauthorized|rate limit`
+	quotaTypePattern    = `(?i)over_quota|billing|insufficient`
+)
+
+// ClassifyFailover 按渠道规则和内置规则对上游错误分类，未命中任何规则时返回给客户端
+func ClassifyFailover(upstream *UpstreamConfig, statusCode int, body []byte) FailoverDecision {
+	var parsed interface{}
+	parsedOK := json.Unmarshal(body, &parsed) == nil
+
+	for _, rules := range [][]FailoverRule{upstream.FailoverRules, DefaultFailoverRules} {
+		for _, rule := range rules {
+			if !rule.matches(statusCode, body, parsed, parsedOK) {
+				continue
+			}
+
+			maxRetries := rule.MaxRetries
+			if maxRetries <= 0 {
+				maxRetries = defaultSameKeyRetries
+			}
+			return FailoverDecision{
+				Action:       rule.Action,
+				QuotaRelated: rule.Quota,
+				MaxRetries:   maxRetries,
+				Rule:         rule.displayName(),
+			}
+		}
+	}
+
+	return FailoverDecision{Action: FailoverReturn}
+}
+
+// matches 判断规则是否命中
+func (r FailoverRule) matches(statusCode int, body []byte, parsed interface{}, parsedOK bool) bool {
+	if len(r.Status) > 0 {
+		matched := false
+		for _, pattern := range r.Status {
+			if matchStatusPattern(pattern, statusCode) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if r.Path == "" && r.Pattern == "" {
+		return true
+	}
+
+	target := string(body)
+	if r.Path != "" {
+		if !parsedOK {
+			return false
+		}
+		value, ok := lookupJSONPath(parsed, r.Path)
+		if !ok {
+			return false
+		}
+		target = value
+	}
+
+	if r.Pattern == "" {
+		return true
+	}
+	re, err := compileFailoverPattern(r.Pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(target)
+}
+
+// displayName 规则名称（未命名时使用动作名）
+func (r FailoverRule) displayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Action
+}
+
+// matchStatusPattern 匹配状态码：精确值（429）、通配（5xx、40x）或区间（400-499）
+func matchStatusPattern(pattern string, statusCode int) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	code := strconv.Itoa(statusCode)
+
+	if from, to, ok := strings.Cut(pattern, "-"); ok {
+		lo, err1 := strconv.Atoi(strings.TrimSpace(from))
+		hi, err2 := strconv.Atoi(strings.TrimSpace(to))
+		return err1 == nil && err2 == nil && statusCode >= lo && statusCode <= hi
+	}
+
+	if len(pattern) != len(code) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != 'x' && pattern[i] != code[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupJSONPath 按点分路径取值，数组使用数字下标；非字符串值序列化为 JSON 文本
+func lookupJSONPath(data interface{}, path string) (string, bool) {
+	current := data
+	for _, part := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, exists := node[part]
+			if !exists {
+				return "", false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			current = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := current.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded), true
+	}
+}
+
+// failoverPatternCache 已编译的故障转移规则正则缓存
+var failoverPatternCache sync.Map
+
+// compileFailoverPattern 编译故障转移规则正则
+func compileFailoverPattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := failoverPatternCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	failoverPatternCache.Store(pattern, re)
+	return re, nil
+}
+
+// isValidFailoverAction 校验故障转移动作
+func isValidFailoverAction(action string) bool {
+	switch action {
+	case FailoverRetrySameKey, FailoverNextKey, FailoverNextChannel, FailoverReturn, FailoverQuarantine:
+		return true
+	}
+	return false
+}
+
+// validateFailoverRules 校验渠道的故障转移规则
+func validateFailoverRules(rules []FailoverRule) error {
+	for i, rule := range rules {
+		if !isValidFailoverAction(rule.Action) {
+			return fmt.Errorf("无效的故障转移规则 #%d: 未知动作 %q", i+1, rule.Action)
+		}
+		for _, status := range rule.Status {
+			if !isValidStatusPattern(status) {
+				return fmt.Errorf("无效的故障转移规则 #%d: 无效的状态码 %q", i+1, status)
+			}
+		}
+		if rule.Pattern != "" {
+			if _, err := compileFailoverPattern(rule.Pattern); err != nil {
+				return fmt.Errorf("无效的故障转移规则 #%d: %v", i+1, err)
+			}
+		}
+		if rule.MaxRetries < 0 {
+			return fmt.Errorf("无效的故障转移规则 #%d: maxRetries 不能为负数", i+1)
+		}
+	}
+	return nil
+}
+
+// isValidStatusPattern 校验状态码匹配写法
+func isValidStatusPattern(pattern string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if from, to, ok := strings.Cut(pattern, "-"); ok {
+		_, err1 := strconv.Atoi(strings.TrimSpace(from))
+		_, err2 := strconv.Atoi(strings.TrimSpace(to))
+		return err1 == nil && err2 == nil
+	}
+	if len(pattern) != 3 {
+		return false
+	}
+	for _, r := range pattern {
+		if r != 'x' && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
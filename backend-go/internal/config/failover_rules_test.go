@@ -0,0 +1,176 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestClassifyFailover_DefaultRules(t *testing.T) {
+	upstream := &UpstreamConfig{Name: "relay"}
+
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantAct   string
+		wantQuota bool
+	}{
+		{"401 认证失败", 401, `{"error":{"message":"bad key"}}`, FailoverNextKey, false},
+		{"余额不足", 400, `{"error":{"message":"Insufficient balance"}}`, FailoverNextKey, true},
+		{"仅含积分不足返回客户端", 402, `{"error":{"message":"积分不足"}}`, FailoverReturn, false},
+		{"认证失败含余额字样不标记降级", 401, `{"error":{"message":"Invalid key, check your credit"}}`, FailoverNextKey, false},
+		{"限流", 429, `{"error":{"message":"Rate limit exceeded"}}`, FailoverNextKey, false},
+		{"无限流字样的 429", 429, `{"error":{"message":"Too many requests"}}`, FailoverNextKey, false},
+		{"计费类型", 400, `{"error":{"type":"billing_error","message":"x"}}`, FailoverNextKey, true},
+		{"5xx 切换渠道", 503, `upstream unavailable`, FailoverNextChannel, false},
+		{"5xx 额度类型", 500, `{"error":{"type":"insufficient_quota","message":"server busy"}}`, FailoverNextChannel, true},
+		{"5xx 余额消息", 503, `{"error":{"message":"Your credit balance is too low"}}`, FailoverNextChannel, true},
+		{"普通 400 返回客户端", 400, `{"error":{"message":"max_tokens too large"}}`, FailoverReturn, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyFailover(upstream, tt.status, []byte(tt.body))
+			if got.Action != tt.wantAct || got.QuotaRelated != tt.wantQuota {
+				t.Errorf("ClassifyFailover() = %s (quota=%v), want %s (quota=%v)", got.Action, got.QuotaRelated, tt.wantAct, tt.wantQuota)
+			}
+		})
+	}
+}
+
+// legacyShouldRetryWithNextKey 引入故障转移规则前的硬编码判断（5xx 为渠道级故障），用于对比内置规则
+func legacyShouldRetryWithNextKey(statusCode int, bodyBytes []byte) (action string, quota bool) {
+	failover := func(quota bool) (string, bool) {
+		if statusCode >= 500 {
+			return FailoverNextChannel, quota
+		}
+		return FailoverNextKey, quota
+	}
+
+	if statusCode == 401 || statusCode == 403 {
+		return failover(false)
+	}
+
+	var errResp map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &errResp); err == nil {
+		if errObj, ok := errResp["error"].(map[string]interface{}); ok {
+			if msg, ok := errObj["message"].(string); ok {
+				msgLower := strings.ToLower(msg)
+				if containsAny(msgLower, "insufficient", "invalid", "unauthorized", "quota", "rate limit", "credit", "balance") {
+					return failover(containsAny(msgLower, "积分不足", "insufficient", "credit", "balance", "quota"))
+				}
+			}
+			if errType, ok := errObj["type"].(string); ok {
+				errTypeLower := strings.ToLower(errType)
+				if containsAny(errTypeLower, "permission", "insufficient", "over_quota", "billing") {
+					return failover(containsAny(errTypeLower, "over_quota", "billing", "insufficient"))
+				}
+			}
+		}
+	}
+
+	if statusCode >= 500 {
+		return FailoverNextChannel, false
+	}
+	return FailoverReturn, false
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClassifyFailover_MatchesLegacyDecisions(t *testing.T) {
+	upstream := &UpstreamConfig{Name: "relay"}
+	// 429 由 rate-limit 规则统一切换密钥，原判断仅在错误信息含关键字时切换，不参与对比
+	statuses := []int{400, 401, 402, 403, 404, 500, 503}
+	bodies := []string{
+		`{"error":{"message":"bad key"}}`,
+		`{"error":{"message":"Insufficient balance"}}`,
+		`{"error":{"message":"Your credit balance is too low"}}`,
+		`{"error":{"message":"积分不足"}}`,
+		`{"error":{"message":"Invalid API key"}}`,
+		`{"error":{"message":"Unauthorized"}}`,
+		`{"error":{"message":"max_tokens too large"}}`,
+		`{"error":{"message":"invalid request","type":"billing_error"}}`,
+		`{"error":{"message":"x","type":"billing_error"}}`,
+		`{"error":{"message":"x","type":"insufficient_quota"}}`,
+		`{"error":{"message":"x","type":"permission_error"}}`,
+		`{"error":{"type":"over_quota"}}`,
+		`{"error":"plain"}`,
+		`upstream unavailable`,
+	}
+
+	for _, status := range statuses {
+		for _, body := range bodies {
+			wantAct, wantQuota := legacyShouldRetryWithNextKey(status, []byte(body))
+			got := ClassifyFailover(upstream, status, []byte(body))
+			if got.Action != wantAct || got.QuotaRelated != wantQuota {
+				t.Errorf("%d %s: ClassifyFailover() = %s (quota=%v), 原判断 = %s (quota=%v)",
+					status, body, got.Action, got.QuotaRelated, wantAct, wantQuota)
+			}
+		}
+	}
+}
+
+func TestClassifyFailover_ChannelRules(t *testing.T) {
+	upstream := &UpstreamConfig{
+		Name: "relay",
+		FailoverRules: []FailoverRule{
+			{Name: "model-revoked", Status: []string{"400"}, Path: "error.code", Pattern: `^model_access_revoked$`, Action: FailoverQuarantine},
+			{Name: "overloaded", Status: []string{"529"}, Action: FailoverRetrySameKey, MaxRetries: 2},
+			{Name: "relay-5xx", Status: []string{"500-502"}, Path: "error.details.0.reason", Pattern: `(?i)maintenance`, Action: FailoverReturn},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantAct string
+	}{
+		{"400 权限吊销隔离密钥", 400, `{"error":{"code":"model_access_revoked"}}`, FailoverQuarantine},
+		{"529 同一密钥重试", 529, `{}`, FailoverRetrySameKey},
+		{"自定义规则优先于内置 5xx", 502, `{"error":{"details":[{"reason":"Maintenance"}]}}`, FailoverReturn},
+		{"未命中自定义规则时回退内置规则", 500, `{"error":{"details":[{"reason":"other"}]}}`, FailoverNextChannel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyFailover(upstream, tt.status, []byte(tt.body)); got.Action != tt.wantAct {
+				t.Errorf("ClassifyFailover() = %s, want %s", got.Action, tt.wantAct)
+			}
+		})
+	}
+
+	if got := ClassifyFailover(upstream, 529, nil); got.MaxRetries != 2 {
+		t.Errorf("MaxRetries = %d, want 2", got.MaxRetries)
+	}
+}
+
+func TestValidateFailoverRules(t *testing.T) {
+	valid := []FailoverRule{
+		{Status: []string{"401", "5xx", "400-499"}, Action: FailoverNextKey},
+		{Path: "error.message", Pattern: `(?i)quota`, Action: FailoverReturn},
+	}
+	if err := validateFailoverRules(valid); err != nil {
+		t.Errorf("合法规则校验失败: %v", err)
+	}
+
+	invalid := [][]FailoverRule{
+		{{Action: "skip"}},
+		{{Status: []string{"50"}, Action: FailoverNextKey}},
+		{{Pattern: `(`, Action: FailoverNextKey}},
+		{{Action: FailoverRetrySameKey, MaxRetries: -1}},
+	}
+	for i, rules := range invalid {
+		if err := validateFailoverRules(rules); err == nil {
+			t.Errorf("第 %d 组非法规则未被拒绝", i+1)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ============== 密钥用量预算 ==============
+
+const (
+	keyUsageFileName      = "key_usage.json"
+	keyUsageFlushInterval = 30 * time.Second
+	defaultKeyBudgetName  = "*" // keyBudgets 中适用于渠道内所有密钥的默认预算
+)
+
+// KeyBudget 密钥用量预算，0 表示不限制
+// 日窗口在本地时间每天零点重置，月窗口在每月 1 日零点重置
+type KeyBudget struct {
+	DailyRequests   int64 `json:"dailyRequests,omitempty"`
+	DailyTokens     int64 `json:"dailyTokens,omitempty"`
+	MonthlyRequests int64 `json:"monthlyRequests,omitempty"`
+	MonthlyTokens   int64 `json:"monthlyTokens,omitempty"`
+}
+
+// isZero 是否未设置任何限制
+func (b KeyBudget) isZero() bool {
+	return b.DailyRequests == 0 && b.DailyTokens == 0 && b.MonthlyRequests == 0 && b.MonthlyTokens == 0
+}
+
+// KeyUsage 密钥在当前窗口内的用量（管理 API 展示用）
+type KeyUsage struct {
+	DailyRequests   int64      `json:"dailyRequests"`
+	DailyTokens     int64      `json:"dailyTokens"`
+	MonthlyRequests int64      `json:"monthlyRequests"`
+	MonthlyTokens   int64      `json:"monthlyTokens"`
+	Budget          *KeyBudget `json:"budget,omitempty"`
+	Exhausted       bool       `json:"exhausted,omitempty"`
+	ResetAt         *time.Time `json:"resetAt,omitempty"` // 预算耗尽时的恢复时间
+}
+
+// keyUsageRecord 持久化的用量计数
+type keyUsageRecord struct {
+	Day             string `json:"day"`   // 2006-01-02
+	Month           string `json:"month"` // 2006-01
+	DailyRequests   int64  `json:"dailyRequests"`
+	DailyTokens     int64  `json:"dailyTokens"`
+	MonthlyRequests int64  `json:"monthlyRequests"`
+	MonthlyTokens   int64  `json:"monthlyTokens"`
+}
+
+// rollover 窗口切换时清零对应计数
+func (r *keyUsageRecord) rollover(now time.Time) {
+	if day := now.Format("2006-01-02"); r.Day != day {
+		r.Day = day
+		r.DailyRequests = 0
+		r.DailyTokens = 0
+	}
+	if month := now.Format("2006-01"); r.Month != month {
+		r.Month = month
+		r.MonthlyRequests = 0
+		r.MonthlyTokens = 0
+	}
+}
+
+// keyUsageFile key_usage.json 文件结构，按密钥指纹索引
+type keyUsageFile struct {
+	Keys map[string]*keyUsageRecord `json:"keys"`
+}
+
+// keyUsages 各密钥的用量计数
+type keyUsages struct {
+	mu      sync.Mutex
+	records map[string]*keyUsageRecord
+	dirty   bool
+}
+
+func newKeyUsages() *keyUsages {
+	return &keyUsages{
+		records: make(map[string]*keyUsageRecord),
+	}
+}
+
+// add 累加一次请求及其 token 用量
+func (k *keyUsages) add(apiKey string, tokens int64, now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	fingerprint := keyFingerprint(apiKey)
+	record, exists := k.records[fingerprint]
+	if !exists {
+		record = &keyUsageRecord{}
+		k.records[fingerprint] = record
+	}
+	record.rollover(now)
+	record.DailyRequests++
+	record.DailyTokens += tokens
+	record.MonthlyRequests++
+	record.MonthlyTokens += tokens
+	k.dirty = true
+}
+
+// snapshot 获取当前窗口内的用量副本
+func (k *keyUsages) snapshot(apiKey string, now time.Time) keyUsageRecord {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	record, exists := k.records[keyFingerprint(apiKey)]
+	if !exists {
+		return keyUsageRecord{}
+	}
+	current := *record
+	current.rollover(now)
+	return current
+}
+
+// exhaustedUntil 判断用量是否超出预算，返回恢复时间
+func exhaustedUntil(usage keyUsageRecord, budget KeyBudget, now time.Time) (time.Time, bool) {
+	nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	if (budget.MonthlyRequests > 0 && usage.MonthlyRequests >= budget.MonthlyRequests) ||
+		(budget.MonthlyTokens > 0 && usage.MonthlyTokens >= budget.MonthlyTokens) {
+		return nextMonth, true
+	}
+
+	nextDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if (budget.DailyRequests > 0 && usage.DailyRequests >= budget.DailyRequests) ||
+		(budget.DailyTokens > 0 && usage.DailyTokens >= budget.DailyTokens) {
+		return nextDay, true
+	}
+	return time.Time{}, false
+}
+
+// keyBudgetFor 获取密钥的预算（未单独配置时使用渠道默认预算）
+func keyBudgetFor(upstream *UpstreamConfig, apiKey string) (KeyBudget, bool) {
+	if budget, exists := upstream.KeyBudgets[apiKey]; exists {
+		return budget, !budget.isZero()
+	}
+	if budget, exists := upstream.KeyBudgets[defaultKeyBudgetName]; exists {
+		return budget, !budget.isZero()
+	}
+	return KeyBudget{}, false
+}
+
+// validateKeyBudgets 校验渠道的密钥预算
+func validateKeyBudgets(budgets map[string]KeyBudget) error {
+	for key, budget := range budgets {
+		if budget.DailyRequests < 0 || budget.DailyTokens < 0 || budget.MonthlyRequests < 0 || budget.MonthlyTokens < 0 {
+			name := key
+			if name != defaultKeyBudgetName {
+				name = maskAPIKey(key)
+			}
+			return fmt.Errorf("无效的密钥预算 %s: 预算不能为负数", name)
+		}
+	}
+	return nil
+}
+
+// RecordKeyUsage 记录密钥的一次成功请求及其 token 用量
+func (cm *ConfigManager) RecordKeyUsage(apiKey string, tokens int64) {
+	cm.usage.add(apiKey, tokens, time.Now())
+}
+
+// isKeyOverBudget 密钥在当前窗口内是否已超出预算
+func (cm *ConfigManager) isKeyOverBudget(upstream *UpstreamConfig, apiKey string) bool {
+	budget, ok := keyBudgetFor(upstream, apiKey)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	_, exhausted := exhaustedUntil(cm.usage.snapshot(apiKey, now), budget, now)
+	return exhausted
+}
+
+// filterKeysWithinBudget 过滤掉已超出用量预算的密钥
+func (cm *ConfigManager) filterKeysWithinBudget(upstream *UpstreamConfig, keys []string) []string {
+	if len(upstream.KeyBudgets) == 0 {
+		return keys
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if cm.isKeyOverBudget(upstream, key) {
+			log.Printf("💰 API密钥 %s 已达到用量预算，跳过", maskAPIKey(key))
+			continue
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+// GetKeyUsage 获取密钥在当前窗口内的用量和预算
+func (cm *ConfigManager) GetKeyUsage(upstream *UpstreamConfig, apiKey string) KeyUsage {
+	now := time.Now()
+	record := cm.usage.snapshot(apiKey, now)
+	usage := KeyUsage{
+		DailyRequests:   record.DailyRequests,
+		DailyTokens:     record.DailyTokens,
+		MonthlyRequests: record.MonthlyRequests,
+		MonthlyTokens:   record.MonthlyTokens,
+	}
+
+	if budget, ok := keyBudgetFor(upstream, apiKey); ok {
+		usage.Budget = &budget
+		if resetAt, exhausted := exhaustedUntil(record, budget, now); exhausted {
+			usage.Exhausted = true
+			usage.ResetAt = &resetAt
+		}
+	}
+	return usage
+}
+
+// keyUsageFilePath 用量计数文件路径
+func (cm *ConfigManager) keyUsageFilePath() string {
+	return filepath.Join(filepath.Dir(cm.configFile), keyUsageFileName)
+}
+
+// loadKeyUsage 加载持久化的用量计数
+func (cm *ConfigManager) loadKeyUsage() error {
+	data, err := os.ReadFile(cm.keyUsageFilePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file keyUsageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	cm.usage.mu.Lock()
+	defer cm.usage.mu.Unlock()
+
+	for fingerprint, record := range file.Keys {
+		if record != nil {
+			cm.usage.records[fingerprint] = record
+		}
+	}
+	return nil
+}
+
+// flushKeyUsage 将有变化的用量计数写入文件
+func (cm *ConfigManager) flushKeyUsage() error {
+	cm.usage.mu.Lock()
+	if !cm.usage.dirty {
+		cm.usage.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(keyUsageFile{Keys: cm.usage.records}, "", "  ")
+	cm.usage.dirty = false
+	cm.usage.mu.Unlock()
+
+	if err == nil {
+		err = os.WriteFile(cm.keyUsageFilePath(), data, 0600)
+	}
+	if err != nil {
+		// 写入失败时保留脏标记，下次重试
+		cm.usage.mu.Lock()
+		cm.usage.dirty = true
+		cm.usage.mu.Unlock()
+	}
+	return err
+}
+
+// persistKeyUsage 定期保存用量计数
+func (cm *ConfigManager) persistKeyUsage() {
+	ticker := time.NewTicker(keyUsageFlushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cm.flushKeyUsage(); err != nil {
+			log.Printf("⚠️ 保存密钥用量失败: %v", err)
+		}
+	}
+}
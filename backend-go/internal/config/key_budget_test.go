@@ -0,0 +1,98 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyBudget_SkipsExhaustedKeys(t *testing.T) {
+	upstream := UpstreamConfig{
+		Name:    "free-tier",
+		APIKeys: []string{"key-free", "key-paid"},
+		KeyBudgets: map[string]KeyBudget{
+			"*":        {DailyRequests: 2},
+			"key-paid": {MonthlyTokens: 1000},
+		},
+	}
+	cm := newTestConfigManager(upstream)
+
+	cm.RecordKeyUsage("key-free", 10)
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-free" {
+		t.Fatalf("预算未耗尽时选择了 %s, want key-free", key)
+	}
+
+	cm.RecordKeyUsage("key-free", 10)
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-paid" {
+		t.Fatalf("日请求预算耗尽后选择了 %s, want key-paid", key)
+	}
+
+	status := cm.GetKeyStatus(&upstream, "key-free")
+	if status.State != KeyStateExhausted || status.RecoverAt == nil {
+		t.Fatalf("state = %s, want %s with recoverAt", status.State, KeyStateExhausted)
+	}
+	if status.Usage.DailyRequests != 2 || status.Usage.DailyTokens != 20 {
+		t.Fatalf("usage = %+v", status.Usage)
+	}
+
+	cm.RecordKeyUsage("key-paid", 1200)
+	if _, err := cm.GetNextAPIKey(&upstream, map[string]bool{}); err == nil {
+		t.Fatal("所有密钥预算耗尽时应返回错误")
+	}
+
+	// 日窗口重置后恢复，月预算仍然生效
+	cm.usage.records[keyFingerprint("key-free")].Day = "2000-01-01"
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-free" {
+		t.Fatalf("日窗口重置后选择了 %s, want key-free", key)
+	}
+}
+
+func TestKeyBudget_ExhaustedUntil(t *testing.T) {
+	now := time.Date(2024, 12, 31, 15, 0, 0, 0, time.Local)
+	usage := keyUsageRecord{DailyRequests: 5, MonthlyTokens: 100}
+
+	tests := []struct {
+		name      string
+		budget    KeyBudget
+		wantReset time.Time
+		wantOK    bool
+	}{
+		{"未超出", KeyBudget{DailyRequests: 10, MonthlyTokens: 1000}, time.Time{}, false},
+		{"日请求数", KeyBudget{DailyRequests: 5}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), true},
+		{"月 token 数", KeyBudget{MonthlyTokens: 100}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset, ok := exhaustedUntil(usage, tt.budget, now)
+			if ok != tt.wantOK || !reset.Equal(tt.wantReset) {
+				t.Errorf("exhaustedUntil = (%v, %v), want (%v, %v)", reset, ok, tt.wantReset, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestKeyBudget_Persist(t *testing.T) {
+	dir := t.TempDir()
+	cm := newTestConfigManager()
+	cm.configFile = filepath.Join(dir, "config.json")
+
+	cm.RecordKeyUsage("key-a", 300)
+	if err := cm.flushKeyUsage(); err != nil {
+		t.Fatalf("保存用量失败: %v", err)
+	}
+
+	restarted := newTestConfigManager()
+	restarted.configFile = cm.configFile
+	if err := restarted.loadKeyUsage(); err != nil {
+		t.Fatalf("加载用量失败: %v", err)
+	}
+	usage := restarted.GetKeyUsage(&UpstreamConfig{}, "key-a")
+	if usage.DailyRequests != 1 || usage.MonthlyTokens != 300 {
+		t.Fatalf("重启后 usage = %+v", usage)
+	}
+
+	if err := validateKeyBudgets(map[string]KeyBudget{"*": {DailyTokens: -1}}); err == nil {
+		t.Fatal("负数预算未被拒绝")
+	}
+}
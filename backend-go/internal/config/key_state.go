@@ -0,0 +1,287 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// ============== 密钥状态机 ==============
+//
+// active      正常可用
+// cooling     临时失败（限流、额度波动等），到期自动恢复
+// exhausted   达到渠道配置的用量预算，日/月窗口重置后自动恢复
+// quarantined 疑似永久失效（如连续认证失败、权限被吊销），需管理员处理，不会自动恢复
+// disabled    管理员手动禁用
+//
+// quarantined / disabled 会持久化到配置目录下的 key_state.json，重启后保留
+
+// 密钥状态
+const (
+	KeyStateActive      = "active"
+	KeyStateCooling     = "cooling"
+	KeyStateExhausted   = "exhausted"
+	KeyStateQuarantined = "quarantined"
+	KeyStateDisabled    = "disabled"
+)
+
+const (
+	keyStateFileName                   = "key_state.json"
+	defaultQuarantineAfterAuthFailures = 3 // 连续认证失败多少次后自动隔离
+)
+
+// authFailurePattern 错误信息中表示密钥无效的关键字
+var authFailurePattern = regexp.MustCompile(`(?i)invalid[ _-]?(x-)?api[ _-]?key|invalid[ _-]?token|api[ _-]?key.*(revoked|deactivated|disabled|expired)|incorrect api key`)
+
+// keyStateRecord 隔离/禁用的密钥记录
+type keyStateRecord struct {
+	State  string    `json:"state"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// keyStateFile key_state.json 文件结构，按密钥指纹索引，避免明文保存密钥
+type keyStateFile struct {
+	Keys map[string]*keyStateRecord `json:"keys"`
+}
+
+// KeyStatus 密钥状态（管理 API 展示用）
+type KeyStatus struct {
+	Key          string        `json:"key"` // 掩码后的密钥
+	State        string        `json:"state"`
+	Reason       string        `json:"reason,omitempty"`
+	Since        *time.Time    `json:"since,omitempty"`
+	RecoverAt    *time.Time    `json:"recoverAt,omitempty"` // cooling / exhausted 状态的恢复时间
+	FailureCount int           `json:"failureCount,omitempty"`
+	AuthFailures int           `json:"authFailures,omitempty"`
+	RateLimit    *KeyRateLimit `json:"rateLimit,omitempty"`
+	Usage        KeyUsage      `json:"usage"`
+}
+
+// keyFingerprint 密钥指纹
+func keyFingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+// keyStateFilePath 密钥状态文件路径
+func (cm *ConfigManager) keyStateFilePath() string {
+	return filepath.Join(filepath.Dir(cm.configFile), keyStateFileName)
+}
+
+// loadKeyStates 加载持久化的密钥状态
+func (cm *ConfigManager) loadKeyStates() error {
+	data, err := os.ReadFile(cm.keyStateFilePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file keyStateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	for fingerprint, record := range file.Keys {
+		if record == nil || (record.State != KeyStateQuarantined && record.State != KeyStateDisabled) {
+			continue
+		}
+		cm.keyStates[fingerprint] = record
+	}
+	if len(cm.keyStates) > 0 {
+		log.Printf("已加载 %d 个隔离/禁用的密钥状态", len(cm.keyStates))
+	}
+	return nil
+}
+
+// saveKeyStatesLocked 持久化密钥状态（需持有锁）
+func (cm *ConfigManager) saveKeyStatesLocked() error {
+	data, err := json.MarshalIndent(keyStateFile{Keys: cm.keyStates}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cm.keyStateFilePath(), data, 0600)
+}
+
+// setKeyStateLocked 设置隔离/禁用状态并持久化（需持有锁）
+func (cm *ConfigManager) setKeyStateLocked(apiKey, state, reason string) {
+	cm.keyStates[keyFingerprint(apiKey)] = &keyStateRecord{State: state, Reason: reason, Since: time.Now()}
+	delete(cm.failedKeysCache, apiKey)
+	if err := cm.saveKeyStatesLocked(); err != nil {
+		log.Printf("⚠️ 保存密钥状态失败: %v", err)
+	}
+}
+
+// QuarantineAPIKey 隔离密钥（如权限被吊销），隔离后不会自动恢复
+func (cm *ConfigManager) QuarantineAPIKey(apiKey, reason string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if record, exists := cm.keyStates[keyFingerprint(apiKey)]; exists && record.State != KeyStateActive {
+		return
+	}
+	cm.setKeyStateLocked(apiKey, KeyStateQuarantined, reason)
+	log.Printf("🚫 API密钥已隔离: %s (原因: %s)", maskAPIKey(apiKey), reason)
+}
+
+// SetKeyState 管理员手动设置密钥状态
+// active 会清除隔离、禁用和冷却状态；disabled / quarantined 会持久化
+func (cm *ConfigManager) SetKeyState(apiKey, state, reason string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	switch state {
+	case KeyStateActive:
+		delete(cm.keyStates, keyFingerprint(apiKey))
+		delete(cm.failedKeysCache, apiKey)
+		delete(cm.authFailures, apiKey)
+		if err := cm.saveKeyStatesLocked(); err != nil {
+			return err
+		}
+	case KeyStateDisabled, KeyStateQuarantined:
+		if reason == "" {
+			reason = "管理员手动设置"
+		}
+		cm.setKeyStateLocked(apiKey, state, reason)
+	default:
+		return fmt.Errorf("无效的密钥状态: %s", state)
+	}
+
+	log.Printf("🔧 API密钥 %s 状态已设置为 %s", maskAPIKey(apiKey), state)
+	return nil
+}
+
+// IsAuthFailure 判断上游错误是否表示密钥本身无效（用于自动隔离）
+func IsAuthFailure(statusCode int, body []byte) bool {
+	if statusCode == 401 || statusCode == 403 {
+		return true
+	}
+
+	var parsed interface{}
+	if json.Unmarshal(body, &parsed) != nil {
+		return false
+	}
+	if errType, ok := lookupJSONPath(parsed, "error.type"); ok && errType == "authentication_error" {
+		return true
+	}
+	if msg, ok := lookupJSONPath(parsed, "error.message"); ok && authFailurePattern.MatchString(msg) {
+		return true
+	}
+	return false
+}
+
+// RecordKeyAuthFailure 记录密钥认证失败，连续失败达到阈值后自动隔离
+// 返回密钥是否因此被隔离
+func (cm *ConfigManager) RecordKeyAuthFailure(apiKey string, statusCode int) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.authFailures[apiKey]++
+	threshold := cm.config.QuarantineAfterAuthFailures
+	if threshold <= 0 {
+		threshold = defaultQuarantineAfterAuthFailures
+	}
+	if cm.authFailures[apiKey] < threshold {
+		return false
+	}
+
+	reason := fmt.Sprintf("连续 %d 次认证失败 (最近状态: %d)", cm.authFailures[apiKey], statusCode)
+	cm.setKeyStateLocked(apiKey, KeyStateQuarantined, reason)
+	log.Printf("🚫 API密钥已自动隔离: %s (%s)，请在管理界面检查", maskAPIKey(apiKey), reason)
+	return true
+}
+
+// RecordKeySuccess 记录密钥调用成功，清除冷却状态和认证失败计数
+func (cm *ConfigManager) RecordKeySuccess(apiKey string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	delete(cm.authFailures, apiKey)
+	delete(cm.failedKeysCache, apiKey)
+}
+
+// isKeyBlocked 密钥是否被隔离或禁用
+func (cm *ConfigManager) isKeyBlocked(apiKey string) bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	_, blocked := cm.keyStates[keyFingerprint(apiKey)]
+	return blocked
+}
+
+// filterUsableKeys 过滤掉被隔离或禁用的密钥
+func (cm *ConfigManager) filterUsableKeys(keys []string) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if len(cm.keyStates) == 0 {
+		return keys
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, blocked := cm.keyStates[keyFingerprint(key)]; !blocked {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// GetKeyStatus 获取密钥当前状态
+func (cm *ConfigManager) GetKeyStatus(upstream *UpstreamConfig, apiKey string) KeyStatus {
+	cm.mu.RLock()
+	status := KeyStatus{
+		Key:          maskAPIKey(apiKey),
+		State:        KeyStateActive,
+		AuthFailures: cm.authFailures[apiKey],
+	}
+
+	if record, exists := cm.keyStates[keyFingerprint(apiKey)]; exists {
+		since := record.Since
+		status.State = record.State
+		status.Reason = record.Reason
+		status.Since = &since
+	} else if failure, exists := cm.failedKeysCache[apiKey]; exists {
+		recoverAt := cm.keyRecoveryAt(failure)
+		status.FailureCount = failure.FailureCount
+		if time.Now().Before(recoverAt) {
+			since := failure.Timestamp
+			status.State = KeyStateCooling
+			status.Since = &since
+			status.RecoverAt = &recoverAt
+		}
+	}
+	cm.mu.RUnlock()
+
+	if limit, ok := cm.GetKeyRateLimit(apiKey); ok {
+		status.RateLimit = &limit
+	}
+
+	status.Usage = cm.GetKeyUsage(upstream, apiKey)
+	if status.Usage.Exhausted && (status.State == KeyStateActive || status.State == KeyStateCooling) {
+		status.State = KeyStateExhausted
+		status.Since = nil
+		status.RecoverAt = status.Usage.ResetAt
+	}
+	return status
+}
+
+// GetKeyStatuses 获取渠道所有密钥的状态（顺序与密钥列表一致）
+func (cm *ConfigManager) GetKeyStatuses(upstream *UpstreamConfig) []KeyStatus {
+	result := make([]KeyStatus, len(upstream.APIKeys))
+	for i, key := range upstream.APIKeys {
+		result[i] = cm.GetKeyStatus(upstream, key)
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyState_AutoQuarantineAndPersist(t *testing.T) {
+	dir := t.TempDir()
+	upstream := UpstreamConfig{Name: "relay", APIKeys: []string{"sk-revoked", "sk-good"}}
+	cm := newTestConfigManager(upstream)
+	cm.configFile = filepath.Join(dir, "config.json")
+
+	// 未达到阈值前仅计数，成功调用会清零
+	cm.RecordKeyAuthFailure("sk-revoked", 401)
+	cm.RecordKeySuccess("sk-revoked")
+	if got := cm.GetKeyStatus(&upstream, "sk-revoked").AuthFailures; got != 0 {
+		t.Fatalf("成功后认证失败计数 = %d, want 0", got)
+	}
+
+	for i := 1; i <= defaultQuarantineAfterAuthFailures; i++ {
+		quarantined := cm.RecordKeyAuthFailure("sk-revoked", 401)
+		if quarantined != (i == defaultQuarantineAfterAuthFailures) {
+			t.Fatalf("第 %d 次认证失败 quarantined = %v", i, quarantined)
+		}
+	}
+
+	if got := cm.GetKeyStatus(&upstream, "sk-revoked").State; got != KeyStateQuarantined {
+		t.Fatalf("state = %s, want %s", got, KeyStateQuarantined)
+	}
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "sk-good" {
+		t.Fatalf("隔离的密钥仍被选中: %s", key)
+	}
+
+	// 状态文件按指纹保存，不包含明文密钥
+	data, err := os.ReadFile(filepath.Join(dir, keyStateFileName))
+	if err != nil {
+		t.Fatalf("读取状态文件失败: %v", err)
+	}
+	if strings.Contains(string(data), "sk-revoked") {
+		t.Fatal("状态文件不应包含明文密钥")
+	}
+
+	// 重启后恢复隔离状态
+	restarted := newTestConfigManager(upstream)
+	restarted.configFile = cm.configFile
+	if err := restarted.loadKeyStates(); err != nil {
+		t.Fatalf("加载状态失败: %v", err)
+	}
+	if got := restarted.GetKeyStatus(&upstream, "sk-revoked").State; got != KeyStateQuarantined {
+		t.Fatalf("重启后 state = %s, want %s", got, KeyStateQuarantined)
+	}
+
+	// 管理员恢复后重新可用
+	if err := restarted.SetKeyState("sk-revoked", KeyStateActive, ""); err != nil {
+		t.Fatalf("SetKeyState 失败: %v", err)
+	}
+	if key, _ := restarted.GetNextAPIKey(&upstream, map[string]bool{}); key != "sk-revoked" {
+		t.Fatalf("恢复后应优先选择 sk-revoked, got %s", key)
+	}
+	if err := restarted.SetKeyState("sk-revoked", "paused", ""); err == nil {
+		t.Fatal("无效状态未被拒绝")
+	}
+}
+
+func TestKeyState_CoolingAndDisabled(t *testing.T) {
+	dir := t.TempDir()
+	upstream := UpstreamConfig{Name: "relay", APIKeys: []string{"key-a", "key-b"}}
+	cm := newTestConfigManager(upstream)
+	cm.configFile = filepath.Join(dir, "config.json")
+
+	cm.MarkKeyAsFailed("key-a")
+	status := cm.GetKeyStatus(&upstream, "key-a")
+	if status.State != KeyStateCooling || status.RecoverAt == nil {
+		t.Fatalf("state = %s, want %s with recoverAt", status.State, KeyStateCooling)
+	}
+
+	if err := cm.SetKeyState("key-b", KeyStateDisabled, ""); err != nil {
+		t.Fatalf("SetKeyState 失败: %v", err)
+	}
+	// 所有可用密钥都在冷却时仍可回退使用，但禁用的密钥不参与回退
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-a" {
+		t.Fatalf("got %s, want key-a", key)
+	}
+	if _, err := cm.GetNextAPIKey(&upstream, map[string]bool{"key-a": true}); err == nil {
+		t.Fatal("禁用的密钥不应被选中")
+	}
+}
+
+func TestIsAuthFailure(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{401, `{}`, true},
+		{400, `{"error":{"type":"authentication_error","message":"x"}}`, true},
+		{400, `{"error":{"message":"Incorrect API key provided"}}`, true},
+		{400, `{"error":{"message":"Your api key has been revoked"}}`, true},
+		{429, `{"error":{"message":"rate limit"}}`, false},
+		{400, `{"error":{"message":"invalid request: max_tokens"}}`, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsAuthFailure(tt.status, []byte(tt.body)); got != tt.want {
+			t.Errorf("IsAuthFailure(%d, %s) = %v, want %v", tt.status, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/BenedictKing/claude-proxy/internal/utils"
+)
+
+// ============== 密钥限流额度 ==============
+
+const (
+	lowBudgetRatio   = 0.05            // 剩余额度低于上限的该比例时视为即将耗尽
+	budgetStaleAfter = 1 * time.Minute // 上游未提供重置时间时，额度信息的有效期
+)
+
+// KeyRateLimit 密钥最近一次观测到的限流额度
+type KeyRateLimit struct {
+	RequestsLimit     int64     `json:"requestsLimit"`
+	RequestsRemaining int64     `json:"requestsRemaining"`
+	RequestsReset     time.Time `json:"requestsReset,omitempty"`
+	TokensLimit       int64     `json:"tokensLimit"`
+	TokensRemaining   int64     `json:"tokensRemaining"`
+	TokensReset       time.Time `json:"tokensReset,omitempty"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// keyRateLimits 各密钥的限流额度
+type keyRateLimits struct {
+	mu     sync.Mutex
+	limits map[string]*KeyRateLimit
+}
+
+func newKeyRateLimits() *keyRateLimits {
+	return &keyRateLimits{
+		limits: make(map[string]*KeyRateLimit),
+	}
+}
+
+// update 合并最新的额度信息（上游未提供的字段保留旧值）
+func (k *keyRateLimits) update(apiKey string, info utils.RateLimitInfo) {
+	if !info.HasBudget() {
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	limit, exists := k.limits[apiKey]
+	if !exists {
+		limit = &KeyRateLimit{RequestsLimit: -1, RequestsRemaining: -1, TokensLimit: -1, TokensRemaining: -1}
+		k.limits[apiKey] = limit
+	}
+
+	if info.RequestsRemaining >= 0 {
+		limit.RequestsLimit = info.RequestsLimit
+		limit.RequestsRemaining = info.RequestsRemaining
+		limit.RequestsReset = info.RequestsReset
+	}
+	if info.TokensRemaining >= 0 {
+		limit.TokensLimit = info.TokensLimit
+		limit.TokensRemaining = info.TokensRemaining
+		limit.TokensReset = info.TokensReset
+	}
+	limit.UpdatedAt = time.Now()
+}
+
+// isLow 判断密钥额度是否即将耗尽（过期的额度信息不作数）
+func (k *keyRateLimits) isLow(apiKey string, now time.Time) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	limit, exists := k.limits[apiKey]
+	if !exists {
+		return false
+	}
+
+	return budgetLow(limit.RequestsRemaining, limit.RequestsLimit, limit.RequestsReset, limit.UpdatedAt, now) ||
+		budgetLow(limit.TokensRemaining, limit.TokensLimit, limit.TokensReset, limit.UpdatedAt, now)
+}
+
+// budgetLow 判断单项额度是否即将耗尽
+func budgetLow(remaining, limit int64, reset, updatedAt, now time.Time) bool {
+	if remaining < 0 {
+		return false
+	}
+	if reset.IsZero() {
+		if now.Sub(updatedAt) > budgetStaleAfter {
+			return false
+		}
+	} else if !now.Before(reset) {
+		return false
+	}
+
+	if remaining == 0 {
+		return true
+	}
+	return limit > 0 && float64(remaining) <= float64(limit)*lowBudgetRatio
+}
+
+// snapshot 获取密钥额度副本
+func (k *keyRateLimits) snapshot(apiKey string) (KeyRateLimit, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	limit, exists := k.limits[apiKey]
+	if !exists {
+		return KeyRateLimit{}, false
+	}
+	return *limit, true
+}
+
+// RecordKeyRateLimit 记录上游响应头中的剩余额度，用于在密钥耗尽前降低其优先级
+func (cm *ConfigManager) RecordKeyRateLimit(apiKey string, info utils.RateLimitInfo) {
+	cm.rateLimits.update(apiKey, info)
+}
+
+// GetKeyRateLimit 获取密钥最近一次观测到的限流额度
+func (cm *ConfigManager) GetKeyRateLimit(apiKey string) (KeyRateLimit, bool) {
+	return cm.rateLimits.snapshot(apiKey)
+}
+
+// preferKeysWithBudget 优先选择额度充足的密钥；若所有密钥额度都即将耗尽则原样返回
+func (cm *ConfigManager) preferKeysWithBudget(keys []string) []string {
+	now := time.Now()
+	healthy := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !cm.rateLimits.isLow(key, now) {
+			healthy = append(healthy, key)
+		}
+	}
+
+	if len(healthy) == 0 || len(healthy) == len(keys) {
+		return keys
+	}
+
+	log.Printf("📉 %d 个密钥剩余额度即将耗尽，优先使用其他密钥", len(keys)-len(healthy))
+	return healthy
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/claude-proxy/internal/utils"
+)
+
+func newTestConfigManager(upstreams ...UpstreamConfig) *ConfigManager {
+	return &ConfigManager{
+		config:          Config{Upstream: upstreams, LoadBalance: "failover"},
+		failedKeysCache: make(map[string]*FailedKey),
+		keyStates:       make(map[string]*keyStateRecord),
+		authFailures:    make(map[string]int),
+		keyRecoveryTime: keyRecoveryTime,
+		maxFailureCount: maxFailureCount,
+		balancer:        newChannelBalancer(),
+		breakers:        newCircuitBreakers(),
+		rateLimits:      newKeyRateLimits(),
+		stickyPins:      newStickySessions(),
+		usage:           newKeyUsages(),
+	}
+}
+
+func TestGetNextAPIKey_HonorsCooldown(t *testing.T) {
+	upstream := UpstreamConfig{Name: "relay", APIKeys: []string{"key-a", "key-b"}}
+	cm := newTestConfigManager(upstream)
+
+	// 上游要求短暂冷却：冷却期间跳过，到期后立即恢复
+	cm.MarkKeyAsFailedWithCooldown("key-a", 50*time.Millisecond)
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-b" {
+		t.Fatalf("冷却期间选择了 %s, want key-b", key)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-a" {
+		t.Fatalf("冷却结束后选择了 %s, want key-a", key)
+	}
+
+	// 所有密钥都在冷却时，选择最早恢复的密钥
+	cm.MarkKeyAsFailedWithCooldown("key-a", 2*time.Hour)
+	cm.MarkKeyAsFailedWithCooldown("key-b", 2*time.Second)
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-b" {
+		t.Fatalf("全部冷却时选择了 %s, want key-b", key)
+	}
+}
+
+func TestGetNextAPIKey_DeprioritizesLowBudget(t *testing.T) {
+	upstream := UpstreamConfig{Name: "relay", APIKeys: []string{"key-a", "key-b"}}
+	cm := newTestConfigManager(upstream)
+
+	cm.RecordKeyRateLimit("key-a", utils.RateLimitInfo{
+		RequestsLimit:     1000,
+		RequestsRemaining: 10,
+		RequestsReset:     time.Now().Add(time.Minute),
+		TokensLimit:       -1,
+		TokensRemaining:   -1,
+	})
+
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-b" {
+		t.Fatalf("额度即将耗尽时选择了 %s, want key-b", key)
+	}
+
+	// 其他密钥已失败时仍可使用额度较低的密钥
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{"key-b": true}); key != "key-a" {
+		t.Fatalf("仅剩低额度密钥时选择了 %s, want key-a", key)
+	}
+
+	// 额度重置后恢复正常优先级
+	cm.RecordKeyRateLimit("key-a", utils.RateLimitInfo{
+		RequestsLimit:     1000,
+		RequestsRemaining: 10,
+		RequestsReset:     time.Now().Add(-time.Second),
+		TokensLimit:       -1,
+		TokensRemaining:   -1,
+	})
+	if key, _ := cm.GetNextAPIKey(&upstream, map[string]bool{}); key != "key-a" {
+		t.Fatalf("额度重置后选择了 %s, want key-a", key)
+	}
+}
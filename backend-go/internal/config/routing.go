@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ============== 按模型路由渠道 ==============
+
+// buildFailoverChain 构建故障转移链
+func buildFailoverChain(upstreams []UpstreamConfig, current int, model string) []UpstreamCandidate {
+	chain := make([]UpstreamCandidate, 0, len(upstreams))
+	added := make(map[int]bool)
+
+	appendCandidate := func(index int) {
+		if added[index] {
+			return
+		}
+		added[index] = true
+		upstream := upstreams[index]
+		chain = append(chain, UpstreamCandidate{Index: index, Upstream: &upstream})
+	}
+
+	// 1. 按模型路由命中的渠道（按配置顺序）
+	if model != "" {
+		for i := range upstreams {
+			if UpstreamServesModel(&upstreams[i], model) && len(upstreams[i].Models) > 0 {
+				appendCandidate(i)
+			}
+		}
+	}
+
+	// 2. 无渠道命中时回退到当前渠道；当前渠道为通用渠道时同样优先
+	if len(chain) == 0 || len(upstreams[current].Models) == 0 {
+		appendCandidate(current)
+	}
+
+	// 3. 其余通用渠道作为兜底
+	for i := range upstreams {
+		if len(upstreams[i].Models) == 0 {
+			appendCandidate(i)
+		}
+	}
+
+	return chain
+}
+
+// UpstreamServesModel 判断渠道是否服务指定模型（未声明 models 的渠道服务所有模型）
+func UpstreamServesModel(upstream *UpstreamConfig, model string) bool {
+	if len(upstream.Models) == 0 {
+		return true
+	}
+
+	for _, pattern := range upstream.Models {
+		if MatchModelPattern(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeclaredModels 渠道配置中可列举的模型名称：模型映射的源名称和 models 中的精确名称（已排序去重）
+// 通配符和正则规则无法列举，不包含在内
+func DeclaredModels(upstream *UpstreamConfig) []string {
+	seen := make(map[string]bool)
+	models := []string{}
+	add := func(model string) {
+		if model != "" && !seen[model] {
+			seen[model] = true
+			models = append(models, model)
+		}
+	}
+
+	for source := range upstream.ModelMapping {
+		add(source)
+	}
+	for _, pattern := range upstream.Models {
+		if re, err := compileModelPattern(pattern); err == nil && re == nil {
+			add(pattern)
+		}
+	}
+
+	sort.Strings(models)
+	return models
+}
+
+// MatchModelPattern 模型匹配规则
+// 支持三种写法：
+// 1. 精确名称 - claude-3-5-haiku-20241022
+// 2. 通配符 - claude-3-5-haiku*、*opus*（* 匹配任意字符，? 匹配单个字符）
+// 3. 正则 - re:^claude-(opus|sonnet)-4
+func MatchModelPattern(pattern, model string) bool {
+	re, err := compileModelPattern(pattern)
+	if err != nil {
+		return false
+	}
+	if re == nil {
+		return pattern == model
+	}
+	return re.MatchString(model)
+}
+
+// modelPatternCache 已编译的模型匹配规则缓存
+var modelPatternCache sync.Map
+
+// compileModelPattern 编译模型匹配规则，精确名称返回 nil
+func compileModelPattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := modelPatternCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	var expr string
+	switch {
+	case strings.HasPrefix(pattern, "re:"):
+		expr = strings.TrimPrefix(pattern, "re:")
+	case strings.ContainsAny(pattern, "*?"):
+		var sb strings.Builder
+		sb.WriteString("^")
+		for _, r := range pattern {
+			switch r {
+			case '*':
+				sb.WriteString(".*")
+			case '?':
+				sb.WriteString(".")
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		sb.WriteString("$")
+		expr = sb.String()
+	default:
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	modelPatternCache.Store(pattern, re)
+	return re, nil
+}
+
+// validateModelPatterns 校验渠道声明的模型匹配规则
+func validateModelPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("无效的模型匹配规则: 不能为空")
+		}
+		if _, err := compileModelPattern(pattern); err != nil {
+			return fmt.Errorf("无效的模型匹配规则 %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// RouteChannel 路由表中的渠道摘要
+type RouteChannel struct {
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	ServiceType string `json:"serviceType"`
+}
+
+// RouteEntry 路由表条目
+type RouteEntry struct {
+	Pattern  string         `json:"pattern"`
+	Channels []RouteChannel `json:"channels"`
+}
+
+// RoutingTable 解析后的路由表
+type RoutingTable struct {
+	Routes   []RouteEntry   `json:"routes"`
+	Fallback []RouteChannel `json:"fallback"` // 未命中任何规则时的故障转移链
+}
+
+// ToRouteChannels 将故障转移链转换为路由表渠道摘要
+func ToRouteChannels(chain []UpstreamCandidate) []RouteChannel {
+	channels := make([]RouteChannel, 0, len(chain))
+	for _, candidate := range chain {
+		channels = append(channels, RouteChannel{
+			Index:       candidate.Index,
+			Name:        candidate.Upstream.Name,
+			ServiceType: candidate.Upstream.ServiceType,
+		})
+	}
+	return channels
+}
+
+// GetRoutingTable 获取 Messages 渠道的路由表
+func (cm *ConfigManager) GetRoutingTable() RoutingTable {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return buildRoutingTable(cm.config.Upstream, cm.config.CurrentUpstream)
+}
+
+// buildRoutingTable 按声明的模型规则汇总路由表（规则按首次出现顺序排列）
+func buildRoutingTable(upstreams []UpstreamConfig, current int) RoutingTable {
+	table := RoutingTable{
+		Routes:   []RouteEntry{},
+		Fallback: []RouteChannel{},
+	}
+	if len(upstreams) == 0 || current >= len(upstreams) {
+		return table
+	}
+
+	routeIndex := make(map[string]int)
+	for i := range upstreams {
+		for _, pattern := range upstreams[i].Models {
+			pos, exists := routeIndex[pattern]
+			if !exists {
+				pos = len(table.Routes)
+				routeIndex[pattern] = pos
+				table.Routes = append(table.Routes, RouteEntry{Pattern: pattern})
+			}
+			table.Routes[pos].Channels = append(table.Routes[pos].Channels, RouteChannel{
+				Index:       i,
+				Name:        upstreams[i].Name,
+				ServiceType: upstreams[i].ServiceType,
+			})
+		}
+	}
+
+	table.Fallback = ToRouteChannels(buildFailoverChain(upstreams, current, ""))
+	return table
+}
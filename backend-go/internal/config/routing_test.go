@@ -0,0 +1,98 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestMatchModelPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		model   string
+		want    bool
+	}{
+		{"精确匹配", "claude-3-5-haiku-20241022", "claude-3-5-haiku-20241022", true},
+		{"精确不匹配", "claude-3-5-haiku", "claude-3-5-haiku-20241022", false},
+		{"通配符前缀", "claude-3-5-haiku*", "claude-3-5-haiku-20241022", true},
+		{"通配符包含", "*opus*", "claude-opus-4-20250514", true},
+		{"通配符不匹配", "*opus*", "claude-sonnet-4-20250514", false},
+		{"单字符通配符", "gpt-4?", "gpt-4o", true},
+		{"通配符转义点号", "gpt-4.1*", "gpt-401", false},
+		{"正则匹配", "re:^claude-(opus|sonnet)-4", "claude-sonnet-4-20250514", true},
+		{"正则不匹配", "re:^claude-(opus|sonnet)-4", "claude-3-5-haiku", false},
+		{"无效正则", "re:([", "anything", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchModelPattern(tt.pattern, tt.model); got != tt.want {
+				t.Errorf("MatchModelPattern(%q, %q) = %v, want %v", tt.pattern, tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeclaredModels(t *testing.T) {
+	upstream := &UpstreamConfig{
+		ModelMapping: map[string]string{"opus": "claude-opus-4-20250514", "sonnet": "claude-sonnet-4-20250514"},
+		Models:       []string{"claude-3-5-haiku-20241022", "*opus*", "re:^gpt-", "sonnet"},
+	}
+
+	got := DeclaredModels(upstream)
+	want := []string{"claude-3-5-haiku-20241022", "opus", "sonnet"}
+	if len(got) != len(want) {
+		t.Fatalf("DeclaredModels() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("DeclaredModels()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBuildFailoverChain(t *testing.T) {
+	upstreams := []UpstreamConfig{
+		{Name: "official", Models: []string{"*opus*"}},
+		{Name: "relay-a"},
+		{Name: "cheap", Models: []string{"claude-3-5-haiku*"}},
+		{Name: "relay-b"},
+	}
+
+	tests := []struct {
+		name    string
+		current int
+		model   string
+		want    []int
+	}{
+		{"命中模型规则的渠道优先，通用渠道兜底", 1, "claude-3-5-haiku-20241022", []int{2, 1, 3}},
+		{"未命中任何规则回退到当前渠道", 1, "claude-sonnet-4", []int{1, 3}},
+		{"当前渠道声明了规则但未命中仍作为回退", 0, "claude-sonnet-4", []int{0, 1, 3}},
+		{"未指定模型", 3, "", []int{3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := buildFailoverChain(upstreams, tt.current, tt.model)
+			if len(chain) != len(tt.want) {
+				t.Fatalf("链长度 = %d, want %d", len(chain), len(tt.want))
+			}
+			for i, candidate := range chain {
+				if candidate.Index != tt.want[i] {
+					t.Errorf("chain[%d] = %d, want %d", i, candidate.Index, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateModelPatterns(t *testing.T) {
+	if err := validateModelPatterns([]string{"claude-*", "re:^gpt-4o$"}); err != nil {
+		t.Errorf("期望校验通过，实际得到错误: %v", err)
+	}
+	if err := validateModelPatterns([]string{"re:(["}); err == nil {
+		t.Error("期望无效正则校验失败")
+	}
+	if err := validateModelPatterns([]string{" "}); err == nil {
+		t.Error("期望空规则校验失败")
+	}
+}
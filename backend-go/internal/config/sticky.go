@@ -0,0 +1,183 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ============== 会话粘性路由 ==============
+
+const (
+	defaultStickyTTL    = 1 * time.Hour // 会话绑定的默认有效期（每次命中后顺延）
+	defaultStickyHeader = "X-Session-Id"
+	stickySweepInterval = 1 * time.Minute
+)
+
+// StickySessionConfig 会话粘性路由配置（config.json 中的 stickySession 字段）
+// 同一会话的连续请求固定使用同一渠道和密钥，以保证上游提示词缓存命中
+type StickySessionConfig struct {
+	Enabled    *bool  `json:"enabled,omitempty"`    // 是否启用，默认启用
+	TTLSeconds int    `json:"ttlSeconds,omitempty"` // 绑定有效期（秒），默认 3600
+	Header     string `json:"header,omitempty"`     // 客户端指定会话标识的请求头，默认 X-Session-Id
+}
+
+// IsEnabled 是否启用会话粘性路由
+func (s StickySessionConfig) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// withDefaults 补全会话粘性配置默认值
+func (s StickySessionConfig) withDefaults() StickySessionConfig {
+	if s.TTLSeconds <= 0 {
+		s.TTLSeconds = int(defaultStickyTTL / time.Second)
+	}
+	if s.Header == "" {
+		s.Header = defaultStickyHeader
+	}
+	return s
+}
+
+// stickyPin 会话与密钥（或渠道）的绑定
+type stickyPin struct {
+	value     string // 绑定的密钥或渠道标识
+	expiresAt time.Time
+}
+
+// stickySessions 会话绑定表
+// 密钥绑定的键为 渠道标识|会话标识，渠道绑定的键为 channel|模型|会话标识（提示词缓存按模型区分）
+type stickySessions struct {
+	mu        sync.Mutex
+	pins      map[string]*stickyPin
+	lastSweep time.Time
+}
+
+func newStickySessions() *stickySessions {
+	return &stickySessions{
+		pins:      make(map[string]*stickyPin),
+		lastSweep: time.Now(),
+	}
+}
+
+// get 获取未过期的绑定
+func (s *stickySessions) get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pin, exists := s.pins[key]
+	if !exists || time.Now().After(pin.expiresAt) {
+		return "", false
+	}
+	return pin.value, true
+}
+
+// pin 绑定（或续期）会话，并顺带清理过期绑定
+func (s *stickySessions) pin(key, value string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.pins[key] = &stickyPin{value: value, expiresAt: now.Add(ttl)}
+
+	if now.Sub(s.lastSweep) < stickySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for k, p := range s.pins {
+		if now.After(p.expiresAt) {
+			delete(s.pins, k)
+		}
+	}
+}
+
+// count 当前绑定数量（含尚未清理的过期绑定）
+func (s *stickySessions) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pins)
+}
+
+// GetStickySessionConfig 获取会话粘性路由配置（含默认值）
+func (cm *ConfigManager) GetStickySessionConfig() StickySessionConfig {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.config.StickySession == nil {
+		return StickySessionConfig{}.withDefaults()
+	}
+	return cm.config.StickySession.withDefaults()
+}
+
+// GetAPIKeyForSession 按会话获取 API 密钥
+// 会话已绑定且密钥仍可用时返回绑定的密钥，否则按负载均衡策略重新选择
+func (cm *ConfigManager) GetAPIKeyForSession(upstream *UpstreamConfig, failedKeys map[string]bool, sessionID string) (string, error) {
+	if sessionID == "" || !cm.GetStickySessionConfig().IsEnabled() {
+		return cm.GetNextAPIKey(upstream, failedKeys)
+	}
+
+	if apiKey, ok := cm.stickyPins.get(channelKey(upstream) + "|" + sessionID); ok {
+		if containsKey(upstream.APIKeys, apiKey) && !failedKeys[apiKey] && !cm.isKeyFailed(apiKey) && !cm.isKeyBlocked(apiKey) && !cm.isKeyOverBudget(upstream, apiKey) {
+			log.Printf("📌 会话粘性路由命中密钥 %s", maskAPIKey(apiKey))
+			return apiKey, nil
+		}
+		log.Printf("📌 会话绑定的密钥 %s 不可用，重新选择密钥", maskAPIKey(apiKey))
+	}
+
+	return cm.GetNextAPIKey(upstream, failedKeys)
+}
+
+// PinSessionKey 请求成功后将会话绑定到该密钥（已绑定时顺延有效期）
+func (cm *ConfigManager) PinSessionKey(upstream *UpstreamConfig, sessionID, apiKey string) {
+	if sessionID == "" {
+		return
+	}
+
+	cfg := cm.GetStickySessionConfig()
+	if !cfg.IsEnabled() {
+		return
+	}
+	cm.stickyPins.pin(channelKey(upstream)+"|"+sessionID, apiKey, time.Duration(cfg.TTLSeconds)*time.Second)
+}
+
+// PinSessionChannel 请求成功后将会话（按模型）绑定到该渠道
+// 启用渠道负载均衡时，后续请求的故障转移链以该渠道开头，避免会话在渠道间漂移而丢失提示词缓存
+func (cm *ConfigManager) PinSessionChannel(upstream *UpstreamConfig, model, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	cfg := cm.GetStickySessionConfig()
+	if !cfg.IsEnabled() {
+		return
+	}
+	cm.stickyPins.pin(sessionChannelPinKey(model, sessionID), channelKey(upstream), time.Duration(cfg.TTLSeconds)*time.Second)
+}
+
+// sessionChannel 获取会话绑定的渠道标识，未绑定时返回空字符串
+func (cm *ConfigManager) sessionChannel(model, sessionID string) string {
+	if sessionID == "" || !cm.GetStickySessionConfig().IsEnabled() {
+		return ""
+	}
+	pinned, _ := cm.stickyPins.get(sessionChannelPinKey(model, sessionID))
+	return pinned
+}
+
+// sessionChannelPinKey 会话渠道绑定的键
+func sessionChannelPinKey(model, sessionID string) string {
+	return "channel|" + model + "|" + sessionID
+}
+
+// GetStickySessionCount 获取当前会话绑定数量
+func (cm *ConfigManager) GetStickySessionCount() int {
+	return cm.stickyPins.count()
+}
+
+// containsKey 判断密钥是否仍在渠道的密钥列表中
+func containsKey(keys []string, apiKey string) bool {
+	for _, key := range keys {
+		if key == apiKey {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetAPIKeyForSession(t *testing.T) {
+	upstream := UpstreamConfig{Name: "relay", APIKeys: []string{"key-a", "key-b", "key-c"}}
+	cm := newTestConfigManager(upstream)
+	cm.config.LoadBalance = "round-robin"
+
+	// 同一会话在 round-robin 下仍固定命中绑定的密钥
+	first, _ := cm.GetAPIKeyForSession(&upstream, map[string]bool{}, "session-1")
+	cm.PinSessionKey(&upstream, "session-1", first)
+	for i := 0; i < 5; i++ {
+		if key, _ := cm.GetAPIKeyForSession(&upstream, map[string]bool{}, "session-1"); key != first {
+			t.Fatalf("第 %d 次请求命中 %s, want %s", i+1, key, first)
+		}
+	}
+
+	// 绑定的密钥在本次请求中失败时，回退到其他密钥并重新绑定
+	fallback, _ := cm.GetAPIKeyForSession(&upstream, map[string]bool{first: true}, "session-1")
+	if fallback == first {
+		t.Fatalf("绑定的密钥失败后仍返回 %s", first)
+	}
+	cm.PinSessionKey(&upstream, "session-1", fallback)
+
+	// 绑定的密钥进入冷却时同样回退
+	cm.MarkKeyAsFailed(fallback)
+	if key, _ := cm.GetAPIKeyForSession(&upstream, map[string]bool{}, "session-1"); key == fallback {
+		t.Fatalf("冷却中的绑定密钥不应被使用")
+	}
+
+	// 绑定过期后不再生效
+	cm.stickyPins.pin(channelKey(&upstream)+"|session-2", "key-c", -time.Second)
+	cm.config.LoadBalance = "failover"
+	if key, _ := cm.GetAPIKeyForSession(&upstream, map[string]bool{}, "session-2"); key != "key-a" {
+		t.Fatalf("过期绑定仍生效: got %s", key)
+	}
+}
+
+func TestGetUpstreamFailoverChain_SessionChannelPin(t *testing.T) {
+	cm := newTestConfigManager(
+		UpstreamConfig{Name: "heavy", BaseURL: "https://heavy.example.com", Weight: 50, APIKeys: []string{"key-a"}},
+		UpstreamConfig{Name: "medium", BaseURL: "https://medium.example.com", Weight: 10, APIKeys: []string{"key-b"}},
+		UpstreamConfig{Name: "light", BaseURL: "https://light.example.com", Weight: 1, APIKeys: []string{"key-c"}},
+	)
+	cm.config.ChannelLoadBalance = ChannelStrategyWeighted
+
+	// 会话首次成功落在权重最低的渠道后，后续请求始终以该渠道开头，其余渠道照常参与故障转移
+	light := cm.config.Upstream[2]
+	cm.PinSessionChannel(&light, "claude-sonnet", "session-1")
+	for i := 0; i < 200; i++ {
+		chain, err := cm.GetUpstreamFailoverChain("claude-sonnet", "session-1")
+		if err != nil {
+			t.Fatalf("获取故障转移链失败: %v", err)
+		}
+		if len(chain) != 3 || chain[0].Upstream.Name != "light" {
+			t.Fatalf("第 %d 次请求首选 %s, want light", i+1, chain[0].Upstream.Name)
+		}
+	}
+
+	// 绑定按模型区分；未绑定的会话仍按权重分配
+	first := map[string]int{}
+	for i := 0; i < 200; i++ {
+		chain, _ := cm.GetUpstreamFailoverChain("claude-haiku", "session-1")
+		first[chain[0].Upstream.Name]++
+	}
+	if first["heavy"] < first["light"] {
+		t.Errorf("未绑定的模型应按权重分配: %v", first)
+	}
+
+	// failover 策略下保持原有顺序（当前渠道优先），渠道恢复后会话回到主渠道
+	cm.config.ChannelLoadBalance = ChannelStrategyFailover
+	if chain, _ := cm.GetUpstreamFailoverChain("claude-sonnet", "session-1"); chain[0].Upstream.Name != "heavy" {
+		t.Errorf("failover 策略首选 %s, want heavy", chain[0].Upstream.Name)
+	}
+}
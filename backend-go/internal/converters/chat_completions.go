@@ -0,0 +1,592 @@
+package converters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ============== OpenAI Chat Completions 入口转换 ==============
+
+// defaultChatMaxTokens Chat Completions 请求未指定 max_tokens 时使用的默认值（Claude 要求必填）
+const defaultChatMaxTokens = 8192
+
+// ChatCompletionsToClaudeRequest 将 OpenAI Chat Completions 请求转换为 Claude Messages 请求
+// 使用 map 构建请求体，保留 top_p、stop_sequences 等 ClaudeRequest 未声明的字段
+func ChatCompletionsToClaudeRequest(body []byte) (map[string]interface{}, error) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("解析 Chat Completions 请求失败: %w", err)
+	}
+
+	model, _ := req["model"].(string)
+	if model == "" {
+		return nil, fmt.Errorf("缺少 model 字段")
+	}
+	rawMessages, ok := req["messages"].([]interface{})
+	if !ok || len(rawMessages) == 0 {
+		return nil, fmt.Errorf("messages 不能为空")
+	}
+
+	claudeReq := map[string]interface{}{
+		"model": model,
+	}
+
+	system, messages, err := chatMessagesToClaude(rawMessages)
+	if err != nil {
+		return nil, err
+	}
+	claudeReq["messages"] = messages
+	if system != "" {
+		claudeReq["system"] = system
+	}
+
+	maxTokens := defaultChatMaxTokens
+	if v, ok := req["max_completion_tokens"].(float64); ok && v > 0 {
+		maxTokens = int(v)
+	} else if v, ok := req["max_tokens"].(float64); ok && v > 0 {
+		maxTokens = int(v)
+	}
+	claudeReq["max_tokens"] = maxTokens
+
+	if v, ok := req["temperature"].(float64); ok {
+		claudeReq["temperature"] = v
+	}
+	if v, ok := req["top_p"].(float64); ok {
+		claudeReq["top_p"] = v
+	}
+	if stream, ok := req["stream"].(bool); ok && stream {
+		claudeReq["stream"] = true
+	}
+
+	switch stop := req["stop"].(type) {
+	case string:
+		claudeReq["stop_sequences"] = []string{stop}
+	case []interface{}:
+		if len(stop) > 0 {
+			claudeReq["stop_sequences"] = stop
+		}
+	}
+
+	if user, ok := req["user"].(string); ok && user != "" {
+		claudeReq["metadata"] = map[string]interface{}{"user_id": user}
+	}
+
+	if rawTools, ok := req["tools"].([]interface{}); ok && len(rawTools) > 0 {
+		claudeReq["tools"] = chatToolsToClaude(rawTools)
+
+		parallel, hasParallel := req["parallel_tool_calls"].(bool)
+		if toolChoice := chatToolChoiceToClaude(req["tool_choice"]); toolChoice != nil {
+			if hasParallel && !parallel {
+				toolChoice["disable_parallel_tool_use"] = true
+			}
+			claudeReq["tool_choice"] = toolChoice
+		} else if hasParallel && !parallel {
+			claudeReq["tool_choice"] = map[string]interface{}{"type": "auto", "disable_parallel_tool_use": true}
+		}
+	}
+
+	return claudeReq, nil
+}
+
+// chatMessagesToClaude 转换消息列表：system / developer 消息合并为系统提示词，
+// tool 消息转换为 user 消息中的 tool_result 块，相邻的同角色消息合并
+func chatMessagesToClaude(rawMessages []interface{}) (string, []map[string]interface{}, error) {
+	var systemParts []string
+	messages := []map[string]interface{}{}
+
+	appendBlocks := func(role string, blocks []interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+			messages[n-1]["content"] = append(messages[n-1]["content"].([]interface{}), blocks...)
+			return
+		}
+		messages = append(messages, map[string]interface{}{"role": role, "content": blocks})
+	}
+
+	for i, raw := range rawMessages {
+		msg, ok := raw.(map[string]interface{})
+		if !ok {
+			return "", nil, fmt.Errorf("messages[%d] 格式无效", i)
+		}
+		role, _ := msg["role"].(string)
+
+		switch role {
+		case "system", "developer":
+			if text := chatContentText(msg["content"]); text != "" {
+				systemParts = append(systemParts, text)
+			}
+
+		case "user":
+			appendBlocks("user", chatContentToClaudeBlocks(msg["content"]))
+
+		case "assistant":
+			blocks := chatContentToClaudeBlocks(msg["content"])
+			if toolCalls, ok := msg["tool_calls"].([]interface{}); ok {
+				for _, tc := range toolCalls {
+					if block := chatToolCallToClaude(tc); block != nil {
+						blocks = append(blocks, block)
+					}
+				}
+			}
+			appendBlocks("assistant", blocks)
+
+		case "tool", "function":
+			toolCallID, _ := msg["tool_call_id"].(string)
+			appendBlocks("user", []interface{}{map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": toolCallID,
+				"content":     chatContentText(msg["content"]),
+			}})
+
+		default:
+			return "", nil, fmt.Errorf("messages[%d] 的角色 %q 不受支持", i, role)
+		}
+	}
+
+	if len(messages) == 0 {
+		return "", nil, fmt.Errorf("messages 中没有可发送的对话消息")
+	}
+	return strings.Join(systemParts, "\n\n"), messages, nil
+}
+
+// chatContentText 提取消息内容中的文本（字符串或 text 片段数组）
+func chatContentText(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if part, ok := item.(map[string]interface{}); ok && part["type"] == "text" {
+				if text, ok := part["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// chatContentToClaudeBlocks 将消息内容转换为 Claude 内容块（支持文本和图片）
+func chatContentToClaudeBlocks(content interface{}) []interface{} {
+	blocks := []interface{}{}
+
+	switch v := content.(type) {
+	case string:
+		if v != "" {
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": v})
+		}
+	case []interface{}:
+		for _, item := range v {
+			part, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch part["type"] {
+			case "text":
+				if text, _ := part["text"].(string); text != "" {
+					blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+				}
+			case "image_url":
+				imageURL, _ := part["image_url"].(map[string]interface{})
+				url, _ := imageURL["url"].(string)
+				if source := imageURLToClaudeSource(url); source != nil {
+					blocks = append(blocks, map[string]interface{}{"type": "image", "source": source})
+				}
+			}
+		}
+	}
+
+	return blocks
+}
+
+// imageURLToClaudeSource 将图片 URL（含 data: URL）转换为 Claude 图片来源
+func imageURLToClaudeSource(url string) map[string]interface{} {
+	if url == "" {
+		return nil
+	}
+
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		meta, data, found := strings.Cut(rest, ",")
+		mediaType, isBase64 := strings.CutSuffix(meta, ";base64")
+		if !found || !isBase64 {
+			return nil
+		}
+		return map[string]interface{}{
+			"type":       "base64",
+			"media_type": mediaType,
+			"data":       data,
+		}
+	}
+
+	return map[string]interface{}{"type": "url", "url": url}
+}
+
+// chatToolCallToClaude 将 assistant 消息中的工具调用转换为 tool_use 块
+func chatToolCallToClaude(raw interface{}) map[string]interface{} {
+	toolCall, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	function, _ := toolCall["function"].(map[string]interface{})
+	id, _ := toolCall["id"].(string)
+	name, _ := function["name"].(string)
+	arguments, _ := function["arguments"].(string)
+
+	input := map[string]interface{}{}
+	if arguments != "" {
+		_ = json.Unmarshal([]byte(arguments), &input)
+	}
+
+	return map[string]interface{}{
+		"type":  "tool_use",
+		"id":    id,
+		"name":  name,
+		"input": input,
+	}
+}
+
+// chatToolsToClaude 转换工具定义
+func chatToolsToClaude(rawTools []interface{}) []map[string]interface{} {
+	tools := []map[string]interface{}{}
+	for _, raw := range rawTools {
+		tool, ok := raw.(map[string]interface{})
+		if !ok || tool["type"] != "function" {
+			continue
+		}
+		function, _ := tool["function"].(map[string]interface{})
+		name, _ := function["name"].(string)
+		if name == "" {
+			continue
+		}
+
+		schema := function["parameters"]
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+
+		claudeTool := map[string]interface{}{
+			"name":         name,
+			"input_schema": schema,
+		}
+		if description, ok := function["description"].(string); ok && description != "" {
+			claudeTool["description"] = description
+		}
+		tools = append(tools, claudeTool)
+	}
+	return tools
+}
+
+// chatToolChoiceToClaude 转换 tool_choice：auto / required / none / 指定函数
+func chatToolChoiceToClaude(raw interface{}) map[string]interface{} {
+	switch v := raw.(type) {
+	case string:
+		switch v {
+		case "auto":
+			return map[string]interface{}{"type": "auto"}
+		case "required":
+			return map[string]interface{}{"type": "any"}
+		case "none":
+			return map[string]interface{}{"type": "none"}
+		}
+	case map[string]interface{}:
+		if function, ok := v["function"].(map[string]interface{}); ok {
+			if name, _ := function["name"].(string); name != "" {
+				return map[string]interface{}{"type": "tool", "name": name}
+			}
+		}
+	}
+	return nil
+}
+
+// ClaudeStopReasonToFinishReason 将 Claude stop_reason 转换为 OpenAI finish_reason
+func ClaudeStopReasonToFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// ClaudeResponseToChatCompletion 将 Claude 响应转换为 Chat Completions 响应
+func ClaudeResponseToChatCompletion(resp *types.ClaudeResponse, model string) map[string]interface{} {
+	var texts []string
+	toolCalls := []map[string]interface{}{}
+
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			texts = append(texts, block.Text)
+		case "tool_use":
+			arguments, _ := json.Marshal(block.Input)
+			if block.Input == nil {
+				arguments = []byte("{}")
+			}
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":   block.ID,
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      block.Name,
+					"arguments": string(arguments),
+				},
+			})
+		}
+	}
+
+	message := map[string]interface{}{
+		"role":    "assistant",
+		"content": nil,
+	}
+	if len(texts) > 0 {
+		message["content"] = strings.Join(texts, "")
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+
+	result := map[string]interface{}{
+		"id":      chatCompletionID(resp.ID),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []map[string]interface{}{
+			{
+				"index":         0,
+				"message":       message,
+				"finish_reason": ClaudeStopReasonToFinishReason(resp.StopReason),
+			},
+		},
+	}
+
+	if resp.Usage != nil {
+		prompt := resp.Usage.InputTokens + resp.Usage.PromptTokens
+		completion := resp.Usage.OutputTokens + resp.Usage.CompletionTokens
+		result["usage"] = map[string]interface{}{
+			"prompt_tokens":     prompt,
+			"completion_tokens": completion,
+			"total_tokens":      prompt + completion,
+		}
+	}
+
+	return result
+}
+
+// chatCompletionID 生成 Chat Completions 响应 ID
+func chatCompletionID(claudeID string) string {
+	if claudeID == "" {
+		return fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+	return "chatcmpl-" + strings.TrimPrefix(claudeID, "msg_")
+}
+
+// ChatCompletionsStreamConverter 将 Claude 流式事件转换为 Chat Completions 流式数据块
+type ChatCompletionsStreamConverter struct {
+	model        string
+	includeUsage bool
+	id           string
+	created      int64
+
+	toolIndexes   map[int]int // Claude 内容块索引 -> tool_calls 索引
+	finishEmitted bool
+	done          bool
+	inputTokens   int64
+	outputTokens  int64
+}
+
+// NewChatCompletionsStreamConverter 创建流式转换器
+// includeUsage 对应请求中的 stream_options.include_usage，为 true 时在结束前输出用量数据块
+func NewChatCompletionsStreamConverter(model string, includeUsage bool) *ChatCompletionsStreamConverter {
+	return &ChatCompletionsStreamConverter{
+		model:        model,
+		includeUsage: includeUsage,
+		id:           chatCompletionID(""),
+		created:      time.Now().Unix(),
+		toolIndexes:  make(map[int]int),
+	}
+}
+
+// Convert 转换一个 Claude 事件（可能包含多行），返回需要输出的 SSE 数据块
+func (s *ChatCompletionsStreamConverter) Convert(event string) []string {
+	var chunks []string
+	for _, line := range strings.Split(event, "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &payload); err != nil {
+			continue
+		}
+		chunks = append(chunks, s.convertPayload(payload)...)
+	}
+	return chunks
+}
+
+// convertPayload 按事件类型转换
+func (s *ChatCompletionsStreamConverter) convertPayload(payload map[string]interface{}) []string {
+	if s.done {
+		return nil
+	}
+
+	switch payload["type"] {
+	case "message_start":
+		if message, ok := payload["message"].(map[string]interface{}); ok {
+			if id, _ := message["id"].(string); id != "" {
+				s.id = chatCompletionID(id)
+			}
+			s.recordUsage(message["usage"])
+		}
+		return []string{s.chunk(map[string]interface{}{"role": "assistant", "content": ""}, nil)}
+
+	case "content_block_start":
+		block, _ := payload["content_block"].(map[string]interface{})
+		if block["type"] != "tool_use" {
+			return nil
+		}
+		index := len(s.toolIndexes)
+		s.toolIndexes[jsonInt(payload["index"])] = index
+		return []string{s.chunk(map[string]interface{}{
+			"tool_calls": []map[string]interface{}{{
+				"index": index,
+				"id":    block["id"],
+				"type":  "function",
+				"function": map[string]interface{}{
+					"name":      block["name"],
+					"arguments": "",
+				},
+			}},
+		}, nil)}
+
+	case "content_block_delta":
+		delta, _ := payload["delta"].(map[string]interface{})
+		switch delta["type"] {
+		case "text_delta":
+			return []string{s.chunk(map[string]interface{}{"content": delta["text"]}, nil)}
+		case "input_json_delta":
+			index, ok := s.toolIndexes[jsonInt(payload["index"])]
+			if !ok {
+				return nil
+			}
+			return []string{s.chunk(map[string]interface{}{
+				"tool_calls": []map[string]interface{}{{
+					"index":    index,
+					"function": map[string]interface{}{"arguments": delta["partial_json"]},
+				}},
+			}, nil)}
+		}
+
+	case "message_delta":
+		s.recordUsage(payload["usage"])
+		delta, _ := payload["delta"].(map[string]interface{})
+		stopReason, _ := delta["stop_reason"].(string)
+		if stopReason == "" || s.finishEmitted {
+			return nil
+		}
+		s.finishEmitted = true
+		finishReason := ClaudeStopReasonToFinishReason(stopReason)
+		return []string{s.chunk(map[string]interface{}{}, &finishReason)}
+
+	case "message_stop":
+		return s.Finish()
+
+	case "error":
+		s.done = true
+		errObj, _ := payload["error"].(map[string]interface{})
+		data, _ := json.Marshal(map[string]interface{}{"error": errObj})
+		return []string{fmt.Sprintf("data: %s\n\n", data), "data: [DONE]\n\n"}
+	}
+
+	return nil
+}
+
+// Finish 结束流：补发 finish_reason（上游未提供时）、用量数据块和 [DONE]
+func (s *ChatCompletionsStreamConverter) Finish() []string {
+	if s.done {
+		return nil
+	}
+	s.done = true
+
+	var chunks []string
+	if !s.finishEmitted {
+		s.finishEmitted = true
+		finishReason := "stop"
+		if len(s.toolIndexes) > 0 {
+			finishReason = "tool_calls"
+		}
+		chunks = append(chunks, s.chunk(map[string]interface{}{}, &finishReason))
+	}
+
+	if s.includeUsage {
+		data, _ := json.Marshal(map[string]interface{}{
+			"id":      s.id,
+			"object":  "chat.completion.chunk",
+			"created": s.created,
+			"model":   s.model,
+			"choices": []interface{}{},
+			"usage": map[string]interface{}{
+				"prompt_tokens":     s.inputTokens,
+				"completion_tokens": s.outputTokens,
+				"total_tokens":      s.inputTokens + s.outputTokens,
+			},
+		})
+		chunks = append(chunks, fmt.Sprintf("data: %s\n\n", data))
+	}
+
+	return append(chunks, "data: [DONE]\n\n")
+}
+
+// chunk 构造一个 chat.completion.chunk 数据块
+func (s *ChatCompletionsStreamConverter) chunk(delta map[string]interface{}, finishReason *string) string {
+	choice := map[string]interface{}{
+		"index":         0,
+		"delta":         delta,
+		"finish_reason": nil,
+	}
+	if finishReason != nil {
+		choice["finish_reason"] = *finishReason
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":      s.id,
+		"object":  "chat.completion.chunk",
+		"created": s.created,
+		"model":   s.model,
+		"choices": []interface{}{choice},
+	})
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+// recordUsage 记录 message_start / message_delta 中的用量（均为累计值）
+func (s *ChatCompletionsStreamConverter) recordUsage(raw interface{}) {
+	usage, ok := raw.(map[string]interface{})
+	if !ok {
+		return
+	}
+	input := int64(jsonInt(usage["input_tokens"]) + jsonInt(usage["cache_creation_input_tokens"]) + jsonInt(usage["cache_read_input_tokens"]))
+	if input > 0 {
+		s.inputTokens = input
+	}
+	if output := int64(jsonInt(usage["output_tokens"])); output > 0 {
+		s.outputTokens = output
+	}
+}
+
+// jsonInt 读取 JSON 数字
+func jsonInt(v interface{}) int {
+	if f, ok := v.(float64); ok {
+		return int(f)
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+package converters
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+func TestChatCompletionsToClaudeRequest(t *testing.T) {
+	body := `{
+		"model": "claude-sonnet-4",
+		"max_tokens": 512,
+		"stop": "END",
+		"user": "u-1",
+		"stream": true,
+		"messages": [
+			{"role": "system", "content": "You are helpful."},
+			{"role": "user", "content": [
+				{"type": "text", "text": "What is in this image?"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA"}}
+			]},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"cat\"}"}},
+				{"id": "call_2", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"dog\"}"}}
+			]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "a cat"},
+			{"role": "tool", "tool_call_id": "call_2", "content": "a dog"}
+		],
+		"tools": [{"type": "function", "function": {"name": "lookup", "parameters": {"type": "object"}}}],
+		"tool_choice": "required",
+		"parallel_tool_calls": false
+	}`
+
+	req, err := ChatCompletionsToClaudeRequest([]byte(body))
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+
+	if req["system"] != "You are helpful." {
+		t.Errorf("system = %v", req["system"])
+	}
+	if req["max_tokens"] != 512 || req["stream"] != true {
+		t.Errorf("max_tokens = %v, stream = %v", req["max_tokens"], req["stream"])
+	}
+	if stops, _ := req["stop_sequences"].([]string); len(stops) != 1 || stops[0] != "END" {
+		t.Errorf("stop_sequences = %v", req["stop_sequences"])
+	}
+	if metadata, _ := req["metadata"].(map[string]interface{}); metadata["user_id"] != "u-1" {
+		t.Errorf("metadata = %v", req["metadata"])
+	}
+
+	toolChoice, _ := req["tool_choice"].(map[string]interface{})
+	if toolChoice["type"] != "any" || toolChoice["disable_parallel_tool_use"] != true {
+		t.Errorf("tool_choice = %v", toolChoice)
+	}
+
+	messages := req["messages"].([]map[string]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("期望 3 条消息（连续的 tool 消息合并），实际 %d", len(messages))
+	}
+
+	userBlocks := messages[0]["content"].([]interface{})
+	image := userBlocks[1].(map[string]interface{})
+	source := image["source"].(map[string]interface{})
+	if image["type"] != "image" || source["media_type"] != "image/png" || source["data"] != "AAAA" {
+		t.Errorf("图片块转换错误: %v", image)
+	}
+
+	assistantBlocks := messages[1]["content"].([]interface{})
+	toolUse := assistantBlocks[0].(map[string]interface{})
+	if len(assistantBlocks) != 2 || toolUse["type"] != "tool_use" || toolUse["id"] != "call_1" {
+		t.Errorf("tool_use 块转换错误: %v", assistantBlocks)
+	}
+	if input := toolUse["input"].(map[string]interface{}); input["q"] != "cat" {
+		t.Errorf("tool_use input = %v", input)
+	}
+
+	results := messages[2]["content"].([]interface{})
+	if messages[2]["role"] != "user" || len(results) != 2 {
+		t.Fatalf("tool_result 消息转换错误: %v", messages[2])
+	}
+	if result := results[1].(map[string]interface{}); result["tool_use_id"] != "call_2" || result["content"] != "a dog" {
+		t.Errorf("tool_result = %v", result)
+	}
+}
+
+func TestChatCompletionsToClaudeRequest_Invalid(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`{"messages": [{"role": "user", "content": "hi"}]}`,
+		`{"model": "m", "messages": []}`,
+		`{"model": "m", "messages": [{"role": "system", "content": "only system"}]}`,
+	}
+	for _, body := range tests {
+		if _, err := ChatCompletionsToClaudeRequest([]byte(body)); err == nil {
+			t.Errorf("期望转换失败: %s", body)
+		}
+	}
+}
+
+func TestClaudeResponseToChatCompletion(t *testing.T) {
+	resp := &types.ClaudeResponse{
+		ID: "msg_abc",
+		Content: []types.ClaudeContent{
+			{Type: "text", Text: "Let me check."},
+			{Type: "tool_use", ID: "toolu_1", Name: "lookup", Input: map[string]interface{}{"q": "cat"}},
+		},
+		StopReason: "tool_use",
+		Usage:      &types.Usage{InputTokens: 10, OutputTokens: 5},
+	}
+
+	result := ClaudeResponseToChatCompletion(resp, "gpt-4o")
+	data, _ := json.Marshal(result)
+
+	var parsed struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	if parsed.ID != "chatcmpl-abc" || parsed.Object != "chat.completion" || parsed.Model != "gpt-4o" {
+		t.Errorf("响应头字段错误: %s", data)
+	}
+	choice := parsed.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.Content != "Let me check." {
+		t.Errorf("choice = %+v", choice)
+	}
+	if len(choice.Message.ToolCalls) != 1 || choice.Message.ToolCalls[0].Function.Arguments != `{"q":"cat"}` {
+		t.Errorf("tool_calls = %+v", choice.Message.ToolCalls)
+	}
+	if parsed.Usage.TotalTokens != 15 {
+		t.Errorf("total_tokens = %d", parsed.Usage.TotalTokens)
+	}
+}
+
+func TestChatCompletionsStreamConverter(t *testing.T) {
+	events := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":7}}}\n\n",
+		"data: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n",
+		"data: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"lookup\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"q\\\":1}\"}}\n",
+		"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":3}}\n",
+		"data: {\"type\":\"message_stop\"}\n",
+	}
+
+	converter := NewChatCompletionsStreamConverter("gpt-4o", true)
+	var output []string
+	for _, event := range events {
+		output = append(output, converter.Convert(event)...)
+	}
+	output = append(output, converter.Finish()...)
+
+	joined := strings.Join(output, "")
+	for _, want := range []string{
+		`"role":"assistant"`,
+		`"content":"Hi"`,
+		`"id":"toolu_1"`,
+		`"arguments":"{\"q\":1}"`,
+		`"finish_reason":"tool_calls"`,
+		`"total_tokens":10`,
+		`"id":"chatcmpl-1"`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("输出缺少 %s:\n%s", want, joined)
+		}
+	}
+	if !strings.HasSuffix(joined, "data: [DONE]\n\n") || strings.Count(joined, "[DONE]") != 1 {
+		t.Errorf("[DONE] 输出错误:\n%s", joined)
+	}
+
+	// 上游未提供 stop_reason 时（如 OpenAI 渠道的普通结束），Finish 补发 finish_reason
+	converter = NewChatCompletionsStreamConverter("gpt-4o", false)
+	converter.Convert(events[2])
+	if finish := strings.Join(converter.Finish(), ""); !strings.Contains(finish, `"finish_reason":"stop"`) {
+		t.Errorf("未补发 finish_reason:\n%s", finish)
+	}
+}
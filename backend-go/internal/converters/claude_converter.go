@@ -52,6 +52,14 @@ func (c *ClaudeConverter) FromProviderResponse(resp map[string]interface{}, sess
 	return ClaudeResponseToResponses(resp, sessionID)
 }
 
+// NewStreamConverter 创建 Claude SSE → Responses 事件的流式转换器
+func (c *ClaudeConverter) NewStreamConverter(model, previousResponseID string) ResponsesStreamConverter {
+	return &claudeResponsesStream{
+		responsesStreamState: newResponsesStreamState(model, previousResponseID),
+		toolCalls:            make(map[int]*responsesOutputItem),
+	}
+}
+
 // GetProviderName 获取上游服务名称
 func (c *ClaudeConverter) GetProviderName() string {
 	return "Claude Messages API"
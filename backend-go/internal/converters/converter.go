@@ -16,6 +16,10 @@ type ResponsesConverter interface {
 	// 返回：Responses 响应、错误
 	FromProviderResponse(resp map[string]interface{}, sessionID string) (*types.ResponsesResponse, error)
 
+	// NewStreamConverter 创建流式转换器，将上游 SSE 流转换为 Responses 事件
+	// model 和 previousResponseID 来自客户端请求，用于填写 response 对象
+	NewStreamConverter(model, previousResponseID string) ResponsesStreamConverter
+
 	// GetProviderName 获取上游服务名称（用于日志和调试）
 	GetProviderName() string
 }
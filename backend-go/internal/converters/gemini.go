@@ -0,0 +1,556 @@
+package converters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ============== Gemini generateContent 入口转换 ==============
+
+// defaultGeminiMaxTokens Gemini 请求未指定 maxOutputTokens 时使用的默认值（Claude 要求必填）
+const defaultGeminiMaxTokens = 8192
+
+// GeminiToClaudeRequest 将 Gemini generateContent 请求转换为 Claude Messages 请求
+// model 与是否流式来自请求路径（/v1beta/models/{model}:generateContent / :streamGenerateContent）
+func GeminiToClaudeRequest(body []byte, model string, stream bool) (map[string]interface{}, error) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("解析 Gemini 请求失败: %w", err)
+	}
+	if model == "" {
+		return nil, fmt.Errorf("缺少模型名称")
+	}
+	rawContents, ok := req["contents"].([]interface{})
+	if !ok || len(rawContents) == 0 {
+		return nil, fmt.Errorf("contents 不能为空")
+	}
+
+	messages, err := geminiContentsToClaude(rawContents)
+	if err != nil {
+		return nil, err
+	}
+
+	claudeReq := map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+	}
+	if stream {
+		claudeReq["stream"] = true
+	}
+
+	if instruction, ok := geminiField(req, "systemInstruction", "system_instruction").(map[string]interface{}); ok {
+		if system := geminiPartsText(instruction["parts"]); system != "" {
+			claudeReq["system"] = system
+		}
+	}
+
+	maxTokens := defaultGeminiMaxTokens
+	if genConfig, ok := geminiField(req, "generationConfig", "generation_config").(map[string]interface{}); ok {
+		if v := jsonInt(geminiField(genConfig, "maxOutputTokens", "max_output_tokens")); v > 0 {
+			maxTokens = v
+		}
+		if v, ok := genConfig["temperature"].(float64); ok {
+			claudeReq["temperature"] = v
+		}
+		if v, ok := geminiField(genConfig, "topP", "top_p").(float64); ok {
+			claudeReq["top_p"] = v
+		}
+		if v := jsonInt(geminiField(genConfig, "topK", "top_k")); v > 0 {
+			claudeReq["top_k"] = v
+		}
+		if stops, ok := geminiField(genConfig, "stopSequences", "stop_sequences").([]interface{}); ok && len(stops) > 0 {
+			claudeReq["stop_sequences"] = stops
+		}
+	}
+	claudeReq["max_tokens"] = maxTokens
+
+	if rawTools, ok := req["tools"].([]interface{}); ok {
+		if tools := geminiToolsToClaude(rawTools); len(tools) > 0 {
+			claudeReq["tools"] = tools
+			if toolChoice := geminiToolConfigToClaude(geminiField(req, "toolConfig", "tool_config")); toolChoice != nil {
+				claudeReq["tool_choice"] = toolChoice
+			}
+		}
+	}
+
+	return claudeReq, nil
+}
+
+// geminiContentsToClaude 转换对话内容：model 角色对应 assistant，其余对应 user，相邻的同角色消息合并
+// Gemini 的 functionCall / functionResponse 没有调用 ID，按函数名依次配对生成 tool_use_id
+func geminiContentsToClaude(rawContents []interface{}) ([]map[string]interface{}, error) {
+	messages := []map[string]interface{}{}
+	pendingCalls := map[string][]string{} // 函数名 -> 尚未返回结果的 tool_use_id
+	callCount := 0
+
+	for i, raw := range rawContents {
+		content, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("contents[%d] 格式无效", i)
+		}
+		role := "user"
+		if content["role"] == "model" {
+			role = "assistant"
+		}
+
+		parts, _ := content["parts"].([]interface{})
+		blocks := []interface{}{}
+		for _, rawPart := range parts {
+			part, ok := rawPart.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			inlineData := geminiField(part, "inlineData", "inline_data")
+			functionCall := geminiField(part, "functionCall", "function_call")
+			functionResponse := geminiField(part, "functionResponse", "function_response")
+
+			switch {
+			case part["text"] != nil:
+				if text, _ := part["text"].(string); text != "" {
+					blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+				}
+
+			case inlineData != nil:
+				data, _ := inlineData.(map[string]interface{})
+				if block := geminiBlobToClaude(data); block != nil {
+					blocks = append(blocks, block)
+				}
+
+			case functionCall != nil:
+				call, _ := functionCall.(map[string]interface{})
+				name, _ := call["name"].(string)
+				id, _ := call["id"].(string)
+				if id == "" {
+					callCount++
+					id = fmt.Sprintf("toolu_gemini_%d", callCount)
+				}
+				pendingCalls[name] = append(pendingCalls[name], id)
+
+				input := call["args"]
+				if input == nil {
+					input = map[string]interface{}{}
+				}
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    id,
+					"name":  name,
+					"input": input,
+				})
+
+			case functionResponse != nil:
+				response, _ := functionResponse.(map[string]interface{})
+				name, _ := response["name"].(string)
+				id, _ := response["id"].(string)
+				if id == "" && len(pendingCalls[name]) > 0 {
+					id = pendingCalls[name][0]
+					pendingCalls[name] = pendingCalls[name][1:]
+				}
+
+				result, _ := json.Marshal(response["response"])
+				blocks = append(blocks, map[string]interface{}{
+					"type":        "tool_result",
+					"tool_use_id": id,
+					"content":     string(result),
+				})
+			}
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+			messages[n-1]["content"] = append(messages[n-1]["content"].([]interface{}), blocks...)
+			continue
+		}
+		messages = append(messages, map[string]interface{}{"role": role, "content": blocks})
+	}
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("contents 中没有可发送的对话内容")
+	}
+	return messages, nil
+}
+
+// geminiField 读取请求字段：Gemini REST API 同时接受 camelCase 和 snake_case 两种写法
+func geminiField(m map[string]interface{}, camel, snake string) interface{} {
+	if v, ok := m[camel]; ok && v != nil {
+		return v
+	}
+	return m[snake]
+}
+
+// geminiBlobToClaude 将 inlineData 转换为 Claude 图片 / 文档块
+func geminiBlobToClaude(data map[string]interface{}) map[string]interface{} {
+	mimeType, _ := geminiField(data, "mimeType", "mime_type").(string)
+	payload, _ := data["data"].(string)
+	if mimeType == "" || payload == "" {
+		return nil
+	}
+
+	blockType := "image"
+	if mimeType == "application/pdf" {
+		blockType = "document"
+	} else if !strings.HasPrefix(mimeType, "image/") {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"type": blockType,
+		"source": map[string]interface{}{
+			"type":       "base64",
+			"media_type": mimeType,
+			"data":       payload,
+		},
+	}
+}
+
+// geminiPartsText 提取 parts 中的文本
+func geminiPartsText(raw interface{}) string {
+	parts, _ := raw.([]interface{})
+	var texts []string
+	for _, rawPart := range parts {
+		if part, ok := rawPart.(map[string]interface{}); ok {
+			if text, _ := part["text"].(string); text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// geminiToolsToClaude 转换 functionDeclarations
+func geminiToolsToClaude(rawTools []interface{}) []map[string]interface{} {
+	tools := []map[string]interface{}{}
+	for _, raw := range rawTools {
+		tool, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		declarations, _ := geminiField(tool, "functionDeclarations", "function_declarations").([]interface{})
+		for _, rawDecl := range declarations {
+			decl, ok := rawDecl.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := decl["name"].(string)
+			if name == "" {
+				continue
+			}
+
+			schema := decl["parameters"]
+			if schema == nil {
+				schema = geminiField(decl, "parametersJsonSchema", "parameters_json_schema")
+			}
+			if schema == nil {
+				schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			}
+
+			claudeTool := map[string]interface{}{
+				"name":         name,
+				"input_schema": normalizeGeminiSchema(schema),
+			}
+			if description, ok := decl["description"].(string); ok && description != "" {
+				claudeTool["description"] = description
+			}
+			tools = append(tools, claudeTool)
+		}
+	}
+	return tools
+}
+
+// normalizeGeminiSchema Gemini 的 Schema 类型为大写（OBJECT、STRING 等），转换为 JSON Schema 的小写形式
+func normalizeGeminiSchema(schema interface{}) interface{} {
+	switch v := schema.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if typeName, ok := value.(string); ok && key == "type" {
+				result[key] = strings.ToLower(typeName)
+				continue
+			}
+			result[key] = normalizeGeminiSchema(value)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalizeGeminiSchema(item)
+		}
+		return result
+	}
+	return schema
+}
+
+// geminiToolConfigToClaude 转换 toolConfig.functionCallingConfig：AUTO / ANY / NONE
+// ANY 且只允许一个函数时转换为指定工具
+func geminiToolConfigToClaude(raw interface{}) map[string]interface{} {
+	toolConfig, _ := raw.(map[string]interface{})
+	callingConfig, _ := geminiField(toolConfig, "functionCallingConfig", "function_calling_config").(map[string]interface{})
+	mode, _ := callingConfig["mode"].(string)
+
+	switch strings.ToUpper(mode) {
+	case "AUTO":
+		return map[string]interface{}{"type": "auto"}
+	case "ANY":
+		if allowed, ok := geminiField(callingConfig, "allowedFunctionNames", "allowed_function_names").([]interface{}); ok && len(allowed) == 1 {
+			if name, _ := allowed[0].(string); name != "" {
+				return map[string]interface{}{"type": "tool", "name": name}
+			}
+		}
+		return map[string]interface{}{"type": "any"}
+	case "NONE":
+		return map[string]interface{}{"type": "none"}
+	}
+	return nil
+}
+
+// ClaudeStopReasonToGeminiFinishReason 将 Claude stop_reason 转换为 Gemini finishReason
+func ClaudeStopReasonToGeminiFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "MAX_TOKENS"
+	case "refusal":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// ClaudeResponseToGemini 将 Claude 响应转换为 Gemini generateContent 响应
+func ClaudeResponseToGemini(resp *types.ClaudeResponse, model string) map[string]interface{} {
+	parts := []map[string]interface{}{}
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			parts = append(parts, map[string]interface{}{"text": block.Text})
+		case "tool_use":
+			args := block.Input
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			parts = append(parts, map[string]interface{}{
+				"functionCall": map[string]interface{}{
+					"name": block.Name,
+					"args": args,
+				},
+			})
+		}
+	}
+
+	result := map[string]interface{}{
+		"candidates": []map[string]interface{}{
+			{
+				"content": map[string]interface{}{
+					"role":  "model",
+					"parts": parts,
+				},
+				"finishReason": ClaudeStopReasonToGeminiFinishReason(resp.StopReason),
+				"index":        0,
+			},
+		},
+		"modelVersion": model,
+	}
+	if resp.ID != "" {
+		result["responseId"] = resp.ID
+	}
+
+	if resp.Usage != nil {
+		prompt := resp.Usage.InputTokens + resp.Usage.PromptTokens
+		candidates := resp.Usage.OutputTokens + resp.Usage.CompletionTokens
+		result["usageMetadata"] = geminiUsageMetadata(int64(prompt), int64(candidates))
+	}
+
+	return result
+}
+
+// geminiUsageMetadata 构造 usageMetadata
+func geminiUsageMetadata(prompt, candidates int64) map[string]interface{} {
+	return map[string]interface{}{
+		"promptTokenCount":     prompt,
+		"candidatesTokenCount": candidates,
+		"totalTokenCount":      prompt + candidates,
+	}
+}
+
+// GeminiStreamConverter 将 Claude 流式事件转换为 Gemini streamGenerateContent（alt=sse）数据块
+type GeminiStreamConverter struct {
+	model      string
+	responseID string
+
+	toolCalls    map[int]*geminiPendingCall // Claude 内容块索引 -> 累积中的函数调用
+	finished     bool
+	done         bool
+	inputTokens  int64
+	outputTokens int64
+}
+
+// geminiPendingCall 流式累积的工具调用（Gemini 需要完整的 args，在内容块结束时输出）
+type geminiPendingCall struct {
+	name string
+	args strings.Builder
+}
+
+// NewGeminiStreamConverter 创建流式转换器
+func NewGeminiStreamConverter(model string) *GeminiStreamConverter {
+	return &GeminiStreamConverter{
+		model:     model,
+		toolCalls: make(map[int]*geminiPendingCall),
+	}
+}
+
+// Convert 转换一个 Claude 事件（可能包含多行），返回需要输出的 SSE 数据块
+func (s *GeminiStreamConverter) Convert(event string) []string {
+	var chunks []string
+	for _, line := range strings.Split(event, "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &payload); err != nil {
+			continue
+		}
+		chunks = append(chunks, s.convertPayload(payload)...)
+	}
+	return chunks
+}
+
+// convertPayload 按事件类型转换
+func (s *GeminiStreamConverter) convertPayload(payload map[string]interface{}) []string {
+	if s.done {
+		return nil
+	}
+
+	switch payload["type"] {
+	case "message_start":
+		if message, ok := payload["message"].(map[string]interface{}); ok {
+			s.responseID, _ = message["id"].(string)
+			s.recordUsage(message["usage"])
+		}
+
+	case "content_block_start":
+		block, _ := payload["content_block"].(map[string]interface{})
+		if block["type"] == "tool_use" {
+			name, _ := block["name"].(string)
+			s.toolCalls[jsonInt(payload["index"])] = &geminiPendingCall{name: name}
+		}
+
+	case "content_block_delta":
+		delta, _ := payload["delta"].(map[string]interface{})
+		switch delta["type"] {
+		case "text_delta":
+			text, _ := delta["text"].(string)
+			return []string{s.chunk([]interface{}{map[string]interface{}{"text": text}}, "", false)}
+		case "input_json_delta":
+			if call, ok := s.toolCalls[jsonInt(payload["index"])]; ok {
+				partial, _ := delta["partial_json"].(string)
+				call.args.WriteString(partial)
+			}
+		}
+
+	case "content_block_stop":
+		index := jsonInt(payload["index"])
+		call, ok := s.toolCalls[index]
+		if !ok {
+			return nil
+		}
+		delete(s.toolCalls, index)
+
+		args := map[string]interface{}{}
+		if call.args.Len() > 0 {
+			_ = json.Unmarshal([]byte(call.args.String()), &args)
+		}
+		return []string{s.chunk([]interface{}{map[string]interface{}{
+			"functionCall": map[string]interface{}{"name": call.name, "args": args},
+		}}, "", false)}
+
+	case "message_delta":
+		s.recordUsage(payload["usage"])
+		delta, _ := payload["delta"].(map[string]interface{})
+		stopReason, _ := delta["stop_reason"].(string)
+		if stopReason == "" || s.finished {
+			return nil
+		}
+		s.finished = true
+		return []string{s.chunk([]interface{}{}, ClaudeStopReasonToGeminiFinishReason(stopReason), true)}
+
+	case "message_stop":
+		return s.Finish()
+
+	case "error":
+		s.done = true
+		errObj, _ := payload["error"].(map[string]interface{})
+		message, _ := errObj["message"].(string)
+		data, _ := json.Marshal(map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    500,
+				"message": message,
+				"status":  "INTERNAL",
+			},
+		})
+		return []string{fmt.Sprintf("data: %s\n\n", data)}
+	}
+
+	return nil
+}
+
+// Finish 结束流：上游未提供 stop_reason 时补发带 finishReason 的数据块
+func (s *GeminiStreamConverter) Finish() []string {
+	if s.done {
+		return nil
+	}
+	s.done = true
+
+	if s.finished {
+		return nil
+	}
+	s.finished = true
+	return []string{s.chunk([]interface{}{}, "STOP", true)}
+}
+
+// chunk 构造一个 GenerateContentResponse 数据块，结束块附带 finishReason 和 usageMetadata
+func (s *GeminiStreamConverter) chunk(parts []interface{}, finishReason string, final bool) string {
+	candidate := map[string]interface{}{
+		"content": map[string]interface{}{
+			"role":  "model",
+			"parts": parts,
+		},
+		"index": 0,
+	}
+	if finishReason != "" {
+		candidate["finishReason"] = finishReason
+	}
+
+	resp := map[string]interface{}{
+		"candidates":   []interface{}{candidate},
+		"modelVersion": s.model,
+	}
+	if s.responseID != "" {
+		resp["responseId"] = s.responseID
+	}
+	if final {
+		resp["usageMetadata"] = geminiUsageMetadata(s.inputTokens, s.outputTokens)
+	}
+
+	data, _ := json.Marshal(resp)
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+// recordUsage 记录 message_start / message_delta 中的用量（均为累计值）
+func (s *GeminiStreamConverter) recordUsage(raw interface{}) {
+	usage, ok := raw.(map[string]interface{})
+	if !ok {
+		return
+	}
+	input := int64(jsonInt(usage["input_tokens"]) + jsonInt(usage["cache_creation_input_tokens"]) + jsonInt(usage["cache_read_input_tokens"]))
+	if input > 0 {
+		s.inputTokens = input
+	}
+	if output := int64(jsonInt(usage["output_tokens"])); output > 0 {
+		s.outputTokens = output
+	}
+}
@@ -0,0 +1,236 @@
+package converters
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+func TestGeminiToClaudeRequest(t *testing.T) {
+	body := `{
+		"systemInstruction": {"parts": [{"text": "You are helpful."}]},
+		"contents": [
+			{"role": "user", "parts": [
+				{"text": "What is in this image?"},
+				{"inlineData": {"mimeType": "image/png", "data": "AAAA"}}
+			]},
+			{"role": "model", "parts": [
+				{"functionCall": {"name": "lookup", "args": {"q": "cat"}}},
+				{"functionCall": {"name": "lookup", "args": {"q": "dog"}}}
+			]},
+			{"role": "user", "parts": [
+				{"functionResponse": {"name": "lookup", "response": {"result": "a cat"}}},
+				{"functionResponse": {"name": "lookup", "response": {"result": "a dog"}}}
+			]}
+		],
+		"generationConfig": {"maxOutputTokens": 256, "temperature": 0.5, "topP": 0.9, "stopSequences": ["END"]},
+		"tools": [{"functionDeclarations": [{"name": "lookup", "parameters": {"type": "OBJECT", "properties": {"q": {"type": "STRING"}}}}]}],
+		"toolConfig": {"functionCallingConfig": {"mode": "ANY", "allowedFunctionNames": ["lookup"]}}
+	}`
+
+	req, err := GeminiToClaudeRequest([]byte(body), "gemini-2.0-flash", true)
+	if err != nil {
+		t.Fatalf("转换失败: %v", err)
+	}
+
+	if req["model"] != "gemini-2.0-flash" || req["stream"] != true {
+		t.Errorf("model = %v, stream = %v", req["model"], req["stream"])
+	}
+	if req["system"] != "You are helpful." {
+		t.Errorf("system = %v", req["system"])
+	}
+	if req["max_tokens"] != 256 || req["top_p"] != 0.9 {
+		t.Errorf("max_tokens = %v, top_p = %v", req["max_tokens"], req["top_p"])
+	}
+	if stops, _ := req["stop_sequences"].([]interface{}); len(stops) != 1 || stops[0] != "END" {
+		t.Errorf("stop_sequences = %v", req["stop_sequences"])
+	}
+
+	toolChoice, _ := req["tool_choice"].(map[string]interface{})
+	if toolChoice["type"] != "tool" || toolChoice["name"] != "lookup" {
+		t.Errorf("tool_choice = %v", toolChoice)
+	}
+	tools := req["tools"].([]map[string]interface{})
+	schema, _ := tools[0]["input_schema"].(map[string]interface{})
+	properties, _ := schema["properties"].(map[string]interface{})
+	if schema["type"] != "object" || properties["q"].(map[string]interface{})["type"] != "string" {
+		t.Errorf("input_schema 类型未转换为小写: %v", schema)
+	}
+
+	messages := req["messages"].([]map[string]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("消息数量 = %d, 期望 3", len(messages))
+	}
+	userBlocks := messages[0]["content"].([]interface{})
+	if image, _ := userBlocks[1].(map[string]interface{}); image["type"] != "image" {
+		t.Errorf("图片块 = %v", userBlocks[1])
+	}
+
+	// 没有调用 ID 的 functionCall / functionResponse 按函数名依次配对
+	calls := messages[1]["content"].([]interface{})
+	results := messages[2]["content"].([]interface{})
+	for i := range calls {
+		callID := calls[i].(map[string]interface{})["id"]
+		resultID := results[i].(map[string]interface{})["tool_use_id"]
+		if callID == "" || callID != resultID {
+			t.Errorf("第 %d 个工具调用 ID = %v, 结果 ID = %v", i, callID, resultID)
+		}
+	}
+	if calls[0].(map[string]interface{})["id"] == calls[1].(map[string]interface{})["id"] {
+		t.Error("并行工具调用的 ID 重复")
+	}
+}
+
+func TestGeminiToClaudeRequest_SnakeCase(t *testing.T) {
+	camel := `{
+		"systemInstruction": {"parts": [{"text": "sys"}]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "hi"}, {"inlineData": {"mimeType": "application/pdf", "data": "AAAA"}}]},
+			{"role": "model", "parts": [{"functionCall": {"name": "lookup", "args": {"user_id": 1}}}]},
+			{"role": "user", "parts": [{"functionResponse": {"name": "lookup", "response": {"result": "ok"}}}]}
+		],
+		"generationConfig": {"maxOutputTokens": 64, "topP": 0.5, "topK": 3, "stopSequences": ["END"]},
+		"tools": [{"functionDeclarations": [{"name": "lookup", "parametersJsonSchema": {"type": "object"}}]}],
+		"toolConfig": {"functionCallingConfig": {"mode": "ANY", "allowedFunctionNames": ["lookup"]}}
+	}`
+	snake := `{
+		"system_instruction": {"parts": [{"text": "sys"}]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "hi"}, {"inline_data": {"mime_type": "application/pdf", "data": "AAAA"}}]},
+			{"role": "model", "parts": [{"function_call": {"name": "lookup", "args": {"user_id": 1}}}]},
+			{"role": "user", "parts": [{"function_response": {"name": "lookup", "response": {"result": "ok"}}}]}
+		],
+		"generation_config": {"max_output_tokens": 64, "top_p": 0.5, "top_k": 3, "stop_sequences": ["END"]},
+		"tools": [{"function_declarations": [{"name": "lookup", "parameters_json_schema": {"type": "object"}}]}],
+		"tool_config": {"function_calling_config": {"mode": "ANY", "allowed_function_names": ["lookup"]}}
+	}`
+
+	camelReq, err := GeminiToClaudeRequest([]byte(camel), "gemini-2.0-flash", false)
+	if err != nil {
+		t.Fatalf("转换 camelCase 请求失败: %v", err)
+	}
+	snakeReq, err := GeminiToClaudeRequest([]byte(snake), "gemini-2.0-flash", false)
+	if err != nil {
+		t.Fatalf("转换 snake_case 请求失败: %v", err)
+	}
+
+	camelJSON, _ := json.Marshal(camelReq)
+	snakeJSON, _ := json.Marshal(snakeReq)
+	if string(camelJSON) != string(snakeJSON) {
+		t.Errorf("snake_case 请求转换结果不一致:\n%s\n%s", snakeJSON, camelJSON)
+	}
+	for _, field := range []string{"system", "top_k", "stop_sequences", "tools", "tool_choice"} {
+		if snakeReq[field] == nil {
+			t.Errorf("snake_case 请求缺少 %s", field)
+		}
+	}
+}
+
+func TestGeminiToClaudeRequest_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		model string
+	}{
+		{"非法JSON", `{`, "gemini-2.0-flash"},
+		{"缺少contents", `{}`, "gemini-2.0-flash"},
+		{"缺少模型", `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := GeminiToClaudeRequest([]byte(tt.body), tt.model, false); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
+
+func TestClaudeResponseToGemini(t *testing.T) {
+	resp := &types.ClaudeResponse{
+		ID: "msg_1",
+		Content: []types.ClaudeContent{
+			{Type: "text", Text: "Hello"},
+			{Type: "tool_use", ID: "toolu_1", Name: "lookup", Input: map[string]interface{}{"q": "cat"}},
+		},
+		StopReason: "max_tokens",
+		Usage:      &types.Usage{InputTokens: 10, OutputTokens: 5},
+	}
+
+	data, _ := json.Marshal(ClaudeResponseToGemini(resp, "gemini-2.0-flash"))
+	var parsed struct {
+		Candidates []struct {
+			Content struct {
+				Role  string                   `json:"role"`
+				Parts []map[string]interface{} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			TotalTokenCount int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	candidate := parsed.Candidates[0]
+	if candidate.Content.Role != "model" || len(candidate.Content.Parts) != 2 {
+		t.Errorf("content = %+v", candidate.Content)
+	}
+	if candidate.Content.Parts[0]["text"] != "Hello" || candidate.Content.Parts[1]["functionCall"] == nil {
+		t.Errorf("parts = %v", candidate.Content.Parts)
+	}
+	if candidate.FinishReason != "MAX_TOKENS" {
+		t.Errorf("finishReason = %s", candidate.FinishReason)
+	}
+	if parsed.UsageMetadata.TotalTokenCount != 15 {
+		t.Errorf("totalTokenCount = %d", parsed.UsageMetadata.TotalTokenCount)
+	}
+}
+
+func TestGeminiStreamConverter(t *testing.T) {
+	events := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"usage\":{\"input_tokens\":7}}}\n\n",
+		"data: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n",
+		"data: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"lookup\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"q\\\":\"}}\n",
+		"data: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"1}\"}}\n",
+		"data: {\"type\":\"content_block_stop\",\"index\":1}\n",
+		"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":3}}\n",
+		"data: {\"type\":\"message_stop\"}\n",
+	}
+
+	converter := NewGeminiStreamConverter("gemini-2.0-flash")
+	var output []string
+	for _, event := range events {
+		output = append(output, converter.Convert(event)...)
+	}
+	output = append(output, converter.Finish()...)
+
+	if len(output) != 3 {
+		t.Fatalf("数据块数量 = %d, 期望 3:\n%s", len(output), strings.Join(output, ""))
+	}
+	for i, want := range []string{
+		`"text":"Hi"`,
+		`"functionCall":{"args":{"q":1},"name":"lookup"}`,
+		`"finishReason":"STOP"`,
+	} {
+		if !strings.Contains(output[i], want) {
+			t.Errorf("第 %d 个数据块缺少 %s:\n%s", i, want, output[i])
+		}
+	}
+	if !strings.Contains(output[2], `"totalTokenCount":10`) {
+		t.Errorf("结束数据块缺少用量:\n%s", output[2])
+	}
+
+	// 上游未提供 stop_reason 时，Finish 补发 finishReason
+	converter = NewGeminiStreamConverter("gemini-2.0-flash")
+	converter.Convert(events[2])
+	if finish := strings.Join(converter.Finish(), ""); !strings.Contains(finish, `"finishReason":"STOP"`) {
+		t.Errorf("未补发 finishReason:\n%s", finish)
+	}
+}
@@ -49,6 +49,9 @@ func (c *OpenAIChatConverter) ToProviderRequest(sess *session.Session, req *type
 	}
 	if req.StreamOptions != nil {
 		openaiReq["stream_options"] = req.StreamOptions
+	} else if req.Stream {
+		// 流式响应需要用量数据来填写 response.completed 的 usage
+		openaiReq["stream_options"] = map[string]interface{}{"include_usage": true}
 	}
 
 	return openaiReq, nil
@@ -59,6 +62,14 @@ func (c *OpenAIChatConverter) FromProviderResponse(resp map[string]interface{},
 	return OpenAIChatResponseToResponses(resp, sessionID)
 }
 
+// NewStreamConverter 创建 Chat Completions SSE → Responses 事件的流式转换器
+func (c *OpenAIChatConverter) NewStreamConverter(model, previousResponseID string) ResponsesStreamConverter {
+	return &openAIChatResponsesStream{
+		responsesStreamState: newResponsesStreamState(model, previousResponseID),
+		toolCalls:            make(map[int]*responsesOutputItem),
+	}
+}
+
 // GetProviderName 获取上游服务名称
 func (c *OpenAIChatConverter) GetProviderName() string {
 	return "OpenAI Chat Completions"
@@ -120,6 +131,11 @@ func (c *OpenAICompletionsConverter) FromProviderResponse(resp map[string]interf
 	return OpenAICompletionsResponseToResponses(resp, sessionID)
 }
 
+// NewStreamConverter 创建 Completions SSE → Responses 事件的流式转换器
+func (c *OpenAICompletionsConverter) NewStreamConverter(model, previousResponseID string) ResponsesStreamConverter {
+	return &openAICompletionsResponsesStream{newResponsesStreamState(model, previousResponseID)}
+}
+
 // GetProviderName 获取上游服务名称
 func (c *OpenAICompletionsConverter) GetProviderName() string {
 	return "OpenAI Completions"
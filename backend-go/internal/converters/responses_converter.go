@@ -1,9 +1,12 @@
 package converters
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/BenedictKing/claude-proxy/internal/session"
 	"github.com/BenedictKing/claude-proxy/internal/types"
@@ -94,11 +97,11 @@ func responsesItemToClaudeMessage(item types.ResponsesItem) (*types.ClaudeMessag
 			},
 		}, nil
 
-	case "tool_call":
+	case "tool_call", "function_call":
 		// 工具调用（暂时简化处理）
 		return nil, nil
 
-	case "tool_result":
+	case "tool_result", "function_call_output":
 		// 工具结果（暂时简化处理）
 		return nil, nil
 
@@ -137,13 +140,13 @@ func ClaudeResponseToResponses(claudeResp map[string]interface{}, sessionID stri
 	usageMap, _ := claudeResp["usage"].(map[string]interface{})
 	usage := types.ResponsesUsage{}
 	if usageMap != nil {
-		usage.PromptTokens, _ = usageMap["input_tokens"].(int)
-		usage.CompletionTokens, _ = usageMap["output_tokens"].(int)
+		usage.PromptTokens = jsonInt(usageMap["input_tokens"])
+		usage.CompletionTokens = jsonInt(usageMap["output_tokens"])
 		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	}
 
 	// 生成 response ID
-	responseID := generateResponseID()
+	responseID := GenerateResponseID()
 
 	return &types.ResponsesResponse{
 		ID:         responseID,
@@ -270,7 +273,7 @@ func OpenAIChatResponseToResponses(openaiResp map[string]interface{}, sessionID
 	}
 
 	// 生成 response ID
-	responseID := generateResponseID()
+	responseID := GenerateResponseID()
 
 	return &types.ResponsesResponse{
 		ID:         responseID,
@@ -365,24 +368,25 @@ func parseResponsesInput(input interface{}) ([]types.ResponsesItem, error) {
 	}
 }
 
-// generateResponseID 生成响应ID
-func generateResponseID() string {
-	return fmt.Sprintf("resp_%d", getCurrentTimestamp())
-}
-
-// getCurrentTimestamp 获取当前时间戳（毫秒）
-func getCurrentTimestamp() int64 {
-	return 0 // 占位符，实际应使用 time.Now().UnixNano() / 1e6
+// GenerateResponseID 生成响应ID
+// 响应 ID 用于会话映射和 GET /v1/responses/{id} 查询，必须全局唯一
+func GenerateResponseID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		// 降级方案：使用时间戳
+		return fmt.Sprintf("resp_%d", time.Now().UnixNano())
+	}
+	return "resp_" + hex.EncodeToString(bytes)
 }
 
 // ExtractTextFromResponses 从 Responses 消息中提取纯文本（用于 OpenAI Completions）
 func ExtractTextFromResponses(sess *session.Session, newInput interface{}) (string, error) {
 	texts := []string{}
 
-	// 历史消息
+	// 历史消息（含 message 格式的用户输入）
 	for _, item := range sess.Messages {
-		if item.Type == "text" {
-			if text, ok := item.Content.(string); ok {
+		if item.Type == "text" || item.Type == "message" {
+			if text := extractTextFromContent(item.Content); text != "" {
 				texts = append(texts, text)
 			}
 		}
@@ -434,7 +438,7 @@ func OpenAICompletionsResponseToResponses(completionsResp map[string]interface{}
 		usage.TotalTokens = int(totalTokens)
 	}
 
-	responseID := generateResponseID()
+	responseID := GenerateResponseID()
 
 	return &types.ResponsesResponse{
 		ID:         responseID,
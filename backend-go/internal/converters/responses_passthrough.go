@@ -83,6 +83,11 @@ func (c *ResponsesPassthroughConverter) FromProviderResponse(resp map[string]int
 	}, nil
 }
 
+// NewStreamConverter 创建透传流式转换器（事件原样转发，仅从最终事件中解析响应）
+func (c *ResponsesPassthroughConverter) NewStreamConverter(model, previousResponseID string) ResponsesStreamConverter {
+	return &passthroughResponsesStream{converter: c}
+}
+
 // GetProviderName 获取上游服务名称
 func (c *ResponsesPassthroughConverter) GetProviderName() string {
 	return "Responses API (Passthrough)"
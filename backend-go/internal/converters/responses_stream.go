@@ -0,0 +1,599 @@
+package converters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ============== 上游流式响应 → Responses 流式事件 ==============
+
+// ResponsesStreamConverter 流式转换器
+// 将上游 SSE 行转换为 Responses API 事件（response.created、response.output_text.delta、response.completed 等），
+// 同时累积最终输出，用于流结束后更新会话
+type ResponsesStreamConverter interface {
+	// ProcessLine 处理一行上游 SSE 数据，返回需要写给客户端的内容
+	ProcessLine(line string) []string
+
+	// Finish 上游流结束时调用，返回收尾事件（上游未发送结束标志时补发 response.incomplete）
+	Finish() []string
+
+	// Response 返回累积的最终响应，流尚未结束时返回 nil
+	Response() *types.ResponsesResponse
+}
+
+// responsesStreamState 生成 Responses 流式事件的公共状态
+// 输出项按出现顺序编号：assistant 文本消息（message）和工具调用（function_call）
+type responsesStreamState struct {
+	responseID         string
+	model              string
+	previousResponseID string
+	createdAt          int64
+	sequence           int
+
+	started bool
+	done    bool
+	items   []*responsesOutputItem
+	message *responsesOutputItem // 正在输出的文本消息，工具调用开始后结束
+
+	status           string // completed、incomplete、failed
+	incompleteReason string // 上游因长度限制截断时为 max_output_tokens，被安全策略拦截时为 content_filter
+	errorCode        string
+	errorMessage     string
+	inputTokens      int
+	outputTokens     int
+}
+
+// responsesOutputItem 流式输出项
+type responsesOutputItem struct {
+	index    int
+	id       string
+	itemType string          // message、function_call
+	callID   string          // function_call 的调用 ID
+	name     string          // function_call 的函数名
+	content  strings.Builder // 文本内容或函数参数
+	done     bool
+}
+
+func newResponsesStreamState(model, previousResponseID string) *responsesStreamState {
+	return &responsesStreamState{
+		responseID:         GenerateResponseID(),
+		model:              model,
+		previousResponseID: previousResponseID,
+		createdAt:          time.Now().Unix(),
+	}
+}
+
+// event 序列化单个事件（自动填写 type 和 sequence_number）
+func (s *responsesStreamState) event(eventType string, payload map[string]interface{}) string {
+	payload["type"] = eventType
+	payload["sequence_number"] = s.sequence
+	s.sequence++
+	data, _ := json.Marshal(payload)
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, data)
+}
+
+// outputText 输出文本内容块
+func (item *responsesOutputItem) outputText() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "output_text",
+		"text":        item.content.String(),
+		"annotations": []interface{}{},
+	}
+}
+
+// object 输出项对象（message 或 function_call）
+func (item *responsesOutputItem) object(status string) map[string]interface{} {
+	if item.itemType == "function_call" {
+		arguments := ""
+		if status == "completed" {
+			arguments = item.content.String()
+		}
+		return map[string]interface{}{
+			"id":        item.id,
+			"type":      "function_call",
+			"status":    status,
+			"call_id":   item.callID,
+			"name":      item.name,
+			"arguments": arguments,
+		}
+	}
+
+	content := []interface{}{}
+	if status == "completed" {
+		content = append(content, item.outputText())
+	}
+	return map[string]interface{}{
+		"id":      item.id,
+		"type":    "message",
+		"role":    "assistant",
+		"status":  status,
+		"content": content,
+	}
+}
+
+// responseObject 当前状态下的 response 对象
+func (s *responsesStreamState) responseObject(status string) map[string]interface{} {
+	output := []interface{}{}
+	if status != "in_progress" {
+		for _, item := range s.items {
+			output = append(output, item.object("completed"))
+		}
+	}
+
+	resp := map[string]interface{}{
+		"id":                   s.responseID,
+		"object":               "response",
+		"created_at":           s.createdAt,
+		"status":               status,
+		"model":                s.model,
+		"output":               output,
+		"previous_response_id": nil,
+		"error":                nil,
+		"incomplete_details":   nil,
+		"usage":                nil,
+	}
+	if s.previousResponseID != "" {
+		resp["previous_response_id"] = s.previousResponseID
+	}
+	if status == "failed" {
+		resp["error"] = map[string]interface{}{"code": s.errorCode, "message": s.errorMessage}
+	}
+	if status == "incomplete" && s.incompleteReason != "" {
+		resp["incomplete_details"] = map[string]interface{}{"reason": s.incompleteReason}
+	}
+	if status != "in_progress" {
+		resp["usage"] = map[string]interface{}{
+			"input_tokens":  s.inputTokens,
+			"output_tokens": s.outputTokens,
+			"total_tokens":  s.inputTokens + s.outputTokens,
+		}
+	}
+	return resp
+}
+
+// start 发送 response.created 和 response.in_progress（仅一次）
+func (s *responsesStreamState) start() []string {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []string{
+		s.event("response.created", map[string]interface{}{"response": s.responseObject("in_progress")}),
+		s.event("response.in_progress", map[string]interface{}{"response": s.responseObject("in_progress")}),
+	}
+}
+
+// addItem 新增输出项并发送 response.output_item.added
+func (s *responsesStreamState) addItem(item *responsesOutputItem) string {
+	item.index = len(s.items)
+	s.items = append(s.items, item)
+	return s.event("response.output_item.added", map[string]interface{}{
+		"output_index": item.index,
+		"item":         item.object("in_progress"),
+	})
+}
+
+// addText 追加文本增量（首个增量前发送输出项和内容块的 added 事件）
+func (s *responsesStreamState) addText(delta string) []string {
+	if delta == "" || s.done {
+		return nil
+	}
+	events := s.start()
+	if s.message == nil {
+		s.message = &responsesOutputItem{
+			id:       "msg_" + strings.TrimPrefix(GenerateResponseID(), "resp_"),
+			itemType: "message",
+		}
+		events = append(events,
+			s.addItem(s.message),
+			s.event("response.content_part.added", map[string]interface{}{
+				"item_id":       s.message.id,
+				"output_index":  s.message.index,
+				"content_index": 0,
+				"part":          map[string]interface{}{"type": "output_text", "text": "", "annotations": []interface{}{}},
+			}),
+		)
+	}
+	s.message.content.WriteString(delta)
+	return append(events, s.event("response.output_text.delta", map[string]interface{}{
+		"item_id":       s.message.id,
+		"output_index":  s.message.index,
+		"content_index": 0,
+		"delta":         delta,
+	}))
+}
+
+// startToolCall 开始一个工具调用输出项（之前的文本消息随之结束）
+func (s *responsesStreamState) startToolCall(callID, name string) (*responsesOutputItem, []string) {
+	if s.done {
+		return nil, nil
+	}
+	events := s.start()
+	if s.message != nil {
+		events = append(events, s.closeItem(s.message)...)
+		s.message = nil
+	}
+	item := &responsesOutputItem{
+		id:       "fc_" + strings.TrimPrefix(GenerateResponseID(), "resp_"),
+		itemType: "function_call",
+		callID:   callID,
+		name:     name,
+	}
+	return item, append(events, s.addItem(item))
+}
+
+// addArguments 追加工具调用的参数增量
+func (s *responsesStreamState) addArguments(item *responsesOutputItem, delta string) []string {
+	if item == nil || item.done || delta == "" || s.done {
+		return nil
+	}
+	item.content.WriteString(delta)
+	return []string{s.event("response.function_call_arguments.delta", map[string]interface{}{
+		"item_id":      item.id,
+		"output_index": item.index,
+		"delta":        delta,
+	})}
+}
+
+// closeItem 结束输出项并发送对应的 done 事件
+func (s *responsesStreamState) closeItem(item *responsesOutputItem) []string {
+	if item == nil || item.done {
+		return nil
+	}
+	item.done = true
+
+	var events []string
+	if item.itemType == "function_call" {
+		events = append(events, s.event("response.function_call_arguments.done", map[string]interface{}{
+			"item_id":      item.id,
+			"output_index": item.index,
+			"arguments":    item.content.String(),
+		}))
+	} else {
+		events = append(events,
+			s.event("response.output_text.done", map[string]interface{}{
+				"item_id":       item.id,
+				"output_index":  item.index,
+				"content_index": 0,
+				"text":          item.content.String(),
+			}),
+			s.event("response.content_part.done", map[string]interface{}{
+				"item_id":       item.id,
+				"output_index":  item.index,
+				"content_index": 0,
+				"part":          item.outputText(),
+			}),
+		)
+	}
+	return append(events, s.event("response.output_item.done", map[string]interface{}{
+		"output_index": item.index,
+		"item":         item.object("completed"),
+	}))
+}
+
+// fail 标记失败并发送 response.failed
+func (s *responsesStreamState) fail(code, message string) []string {
+	if s.done {
+		return nil
+	}
+	s.errorCode = code
+	s.errorMessage = message
+	return s.complete("failed")
+}
+
+// complete 结束所有输出项并发送最终事件（response.completed、response.incomplete 或 response.failed）
+func (s *responsesStreamState) complete(status string) []string {
+	if s.done {
+		return nil
+	}
+	if status == "completed" && s.incompleteReason != "" {
+		status = "incomplete"
+	}
+	events := s.start()
+	for _, item := range s.items {
+		events = append(events, s.closeItem(item)...)
+	}
+	s.done = true
+	s.status = status
+	return append(events, s.event("response."+status, map[string]interface{}{"response": s.responseObject(status)}))
+}
+
+// Finish 上游流结束但未收到结束标志时按未完成处理
+func (s *responsesStreamState) Finish() []string {
+	if s.done {
+		return nil
+	}
+	return s.complete("incomplete")
+}
+
+// Response 返回累积的最终响应（文本输出格式与非流式转换一致）
+func (s *responsesStreamState) Response() *types.ResponsesResponse {
+	if !s.done {
+		return nil
+	}
+	output := []types.ResponsesItem{}
+	for _, item := range s.items {
+		if item.itemType == "function_call" {
+			output = append(output, types.ResponsesItem{
+				ID:        item.id,
+				Type:      "function_call",
+				CallID:    item.callID,
+				Name:      item.name,
+				Arguments: item.content.String(),
+				Status:    "completed",
+			})
+			continue
+		}
+		output = append(output, types.ResponsesItem{Type: "text", Content: item.content.String()})
+	}
+	return &types.ResponsesResponse{
+		ID:         s.responseID,
+		Model:      s.model,
+		Output:     output,
+		Status:     s.status,
+		PreviousID: s.previousResponseID,
+		Usage: types.ResponsesUsage{
+			PromptTokens:     s.inputTokens,
+			CompletionTokens: s.outputTokens,
+			TotalTokens:      s.inputTokens + s.outputTokens,
+		},
+		Created: s.createdAt,
+	}
+}
+
+// parseSSEData 解析 "data:" 行的 JSON，非数据行或 [DONE] 时 ok 为 false
+func parseSSEData(line string) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return nil, false
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if data == "" || data == "[DONE]" {
+		return nil, false
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return nil, false
+	}
+	return payload, true
+}
+
+// isSSEDone 判断是否为 OpenAI 的 data: [DONE] 结束标志
+func isSSEDone(line string) bool {
+	return strings.HasPrefix(line, "data:") && strings.TrimSpace(strings.TrimPrefix(line, "data:")) == "[DONE]"
+}
+
+// upstreamErrorMessage 提取上游错误对象中的类型和消息
+func upstreamErrorMessage(errObj map[string]interface{}) (string, string) {
+	code, _ := errObj["type"].(string)
+	if c, ok := errObj["code"].(string); ok && c != "" {
+		code = c
+	}
+	if code == "" {
+		code = "server_error"
+	}
+	message, _ := errObj["message"].(string)
+	return code, message
+}
+
+// ============== Claude Messages 流 ==============
+
+// claudeResponsesStream Claude SSE → Responses 事件
+type claudeResponsesStream struct {
+	*responsesStreamState
+	toolCalls map[int]*responsesOutputItem // Claude 内容块索引 → 工具调用输出项
+}
+
+func (s *claudeResponsesStream) ProcessLine(line string) []string {
+	payload, ok := parseSSEData(line)
+	if !ok {
+		return nil
+	}
+
+	eventType, _ := payload["type"].(string)
+	switch eventType {
+	case "message_start":
+		message, _ := payload["message"].(map[string]interface{})
+		if model, ok := message["model"].(string); ok && model != "" {
+			s.model = model
+		}
+		if usage, ok := message["usage"].(map[string]interface{}); ok {
+			s.inputTokens = jsonInt(usage["input_tokens"])
+			s.outputTokens = jsonInt(usage["output_tokens"])
+		}
+		return s.start()
+
+	case "content_block_start":
+		block, _ := payload["content_block"].(map[string]interface{})
+		if blockType, _ := block["type"].(string); blockType == "tool_use" {
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			item, events := s.startToolCall(id, name)
+			if item != nil {
+				s.toolCalls[jsonInt(payload["index"])] = item
+			}
+			return events
+		}
+
+	case "content_block_delta":
+		delta, _ := payload["delta"].(map[string]interface{})
+		switch deltaType, _ := delta["type"].(string); deltaType {
+		case "text_delta":
+			text, _ := delta["text"].(string)
+			return s.addText(text)
+		case "input_json_delta":
+			partial, _ := delta["partial_json"].(string)
+			return s.addArguments(s.toolCalls[jsonInt(payload["index"])], partial)
+		}
+
+	case "content_block_stop":
+		if item, ok := s.toolCalls[jsonInt(payload["index"])]; ok && !s.done {
+			return s.closeItem(item)
+		}
+
+	case "message_delta":
+		if delta, ok := payload["delta"].(map[string]interface{}); ok {
+			switch stopReason, _ := delta["stop_reason"].(string); stopReason {
+			case "max_tokens":
+				s.incompleteReason = "max_output_tokens"
+			case "refusal":
+				s.incompleteReason = "content_filter"
+			}
+		}
+		if usage, ok := payload["usage"].(map[string]interface{}); ok {
+			if v, exists := usage["input_tokens"]; exists {
+				s.inputTokens = jsonInt(v)
+			}
+			s.outputTokens = jsonInt(usage["output_tokens"])
+		}
+
+	case "message_stop":
+		return s.complete("completed")
+
+	case "error":
+		errObj, _ := payload["error"].(map[string]interface{})
+		return s.fail(upstreamErrorMessage(errObj))
+	}
+	return nil
+}
+
+// ============== OpenAI Chat Completions 流 ==============
+
+// openAIChatResponsesStream Chat Completions SSE → Responses 事件
+type openAIChatResponsesStream struct {
+	*responsesStreamState
+	toolCalls map[int]*responsesOutputItem // delta.tool_calls[].index → 工具调用输出项
+}
+
+func (s *openAIChatResponsesStream) ProcessLine(line string) []string {
+	if isSSEDone(line) {
+		return s.complete("completed")
+	}
+	payload, ok := parseSSEData(line)
+	if !ok {
+		return nil
+	}
+	if errObj, ok := payload["error"].(map[string]interface{}); ok {
+		return s.fail(upstreamErrorMessage(errObj))
+	}
+
+	s.recordChunkMeta(payload)
+
+	choices, _ := payload["choices"].([]interface{})
+	if len(choices) == 0 {
+		return nil
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	if finishReason, _ := choice["finish_reason"].(string); finishReason == "length" {
+		s.incompleteReason = "max_output_tokens"
+	}
+	delta, _ := choice["delta"].(map[string]interface{})
+	events := s.start()
+	if content, _ := delta["content"].(string); content != "" {
+		events = append(events, s.addText(content)...)
+	}
+	toolCalls, _ := delta["tool_calls"].([]interface{})
+	for _, tc := range toolCalls {
+		events = append(events, s.addToolCallDelta(tc)...)
+	}
+	return events
+}
+
+// addToolCallDelta 处理 delta.tool_calls 中的一项：首个分片带 id 和函数名，后续分片只带参数增量
+func (s *openAIChatResponsesStream) addToolCallDelta(tc interface{}) []string {
+	toolCall, _ := tc.(map[string]interface{})
+	index := jsonInt(toolCall["index"])
+	function, _ := toolCall["function"].(map[string]interface{})
+
+	var events []string
+	item, ok := s.toolCalls[index]
+	if !ok {
+		id, _ := toolCall["id"].(string)
+		name, _ := function["name"].(string)
+		item, events = s.startToolCall(id, name)
+		if item == nil {
+			return nil
+		}
+		s.toolCalls[index] = item
+	}
+	arguments, _ := function["arguments"].(string)
+	return append(events, s.addArguments(item, arguments)...)
+}
+
+// recordChunkMeta 记录数据块中的模型名称和用量
+func (s *responsesStreamState) recordChunkMeta(payload map[string]interface{}) {
+	if model, ok := payload["model"].(string); ok && model != "" {
+		s.model = model
+	}
+	if usage, ok := payload["usage"].(map[string]interface{}); ok {
+		s.inputTokens = jsonInt(usage["prompt_tokens"])
+		s.outputTokens = jsonInt(usage["completion_tokens"])
+	}
+}
+
+// ============== OpenAI Completions 流 ==============
+
+// openAICompletionsResponsesStream Completions SSE → Responses 事件
+type openAICompletionsResponsesStream struct {
+	*responsesStreamState
+}
+
+func (s *openAICompletionsResponsesStream) ProcessLine(line string) []string {
+	if isSSEDone(line) {
+		return s.complete("completed")
+	}
+	payload, ok := parseSSEData(line)
+	if !ok {
+		return nil
+	}
+	if errObj, ok := payload["error"].(map[string]interface{}); ok {
+		return s.fail(upstreamErrorMessage(errObj))
+	}
+
+	s.recordChunkMeta(payload)
+
+	choices, _ := payload["choices"].([]interface{})
+	if len(choices) == 0 {
+		return nil
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	if finishReason, _ := choice["finish_reason"].(string); finishReason == "length" {
+		s.incompleteReason = "max_output_tokens"
+	}
+	text, _ := choice["text"].(string)
+	if text == "" {
+		return s.start()
+	}
+	return s.addText(text)
+}
+
+// ============== Responses 透传流 ==============
+
+// passthroughResponsesStream 原样转发上游 Responses 事件，从最终事件中解析响应
+type passthroughResponsesStream struct {
+	converter *ResponsesPassthroughConverter
+	response  *types.ResponsesResponse
+}
+
+func (s *passthroughResponsesStream) ProcessLine(line string) []string {
+	if payload, ok := parseSSEData(line); ok {
+		switch payload["type"] {
+		case "response.completed", "response.incomplete", "response.failed":
+			if respMap, ok := payload["response"].(map[string]interface{}); ok {
+				s.response, _ = s.converter.FromProviderResponse(respMap, "")
+			}
+		}
+	}
+	return []string{line + "\n"}
+}
+
+func (s *passthroughResponsesStream) Finish() []string {
+	return nil
+}
+
+func (s *passthroughResponsesStream) Response() *types.ResponsesResponse {
+	return s.response
+}
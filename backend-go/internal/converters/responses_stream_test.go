@@ -0,0 +1,252 @@
+package converters
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// runResponsesStream 依次处理上游 SSE 行，返回事件类型序列和解析后的事件
+func runResponsesStream(conv ResponsesStreamConverter, upstream string) ([]string, []map[string]interface{}) {
+	var outputs []string
+	for _, line := range strings.Split(upstream, "\n") {
+		outputs = append(outputs, conv.ProcessLine(line)...)
+	}
+	outputs = append(outputs, conv.Finish()...)
+
+	var eventTypes []string
+	var events []map[string]interface{}
+	for _, out := range outputs {
+		for _, line := range strings.Split(out, "\n") {
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			var event map[string]interface{}
+			json.Unmarshal([]byte(data), &event)
+			eventTypes = append(eventTypes, event["type"].(string))
+			events = append(events, event)
+		}
+	}
+	return eventTypes, events
+}
+
+func TestClaudeResponsesStream(t *testing.T) {
+	upstream := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","model":"claude-x","usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":5}}
+
+event: message_stop
+data: {"type":"message_stop"}`
+
+	conv := (&ClaudeConverter{}).NewStreamConverter("gpt-5", "resp_prev")
+	eventTypes, events := runResponsesStream(conv, upstream)
+
+	expected := []string{
+		"response.created", "response.in_progress",
+		"response.output_item.added", "response.content_part.added",
+		"response.output_text.delta", "response.output_text.delta",
+		"response.output_text.done", "response.content_part.done", "response.output_item.done",
+		"response.completed",
+	}
+	if strings.Join(eventTypes, ",") != strings.Join(expected, ",") {
+		t.Fatalf("事件序列 = %v", eventTypes)
+	}
+	for i, event := range events {
+		if int(event["sequence_number"].(float64)) != i {
+			t.Errorf("事件 %d 的 sequence_number = %v", i, event["sequence_number"])
+		}
+	}
+
+	completed := events[len(events)-1]["response"].(map[string]interface{})
+	if completed["status"] != "completed" || completed["previous_response_id"] != "resp_prev" || completed["model"] != "claude-x" {
+		t.Errorf("response.completed = %v", completed)
+	}
+	usage := completed["usage"].(map[string]interface{})
+	if usage["input_tokens"] != 10.0 || usage["output_tokens"] != 5.0 || usage["total_tokens"] != 15.0 {
+		t.Errorf("usage = %v", usage)
+	}
+
+	resp := conv.Response()
+	if resp == nil || resp.Status != "completed" || resp.ID != completed["id"] {
+		t.Fatalf("最终响应 = %+v", resp)
+	}
+	if len(resp.Output) != 1 || resp.Output[0].Content != "Hello" || resp.Usage.TotalTokens != 15 {
+		t.Errorf("最终响应 = %+v", resp)
+	}
+}
+
+func TestClaudeResponsesStream_Error(t *testing.T) {
+	upstream := `data: {"type":"message_start","message":{"usage":{"input_tokens":3}}}
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+
+	conv := (&ClaudeConverter{}).NewStreamConverter("m", "")
+	eventTypes, events := runResponsesStream(conv, upstream)
+
+	if eventTypes[len(eventTypes)-1] != "response.failed" {
+		t.Fatalf("事件序列 = %v", eventTypes)
+	}
+	errObj := events[len(events)-1]["response"].(map[string]interface{})["error"].(map[string]interface{})
+	if errObj["code"] != "overloaded_error" || errObj["message"] != "Overloaded" {
+		t.Errorf("error = %v", errObj)
+	}
+	if resp := conv.Response(); resp == nil || resp.Status != "failed" {
+		t.Errorf("最终响应 = %+v", resp)
+	}
+}
+
+func TestOpenAIChatResponsesStream(t *testing.T) {
+	upstream := `data: {"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}
+
+data: {"id":"c1","choices":[{"index":0,"delta":{"content":"Hi"}}]}
+
+data: {"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"length"}]}
+
+data: {"id":"c1","choices":[],"usage":{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}}
+
+data: [DONE]`
+
+	conv := (&OpenAIChatConverter{}).NewStreamConverter("gpt-4o", "")
+	eventTypes, events := runResponsesStream(conv, upstream)
+
+	if eventTypes[0] != "response.created" || eventTypes[len(eventTypes)-1] != "response.incomplete" {
+		t.Fatalf("事件序列 = %v", eventTypes)
+	}
+	final := events[len(events)-1]["response"].(map[string]interface{})
+	details := final["incomplete_details"].(map[string]interface{})
+	if details["reason"] != "max_output_tokens" {
+		t.Errorf("incomplete_details = %v", details)
+	}
+	if usage := final["usage"].(map[string]interface{}); usage["total_tokens"] != 6.0 {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+func TestOpenAICompletionsResponsesStream_MissingDone(t *testing.T) {
+	upstream := `data: {"choices":[{"text":"abc"}]}`
+
+	conv := (&OpenAICompletionsConverter{}).NewStreamConverter("m", "")
+	eventTypes, _ := runResponsesStream(conv, upstream)
+
+	// 上游未发送 [DONE] 时补发 response.incomplete
+	if eventTypes[len(eventTypes)-1] != "response.incomplete" {
+		t.Fatalf("事件序列 = %v", eventTypes)
+	}
+	if resp := conv.Response(); resp == nil || resp.Status != "incomplete" || resp.Output[0].Content != "abc" {
+		t.Errorf("最终响应 = %+v", resp)
+	}
+}
+
+func TestPassthroughResponsesStream(t *testing.T) {
+	upstream := `event: response.output_text.delta
+data: {"type":"response.output_text.delta","delta":"Hi"}
+
+event: response.completed
+data: {"type":"response.completed","response":{"id":"resp_up","status":"completed","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Hi"}]}]}}`
+
+	conv := (&ResponsesPassthroughConverter{}).NewStreamConverter("m", "")
+	var outputs []string
+	for _, line := range strings.Split(upstream, "\n") {
+		outputs = append(outputs, conv.ProcessLine(line)...)
+	}
+	if strings.Join(outputs, "") != upstream+"\n" {
+		t.Errorf("透传输出与上游不一致:\n%s", strings.Join(outputs, ""))
+	}
+	if resp := conv.Response(); resp == nil || resp.ID != "resp_up" || resp.Status != "completed" {
+		t.Errorf("最终响应 = %+v", resp)
+	}
+}
+
+func TestClaudeResponsesStream_ToolUse(t *testing.T) {
+	upstream := `data: {"type":"message_start","message":{"model":"claude-x","usage":{"input_tokens":10}}}
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"查一下"}}
+data: {"type":"content_block_stop","index":0}
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"北京\"}"}}
+data: {"type":"content_block_stop","index":1}
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":8}}
+data: {"type":"message_stop"}`
+
+	conv := (&ClaudeConverter{}).NewStreamConverter("m", "")
+	eventTypes, events := runResponsesStream(conv, upstream)
+
+	expected := []string{
+		"response.created", "response.in_progress",
+		"response.output_item.added", "response.content_part.added", "response.output_text.delta",
+		"response.output_text.done", "response.content_part.done", "response.output_item.done",
+		"response.output_item.added", "response.function_call_arguments.delta", "response.function_call_arguments.delta",
+		"response.function_call_arguments.done", "response.output_item.done",
+		"response.completed",
+	}
+	if strings.Join(eventTypes, ",") != strings.Join(expected, ",") {
+		t.Fatalf("事件序列 = %v", eventTypes)
+	}
+
+	added := events[8]
+	item := added["item"].(map[string]interface{})
+	if added["output_index"] != 1.0 || item["type"] != "function_call" || item["call_id"] != "toolu_1" || item["name"] != "get_weather" || item["arguments"] != "" {
+		t.Errorf("output_item.added = %v", added)
+	}
+	if delta := events[9]; delta["item_id"] != item["id"] || delta["delta"] != `{"city":` {
+		t.Errorf("function_call_arguments.delta = %v", delta)
+	}
+	if done := events[11]; done["arguments"] != `{"city":"北京"}` || done["output_index"] != 1.0 {
+		t.Errorf("function_call_arguments.done = %v", done)
+	}
+
+	output := events[len(events)-1]["response"].(map[string]interface{})["output"].([]interface{})
+	if len(output) != 2 || output[1].(map[string]interface{})["arguments"] != `{"city":"北京"}` {
+		t.Errorf("response.completed.output = %v", output)
+	}
+	resp := conv.Response()
+	if len(resp.Output) != 2 || resp.Output[1].Type != "function_call" || resp.Output[1].CallID != "toolu_1" || resp.Output[1].Arguments != `{"city":"北京"}` {
+		t.Errorf("最终响应 = %+v", resp.Output)
+	}
+}
+
+func TestOpenAIChatResponsesStream_ToolCalls(t *testing.T) {
+	upstream := `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":\"北京\"}"}}]}}]}
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}
+data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+data: [DONE]`
+
+	conv := (&OpenAIChatConverter{}).NewStreamConverter("gpt-4o", "")
+	eventTypes, events := runResponsesStream(conv, upstream)
+
+	expected := []string{
+		"response.created", "response.in_progress",
+		"response.output_item.added", "response.function_call_arguments.delta",
+		"response.output_item.added", "response.function_call_arguments.delta",
+		"response.function_call_arguments.done", "response.output_item.done",
+		"response.function_call_arguments.done", "response.output_item.done",
+		"response.completed",
+	}
+	if strings.Join(eventTypes, ",") != strings.Join(expected, ",") {
+		t.Fatalf("事件序列 = %v", eventTypes)
+	}
+	if item := events[4]["item"].(map[string]interface{}); events[4]["output_index"] != 1.0 || item["call_id"] != "call_2" || item["name"] != "get_time" {
+		t.Errorf("第二个 output_item.added = %v", events[4])
+	}
+	if done := events[6]; done["arguments"] != `{"city":"北京"}` || done["output_index"] != 0.0 {
+		t.Errorf("function_call_arguments.done = %v", done)
+	}
+
+	resp := conv.Response()
+	if resp.Status != "completed" || len(resp.Output) != 2 || resp.Output[0].Name != "get_weather" || resp.Output[1].Arguments != "{}" {
+		t.Errorf("最终响应 = %+v", resp)
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/batch"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/middleware"
+)
+
+// ============== Message Batches API（/v1/messages/batches） ==============
+// 批处理中的请求由本地队列按配置的并发数逐个经 Messages 渠道池执行，
+// 与 /v1/messages 共享模型路由、密钥故障转移和用量预算，适用于不提供批处理接口的中转和 OpenAI / Gemini 渠道
+
+const (
+	defaultBatchListLimit = 20
+	maxBatchListLimit     = 1000
+)
+
+// NewBatchExecutor 创建批处理请求执行器：以内部请求的方式调用 Messages 代理流程
+func NewBatchExecutor(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) batch.Executor {
+	execute := newInternalExecutor("/v1/messages", func(c *gin.Context) {
+		proxyMessages(c, envCfg, cfgManager, nil)
+	})
+	return func(params []byte, headers http.Header) (int, []byte) {
+		headers = headers.Clone()
+		if headers.Get("anthropic-version") == "" {
+			headers.Set("anthropic-version", "2023-06-01")
+		}
+		return execute(context.Background(), params, headers)
+	}
+}
+
+// batchRequestHeaders 创建批处理时需要保留、执行请求时附带的请求头
+func batchRequestHeaders(c *gin.Context) http.Header {
+	headers := http.Header{}
+	for _, key := range []string{"anthropic-version", "anthropic-beta"} {
+		if value := c.GetHeader(key); value != "" {
+			headers.Set(key, value)
+		}
+	}
+	return headers
+}
+
+// CreateBatch 创建批处理
+func CreateBatch(envCfg *config.EnvConfig, manager *batch.Manager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var body struct {
+			Requests []batch.Request `json:"requests"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(400, anthropicError("invalid_request_error", "Invalid JSON body: "+err.Error()))
+			return
+		}
+
+		created, err := manager.Create(body.Requests, batchRequestHeaders(c))
+		if err != nil {
+			c.JSON(400, anthropicError("invalid_request_error", err.Error()))
+			return
+		}
+		c.JSON(200, withResultsURL(c, created))
+	})
+}
+
+// GetBatch 获取批处理
+func GetBatch(envCfg *config.EnvConfig, manager *batch.Manager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		found, ok := manager.Get(c.Param("id"))
+		if !ok {
+			c.JSON(404, anthropicError("not_found_error", batch.ErrNotFound.Error()))
+			return
+		}
+		c.JSON(200, withResultsURL(c, found))
+	})
+}
+
+// ListBatches 分页列出批处理（按创建时间倒序，支持 limit、before_id、after_id）
+func ListBatches(envCfg *config.EnvConfig, manager *batch.Manager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		limit := defaultBatchListLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxBatchListLimit {
+				c.JSON(400, anthropicError("invalid_request_error", "limit 必须在 1-1000 之间"))
+				return
+			}
+			limit = n
+		}
+
+		all := manager.List()
+		start, end := 0, len(all)
+		if afterID := c.Query("after_id"); afterID != "" {
+			index := indexOfBatch(all, afterID)
+			if index < 0 {
+				c.JSON(400, anthropicError("invalid_request_error", "after_id 对应的批处理不存在: "+afterID))
+				return
+			}
+			start = index + 1
+			end = min(start+limit, len(all))
+		} else if beforeID := c.Query("before_id"); beforeID != "" {
+			end = indexOfBatch(all, beforeID)
+			if end < 0 {
+				c.JSON(400, anthropicError("invalid_request_error", "before_id 对应的批处理不存在: "+beforeID))
+				return
+			}
+			start = max(end-limit, 0)
+		} else {
+			end = min(limit, len(all))
+		}
+
+		page := all[start:end]
+		data := make([]batch.Batch, len(page))
+		for i, b := range page {
+			data[i] = withResultsURL(c, b)
+		}
+
+		resp := gin.H{
+			"data":     data,
+			"has_more": end < len(all),
+			"first_id": nil,
+			"last_id":  nil,
+		}
+		if c.Query("before_id") != "" {
+			resp["has_more"] = start > 0
+		}
+		if len(data) > 0 {
+			resp["first_id"] = data[0].ID
+			resp["last_id"] = data[len(data)-1].ID
+		}
+		c.JSON(200, resp)
+	})
+}
+
+// CancelBatch 取消批处理
+func CancelBatch(envCfg *config.EnvConfig, manager *batch.Manager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		canceled, err := manager.Cancel(c.Param("id"))
+		if err != nil {
+			c.JSON(404, anthropicError("not_found_error", err.Error()))
+			return
+		}
+		c.JSON(200, withResultsURL(c, canceled))
+	})
+}
+
+// DeleteBatch 删除已结束的批处理
+func DeleteBatch(envCfg *config.EnvConfig, manager *batch.Manager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		id := c.Param("id")
+		if err := manager.Delete(id); err != nil {
+			respondBatchError(c, err)
+			return
+		}
+		c.JSON(200, gin.H{"id": id, "type": "message_batch_deleted"})
+	})
+}
+
+// GetBatchResults 以 JSONL 格式返回已结束批处理的结果
+func GetBatchResults(envCfg *config.EnvConfig, manager *batch.Manager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		path, err := manager.ResultsPath(c.Param("id"))
+		if err != nil {
+			respondBatchError(c, err)
+			return
+		}
+		c.Header("Content-Type", "application/x-jsonl")
+		c.File(path)
+	})
+}
+
+// respondBatchError 返回批处理操作错误
+func respondBatchError(c *gin.Context, err error) {
+	if err == batch.ErrNotFound {
+		c.JSON(404, anthropicError("not_found_error", err.Error()))
+		return
+	}
+	c.JSON(400, anthropicError("invalid_request_error", err.Error()))
+}
+
+// withResultsURL 为已结束的批处理填写结果下载地址
+func withResultsURL(c *gin.Context, b batch.Batch) batch.Batch {
+	if b.ProcessingStatus != batch.StatusEnded {
+		return b
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	url := scheme + "://" + c.Request.Host + "/v1/messages/batches/" + b.ID + "/results"
+	b.ResultsURL = &url
+	return b
+}
+
+// indexOfBatch 查找批处理在列表中的位置，不存在时返回 -1
+func indexOfBatch(batches []batch.Batch, id string) int {
+	for i, b := range batches {
+		if b.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/batch"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+)
+
+func TestListBatches_UnknownCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	executor := func(params []byte, headers http.Header) (int, []byte) {
+		return 200, []byte(`{"type":"message"}`)
+	}
+	manager, err := batch.NewManager(t.TempDir(), executor, func() int { return 1 })
+	if err != nil {
+		t.Fatalf("创建批处理管理器失败: %v", err)
+	}
+	created, err := manager.Create([]batch.Request{{CustomID: "a", Params: json.RawMessage(`{}`)}}, http.Header{})
+	if err != nil {
+		t.Fatalf("创建批处理失败: %v", err)
+	}
+
+	envCfg := &config.EnvConfig{ProxyAccessKey: "test-key"}
+	router := gin.New()
+	router.GET("/v1/messages/batches", ListBatches(envCfg, manager))
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{query: "", status: 200},
+		{query: "?after_id=" + created.ID, status: 200},
+		{query: "?before_id=" + created.ID, status: 200},
+		{query: "?after_id=msgbatch_unknown", status: 400},
+		{query: "?before_id=msgbatch_unknown", status: 400},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/messages/batches"+tt.query, nil)
+		req.Header.Set("x-api-key", "test-key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: 状态码 = %d, 响应 = %s", tt.query, w.Code, w.Body.String())
+			continue
+		}
+		if tt.status == 400 {
+			var body struct {
+				Error struct {
+					Type string `json:"type"`
+				} `json:"error"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body.Error.Type != "invalid_request_error" {
+				t.Errorf("%s: 错误类型 = %s", tt.query, body.Error.Type)
+			}
+		}
+	}
+}
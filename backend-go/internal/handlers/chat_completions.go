@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/converters"
+	"github.com/BenedictKing/claude-proxy/internal/middleware"
+	"github.com/BenedictKing/claude-proxy/internal/providers"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ChatCompletionsHandler OpenAI Chat Completions 入口（/v1/chat/completions）
+// 使用 Messages 渠道池：openai 渠道直接透传，claude / gemini 等渠道经 Claude 格式转换
+func ChatCompletionsHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(400, openAIError("Failed to read request body"))
+			return
+		}
+
+		claudeReq, err := converters.ChatCompletionsToClaudeRequest(bodyBytes)
+		if err != nil {
+			c.JSON(400, openAIError(err.Error()))
+			return
+		}
+		claudeBody, err := json.Marshal(claudeReq)
+		if err != nil {
+			c.JSON(500, openAIError(err.Error()))
+			return
+		}
+
+		var chatReq struct {
+			Model         string `json:"model"`
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		_ = json.Unmarshal(bodyBytes, &chatReq)
+
+		// 后续流程按 Claude 请求处理
+		c.Request.Body = io.NopCloser(bytes.NewReader(claudeBody))
+		proxyMessages(c, envCfg, cfgManager, &chatCompletionsIngress{
+			body:         bodyBytes,
+			model:        chatReq.Model,
+			includeUsage: chatReq.StreamOptions.IncludeUsage,
+		})
+	})
+}
+
+// chatCompletionsIngress Chat Completions 入口适配
+type chatCompletionsIngress struct {
+	body         []byte // 原始请求体（透传用）
+	model        string
+	includeUsage bool
+}
+
+func (i *chatCompletionsIngress) passthroughProvider(upstream *config.UpstreamConfig) providers.Provider {
+	if upstream.ServiceType != "openai" {
+		return nil
+	}
+	return &providers.ChatCompletionsPassthroughProvider{Body: i.body}
+}
+
+func (i *chatCompletionsIngress) convertResponse(claudeResp *types.ClaudeResponse) interface{} {
+	return converters.ClaudeResponseToChatCompletion(claudeResp, i.model)
+}
+
+func (i *chatCompletionsIngress) newStreamConverter() streamConverter {
+	return converters.NewChatCompletionsStreamConverter(i.model, i.includeUsage)
+}
+
+func (i *chatCompletionsIngress) convertError(status int, body []byte) interface{} {
+	upstreamErr := parseUpstreamError(body)
+	errType := upstreamErr.Type
+	if errType == "" {
+		errType = openAIErrorType(status)
+	}
+	var code interface{}
+	if upstreamErr.Code != "" {
+		code = upstreamErr.Code
+	}
+	return gin.H{
+		"error": gin.H{
+			"message": upstreamErr.Message,
+			"type":    errType,
+			"code":    code,
+		},
+	}
+}
+
+// openAIErrorType 上游未给出错误类型时按状态码推断
+func openAIErrorType(status int) string {
+	switch {
+	case status == 401:
+		return "authentication_error"
+	case status == 403:
+		return "permission_error"
+	case status == 404:
+		return "not_found_error"
+	case status == 429:
+		return "rate_limit_error"
+	case status >= 500:
+		return "api_error"
+	default:
+		return "invalid_request_error"
+	}
+}
+
+// openAIError 构造 OpenAI 格式的错误响应
+func openAIError(message string) gin.H {
+	return gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    "invalid_request_error",
+		},
+	}
+}
@@ -30,6 +30,14 @@ func GetUpstreams(cfgManager *config.ConfigManager) gin.HandlerFunc {
 				"website":            up.Website,
 				"insecureSkipVerify": up.InsecureSkipVerify,
 				"modelMapping":       up.ModelMapping,
+				"models":             up.Models,
+				"weight":             up.Weight,
+				"failoverRules":      up.FailoverRules,
+				"keyBudgets":         up.KeyBudgets,
+				"discoverModels":     up.DiscoverModels,
+				"reasoningEffort":    up.ReasoningEffort,
+				"keyStates":          cfgManager.GetKeyStatuses(&cfg.Upstream[i]),
+				"circuitBreaker":     cfgManager.GetCircuitState(&cfg.Upstream[i]),
 				"latency":            nil,
 				"status":             "unknown",
 			}
@@ -53,7 +61,11 @@ func AddUpstream(cfgManager *config.ConfigManager) gin.HandlerFunc {
 		}
 
 		if err := cfgManager.AddUpstream(upstream); err != nil {
-			c.JSON(500, gin.H{"error": "Failed to save config"})
+			if isConfigValidationError(err) {
+				c.JSON(400, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(500, gin.H{"error": "Failed to save config"})
+			}
 			return
 		}
 
@@ -83,6 +95,8 @@ func UpdateUpstream(cfgManager *config.ConfigManager) gin.HandlerFunc {
 		if err := cfgManager.UpdateUpstream(id, updates); err != nil {
 			if strings.Contains(err.Error(), "无效的上游索引") {
 				c.JSON(404, gin.H{"error": "Upstream not found"})
+			} else if isConfigValidationError(err) {
+				c.JSON(400, gin.H{"error": err.Error()})
 			} else {
 				c.JSON(500, gin.H{"error": "Failed to save config"})
 			}
@@ -221,34 +235,227 @@ func SetCurrentUpstream(cfgManager *config.ConfigManager) gin.HandlerFunc {
 	}
 }
 
+// ResetCircuitBreaker 手动重置渠道熔断器
+func ResetCircuitBreaker(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resetCircuitBreaker(c, cfgManager, cfgManager.GetConfig().Upstream)
+	}
+}
+
+// ResetResponsesCircuitBreaker 手动重置 Responses 渠道熔断器
+func ResetResponsesCircuitBreaker(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resetCircuitBreaker(c, cfgManager, cfgManager.GetConfig().ResponsesUpstream)
+	}
+}
+
+// resetCircuitBreaker 重置指定索引渠道的熔断器
+func resetCircuitBreaker(c *gin.Context, cfgManager *config.ConfigManager, upstreams []config.UpstreamConfig) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid upstream ID"})
+		return
+	}
+	if id < 0 || id >= len(upstreams) {
+		c.JSON(404, gin.H{"error": "Upstream not found"})
+		return
+	}
+
+	cfgManager.ResetCircuitBreaker(&upstreams[id])
+	c.JSON(200, gin.H{
+		"message":        "熔断器已重置",
+		"circuitBreaker": cfgManager.GetCircuitState(&upstreams[id]),
+	})
+}
+
+// SetKeyState 设置 Messages 渠道密钥状态
+func SetKeyState(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setKeyState(c, cfgManager, cfgManager.GetConfig().Upstream)
+	}
+}
+
+// SetResponsesKeyState 设置 Responses 渠道密钥状态
+func SetResponsesKeyState(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setKeyState(c, cfgManager, cfgManager.GetConfig().ResponsesUpstream)
+	}
+}
+
+// setKeyState 设置指定渠道中密钥的状态
+// 请求体：{"state": "active" | "disabled" | "quarantined", "reason": "可选"}，active 会解除隔离/禁用/冷却
+func setKeyState(c *gin.Context, cfgManager *config.ConfigManager, upstreams []config.UpstreamConfig) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid upstream ID"})
+		return
+	}
+	if id < 0 || id >= len(upstreams) {
+		c.JSON(404, gin.H{"error": "Upstream not found"})
+		return
+	}
+
+	apiKey := c.Param("apiKey")
+	found := false
+	for _, key := range upstreams[id].APIKeys {
+		if key == apiKey {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.JSON(404, gin.H{"error": "API key not found"})
+		return
+	}
+
+	var req struct {
+		State  string `json:"state"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := cfgManager.SetKeyState(apiKey, req.State, req.Reason); err != nil {
+		if strings.Contains(err.Error(), "无效的密钥状态") {
+			c.JSON(400, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(500, gin.H{"error": "Failed to save key state"})
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":  "密钥状态已更新",
+		"keyState": cfgManager.GetKeyStatus(&upstreams[id], apiKey),
+	})
+}
+
+// GetRoutingTable 获取 Messages 渠道的模型路由表
+// 可通过 ?model=xxx 查询指定模型最终解析出的故障转移链
+func GetRoutingTable(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondRoutingTable(c, cfgManager.GetRoutingTable(), cfgManager.GetUpstreamFailoverChain)
+	}
+}
+
+// GetResponsesRoutingTable 获取 Responses 渠道的模型路由表
+func GetResponsesRoutingTable(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondRoutingTable(c, cfgManager.GetResponsesRoutingTable(), cfgManager.GetResponsesFailoverChain)
+	}
+}
+
+// respondRoutingTable 输出路由表，并按需解析指定模型的渠道链
+func respondRoutingTable(c *gin.Context, table config.RoutingTable, resolve func(model, sessionID string) ([]config.UpstreamCandidate, error)) {
+	result := gin.H{
+		"routes":   table.Routes,
+		"fallback": table.Fallback,
+	}
+
+	if model := c.Query("model"); model != "" {
+		chain, err := resolve(model, "")
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		result["model"] = model
+		result["resolved"] = config.ToRouteChannels(chain)
+	}
+
+	c.JSON(200, result)
+}
+
+// GetLoadBalance 获取负载均衡配置及渠道运行指标
+func GetLoadBalance(cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := cfgManager.GetConfig()
+
+		channelStrategy := cfg.ChannelLoadBalance
+		if channelStrategy == "" {
+			channelStrategy = config.ChannelStrategyFailover
+		}
+
+		c.JSON(200, gin.H{
+			"strategy":          cfg.LoadBalance,
+			"channelStrategy":   channelStrategy,
+			"channels":          channelBalanceInfo(cfgManager, cfg.Upstream),
+			"responsesChannels": channelBalanceInfo(cfgManager, cfg.ResponsesUpstream),
+		})
+	}
+}
+
+// channelBalanceInfo 汇总渠道权重与运行指标
+func channelBalanceInfo(cfgManager *config.ConfigManager, upstreams []config.UpstreamConfig) []gin.H {
+	result := make([]gin.H, len(upstreams))
+	for i := range upstreams {
+		weight := upstreams[i].Weight
+		if weight <= 0 {
+			weight = 1
+		}
+
+		info := gin.H{
+			"index":   i,
+			"name":    upstreams[i].Name,
+			"weight":  weight,
+			"metrics": nil,
+		}
+		if metrics, ok := cfgManager.GetChannelMetrics(&upstreams[i]); ok {
+			info["metrics"] = metrics
+		}
+		result[i] = info
+	}
+	return result
+}
 
 // UpdateLoadBalance 更新负载均衡策略
+// 请求体字段均为可选：strategy（密钥策略）、channelStrategy（渠道策略）、weights / responsesWeights（渠道索引 → 权重）
 func UpdateLoadBalance(cfgManager *config.ConfigManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			Strategy string `json:"strategy"`
-		}
+		var req config.LoadBalanceUpdate
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid request body"})
 			return
 		}
 
-		if err := cfgManager.SetLoadBalance(req.Strategy); err != nil {
-			if strings.Contains(err.Error(), "无效的负载均衡策略") {
+		if err := cfgManager.UpdateLoadBalance(req); err != nil {
+			if strings.Contains(err.Error(), "无效的负载均衡策略") ||
+				strings.Contains(err.Error(), "无效的渠道权重") {
 				c.JSON(400, gin.H{"error": err.Error()})
+			} else if strings.Contains(err.Error(), "无效的上游索引") {
+				c.JSON(404, gin.H{"error": err.Error()})
 			} else {
 				c.JSON(500, gin.H{"error": "Failed to save config"})
 			}
 			return
 		}
 
+		cfg := cfgManager.GetConfig()
 		c.JSON(200, gin.H{
-			"message":  "负载均衡策略已更新",
-			"strategy": req.Strategy,
+			"message":         "负载均衡策略已更新",
+			"strategy":        cfg.LoadBalance,
+			"channelStrategy": cfg.ChannelLoadBalance,
 		})
 	}
 }
 
+// GetDefaultFailoverRules 获取内置故障转移规则（在渠道自定义规则之后匹配）
+func GetDefaultFailoverRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"rules": config.DefaultFailoverRules,
+		})
+	}
+}
+
+// isConfigValidationError 判断是否为渠道配置校验错误（应返回 400）
+func isConfigValidationError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "无效的模型匹配规则") || strings.Contains(msg, "无效的故障转移规则") ||
+		strings.Contains(msg, "无效的密钥预算") || strings.Contains(msg, "无效的渠道权重")
+}
+
 // PingChannel Ping单个渠道
 func PingChannel(cfgManager *config.ConfigManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -373,6 +580,14 @@ func GetResponsesUpstreams(cfgManager *config.ConfigManager) gin.HandlerFunc {
 				"website":            up.Website,
 				"insecureSkipVerify": up.InsecureSkipVerify,
 				"modelMapping":       up.ModelMapping,
+				"models":             up.Models,
+				"weight":             up.Weight,
+				"failoverRules":      up.FailoverRules,
+				"keyBudgets":         up.KeyBudgets,
+				"discoverModels":     up.DiscoverModels,
+				"reasoningEffort":    up.ReasoningEffort,
+				"keyStates":          cfgManager.GetKeyStatuses(&cfg.ResponsesUpstream[i]),
+				"circuitBreaker":     cfgManager.GetCircuitState(&cfg.ResponsesUpstream[i]),
 				"latency":            nil,
 				"status":             "unknown",
 			}
@@ -396,7 +611,11 @@ func AddResponsesUpstream(cfgManager *config.ConfigManager) gin.HandlerFunc {
 		}
 
 		if err := cfgManager.AddResponsesUpstream(upstream); err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			if isConfigValidationError(err) {
+				c.JSON(400, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(500, gin.H{"error": err.Error()})
+			}
 			return
 		}
 
@@ -421,7 +640,11 @@ func UpdateResponsesUpstream(cfgManager *config.ConfigManager) gin.HandlerFunc {
 		}
 
 		if err := cfgManager.UpdateResponsesUpstream(id, updates); err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			if isConfigValidationError(err) {
+				c.JSON(400, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(500, gin.H{"error": err.Error()})
+			}
 			return
 		}
 
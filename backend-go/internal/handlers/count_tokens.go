@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/middleware"
+	"github.com/BenedictKing/claude-proxy/internal/providers"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+	"github.com/BenedictKing/claude-proxy/internal/utils"
+)
+
+// CountTokensHandler token 计数端点（/v1/messages/count_tokens）
+// 按模型路由选择渠道：claude 渠道透传，gemini 渠道调用上游 countTokens，其他渠道使用本地估算；
+// 上游计数全部失败时同样回退到本地估算。响应统一为 Anthropic 的 {"input_tokens": N} 格式
+// 计数请求不计入渠道熔断、密钥冷却和用量预算，也不占用半开渠道的探测名额（只使用熔断器关闭的渠道）
+func CountTokensHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+		var claudeReq types.ClaudeRequest
+		if err := json.Unmarshal(bodyBytes, &claudeReq); err != nil {
+			c.JSON(400, anthropicError("invalid_request_error", "Invalid JSON body"))
+			return
+		}
+
+		chain, err := cfgManager.GetUpstreamFailoverChain(claudeReq.Model, "")
+		if err != nil {
+			c.JSON(503, gin.H{
+				"error": "未配置任何渠道，请先在管理界面添加渠道",
+				"code":  "NO_UPSTREAM",
+			})
+			return
+		}
+
+		for _, candidate := range chain {
+			upstream := candidate.Upstream
+			if len(upstream.APIKeys) == 0 {
+				continue
+			}
+			// 计数结果不上报熔断器，熔断中和半开的渠道交给正式请求探测
+			if cfgManager.GetCircuitState(upstream).State != config.CircuitClosed {
+				continue
+			}
+
+			switch upstream.ServiceType {
+			case "claude", "gemini":
+				if countTokensUpstream(c, envCfg, cfgManager, upstream) {
+					return
+				}
+			default:
+				// OpenAI 兼容渠道没有计数接口，使用本地估算
+				respondEstimatedTokens(c, bodyBytes)
+				return
+			}
+		}
+
+		log.Printf("⚠️ 所有渠道的 token 计数均失败，使用本地估算")
+		respondEstimatedTokens(c, bodyBytes)
+	})
+}
+
+// countTokensUpstream 在渠道内依次使用各密钥请求上游计数接口，已向客户端输出响应时返回 true
+func countTokensUpstream(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, upstream *config.UpstreamConfig) bool {
+	failedKeys := make(map[string]bool)
+
+	for attempt := 0; attempt < len(upstream.APIKeys); attempt++ {
+		apiKey, err := cfgManager.GetNextAPIKey(upstream, failedKeys)
+		if err != nil {
+			log.Printf("⚠️ 渠道 %s 无可用密钥: %v", upstream.Name, err)
+			return false
+		}
+		failedKeys[apiKey] = true
+
+		var req *http.Request
+		if upstream.ServiceType == "gemini" {
+			req, err = (&providers.GeminiProvider{}).ConvertToCountTokensRequest(c, upstream, apiKey)
+		} else {
+			req, _, err = (&providers.ClaudeProvider{}).ConvertToProviderRequest(c, upstream, apiKey)
+		}
+		if err != nil {
+			log.Printf("⚠️ 构建 token 计数请求失败: %v", err)
+			return false
+		}
+
+		resp, err := sendRequest(req, upstream, envCfg, false)
+		if err != nil {
+			log.Printf("⚠️ 渠道 %s token 计数请求失败: %v", upstream.Name, err)
+			return false
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("⚠️ 读取 token 计数响应失败: %v", err)
+			continue
+		}
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			if upstream.ServiceType == "gemini" {
+				var geminiResp struct {
+					TotalTokens int `json:"totalTokens"`
+				}
+				if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+					log.Printf("⚠️ 解析 Gemini countTokens 响应失败: %v", err)
+					return false
+				}
+				c.JSON(200, gin.H{"input_tokens": geminiResp.TotalTokens})
+				return true
+			}
+			utils.ForwardResponseHeaders(resp.Header, c.Writer)
+			c.Data(resp.StatusCode, "application/json", respBody)
+			return true
+
+		case resp.StatusCode == 400 && upstream.ServiceType == "claude":
+			// 请求本身有误，直接返回上游错误
+			c.Data(resp.StatusCode, "application/json", respBody)
+			return true
+
+		case resp.StatusCode == 404 || resp.StatusCode == 405:
+			// 渠道（如部分中转）不支持计数接口
+			log.Printf("ℹ️ 渠道 %s 不支持 token 计数接口 (状态: %d)", upstream.Name, resp.StatusCode)
+			return false
+		}
+
+		log.Printf("⚠️ 渠道 %s token 计数失败 (密钥: %s, 状态: %d)", upstream.Name, maskAPIKey(apiKey), resp.StatusCode)
+	}
+	return false
+}
+
+// respondEstimatedTokens 返回本地估算的 token 数
+func respondEstimatedTokens(c *gin.Context, body []byte) {
+	tokens, err := utils.EstimateClaudeRequestTokens(body)
+	if err != nil {
+		c.JSON(400, anthropicError("invalid_request_error", fmt.Sprintf("Invalid request: %v", err)))
+		return
+	}
+	c.JSON(200, gin.H{"input_tokens": tokens})
+}
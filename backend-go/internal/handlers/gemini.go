@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/converters"
+	"github.com/BenedictKing/claude-proxy/internal/middleware"
+	"github.com/BenedictKing/claude-proxy/internal/providers"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// GeminiHandler Gemini generateContent 入口（/v1beta/models/{model}:generateContent 和 :streamGenerateContent）
+// 使用 Messages 渠道池：gemini 渠道直接透传，claude / openai 等渠道经 Claude 格式转换
+// 流式响应与 Gemini REST API 一致：alt=sse 时为 SSE，否则逐个输出 JSON 数组元素
+func GeminiHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		// 路径参数形如 gemini-2.0-flash:generateContent
+		model, action, _ := strings.Cut(c.Param("modelAction"), ":")
+		var stream bool
+		switch action {
+		case "generateContent":
+		case "streamGenerateContent":
+			stream = true
+		default:
+			c.JSON(404, geminiError(404, "NOT_FOUND", "Unsupported method: "+action))
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(400, geminiError(400, "INVALID_ARGUMENT", "Failed to read request body"))
+			return
+		}
+
+		claudeReq, err := converters.GeminiToClaudeRequest(bodyBytes, model, stream)
+		if err != nil {
+			c.JSON(400, geminiError(400, "INVALID_ARGUMENT", err.Error()))
+			return
+		}
+		claudeBody, err := json.Marshal(claudeReq)
+		if err != nil {
+			c.JSON(500, geminiError(500, "INTERNAL", err.Error()))
+			return
+		}
+
+		// 后续流程按 Claude 请求处理
+		c.Request.Body = io.NopCloser(bytes.NewReader(claudeBody))
+		proxyMessages(c, envCfg, cfgManager, &geminiIngress{
+			body:   bodyBytes,
+			model:  model,
+			stream: stream,
+			sse:    c.Query("alt") == "sse",
+		})
+	})
+}
+
+// geminiIngress Gemini generateContent 入口适配
+type geminiIngress struct {
+	body   []byte // 原始请求体（透传用）
+	model  string
+	stream bool
+	sse    bool // 流式响应使用 SSE（alt=sse）
+}
+
+func (i *geminiIngress) passthroughProvider(upstream *config.UpstreamConfig) providers.Provider {
+	if upstream.ServiceType != "gemini" {
+		return nil
+	}
+	return &providers.GeminiPassthroughProvider{Body: i.body, Model: i.model, Stream: i.stream}
+}
+
+func (i *geminiIngress) convertResponse(claudeResp *types.ClaudeResponse) interface{} {
+	return converters.ClaudeResponseToGemini(claudeResp, i.model)
+}
+
+func (i *geminiIngress) newStreamConverter() streamConverter {
+	return converters.NewGeminiStreamConverter(i.model)
+}
+
+func (i *geminiIngress) frameStream(converter streamConverter) (string, streamConverter) {
+	if i.sse {
+		return "", nil
+	}
+	return "application/json", &geminiArrayStream{inner: converter}
+}
+
+func (i *geminiIngress) convertError(status int, body []byte) interface{} {
+	upstreamErr := parseUpstreamError(body)
+	errStatus := upstreamErr.Status
+	if errStatus == "" {
+		errStatus = geminiErrorStatus(status)
+	}
+	return geminiError(status, errStatus, upstreamErr.Message)
+}
+
+// geminiArrayStream 未指定 alt=sse 时将 SSE 数据块改写为逐步输出的 JSON 数组
+type geminiArrayStream struct {
+	inner   streamConverter // 为 nil 时输入已是 Gemini SSE（透传）
+	started bool
+}
+
+func (s *geminiArrayStream) Convert(event string) []string {
+	if s.inner == nil {
+		return s.frame([]string{event})
+	}
+	return s.frame(s.inner.Convert(event))
+}
+
+func (s *geminiArrayStream) Finish() []string {
+	var chunks []string
+	if s.inner != nil {
+		chunks = s.frame(s.inner.Finish())
+	}
+	if !s.started {
+		return append(chunks, "[]")
+	}
+	return append(chunks, "]")
+}
+
+// frame 将 SSE 数据行转换为数组元素
+func (s *geminiArrayStream) frame(events []string) []string {
+	var chunks []string
+	for _, event := range events {
+		for _, line := range strings.Split(event, "\n") {
+			data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+			if data = strings.TrimSpace(data); !ok || data == "" {
+				continue
+			}
+			if s.started {
+				chunks = append(chunks, ",\r\n"+data)
+			} else {
+				chunks = append(chunks, "["+data)
+				s.started = true
+			}
+		}
+	}
+	return chunks
+}
+
+// geminiErrorStatus 上游未给出 Gemini 错误状态时按 HTTP 状态码推断（google.rpc.Code）
+func geminiErrorStatus(status int) string {
+	switch {
+	case status == 401:
+		return "UNAUTHENTICATED"
+	case status == 403:
+		return "PERMISSION_DENIED"
+	case status == 404:
+		return "NOT_FOUND"
+	case status == 409:
+		return "ABORTED"
+	case status == 429:
+		return "RESOURCE_EXHAUSTED"
+	case status == 501:
+		return "UNIMPLEMENTED"
+	case status == 503:
+		return "UNAVAILABLE"
+	case status == 504:
+		return "DEADLINE_EXCEEDED"
+	case status >= 500:
+		return "INTERNAL"
+	default:
+		return "INVALID_ARGUMENT"
+	}
+}
+
+// geminiError 构造 Gemini 格式的错误响应
+func geminiError(code int, status, message string) gin.H {
+	return gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+			"status":  status,
+		},
+	}
+}
@@ -22,13 +22,40 @@ func HealthCheck(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gin
 				"upstreamCount":   len(config.Upstream),
 				"currentUpstream": config.CurrentUpstream,
 				"loadBalance":     config.LoadBalance,
+				"channelBalance":  config.ChannelLoadBalance,
+				"stickySessions":  cfgManager.GetStickySessionCount(),
 			},
+			"channels":          channelHealth(cfgManager, config.Upstream),
+			"responsesChannels": channelHealth(cfgManager, config.ResponsesUpstream),
 		}
 
 		c.JSON(200, healthData)
 	}
 }
 
+// channelHealth 汇总渠道熔断器和密钥状态
+func channelHealth(cfgManager *config.ConfigManager, upstreams []config.UpstreamConfig) []gin.H {
+	result := make([]gin.H, len(upstreams))
+	for i := range upstreams {
+		result[i] = gin.H{
+			"index":          i,
+			"name":           upstreams[i].Name,
+			"circuitBreaker": cfgManager.GetCircuitState(&upstreams[i]),
+			"keys":           keyStateCounts(cfgManager, &upstreams[i]),
+		}
+	}
+	return result
+}
+
+// keyStateCounts 按状态统计渠道密钥数量
+func keyStateCounts(cfgManager *config.ConfigManager, upstream *config.UpstreamConfig) map[string]int {
+	counts := map[string]int{}
+	for _, status := range cfgManager.GetKeyStatuses(upstream) {
+		counts[status.State]++
+	}
+	return counts
+}
+
 // getVersion 获取版本信息
 func getVersion() gin.H {
 	// 这些变量在编译时通过 -ldflags 注入
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/providers"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ingressFormat 非 Claude 协议入口的适配器
+// 入口请求先转换为 Claude Messages 格式，复用 Messages 渠道的路由、密钥故障转移和上游转换，
+// 再将 Claude 响应转换回入口协议；原生支持入口协议的渠道直接透传
+type ingressFormat interface {
+	// passthroughProvider 渠道原生支持入口协议时返回透传提供商，否则返回 nil
+	passthroughProvider(upstream *config.UpstreamConfig) providers.Provider
+
+	// convertResponse 将 Claude 响应转换为入口协议的响应体
+	convertResponse(claudeResp *types.ClaudeResponse) interface{}
+
+	// newStreamConverter 创建 Claude 流式事件到入口协议的转换器
+	newStreamConverter() streamConverter
+
+	// convertError 将错误响应体（Claude / OpenAI / Gemini 格式或纯文本）转换为入口协议的错误响应体
+	convertError(status int, body []byte) interface{}
+}
+
+// streamConverter Claude 流式事件转换器
+type streamConverter interface {
+	// Convert 转换一个 Claude 事件，返回需要输出的 SSE 数据
+	Convert(event string) []string
+
+	// Finish 上游流结束时补发的收尾数据
+	Finish() []string
+}
+
+// streamFramer 可选：入口协议的流式响应不使用 SSE 时实现
+type streamFramer interface {
+	// frameStream 包装输出给客户端的流（透传时 converter 为 nil），返回 Content-Type 和包装后的转换器；仍使用 SSE 时返回 nil
+	frameStream(converter streamConverter) (string, streamConverter)
+}
+
+// upstreamError 从错误响应体中提取的错误信息
+type upstreamError struct {
+	Type    string      // Claude / OpenAI 的 error.type
+	Status  string      // Gemini 的 error.status
+	Code    string // OpenAI 的 error.code（Gemini 的数字 code 为 HTTP 状态码，不保留）
+	Message string
+}
+
+// parseUpstreamError 解析错误响应体，兼容 Claude、OpenAI、Gemini 格式、本服务的 {"error": "...", "details": "..."} 以及纯文本
+func parseUpstreamError(body []byte) upstreamError {
+	var parsed struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+		Details string          `json:"details"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return upstreamError{Message: strings.TrimSpace(string(body))}
+	}
+
+	var detail struct {
+		Type    string      `json:"type"`
+		Status  string      `json:"status"`
+		Code    interface{} `json:"code"`
+		Message string      `json:"message"`
+	}
+	var result upstreamError
+	var text string
+	switch {
+	case json.Unmarshal(parsed.Error, &detail) == nil:
+		result = upstreamError{Type: detail.Type, Status: detail.Status, Message: detail.Message}
+		result.Code, _ = detail.Code.(string)
+	case json.Unmarshal(parsed.Error, &text) == nil:
+		result.Message = text
+	default:
+		result.Message = parsed.Message
+	}
+	if parsed.Details != "" {
+		result.Message = fmt.Sprintf("%s: %s", result.Message, parsed.Details)
+	}
+	if result.Message == "" {
+		result.Message = strings.TrimSpace(string(body))
+	}
+	return result
+}
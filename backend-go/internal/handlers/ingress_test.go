@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// marshalValue 将值序列化为 JSON 字符串，便于比较
+func marshalValue(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestChatCompletionsIngress_ConvertError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{
+			name:     "Claude 错误",
+			status:   400,
+			body:     `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: 必须大于 0"}}`,
+			expected: `{"error":{"code":null,"message":"max_tokens: 必须大于 0","type":"invalid_request_error"}}`,
+		},
+		{
+			name:     "OpenAI 错误",
+			status:   429,
+			body:     `{"error":{"message":"quota exceeded","type":"insufficient_quota","code":"insufficient_quota"}}`,
+			expected: `{"error":{"code":"insufficient_quota","message":"quota exceeded","type":"insufficient_quota"}}`,
+		},
+		{
+			name:     "Gemini 错误",
+			status:   400,
+			body:     `{"error":{"code":400,"message":"API key not valid","status":"INVALID_ARGUMENT"}}`,
+			expected: `{"error":{"code":null,"message":"API key not valid","type":"invalid_request_error"}}`,
+		},
+		{
+			name:     "纯文本",
+			status:   502,
+			body:     "Bad Gateway\n",
+			expected: `{"error":{"code":null,"message":"Bad Gateway","type":"api_error"}}`,
+		},
+		{
+			name:     "本服务错误",
+			status:   500,
+			body:     `{"error":"所有上游API密钥都不可用","details":"连接超时"}`,
+			expected: `{"error":{"code":null,"message":"所有上游API密钥都不可用: 连接超时","type":"api_error"}}`,
+		},
+	}
+
+	ingress := &chatCompletionsIngress{model: "gpt-4o"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := marshalValue(ingress.convertError(tt.status, []byte(tt.body))); got != tt.expected {
+				t.Errorf("convertError = %s", got)
+			}
+		})
+	}
+}
+
+func TestGeminiIngress_ConvertError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected string
+	}{
+		{
+			name:     "Claude 错误",
+			status:   429,
+			body:     `{"type":"error","error":{"type":"rate_limit_error","message":"请求过于频繁"}}`,
+			expected: `{"error":{"code":429,"message":"请求过于频繁","status":"RESOURCE_EXHAUSTED"}}`,
+		},
+		{
+			name:     "OpenAI 错误",
+			status:   401,
+			body:     `{"error":{"message":"Incorrect API key","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			expected: `{"error":{"code":401,"message":"Incorrect API key","status":"UNAUTHENTICATED"}}`,
+		},
+		{
+			name:     "Gemini 错误",
+			status:   400,
+			body:     `{"error":{"code":400,"message":"API key not valid","status":"FAILED_PRECONDITION"}}`,
+			expected: `{"error":{"code":400,"message":"API key not valid","status":"FAILED_PRECONDITION"}}`,
+		},
+		{
+			name:     "纯文本",
+			status:   503,
+			body:     "upstream connect error",
+			expected: `{"error":{"code":503,"message":"upstream connect error","status":"UNAVAILABLE"}}`,
+		},
+		{
+			name:     "本服务错误",
+			status:   503,
+			body:     `{"error":"未配置任何渠道，请先在管理界面添加渠道","code":"NO_UPSTREAM"}`,
+			expected: `{"error":{"code":503,"message":"未配置任何渠道，请先在管理界面添加渠道","status":"UNAVAILABLE"}}`,
+		},
+	}
+
+	ingress := &geminiIngress{model: "gemini-2.0-flash"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := marshalValue(ingress.convertError(tt.status, []byte(tt.body))); got != tt.expected {
+				t.Errorf("convertError = %s", got)
+			}
+		})
+	}
+}
+
+func TestGeminiArrayStream(t *testing.T) {
+	// 透传：上游 SSE 数据行改写为 JSON 数组元素
+	stream := &geminiArrayStream{}
+	var out []string
+	for _, event := range []string{"data: {\"a\":1}\n", "\n", "data: {\"b\":2}\n"} {
+		out = append(out, stream.Convert(event)...)
+	}
+	out = append(out, stream.Finish()...)
+	if got := strings.Join(out, ""); got != "[{\"a\":1},\r\n{\"b\":2}]" {
+		t.Errorf("输出 = %q", got)
+	}
+
+	var chunks []map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.Join(out, "")), &chunks); err != nil || len(chunks) != 2 {
+		t.Errorf("输出不是合法的 JSON 数组: %v", err)
+	}
+
+	// 没有数据块时输出空数组
+	if got := strings.Join((&geminiArrayStream{}).Finish(), ""); got != "[]" {
+		t.Errorf("空流输出 = %q", got)
+	}
+
+	// 非 SSE 请求使用 JSON 数组，alt=sse 时保持 SSE
+	if contentType, framed := (&geminiIngress{stream: true}).frameStream(nil); contentType != "application/json" || framed == nil {
+		t.Errorf("非 SSE 流 = %s, %v", contentType, framed)
+	}
+	if _, framed := (&geminiIngress{stream: true, sse: true}).frameStream(nil); framed != nil {
+		t.Error("alt=sse 时不应改写流")
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== 内部请求 ==============
+// 批处理和 Responses 后台模式需要在没有客户端连接的情况下执行代理流程：
+// 请求经独立的 gin 引擎分发（与外部请求使用同一套上下文生命周期），响应缓存后以状态码和响应体返回
+
+// internalExecutor 以内部请求的方式执行代理流程（非流式），返回状态码和响应体
+// 上游请求随 ctx 取消
+type internalExecutor func(ctx context.Context, body []byte, headers http.Header) (int, []byte)
+
+// newInternalExecutor 创建内部请求执行器，handler 为代理流程的入口
+func newInternalExecutor(path string, handler gin.HandlerFunc) internalExecutor {
+	engine := gin.New()
+	engine.POST(path, handler)
+
+	return func(ctx context.Context, body []byte, headers http.Header) (int, []byte) {
+		req, err := http.NewRequestWithContext(ctx, "POST", path, bytes.NewReader(body))
+		if err != nil {
+			return 500, []byte(err.Error())
+		}
+		for key, values := range headers {
+			req.Header[key] = values
+		}
+		req.Header.Del("Content-Length") // 请求体可能已修改
+		req.Header.Set("Content-Type", "application/json")
+
+		w := newBufferedResponseWriter()
+		engine.ServeHTTP(w, req)
+		return w.status, w.body.Bytes()
+	}
+}
+
+// bufferedResponseWriter 缓存内部请求的响应（实现 gin 所需的 Flusher 和 CloseNotifier）
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: http.Header{}, status: http.StatusOK}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header { return w.header }
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) { return w.body.Write(data) }
+
+func (w *bufferedResponseWriter) WriteHeader(status int) { w.status = status }
+
+func (w *bufferedResponseWriter) Flush() {}
+
+func (w *bufferedResponseWriter) CloseNotify() <-chan bool { return make(chan bool) }
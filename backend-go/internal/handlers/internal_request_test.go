@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInternalExecutor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	execute := newInternalExecutor("/v1/responses", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		responseID, _ := c.Request.Context().Value(backgroundResponseIDKey{}).(string)
+		c.JSON(201, gin.H{
+			"body":        string(body),
+			"version":     c.GetHeader("anthropic-version"),
+			"contentType": c.GetHeader("Content-Type"),
+			"responseID":  responseID,
+		})
+	})
+
+	headers := http.Header{}
+	headers.Set("anthropic-version", "2023-06-01")
+	headers.Set("Content-Length", "999")
+	ctx := context.WithValue(context.Background(), backgroundResponseIDKey{}, "resp_1")
+
+	status, body := execute(ctx, []byte(`{"model":"gpt-5"}`), headers)
+	expected := `{"body":"{\"model\":\"gpt-5\"}","contentType":"application/json","responseID":"resp_1","version":"2023-06-01"}`
+	if status != 201 || string(body) != expected {
+		t.Errorf("状态码 = %d, 响应 = %s", status, body)
+	}
+
+	// 上游请求随 ctx 取消
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	execute = newInternalExecutor("/v1/messages", func(c *gin.Context) {
+		if c.Request.Context().Err() == nil {
+			t.Error("内部请求未继承已取消的 ctx")
+		}
+		c.Status(499)
+	})
+	if status, _ := execute(canceled, nil, http.Header{}); status != 499 {
+		t.Errorf("状态码 = %d", status)
+	}
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/httpclient"
+	"github.com/BenedictKing/claude-proxy/internal/middleware"
+	"github.com/BenedictKing/claude-proxy/internal/utils"
+)
+
+const (
+	upstreamModelsCacheTTL = 10 * time.Minute // 上游模型列表缓存时间（失败时同样等待该时间后重试）
+	upstreamModelsTimeout  = 10 * time.Second
+
+	defaultModelListLimit = 20 // Anthropic 格式的分页参数与官方 /v1/models 一致
+	maxModelListLimit     = 1000
+)
+
+// modelEntry 聚合后的模型
+type modelEntry struct {
+	ID      string
+	OwnedBy string // 首个提供该模型的渠道类型
+}
+
+// cachedUpstreamModels 缓存的上游模型列表
+type cachedUpstreamModels struct {
+	models    []string
+	fetchedAt time.Time
+}
+
+// upstreamModelsCache 按渠道类型和 baseUrl 缓存上游 /models 的结果
+var upstreamModelsCache = struct {
+	sync.Mutex
+	entries map[string]cachedUpstreamModels
+}{entries: make(map[string]cachedUpstreamModels)}
+
+// ModelsHandler 模型列表（GET /v1/models、GET /v1beta/models）
+// 汇总 Messages 和 Responses 渠道的模型映射源名称、models 中的精确名称，
+// 以及开启 discoverModels 的渠道从上游 /models 获取的模型（带缓存）
+// 响应格式：/v1beta 路径返回 Gemini 格式；带 anthropic-version 头返回 Anthropic 格式（支持 limit、before_id、after_id 分页）；否则返回 OpenAI 格式
+func ModelsHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		models := collectModels(cfgManager)
+
+		switch modelsResponseFormat(c) {
+		case "gemini":
+			items := make([]gin.H, len(models))
+			for i, m := range models {
+				items[i] = geminiModelObject(m)
+			}
+			c.JSON(200, gin.H{"models": items})
+
+		case "anthropic":
+			resp, err := anthropicModelsPage(c, models)
+			if err != nil {
+				c.JSON(400, anthropicError("invalid_request_error", err.Error()))
+				return
+			}
+			c.JSON(200, resp)
+
+		default:
+			items := make([]gin.H, len(models))
+			for i, m := range models {
+				items[i] = openAIModelObject(m)
+			}
+			c.JSON(200, gin.H{"object": "list", "data": items})
+		}
+	})
+}
+
+// ModelHandler 查询单个模型（GET /v1/models/:model、GET /v1beta/models/:model）
+func ModelHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		id := strings.TrimPrefix(c.Param("model"), "models/")
+		format := modelsResponseFormat(c)
+
+		for _, m := range collectModels(cfgManager) {
+			if m.ID != id {
+				continue
+			}
+			switch format {
+			case "gemini":
+				c.JSON(200, geminiModelObject(m))
+			case "anthropic":
+				c.JSON(200, anthropicModelObject(m))
+			default:
+				c.JSON(200, openAIModelObject(m))
+			}
+			return
+		}
+
+		message := fmt.Sprintf("model: %s", id)
+		switch format {
+		case "gemini":
+			c.JSON(404, geminiError(404, "NOT_FOUND", message))
+		case "anthropic":
+			c.JSON(404, anthropicError("not_found_error", message))
+		default:
+			c.JSON(404, gin.H{"error": gin.H{"message": message, "type": "invalid_request_error", "code": "model_not_found"}})
+		}
+	})
+}
+
+// modelsResponseFormat 根据请求路径和 anthropic-version 头判断响应格式
+// x-api-key 只是代理访问密钥的一种传递方式，OpenAI 客户端同样可能使用，不作为格式依据
+func modelsResponseFormat(c *gin.Context) string {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1beta/") {
+		return "gemini"
+	}
+	if c.GetHeader("anthropic-version") != "" {
+		return "anthropic"
+	}
+	return "openai"
+}
+
+// anthropicModelsPage 按 limit、before_id、after_id 分页构造 Anthropic 格式的模型列表
+func anthropicModelsPage(c *gin.Context, models []modelEntry) (gin.H, error) {
+	limit := defaultModelListLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxModelListLimit {
+			return nil, fmt.Errorf("limit 必须在 1-%d 之间", maxModelListLimit)
+		}
+		limit = n
+	}
+
+	start, end := 0, len(models)
+	beforeID := c.Query("before_id")
+	if afterID := c.Query("after_id"); afterID != "" {
+		index := indexOfModel(models, afterID)
+		if index < 0 {
+			return nil, fmt.Errorf("after_id 对应的模型不存在: %s", afterID)
+		}
+		start = index + 1
+		end = min(start+limit, len(models))
+		beforeID = ""
+	} else if beforeID != "" {
+		end = indexOfModel(models, beforeID)
+		if end < 0 {
+			return nil, fmt.Errorf("before_id 对应的模型不存在: %s", beforeID)
+		}
+		start = max(end-limit, 0)
+	} else {
+		end = min(limit, len(models))
+	}
+
+	page := models[start:end]
+	items := make([]gin.H, len(page))
+	for i, m := range page {
+		items[i] = anthropicModelObject(m)
+	}
+
+	resp := gin.H{
+		"data":     items,
+		"has_more": end < len(models),
+		"first_id": nil,
+		"last_id":  nil,
+	}
+	if beforeID != "" {
+		resp["has_more"] = start > 0
+	}
+	if len(page) > 0 {
+		resp["first_id"] = page[0].ID
+		resp["last_id"] = page[len(page)-1].ID
+	}
+	return resp, nil
+}
+
+// indexOfModel 查找模型在列表中的位置（不存在时返回 -1）
+func indexOfModel(models []modelEntry, id string) int {
+	for i, m := range models {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func anthropicModelObject(m modelEntry) gin.H {
+	return gin.H{
+		"type":         "model",
+		"id":           m.ID,
+		"display_name": m.ID,
+		"created_at":   time.Unix(0, 0).UTC().Format(time.RFC3339),
+	}
+}
+
+func openAIModelObject(m modelEntry) gin.H {
+	return gin.H{
+		"id":       m.ID,
+		"object":   "model",
+		"created":  0,
+		"owned_by": m.OwnedBy,
+	}
+}
+
+func geminiModelObject(m modelEntry) gin.H {
+	return gin.H{
+		"name":                       "models/" + m.ID,
+		"displayName":                m.ID,
+		"supportedGenerationMethods": []string{"generateContent", "streamGenerateContent"},
+	}
+}
+
+// collectModels 汇总所有渠道的模型（按名称排序去重）
+func collectModels(cfgManager *config.ConfigManager) []modelEntry {
+	cfg := cfgManager.GetConfig()
+	upstreams := append(append([]config.UpstreamConfig{}, cfg.Upstream...), cfg.ResponsesUpstream...)
+
+	// 并发刷新过期的上游模型列表
+	discovered := make([][]string, len(upstreams))
+	var wg sync.WaitGroup
+	for i := range upstreams {
+		if !upstreams[i].DiscoverModels {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			discovered[i] = getUpstreamModels(cfgManager, &upstreams[i])
+		}(i)
+	}
+	wg.Wait()
+
+	owners := make(map[string]string)
+	for i := range upstreams {
+		upstream := &upstreams[i]
+		for _, model := range config.DeclaredModels(upstream) {
+			if _, exists := owners[model]; !exists {
+				owners[model] = upstream.ServiceType
+			}
+		}
+		for _, model := range discovered[i] {
+			if _, exists := owners[model]; !exists && config.UpstreamServesModel(upstream, model) {
+				owners[model] = upstream.ServiceType
+			}
+		}
+	}
+
+	models := make([]modelEntry, 0, len(owners))
+	for id, owner := range owners {
+		models = append(models, modelEntry{ID: id, OwnedBy: owner})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
+
+// getUpstreamModels 获取渠道上游的模型列表（缓存过期时重新请求，失败时沿用旧结果）
+func getUpstreamModels(cfgManager *config.ConfigManager, upstream *config.UpstreamConfig) []string {
+	cacheKey := upstream.ServiceType + "|" + upstream.BaseURL
+
+	upstreamModelsCache.Lock()
+	cached, exists := upstreamModelsCache.entries[cacheKey]
+	upstreamModelsCache.Unlock()
+	if exists && time.Since(cached.fetchedAt) < upstreamModelsCacheTTL {
+		return cached.models
+	}
+
+	models, err := fetchUpstreamModels(cfgManager, upstream)
+	if err != nil {
+		log.Printf("⚠️ 获取渠道 %s 的模型列表失败: %v", upstream.Name, err)
+		models = cached.models
+	}
+
+	upstreamModelsCache.Lock()
+	upstreamModelsCache.entries[cacheKey] = cachedUpstreamModels{models: models, fetchedAt: time.Now()}
+	upstreamModelsCache.Unlock()
+	return models
+}
+
+// fetchUpstreamModels 请求上游的模型列表接口
+func fetchUpstreamModels(cfgManager *config.ConfigManager, upstream *config.UpstreamConfig) ([]string, error) {
+	apiKey, err := cfgManager.GetNextAPIKey(upstream, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// baseURL 已包含版本号（/v1、/v1beta 等）时直接拼接，否则补充 /v1（Gemini 的 baseUrl 本身包含版本号）
+	baseURL := strings.TrimSuffix(upstream.BaseURL, "/")
+	url := baseURL + "/models"
+	if upstream.ServiceType != "gemini" && !regexp.MustCompile(`/v\d+[a-z]*$`).MatchString(baseURL) {
+		url = baseURL + "/v1/models"
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch upstream.ServiceType {
+	case "gemini":
+		utils.SetGeminiAuthenticationHeader(req.Header, apiKey)
+	case "claude":
+		utils.SetAuthenticationHeader(req.Header, apiKey)
+		req.Header.Set("anthropic-version", "2023-06-01")
+	default:
+		utils.SetAuthenticationHeader(req.Header, apiKey)
+	}
+
+	client := httpclient.GetManager().GetStandardClient(upstreamModelsTimeout, upstream.InsecureSkipVerify)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("上游返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Claude / OpenAI: {"data":[{"id":...}]}；Gemini: {"models":[{"name":"models/..."}]}
+	var parsed struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("解析模型列表失败: %w", err)
+	}
+
+	models := []string{}
+	for _, m := range parsed.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+	for _, m := range parsed.Models {
+		if name := strings.TrimPrefix(m.Name, "models/"); name != "" {
+			models = append(models, name)
+		}
+	}
+	return models, nil
+}
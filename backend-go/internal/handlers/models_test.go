@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+)
+
+func TestModelsHandler_FormatAndPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfgManager := newTestConfigManager(t, config.Config{
+		Upstream: []config.UpstreamConfig{{
+			Name:        "claude",
+			BaseURL:     "http://127.0.0.1:1",
+			ServiceType: "claude",
+			APIKeys:     []string{"key-a"},
+			Models:      []string{"model-a", "model-b", "model-c"},
+		}},
+	})
+
+	envCfg := &config.EnvConfig{ProxyAccessKey: "test-key"}
+	router := gin.New()
+	router.GET("/v1/models", ModelsHandler(envCfg, cfgManager))
+
+	tests := []struct {
+		name      string
+		query     string
+		anthropic bool
+		status    int
+		ids       string
+		hasMore   bool
+	}{
+		{name: "x-api-key 不决定格式", query: "", status: 200, ids: "model-a,model-b,model-c"},
+		{name: "anthropic 全部", query: "", anthropic: true, status: 200, ids: "model-a,model-b,model-c"},
+		{name: "limit", query: "?limit=2", anthropic: true, status: 200, ids: "model-a,model-b", hasMore: true},
+		{name: "after_id", query: "?limit=1&after_id=model-a", anthropic: true, status: 200, ids: "model-b", hasMore: true},
+		{name: "before_id", query: "?limit=1&before_id=model-c", anthropic: true, status: 200, ids: "model-b", hasMore: true},
+		{name: "before_id 到开头", query: "?before_id=model-b", anthropic: true, status: 200, ids: "model-a"},
+		{name: "未知游标", query: "?after_id=model-x", anthropic: true, status: 400},
+		{name: "非法 limit", query: "?limit=0", anthropic: true, status: 400},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/models"+tt.query, nil)
+		req.Header.Set("x-api-key", "test-key")
+		if tt.anthropic {
+			req.Header.Set("anthropic-version", "2023-06-01")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: 状态码 = %d, 响应 = %s", tt.name, w.Code, w.Body.String())
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+
+		var resp struct {
+			Object  string `json:"object"`
+			HasMore *bool  `json:"has_more"`
+			Data    []struct {
+				ID   string `json:"id"`
+				Type string `json:"type"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+
+		ids := make([]string, len(resp.Data))
+		for i, m := range resp.Data {
+			ids[i] = m.ID
+		}
+		if got := strings.Join(ids, ","); got != tt.ids {
+			t.Errorf("%s: 模型 = %s, 期望 %s", tt.name, got, tt.ids)
+		}
+		if !tt.anthropic {
+			if resp.Object != "list" || resp.HasMore != nil {
+				t.Errorf("%s: 应返回 OpenAI 格式: %s", tt.name, w.Body.String())
+			}
+			continue
+		}
+		if resp.HasMore == nil || *resp.HasMore != tt.hasMore {
+			t.Errorf("%s: has_more 不符: %s", tt.name, w.Body.String())
+		}
+	}
+}
@@ -28,67 +28,119 @@ func ProxyHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gi
 			return
 		}
 
-		startTime := time.Now()
+		proxyMessages(c, envCfg, cfgManager, nil)
+	})
+}
 
-		// 读取原始请求体
-		bodyBytes, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			c.JSON(400, gin.H{"error": "Failed to read request body"})
-			return
-		}
-		// 恢复请求体供后续使用
-		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-
-		// claudeReq 变量用于判断是否流式请求
-		var claudeReq types.ClaudeRequest
-		// 尝试解析，失败也无妨
-		if len(bodyBytes) > 0 {
-			_ = json.Unmarshal(bodyBytes, &claudeReq)
-		}
+// proxyMessages 通过 Messages 渠道池转发 Claude 格式的请求
+// ingress 不为 nil 时，请求来自其他协议的入口（已转换为 Claude 格式），响应会转换回入口协议，
+// 原生支持入口协议的渠道则直接透传
+func proxyMessages(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, ingress ingressFormat) {
+	startTime := time.Now()
 
-		// 获取当前上游配置
-		upstream, err := cfgManager.GetCurrentUpstream()
-		if err != nil {
-			c.JSON(503, gin.H{
-				"error": "未配置任何渠道，请先在管理界面添加渠道",
-				"code":  "NO_UPSTREAM",
-			})
-			return
-		}
+	// 读取原始请求体
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondError(c, ingress, 400, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	// 恢复请求体供后续使用
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+	// claudeReq 变量用于判断是否流式请求
+	var claudeReq types.ClaudeRequest
+	// 尝试解析，失败也无妨
+	if len(bodyBytes) > 0 {
+		_ = json.Unmarshal(bodyBytes, &claudeReq)
+	}
+
+	// 会话标识（用于粘性路由，保证同一会话命中同一渠道和密钥以复用上游提示词缓存）
+	sessionID := stickySessionID(c, cfgManager, bodyBytes)
+
+	// 获取渠道故障转移链（按模型路由，未命中时当前渠道优先；负载均衡时会话绑定的渠道优先）
+	chain, err := cfgManager.GetUpstreamFailoverChain(claudeReq.Model, sessionID)
+	if err != nil {
+		respondError(c, ingress, 503, gin.H{
+			"error": "未配置任何渠道，请先在管理界面添加渠道",
+			"code":  "NO_UPSTREAM",
+		})
+		return
+	}
+
+	if !hasUsableUpstream(chain) {
+		respondError(c, ingress, 503, gin.H{
+			"error": fmt.Sprintf("当前渠道 \"%s\" 未配置API密钥", chain[0].Upstream.Name),
+			"code":  "NO_API_KEYS",
+		})
+		return
+	}
 
+	// 跳过熔断中的渠道（半开渠道仅放行少量探测请求）
+	chain = cfgManager.FilterAvailableChannels(chain)
+
+	var lastError error
+	var lastOriginalBodyBytes []byte // 用于记录最后一次尝试的原始请求体，以便日志记录
+	// 记录最后一次需要failover的上游错误，用于所有密钥都失败时回传原始错误
+	var lastFailoverError *struct {
+		Status      int
+		Body        []byte
+		Passthrough bool // 错误来自透传渠道，响应体已是入口协议格式
+	}
+	// 候选降级密钥（仅当后续有密钥成功调用时，才将这些密钥移到列表末尾）
+	deprioritizeCandidates := make(map[string]bool)
+
+	for chainPos, candidate := range chain {
+		upstream := candidate.Upstream
 		if len(upstream.APIKeys) == 0 {
-			c.JSON(503, gin.H{
-				"error": fmt.Sprintf("当前渠道 \"%s\" 未配置API密钥", upstream.Name),
-				"code":  "NO_API_KEYS",
-			})
-			return
+			continue
 		}
 
-		// 获取提供商
+		// 获取提供商（每个渠道使用各自的服务类型进行转换）
 		provider := providers.GetProvider(upstream.ServiceType)
+		passthrough := false
+		if ingress != nil {
+			// 渠道原生支持入口协议时直接透传，无需经 Claude 格式中转
+			if p := ingress.passthroughProvider(upstream); p != nil {
+				provider = p
+				passthrough = true
+			}
+		}
 		if provider == nil {
-			c.JSON(400, gin.H{"error": "Unsupported service type"})
-			return
+			lastError = fmt.Errorf("渠道 %s 的服务类型 %s 不受支持", upstream.Name, upstream.ServiceType)
+			continue
+		}
+
+		if chainPos > 0 && envCfg.ShouldLog("info") {
+			log.Printf("🔀 故障转移到渠道: [%d] %s", candidate.Index, upstream.Name)
+		} else if len(upstream.Models) > 0 && envCfg.ShouldLog("info") {
+			log.Printf("🧭 模型 %s 路由到渠道: [%d] %s", claudeReq.Model, candidate.Index, upstream.Name)
 		}
 
+		// 即将向该渠道发送请求：半开渠道占用探测名额，离开该渠道时释放（已上报结果时为空操作）
+		probe, ok := cfgManager.AcquireChannel(candidate)
+		if !ok {
+			log.Printf("⏭️ 渠道 %s 的熔断探测名额已被占用，跳过", upstream.Name)
+			continue
+		}
+		defer probe.Release()
+
+		hasNextChannel := chainPos < len(chain)-1
+
 		// 实现 failover 重试逻辑
 		maxRetries := len(upstream.APIKeys)
 		failedKeys := make(map[string]bool) // 记录本次请求中已经失败过的 key
-		var lastError error
-		var lastOriginalBodyBytes []byte // 用于记录最后一次尝试的原始请求体，以便日志记录
-		// 记录最后一次需要failover的上游错误，用于所有密钥都失败时回传原始错误
-		var lastFailoverError *struct {
-			Status int
-			Body   []byte
-		}
-		// 候选降级密钥（仅当后续有密钥成功调用时，才将这些密钥移到列表末尾）
-		deprioritizeCandidates := make(map[string]bool)
+		sameKeyRetries := make(map[string]int) // 按规则使用同一密钥重试的次数
+		retryKey := ""                         // 下一次尝试需复用的密钥
 
 		for attempt := 0; attempt < maxRetries; attempt++ {
-			apiKey, err := cfgManager.GetNextAPIKey(upstream, failedKeys)
-			if err != nil {
-				lastError = err
-				break
+			apiKey := retryKey
+			retryKey = ""
+			if apiKey == "" {
+				apiKey, err = cfgManager.GetAPIKeyForSession(upstream, failedKeys, sessionID)
+				if err != nil {
+					lastError = err
+					break
+				}
 			}
 
 			if envCfg.ShouldLog("info") {
@@ -140,12 +192,20 @@ func ProxyHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gi
 
 			// 发送请求
 			// claudeReq.Stream 用于判断是否是流式请求
+			requestStart := time.Now()
 			resp, err := sendRequest(providerReq, upstream, envCfg, claudeReq.Stream)
 			if err != nil {
+				cfgManager.RecordChannelResult(upstream, 0, false)
 				lastError = err
 				failedKeys[apiKey] = true
 				cfgManager.MarkKeyAsFailed(apiKey)
 				log.Printf("⚠️ API密钥失败: %v", err)
+
+				// 连接错误属于渠道级故障，直接切换到下一个渠道
+				if hasNextChannel {
+					log.Printf("⏭️ 渠道 %s 连接失败，切换到下一个渠道", upstream.Name)
+					break
+				}
 				continue
 			}
 
@@ -158,15 +218,47 @@ func ProxyHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gi
 				// 这确保错误信息始终可读，用于日志和重试逻辑
 				bodyBytes = utils.DecompressGzipIfNeeded(resp, bodyBytes)
 
-				// 检查是否需要 failover
-				shouldFailover, isQuotaRelated := shouldRetryWithNextKey(resp.StatusCode, bodyBytes)
-				if shouldFailover {
+				// 解析上游限流信息（Retry-After、剩余额度等）
+				rateLimit := utils.ParseRateLimitInfo(resp.Header, bodyBytes)
+				cfgManager.RecordKeyRateLimit(apiKey, rateLimit)
+
+				// 按故障转移规则（渠道自定义规则优先，其次内置规则）决定后续动作
+				decision := config.ClassifyFailover(upstream, resp.StatusCode, bodyBytes)
+				if decision.Action == config.FailoverRetrySameKey {
+					if sameKeyRetries[apiKey] < decision.MaxRetries {
+						sameKeyRetries[apiKey]++
+						log.Printf("🔁 上游错误 (状态: %d) 命中规则 %s，使用同一密钥重试 (%d/%d)",
+							resp.StatusCode, decision.Rule, sameKeyRetries[apiKey], decision.MaxRetries)
+						retryKey = apiKey
+						attempt--
+						continue
+					}
+					// 同一密钥重试次数用尽，切换到下一个密钥
+					decision.Action = config.FailoverNextKey
+				}
+
+				// 渠道级故障计入熔断器失败；其余错误说明渠道可达，计入成功（半开探测据此结束）
+				channelLevel := decision.Action == config.FailoverNextChannel
+				if channelLevel {
+					cfgManager.RecordChannelResult(upstream, 0, false)
+				} else {
+					cfgManager.RecordChannelReachable(upstream)
+				}
+
+				if decision.ShouldFailover() {
 					lastError = fmt.Errorf("上游错误: %d", resp.StatusCode)
 					failedKeys[apiKey] = true
-					cfgManager.MarkKeyAsFailed(apiKey)
+					switch {
+					case decision.Action == config.FailoverQuarantine:
+						cfgManager.QuarantineAPIKey(apiKey, fmt.Sprintf("状态 %d 命中规则 %s", resp.StatusCode, decision.Rule))
+					case config.IsAuthFailure(resp.StatusCode, bodyBytes) && cfgManager.RecordKeyAuthFailure(apiKey, resp.StatusCode):
+						// 连续认证失败，密钥已自动隔离
+					default:
+						cfgManager.MarkKeyAsFailedWithCooldown(apiKey, rateLimitCooldown(resp.StatusCode, rateLimit))
+					}
 
 					// 增强的日志输出
-					log.Printf("⚠️ API密钥失败 (状态: %d)，尝试下一个密钥", resp.StatusCode)
+					log.Printf("⚠️ API密钥失败 (状态: %d, 规则: %s)，尝试下一个密钥", resp.StatusCode, decision.Rule)
 					if envCfg.EnableResponseLogs && envCfg.IsDevelopment() {
 						formattedBody := utils.FormatJSONBytesForLog(bodyBytes, 500)
 						log.Printf("📦 失败原因:\n%s", formattedBody)
@@ -177,18 +269,25 @@ func ProxyHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gi
 
 					// 记录最后一次failover错误（用于所有密钥失败时返回）
 					lastFailoverError = &struct {
-						Status int
-						Body   []byte
+						Status      int
+						Body        []byte
+						Passthrough bool
 					}{
-						Status: resp.StatusCode,
-						Body:   bodyBytes,
+						Status:      resp.StatusCode,
+						Body:        bodyBytes,
+						Passthrough: passthrough,
 					}
 
 					// 仅记录候选降级密钥，待后续任一密钥成功时再移动到末尾
-					if isQuotaRelated {
+					if decision.QuotaRelated {
 						deprioritizeCandidates[apiKey] = true
 					}
 
+					// 渠道级故障（内置规则下为 5xx），直接切换到下一个渠道
+					if hasNextChannel && channelLevel {
+						log.Printf("⏭️ 渠道 %s 返回 %d，切换到下一个渠道", upstream.Name, resp.StatusCode)
+						break
+					}
 					continue
 				}
 
@@ -211,11 +310,48 @@ func ProxyHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gi
 						log.Printf("📋 错误响应头:\n%s", string(respHeadersJSON))
 					}
 				}
+				if ingress != nil && !passthrough {
+					// 经 Claude 格式中转的渠道，错误响应转换为入口协议格式
+					c.JSON(resp.StatusCode, ingress.convertError(resp.StatusCode, bodyBytes))
+					return
+				}
 				c.Data(resp.StatusCode, "application/json", bodyBytes)
 				return
 			}
 
 			// 处理成功响应
+			ttfb := time.Since(requestStart)
+			cfgManager.RecordKeyRateLimit(apiKey, utils.ParseRateLimitInfo(resp.Header, nil))
+
+			var usedTokens int64
+			if claudeReq.Stream {
+				// 流在输出首个内容事件前中断时尚未向客户端提交任何数据，可透明地切换密钥/渠道重试
+				streamTokens, err := handleStreamResponse(c, resp, provider, envCfg, startTime, upstream, claudeReq.Model, ingress, passthrough)
+				if err != nil {
+					if c.Request.Context().Err() != nil {
+						log.Printf("ℹ️ 客户端在流式响应开始前断开连接")
+						return
+					}
+					cfgManager.RecordChannelResult(upstream, 0, false)
+					lastError = err
+					failedKeys[apiKey] = true
+					cfgManager.MarkKeyAsFailed(apiKey)
+					log.Printf("⚠️ 流式响应在输出内容前中断: %v", err)
+
+					if hasNextChannel {
+						log.Printf("⏭️ 渠道 %s 流式响应中断，切换到下一个渠道", upstream.Name)
+						break
+					}
+					continue
+				}
+				usedTokens = streamTokens
+			}
+
+			cfgManager.RecordChannelResult(upstream, ttfb, true)
+			cfgManager.RecordKeySuccess(apiKey)
+			cfgManager.PinSessionKey(upstream, sessionID, apiKey)
+			cfgManager.PinSessionChannel(upstream, claudeReq.Model, sessionID)
+
 			// 如果本次请求最终成功，执行降级移动（仅对额度/余额相关失败的密钥）
 			if len(deprioritizeCandidates) > 0 {
 				for key := range deprioritizeCandidates {
@@ -225,42 +361,52 @@ func ProxyHandler(envCfg *config.EnvConfig, cfgManager *config.ConfigManager) gi
 				}
 			}
 
-			if claudeReq.Stream {
-				handleStreamResponse(c, resp, provider, envCfg, startTime, upstream)
-			} else {
-				handleNormalResponse(c, resp, provider, envCfg, startTime)
+			if !claudeReq.Stream {
+				usedTokens = handleNormalResponse(c, resp, provider, envCfg, startTime, ingress, passthrough)
 			}
+			cfgManager.RecordKeyUsage(apiKey, usedTokens)
 			return
 		}
 
-		// 所有密钥都失败了
-		log.Printf("💥 所有API密钥都失败了")
+		probe.Release()
+		if hasNextChannel {
+			log.Printf("⏭️ 渠道 %s 的所有API密钥都失败了，尝试下一个渠道", upstream.Name)
+		}
+	}
+
+	// 所有渠道的密钥都失败了
+	log.Printf("💥 所有渠道的API密钥都失败了")
 
-		// 若有记录的最后一次上游错误，按原状态码和内容返回
-		if lastFailoverError != nil {
-			status := lastFailoverError.Status
-			if status == 0 {
-				status = 500
-			}
+	// 若有记录的最后一次上游错误，按原状态码和内容返回
+	if lastFailoverError != nil {
+		status := lastFailoverError.Status
+		if status == 0 {
+			status = 500
+		}
 
-			// 尝试解析为JSON返回
-			var errBody map[string]interface{}
-			if err := json.Unmarshal(lastFailoverError.Body, &errBody); err == nil {
-				c.JSON(status, errBody)
-			} else {
-				// 如果不是JSON，返回通用错误
-				c.JSON(status, gin.H{
-					"error": string(lastFailoverError.Body),
-				})
-			}
+		// 尝试解析为JSON返回
+		var errBody map[string]interface{}
+		if ingress != nil && !lastFailoverError.Passthrough {
+			c.JSON(status, ingress.convertError(status, lastFailoverError.Body))
+		} else if err := json.Unmarshal(lastFailoverError.Body, &errBody); err == nil {
+			c.JSON(status, errBody)
 		} else {
-			// 没有上游错误记录，返回通用错误
-			c.JSON(500, gin.H{
-				"error":   "所有上游API密钥都不可用",
-				"details": lastError.Error(),
+			// 如果不是JSON，返回通用错误
+			c.JSON(status, gin.H{
+				"error": string(lastFailoverError.Body),
 			})
 		}
-	})
+	} else {
+		// 没有上游错误记录，返回通用错误
+		details := "未知错误"
+		if lastError != nil {
+			details = lastError.Error()
+		}
+		respondError(c, ingress, 500, gin.H{
+			"error":   "所有上游API密钥都不可用",
+			"details": details,
+		})
+	}
 }
 
 // sendRequest 发送HTTP请求
@@ -315,16 +461,20 @@ func sendRequest(req *http.Request, upstream *config.UpstreamConfig, envCfg *con
 	return client.Do(req)
 }
 
-// handleNormalResponse 处理非流式响应
-func handleNormalResponse(c *gin.Context, resp *http.Response, provider providers.Provider, envCfg *config.EnvConfig, startTime time.Time) {
+// handleNormalResponse 处理非流式响应，返回上游报告的 token 用量
+// passthrough 为 true 时原样返回上游响应体；否则转换为 Claude 格式（来自其他入口时再转换为入口协议）
+func handleNormalResponse(c *gin.Context, resp *http.Response, provider providers.Provider, envCfg *config.EnvConfig, startTime time.Time, ingress ingressFormat, passthrough bool) int64 {
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to read response"})
-		return
+		respondError(c, ingress, 500, gin.H{"error": "Failed to read response"})
+		return 0
 	}
 
+	var usage utils.UsageTracker
+	usage.ProcessJSON(bodyBytes)
+
 	if envCfg.EnableResponseLogs {
 		responseTime := time.Since(startTime).Milliseconds()
 		log.Printf("⏱️ 响应完成: %dms, 状态: %d", responseTime, resp.StatusCode)
@@ -345,6 +495,12 @@ func handleNormalResponse(c *gin.Context, resp *http.Response, provider provider
 		}
 	}
 
+	if passthrough {
+		utils.ForwardResponseHeaders(resp.Header, c.Writer)
+		c.Data(resp.StatusCode, "application/json", bodyBytes)
+		return usage.TotalTokens()
+	}
+
 	providerResp := &types.ProviderResponse{
 		StatusCode: resp.StatusCode,
 		Headers:    resp.Header,
@@ -354,8 +510,8 @@ func handleNormalResponse(c *gin.Context, resp *http.Response, provider provider
 
 	claudeResp, err := provider.ConvertToClaudeResponse(providerResp)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to convert response"})
-		return
+		respondError(c, ingress, 500, gin.H{"error": "Failed to convert response"})
+		return usage.TotalTokens()
 	}
 
 	// 监听响应关闭事件(客户端断开连接)
@@ -379,30 +535,58 @@ func handleNormalResponse(c *gin.Context, resp *http.Response, provider provider
 	// 转发上游响应头到客户端（透明代理）
 	utils.ForwardResponseHeaders(resp.Header, c.Writer)
 
-	c.JSON(200, claudeResp)
+	if ingress != nil {
+		c.JSON(200, ingress.convertResponse(claudeResp))
+	} else {
+		c.JSON(200, claudeResp)
+	}
 
 	// 响应完成后记录
 	if envCfg.EnableResponseLogs {
 		responseTime := time.Since(startTime).Milliseconds()
 		log.Printf("⏱️ 响应发送完成: %dms, 状态: %d", responseTime, resp.StatusCode)
 	}
+	return usage.TotalTokens()
 }
 
 // handleStreamResponse 处理流式响应
-func handleStreamResponse(c *gin.Context, resp *http.Response, provider providers.Provider, envCfg *config.EnvConfig, startTime time.Time, upstream *config.UpstreamConfig) {
+// 在收到第一个有效内容事件前缓冲上游输出，不向客户端提交响应头；
+// 若上游流在此之前中断或返回错误事件，返回非 nil 错误，由调用方透明地切换密钥/渠道重试。
+// 一旦开始向客户端输出，错误恒为 nil，并返回 message_start / message_delta 中报告的 token 用量。
+// 来自其他入口且非透传时，Claude 事件会转换为入口协议的流式格式后输出。
+func handleStreamResponse(c *gin.Context, resp *http.Response, provider providers.Provider, envCfg *config.EnvConfig, startTime time.Time, upstream *config.UpstreamConfig, model string, ingress ingressFormat, passthrough bool) (int64, error) {
 	defer resp.Body.Close()
 
 	eventChan, errChan, err := provider.HandleStreamResponse(resp.Body)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to handle stream response"})
-		return
+		return 0, fmt.Errorf("处理流式响应失败: %w", err)
+	}
+
+	// 缓冲首个有效内容事件之前的事件（message_start、ping 等）
+	pending, err := waitForFirstContentEvent(c, eventChan, errChan)
+	if err != nil {
+		// 丢弃上游剩余输出，避免转换协程阻塞
+		go drainStream(eventChan)
+		return 0, err
+	}
+
+	// 将事件转换为输出给客户端的格式
+	var converter streamConverter
+	if ingress != nil && !passthrough {
+		converter = ingress.newStreamConverter()
+	}
+	contentType := "text/event-stream"
+	if framer, ok := ingress.(streamFramer); ok {
+		if framedType, framed := framer.frameStream(converter); framed != nil {
+			contentType, converter = framedType, framed
+		}
 	}
 
 	// 先转发上游响应头（透明代理）
 	utils.ForwardResponseHeaders(resp.Header, c.Writer)
 
-	// 设置 SSE 响应头（可能覆盖上游的 Content-Type）
-	c.Header("Content-Type", "text/event-stream")
+	// 设置流式响应头（可能覆盖上游的 Content-Type）
+	c.Header("Content-Type", contentType)
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no")
@@ -414,21 +598,85 @@ func handleStreamResponse(c *gin.Context, resp *http.Response, provider provider
 	if envCfg.IsDevelopment() {
 		synthesizer = utils.NewStreamSynthesizer("claude")
 	}
+	var usage utils.UsageTracker
+
+	render := func(event string) []byte {
+		if converter == nil {
+			return []byte(event)
+		}
+		return []byte(strings.Join(converter.Convert(event), ""))
+	}
+
+	// 规范化 Claude 事件序列（补发缺失的事件、重排内容块索引）；透传入口协议时上游输出不是 Claude 格式，不做处理
+	var normalizer *providers.StreamNormalizer
+	if !passthrough {
+		normalizer = providers.NewStreamNormalizer(config.RedirectModel(model, upstream))
+	}
+	normalize := func(event string) []string {
+		if normalizer == nil {
+			return []string{event}
+		}
+		return normalizer.Process(event)
+	}
 
 	w := c.Writer
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		log.Printf("⚠️ ResponseWriter不支持Flush接口")
-		return
+		return 0, nil
 	}
-	flusher.Flush()
 
 	clientGone := false
+	// write 统计用量并实时转发给客户端
+	write := func(events []string) {
+		for _, event := range events {
+			trackStreamUsage(&usage, event)
+			if clientGone {
+				continue
+			}
+			if _, err := w.Write(render(event)); err != nil {
+				clientGone = true // 标记客户端已断开，停止后续写入
+				errMsg := err.Error()
+				if strings.Contains(errMsg, "broken pipe") || strings.Contains(errMsg, "connection reset") {
+					if envCfg.ShouldLog("info") {
+						log.Printf("ℹ️ 客户端中断连接 (正常行为)，继续接收上游数据...")
+					}
+				} else {
+					log.Printf("⚠️ 流式传输写入错误: %v", err)
+				}
+				// 注意：这里不再return，而是继续循环以耗尽eventChan
+			}
+		}
+		if !clientGone {
+			flusher.Flush()
+		}
+	}
+
+	// 输出缓冲的事件
+	for _, event := range pending {
+		if envCfg.IsDevelopment() && envCfg.EnableResponseLogs {
+			logBuffer.WriteString(event)
+			if synthesizer != nil {
+				for _, line := range strings.Split(event, "\n") {
+					synthesizer.ProcessLine(line)
+				}
+			}
+		}
+		write(normalize(event))
+	}
+
 	for {
 		select {
 		case event, ok := <-eventChan:
 			if !ok {
-				// 通道关闭，流式传输结束
+				// 通道关闭，流式传输结束：补发缺失的收尾事件
+				if normalizer != nil {
+					write(normalizer.Finish())
+				}
+				if converter != nil && !clientGone {
+					w.Write([]byte(strings.Join(converter.Finish(), "")))
+					flusher.Flush()
+				}
 				if envCfg.EnableResponseLogs {
 					responseTime := time.Since(startTime).Milliseconds()
 					log.Printf("⏱️ 流式响应完成: %dms", responseTime)
@@ -449,7 +697,7 @@ func handleStreamResponse(c *gin.Context, resp *http.Response, provider provider
 						}
 					}
 				}
-				return
+				return usage.TotalTokens(), nil
 			}
 
 			// 缓存事件用于最后的日志输出
@@ -463,24 +711,8 @@ func handleStreamResponse(c *gin.Context, resp *http.Response, provider provider
 				}
 			}
 
-			// 实时转发给客户端（流式传输）
-			if !clientGone {
-				_, err := w.Write([]byte(event))
-				if err != nil {
-					clientGone = true // 标记客户端已断开，停止后续写入
-					errMsg := err.Error()
-					if strings.Contains(errMsg, "broken pipe") || strings.Contains(errMsg, "connection reset") {
-						if envCfg.ShouldLog("info") {
-							log.Printf("ℹ️ 客户端中断连接 (正常行为)，继续接收上游数据...")
-						}
-					} else {
-						log.Printf("⚠️ 流式传输写入错误: %v", err)
-					}
-					// 注意：这里不再return，而是继续循环以耗尽eventChan
-				} else {
-					flusher.Flush()
-				}
-			}
+			// 规范化后统计 token 用量（message_start / message_delta）并实时转发给客户端
+			write(normalize(event))
 
 		case err, ok := <-errChan:
 			if !ok {
@@ -502,73 +734,98 @@ func handleStreamResponse(c *gin.Context, resp *http.Response, provider provider
 						}
 					}
 				}
-				return
+				return usage.TotalTokens(), nil
 			}
 		}
 	}
 }
 
-// shouldRetryWithNextKey 判断是否应该使用下一个密钥重试
-// 返回: (shouldFailover bool, isQuotaRelated bool)
-func shouldRetryWithNextKey(statusCode int, bodyBytes []byte) (bool, bool) {
-	// 401/403 通常是认证问题
-	if statusCode == 401 || statusCode == 403 {
-		return true, false
-	}
-
-	isQuotaRelated := false
-
-	// 检查错误消息
-	var errResp map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &errResp); err == nil {
-		if errObj, ok := errResp["error"].(map[string]interface{}); ok {
-			if msg, ok := errObj["message"].(string); ok {
-				msgLower := strings.ToLower(msg)
-				if strings.Contains(msgLower, "insufficient") ||
-					strings.Contains(msgLower, "invalid") ||
-					strings.Contains(msgLower, "unauthorized") ||
-					strings.Contains(msgLower, "quota") ||
-					strings.Contains(msgLower, "rate limit") ||
-					strings.Contains(msgLower, "credit") ||
-					strings.Contains(msgLower, "balance") {
-
-					// 判断是否为额度/余额相关
-					if strings.Contains(msgLower, "积分不足") ||
-						strings.Contains(msgLower, "insufficient") ||
-						strings.Contains(msgLower, "credit") ||
-						strings.Contains(msgLower, "balance") ||
-						strings.Contains(msgLower, "quota") {
-						isQuotaRelated = true
+// waitForFirstContentEvent 缓冲上游流式事件，直到出现第一个有效内容事件
+// 返回缓冲的事件（含该内容事件）；若流在此之前结束、报错或返回错误事件，则返回错误
+func waitForFirstContentEvent(c *gin.Context, eventChan <-chan string, errChan <-chan error) ([]string, error) {
+	var pending []string
+
+	for {
+		select {
+		case event, ok := <-eventChan:
+			if !ok {
+				// 流结束时检查是否有尚未读取的错误
+				select {
+				case err, ok := <-errChan:
+					if ok && err != nil {
+						return nil, err
 					}
-					return true, isQuotaRelated
+				default:
 				}
+				return nil, fmt.Errorf("上游流式响应在输出内容前结束")
 			}
 
-			if errType, ok := errObj["type"].(string); ok {
-				errTypeLower := strings.ToLower(errType)
-				if strings.Contains(errTypeLower, "permission") ||
-					strings.Contains(errTypeLower, "insufficient") ||
-					strings.Contains(errTypeLower, "over_quota") ||
-					strings.Contains(errTypeLower, "billing") {
-
-					// 判断是否为额度/余额相关
-					if strings.Contains(errTypeLower, "over_quota") ||
-						strings.Contains(errTypeLower, "billing") ||
-						strings.Contains(errTypeLower, "insufficient") {
-						isQuotaRelated = true
-					}
-					return true, isQuotaRelated
-				}
+			if isStreamErrorEvent(event) {
+				return nil, fmt.Errorf("上游流式响应返回错误: %s", strings.TrimSpace(event))
+			}
+
+			pending = append(pending, event)
+			if isStreamContentEvent(event) {
+				return pending, nil
+			}
+
+		case err, ok := <-errChan:
+			if ok && err != nil {
+				return nil, err
 			}
+			// errChan 已关闭，继续等待 eventChan
+			errChan = nil
+
+		case <-c.Request.Context().Done():
+			return nil, c.Request.Context().Err()
 		}
 	}
+}
 
-	// 500+ 错误也可以尝试 failover
-	if statusCode >= 500 {
-		return true, false
+// isStreamContentEvent 判断是否为有效内容事件（内容块或消息结束增量，透传时为 OpenAI / Gemini 数据块）
+func isStreamContentEvent(event string) bool {
+	return strings.Contains(event, "content_block_start") ||
+		strings.Contains(event, "content_block_delta") ||
+		strings.Contains(event, "message_delta") ||
+		strings.Contains(event, `"choices"`) ||
+		strings.Contains(event, `"candidates"`)
+}
+
+// isStreamErrorEvent 判断是否为 Claude SSE 错误事件
+func isStreamErrorEvent(event string) bool {
+	return strings.HasPrefix(event, "event: error") || strings.Contains(event, `"type":"error"`)
+}
+
+// trackStreamUsage 从流式事件中统计 token 用量
+func trackStreamUsage(usage *utils.UsageTracker, event string) {
+	for _, line := range strings.Split(event, "\n") {
+		usage.ProcessLine(line)
+	}
+}
+
+// drainStream 丢弃剩余的流式事件，使上游转换协程能够正常退出
+func drainStream(eventChan <-chan string) {
+	for range eventChan {
 	}
+}
 
-	return false, false
+// hasUsableUpstream 判断故障转移链中是否存在配置了API密钥的渠道
+func hasUsableUpstream(chain []config.UpstreamCandidate) bool {
+	for _, candidate := range chain {
+		if len(candidate.Upstream.APIKeys) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitCooldown 计算失败密钥的冷却时长
+// 429 按上游限流信息冷却；其他状态码仅在显式携带 Retry-After 时使用；返回 0 表示使用默认恢复时间
+func rateLimitCooldown(statusCode int, rateLimit utils.RateLimitInfo) time.Duration {
+	if statusCode != 429 && rateLimit.RetryAfter == 0 {
+		return 0
+	}
+	return rateLimit.Cooldown(time.Now())
 }
 
 // maskAPIKey 掩码API密钥（与 TS 版本保持一致）
@@ -589,3 +846,24 @@ func maskAPIKey(key string) string {
 	// 长密钥：保留前8位和后5位
 	return key[:8] + "***" + key[length-5:]
 }
+
+// respondError 返回本服务产生的错误；来自其他协议入口时转换为入口协议的错误格式
+func respondError(c *gin.Context, ingress ingressFormat, status int, body gin.H) {
+	if ingress == nil {
+		c.JSON(status, body)
+		return
+	}
+	raw, _ := json.Marshal(body)
+	c.JSON(status, ingress.convertError(status, raw))
+}
+
+// anthropicError 构造 Anthropic 格式的错误响应
+func anthropicError(errType, message string) gin.H {
+	return gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errType,
+			"message": message,
+		},
+	}
+}
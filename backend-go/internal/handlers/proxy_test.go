@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+)
+
+// newTestConfigManager 使用临时配置文件创建配置管理器
+func newTestConfigManager(t *testing.T, cfg config.Config) *config.ConfigManager {
+	t.Helper()
+	cfg.LoadBalance = "failover"
+	data, _ := json.Marshal(cfg)
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatalf("写入配置失败: %v", err)
+	}
+	cfgManager, err := config.NewConfigManager(configFile)
+	if err != nil {
+		t.Fatalf("创建配置管理器失败: %v", err)
+	}
+	return cfgManager
+}
+
+func TestProxyMessages_BareRateLimitCooldown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// key-a 返回不含限流字样的 429，key-b 正常响应
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer key-a" {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(429)
+			w.Write([]byte(`{"type":"error","error":{"type":"error","message":"busy"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	upstream := config.UpstreamConfig{Name: "relay", BaseURL: server.URL, ServiceType: "claude", APIKeys: []string{"key-a", "key-b"}}
+	cfgManager := newTestConfigManager(t, config.Config{Upstream: []config.UpstreamConfig{upstream}})
+	envCfg := &config.EnvConfig{ProxyAccessKey: "test-key", RequestTimeout: 5000}
+
+	execute := newInternalExecutor("/v1/messages", ProxyHandler(envCfg, cfgManager))
+	status, body := execute(context.Background(), []byte(`{"model":"claude-sonnet","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`),
+		http.Header{"X-Api-Key": {"test-key"}})
+	if status != 200 {
+		t.Fatalf("429 后应切换到下一个密钥: 状态码 = %d, 响应 = %s", status, body)
+	}
+
+	// 冷却时间取自 Retry-After，而非默认恢复时间
+	keyStatus := cfgManager.GetKeyStatus(&upstream, "key-a")
+	if keyStatus.State != config.KeyStateCooling || keyStatus.RecoverAt == nil {
+		t.Fatalf("key-a 状态 = %+v", keyStatus)
+	}
+	if remaining := time.Until(*keyStatus.RecoverAt); remaining < 20*time.Second || remaining > 40*time.Second {
+		t.Errorf("key-a 冷却剩余 %v, want 约 30s", remaining)
+	}
+}
+
+func TestProxyMessages_HalfOpenProbeClosesOnClientError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 首个请求返回 5xx 触发熔断，之后返回非渠道级的 400
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"boom"}}`))
+			return
+		}
+		w.WriteHeader(400)
+		w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens too large"}}`))
+	}))
+	defer server.Close()
+
+	upstream := config.UpstreamConfig{Name: "relay", BaseURL: server.URL, ServiceType: "claude", APIKeys: []string{"key-a"}}
+	cfgManager := newTestConfigManager(t, config.Config{
+		Upstream:       []config.UpstreamConfig{upstream},
+		CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 1, OpenSeconds: 1},
+	})
+	envCfg := &config.EnvConfig{ProxyAccessKey: "test-key", RequestTimeout: 5000}
+	execute := newInternalExecutor("/v1/messages", ProxyHandler(envCfg, cfgManager))
+	send := func() int {
+		status, _ := execute(context.Background(), []byte(`{"model":"claude-sonnet","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`),
+			http.Header{"X-Api-Key": {"test-key"}})
+		return status
+	}
+
+	if status := send(); status != 500 {
+		t.Fatalf("首个请求状态码 = %d", status)
+	}
+	if state := cfgManager.GetCircuitState(&upstream).State; state != config.CircuitOpen {
+		t.Fatalf("5xx 后熔断器状态 = %s", state)
+	}
+
+	// 熔断超时后进入半开，探测请求收到 400：渠道可达，熔断器关闭
+	time.Sleep(1100 * time.Millisecond)
+	if status := send(); status != 400 {
+		t.Fatalf("探测请求状态码 = %d", status)
+	}
+	if state := cfgManager.GetCircuitState(&upstream).State; state != config.CircuitClosed {
+		t.Errorf("探测请求返回 400 后熔断器状态 = %s, want %s", state, config.CircuitClosed)
+	}
+}
@@ -27,6 +27,11 @@ func ResponsesHandler(
 	cfgManager *config.ConfigManager,
 	sessionManager *session.SessionManager,
 ) gin.HandlerFunc {
+	// 后台任务以内部请求的方式执行
+	background := newInternalExecutor("/v1/responses", func(c *gin.Context) {
+		proxyResponses(c, envCfg, cfgManager, sessionManager)
+	})
+
 	return gin.HandlerFunc(func(c *gin.Context) {
 		// 先进行认证
 		middleware.ProxyAuthMiddleware(envCfg)(c)
@@ -34,8 +39,6 @@ func ResponsesHandler(
 			return
 		}
 
-		startTime := time.Now()
-
 		// 读取原始请求体
 		bodyBytes, err := io.ReadAll(c.Request.Body)
 		if err != nil {
@@ -45,51 +48,113 @@ func ResponsesHandler(
 		// 恢复请求体供后续使用
 		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 
-		// 解析 Responses 请求
+		// 后台模式：立即返回 queued 响应，由后台任务执行
 		var responsesReq types.ResponsesRequest
 		if len(bodyBytes) > 0 {
 			_ = json.Unmarshal(bodyBytes, &responsesReq)
 		}
-
-		// 获取当前 Responses 上游配置
-		upstream, err := cfgManager.GetCurrentResponsesUpstream()
-		if err != nil {
-			c.JSON(503, gin.H{
-				"error": "未配置任何 Responses 渠道，请先在管理界面添加渠道",
-				"code":  "NO_RESPONSES_UPSTREAM",
-			})
+		if responsesReq.Background {
+			startBackgroundResponse(c, sessionManager, background, bodyBytes, &responsesReq)
 			return
 		}
 
+		proxyResponses(c, envCfg, cfgManager, sessionManager)
+	})
+}
+
+// proxyResponses 通过 Responses 渠道池转发请求（上游请求随 c.Request 的 context 取消）
+func proxyResponses(c *gin.Context, envCfg *config.EnvConfig, cfgManager *config.ConfigManager, sessionManager *session.SessionManager) {
+	startTime := time.Now()
+
+	// 读取原始请求体
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	// 恢复请求体供后续使用
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+	// 解析 Responses 请求
+	var responsesReq types.ResponsesRequest
+	if len(bodyBytes) > 0 {
+		_ = json.Unmarshal(bodyBytes, &responsesReq)
+	}
+
+	// 会话标识（用于粘性路由，保证同一会话命中同一渠道和密钥以复用上游提示词缓存；链式请求沿用首轮的标识）
+	sessionID := responsesStickySessionID(c, cfgManager, sessionManager, bodyBytes, responsesReq.PreviousResponseID)
+
+	// 获取 Responses 渠道故障转移链（按模型路由，未命中时当前渠道优先；负载均衡时会话绑定的渠道优先）
+	chain, err := cfgManager.GetResponsesFailoverChain(responsesReq.Model, sessionID)
+	if err != nil {
+		c.JSON(503, gin.H{
+			"error": "未配置任何 Responses 渠道，请先在管理界面添加渠道",
+			"code":  "NO_RESPONSES_UPSTREAM",
+		})
+		return
+	}
+
+	if !hasUsableUpstream(chain) {
+		c.JSON(503, gin.H{
+			"error": fmt.Sprintf("当前 Responses 渠道 \"%s\" 未配置API密钥", chain[0].Upstream.Name),
+			"code":  "NO_API_KEYS",
+		})
+		return
+	}
+
+	// 创建 ResponsesProvider
+	provider := &providers.ResponsesProvider{
+		SessionManager: sessionManager,
+	}
+
+	// 跳过熔断中的渠道（半开渠道仅放行少量探测请求）
+	chain = cfgManager.FilterAvailableChannels(chain)
+
+	var lastError error
+	var lastOriginalBodyBytes []byte
+	var lastFailoverError *struct {
+		Status int
+		Body   []byte
+	}
+	deprioritizeCandidates := make(map[string]bool)
+
+	for chainPos, candidate := range chain {
+		upstream := candidate.Upstream
 		if len(upstream.APIKeys) == 0 {
-			c.JSON(503, gin.H{
-				"error": fmt.Sprintf("当前 Responses 渠道 \"%s\" 未配置API密钥", upstream.Name),
-				"code":  "NO_API_KEYS",
-			})
-			return
+			continue
 		}
 
-		// 创建 ResponsesProvider
-		provider := &providers.ResponsesProvider{
-			SessionManager: sessionManager,
+		if chainPos > 0 && envCfg.ShouldLog("info") {
+			log.Printf("🔀 Responses 故障转移到渠道: [%d] %s", candidate.Index, upstream.Name)
+		} else if len(upstream.Models) > 0 && envCfg.ShouldLog("info") {
+			log.Printf("🧭 模型 %s 路由到 Responses 渠道: [%d] %s", responsesReq.Model, candidate.Index, upstream.Name)
 		}
 
+		// 即将向该渠道发送请求：半开渠道占用探测名额，离开该渠道时释放（已上报结果时为空操作）
+		probe, ok := cfgManager.AcquireChannel(candidate)
+		if !ok {
+			log.Printf("⏭️ Responses 渠道 %s 的熔断探测名额已被占用，跳过", upstream.Name)
+			continue
+		}
+		defer probe.Release()
+
+		hasNextChannel := chainPos < len(chain)-1
+
 		// 实现 failover 重试逻辑
 		maxRetries := len(upstream.APIKeys)
 		failedKeys := make(map[string]bool)
-		var lastError error
-		var lastOriginalBodyBytes []byte
-		var lastFailoverError *struct {
-			Status int
-			Body   []byte
-		}
-		deprioritizeCandidates := make(map[string]bool)
+		sameKeyRetries := make(map[string]int) // 按规则使用同一密钥重试的次数
+		retryKey := ""                         // 下一次尝试需复用的密钥
 
 		for attempt := 0; attempt < maxRetries; attempt++ {
-			apiKey, err := cfgManager.GetNextAPIKey(upstream, failedKeys)
-			if err != nil {
-				lastError = err
-				break
+			apiKey := retryKey
+			retryKey = ""
+			if apiKey == "" {
+				apiKey, err = cfgManager.GetAPIKeyForSession(upstream, failedKeys, sessionID)
+				if err != nil {
+					lastError = err
+					break
+				}
 			}
 
 			if envCfg.ShouldLog("info") {
@@ -108,6 +173,8 @@ func ResponsesHandler(
 				continue
 			}
 			lastOriginalBodyBytes = originalBodyBytes
+			// 客户端断开或后台响应被取消时中断上游请求
+			providerReq = providerReq.WithContext(c.Request.Context())
 
 			// 请求日志
 			if envCfg.EnableRequestLogs {
@@ -130,12 +197,24 @@ func ResponsesHandler(
 			}
 
 			// 发送请求
+			requestStart := time.Now()
 			resp, err := sendResponsesRequest(providerReq, upstream, envCfg, responsesReq.Stream)
 			if err != nil {
+				if c.Request.Context().Err() != nil {
+					log.Printf("ℹ️ Responses 请求已取消: %v", c.Request.Context().Err())
+					return
+				}
+				cfgManager.RecordChannelResult(upstream, 0, false)
 				lastError = err
 				failedKeys[apiKey] = true
 				cfgManager.MarkKeyAsFailed(apiKey)
 				log.Printf("⚠️ API密钥失败: %v", err)
+
+				// 连接错误属于渠道级故障，直接切换到下一个渠道
+				if hasNextChannel {
+					log.Printf("⏭️ Responses 渠道 %s 连接失败，切换到下一个渠道", upstream.Name)
+					break
+				}
 				continue
 			}
 
@@ -147,15 +226,47 @@ func ResponsesHandler(
 				// 兜底处理：解压缩
 				bodyBytes = utils.DecompressGzipIfNeeded(resp, bodyBytes)
 
-				// 检查是否需要 failover
-				shouldFailover, isQuotaRelated := shouldRetryWithNextKey(resp.StatusCode, bodyBytes)
-				if shouldFailover {
+				// 解析上游限流信息（Retry-After、剩余额度等）
+				rateLimit := utils.ParseRateLimitInfo(resp.Header, bodyBytes)
+				cfgManager.RecordKeyRateLimit(apiKey, rateLimit)
+
+				// 按故障转移规则（渠道自定义规则优先，其次内置规则）决定后续动作
+				decision := config.ClassifyFailover(upstream, resp.StatusCode, bodyBytes)
+				if decision.Action == config.FailoverRetrySameKey {
+					if sameKeyRetries[apiKey] < decision.MaxRetries {
+						sameKeyRetries[apiKey]++
+						log.Printf("🔁 Responses 上游错误 (状态: %d) 命中规则 %s，使用同一密钥重试 (%d/%d)",
+							resp.StatusCode, decision.Rule, sameKeyRetries[apiKey], decision.MaxRetries)
+						retryKey = apiKey
+						attempt--
+						continue
+					}
+					// 同一密钥重试次数用尽，切换到下一个密钥
+					decision.Action = config.FailoverNextKey
+				}
+
+				// 渠道级故障计入熔断器失败；其余错误说明渠道可达，计入成功（半开探测据此结束）
+				channelLevel := decision.Action == config.FailoverNextChannel
+				if channelLevel {
+					cfgManager.RecordChannelResult(upstream, 0, false)
+				} else {
+					cfgManager.RecordChannelReachable(upstream)
+				}
+
+				if decision.ShouldFailover() {
 					lastError = fmt.Errorf("上游错误: %d", resp.StatusCode)
 					failedKeys[apiKey] = true
-					cfgManager.MarkKeyAsFailed(apiKey)
+					switch {
+					case decision.Action == config.FailoverQuarantine:
+						cfgManager.QuarantineAPIKey(apiKey, fmt.Sprintf("状态 %d 命中规则 %s", resp.StatusCode, decision.Rule))
+					case config.IsAuthFailure(resp.StatusCode, bodyBytes) && cfgManager.RecordKeyAuthFailure(apiKey, resp.StatusCode):
+						// 连续认证失败，密钥已自动隔离
+					default:
+						cfgManager.MarkKeyAsFailedWithCooldown(apiKey, rateLimitCooldown(resp.StatusCode, rateLimit))
+					}
 
 					// 增强的日志输出
-					log.Printf("⚠️ Responses API密钥失败 (状态: %d)，尝试下一个密钥", resp.StatusCode)
+					log.Printf("⚠️ Responses API密钥失败 (状态: %d, 规则: %s)，尝试下一个密钥", resp.StatusCode, decision.Rule)
 					if envCfg.EnableResponseLogs && envCfg.IsDevelopment() {
 						formattedBody := utils.FormatJSONBytesForLog(bodyBytes, 500)
 						log.Printf("📦 失败原因:\n%s", formattedBody)
@@ -172,10 +283,15 @@ func ResponsesHandler(
 						Body:   bodyBytes,
 					}
 
-					if isQuotaRelated {
+					if decision.QuotaRelated {
 						deprioritizeCandidates[apiKey] = true
 					}
 
+					// 渠道级故障（内置规则下为 5xx），直接切换到下一个渠道
+					if hasNextChannel && channelLevel {
+						log.Printf("⏭️ Responses 渠道 %s 返回 %d，切换到下一个渠道", upstream.Name, resp.StatusCode)
+						break
+					}
 					continue
 				}
 
@@ -202,6 +318,12 @@ func ResponsesHandler(
 				return
 			}
 
+			cfgManager.RecordChannelResult(upstream, time.Since(requestStart), true)
+			cfgManager.RecordKeySuccess(apiKey)
+			cfgManager.PinSessionKey(upstream, sessionID, apiKey)
+			cfgManager.PinSessionChannel(upstream, responsesReq.Model, sessionID)
+			cfgManager.RecordKeyRateLimit(apiKey, utils.ParseRateLimitInfo(resp.Header, nil))
+
 			// 成功响应：降级失败的密钥
 			if len(deprioritizeCandidates) > 0 {
 				for key := range deprioritizeCandidates {
@@ -212,32 +334,42 @@ func ResponsesHandler(
 			}
 
 			// 处理成功响应
-			handleResponsesSuccess(c, resp, provider, upstream.ServiceType, envCfg, sessionManager, startTime, &responsesReq)
+			usedTokens := handleResponsesSuccess(c, resp, provider, upstream.ServiceType, envCfg, sessionManager, startTime, &responsesReq, sessionID)
+			cfgManager.RecordKeyUsage(apiKey, usedTokens)
 			return
 		}
 
-		// 所有密钥都失败了
-		log.Printf("💥 所有 Responses API密钥都失败了")
+		probe.Release()
+		if hasNextChannel {
+			log.Printf("⏭️ Responses 渠道 %s 的所有API密钥都失败了，尝试下一个渠道", upstream.Name)
+		}
+	}
 
-		if lastFailoverError != nil {
-			status := lastFailoverError.Status
-			if status == 0 {
-				status = 500
-			}
+	// 所有渠道的密钥都失败了
+	log.Printf("💥 所有 Responses 渠道的API密钥都失败了")
 
-			var errBody map[string]interface{}
-			if err := json.Unmarshal(lastFailoverError.Body, &errBody); err == nil {
-				c.JSON(status, errBody)
-			} else {
-				c.JSON(status, gin.H{"error": string(lastFailoverError.Body)})
-			}
+	if lastFailoverError != nil {
+		status := lastFailoverError.Status
+		if status == 0 {
+			status = 500
+		}
+
+		var errBody map[string]interface{}
+		if err := json.Unmarshal(lastFailoverError.Body, &errBody); err == nil {
+			c.JSON(status, errBody)
 		} else {
-			c.JSON(500, gin.H{
-				"error":   "所有上游 Responses API密钥都不可用",
-				"details": lastError.Error(),
-			})
+			c.JSON(status, gin.H{"error": string(lastFailoverError.Body)})
 		}
-	})
+	} else {
+		details := "未知错误"
+		if lastError != nil {
+			details = lastError.Error()
+		}
+		c.JSON(500, gin.H{
+			"error":   "所有上游 Responses API密钥都不可用",
+			"details": details,
+		})
+	}
 }
 
 // sendResponsesRequest 发送 Responses 请求
@@ -291,7 +423,7 @@ func sendResponsesRequest(req *http.Request, upstream *config.UpstreamConfig, en
 	return client.Do(req)
 }
 
-// handleResponsesSuccess 处理成功的 Responses 响应
+// handleResponsesSuccess 处理成功的 Responses 响应，返回上游报告的 token 用量
 func handleResponsesSuccess(
 	c *gin.Context,
 	resp *http.Response,
@@ -301,14 +433,17 @@ func handleResponsesSuccess(
 	sessionManager *session.SessionManager,
 	startTime time.Time,
 	originalReq *types.ResponsesRequest,
-) {
+	stickyID string,
+) int64 {
 	defer resp.Body.Close()
 
+	var usage utils.UsageTracker
+
 	// 检查是否为流式响应
 	isStream := originalReq != nil && originalReq.Stream
 
 	if isStream {
-		// 流式响应处理:直接转发SSE流
+		// 流式响应处理：按渠道类型转换为 Responses 事件（responses 渠道原样转发）
 		if envCfg.EnableResponseLogs {
 			responseTime := time.Since(startTime).Milliseconds()
 			log.Printf("⏱️ Responses 流式响应开始: %dms, 状态: %d", responseTime, resp.StatusCode)
@@ -333,20 +468,21 @@ func handleResponsesSuccess(
 		// 转发流式响应并记录内容
 		c.Status(resp.StatusCode)
 		flusher, _ := c.Writer.(http.Flusher)
+		streamConverter := provider.NewStreamConverter(upstreamType, originalReq)
+		clientGone := false
 
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := scanner.Text()
-
-			// 写入客户端
-			_, err := c.Writer.Write([]byte(line + "\n"))
-			if err != nil {
-				log.Printf("⚠️ 流式响应传输错误: %v", err)
-				break
-			}
-
-			if flusher != nil {
-				flusher.Flush()
+			usage.ProcessLine(line)
+
+			// 转换后写入客户端；客户端断开后继续读取上游，以便完整记录本轮会话
+			events := streamConverter.ProcessLine(line)
+			if !clientGone {
+				if err := writeResponsesEvents(c, flusher, events); err != nil {
+					log.Printf("⚠️ 流式响应传输错误: %v", err)
+					clientGone = true
+				}
 			}
 
 			// 记录日志（仅在开发模式下）
@@ -362,6 +498,17 @@ func handleResponsesSuccess(
 			log.Printf("⚠️ 流式响应读取错误: %v", err)
 		}
 
+		if events := streamConverter.Finish(); !clientGone {
+			if err := writeResponsesEvents(c, flusher, events); err != nil {
+				log.Printf("⚠️ 流式响应传输错误: %v", err)
+			}
+		}
+
+		// 上游流完整结束后与非流式响应一样更新会话（与客户端是否仍在连接无关）
+		if final := streamConverter.Response(); final != nil && final.Status == "completed" {
+			recordResponsesResult(c, sessionManager, originalReq, final, stickyID)
+		}
+
 		if envCfg.EnableResponseLogs {
 			responseTime := time.Since(startTime).Milliseconds()
 			log.Printf("✅ Responses 流式响应完成: %dms", responseTime)
@@ -382,15 +529,16 @@ func handleResponsesSuccess(
 				}
 			}
 		}
-		return
+		return usage.TotalTokens()
 	}
 
 	// 非流式响应处理(原有逻辑)
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to read response"})
-		return
+		return 0
 	}
+	usage.ProcessJSON(bodyBytes)
 
 	if envCfg.EnableResponseLogs {
 		responseTime := time.Since(startTime).Milliseconds()
@@ -422,49 +570,75 @@ func handleResponsesSuccess(
 	responsesResp, err := provider.ConvertToResponsesResponse(providerResp, upstreamType, "")
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to convert response"})
-		return
+		return usage.TotalTokens()
 	}
 
-	// 更新会话（如果需要）
-	if originalReq.Store == nil || *originalReq.Store {
-		// 获取会话
-		sess, err := sessionManager.GetOrCreateSession(originalReq.PreviousResponseID)
-		if err == nil {
-			// 追加用户输入
-			inputItems, _ := parseInputToItems(originalReq.Input)
-			for _, item := range inputItems {
-				sessionManager.AppendMessage(sess.ID, item, 0)
-			}
+	// 更新会话
+	recordResponsesResult(c, sessionManager, originalReq, responsesResp, stickyID)
 
-			// 追加助手响应
-			for _, item := range responsesResp.Output {
-				sessionManager.AppendMessage(sess.ID, item, responsesResp.Usage.TotalTokens)
-			}
+	// 转发上游响应头到客户端（透明代理）
+	utils.ForwardResponseHeaders(resp.Header, c.Writer)
+
+	c.JSON(200, responsesResp)
+	return usage.TotalTokens()
+}
 
-			// 更新 last response ID
-			sessionManager.UpdateLastResponseID(sess.ID, responsesResp.ID)
+// recordResponsesResult 记录上游成功返回的响应（流式和非流式共用）：后台响应使用创建时分配的 ID，再按 store 更新会话
+// stickyID 记录到会话中，供后续 previous_response_id 请求沿用同一粘性路由标识
+func recordResponsesResult(c *gin.Context, sessionManager *session.SessionManager, originalReq *types.ResponsesRequest, responsesResp *types.ResponsesResponse, stickyID string) {
+	if responseID, _ := c.Request.Context().Value(backgroundResponseIDKey{}).(string); responseID != "" {
+		responsesResp.ID = responseID
+	}
+	if recordResponsesSession(sessionManager, originalReq, responsesResp) {
+		sessionManager.BindStickyID(responsesResp.ID, stickyID)
+	}
+}
 
-			// 记录映射
-			sessionManager.RecordResponseMapping(responsesResp.ID, sess.ID)
+// recordResponsesSession 将本轮输入和响应追加到会话（store 为 false 时跳过），返回是否已记录
+func recordResponsesSession(sessionManager *session.SessionManager, originalReq *types.ResponsesRequest, responsesResp *types.ResponsesResponse) bool {
+	if originalReq.Store != nil && !*originalReq.Store {
+		return false
+	}
 
-			// 设置 previous_id
-			if sess.LastResponseID != "" {
-				responsesResp.PreviousID = sess.LastResponseID
-			}
+	// 追加本轮输入和助手响应、记录映射并存储响应，供 previous_response_id 和 GET /v1/responses/{id} 使用
+	inputItems, _ := parseInputToItems(originalReq.Input)
+	recorded, err := sessionManager.RecordResponse(originalReq.PreviousResponseID, *responsesResp, inputItems)
+	if err != nil {
+		if err == session.ErrResponseFinished {
+			log.Printf("ℹ️ 后台响应 %s 已结束，丢弃上游结果", responsesResp.ID)
 		}
+		return false
 	}
+	responsesResp.PreviousID = recorded.PreviousID
+	return true
+}
 
-	// 转发上游响应头到客户端（透明代理）
-	utils.ForwardResponseHeaders(resp.Header, c.Writer)
-
-	c.JSON(200, responsesResp)
+// writeResponsesEvents 将转换后的流式事件写入客户端
+func writeResponsesEvents(c *gin.Context, flusher http.Flusher, events []string) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for _, event := range events {
+		if _, err := c.Writer.Write([]byte(event)); err != nil {
+			return err
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return nil
 }
 
-// parseInputToItems 解析 input 为 ResponsesItem 数组
+// parseInputToItems 解析 input 为 ResponsesItem 数组（条目格式与 OpenAI input_items 一致）
+// 字符串输入视为一条用户消息；function_call / function_call_output 保留调用 ID、函数名、参数和输出
 func parseInputToItems(input interface{}) ([]types.ResponsesItem, error) {
 	switch v := input.(type) {
 	case string:
-		return []types.ResponsesItem{{Type: "text", Content: v}}, nil
+		return []types.ResponsesItem{{
+			Type:    "message",
+			Role:    "user",
+			Content: []types.ContentBlock{{Type: "input_text", Text: v}},
+		}}, nil
 	case []interface{}:
 		items := []types.ResponsesItem{}
 		for _, item := range v {
@@ -472,12 +646,44 @@ func parseInputToItems(input interface{}) ([]types.ResponsesItem, error) {
 			if !ok {
 				continue
 			}
-			itemType, _ := itemMap["type"].(string)
-			content := itemMap["content"]
-			items = append(items, types.ResponsesItem{Type: itemType, Content: content})
+			items = append(items, parseInputItem(itemMap))
 		}
 		return items, nil
 	default:
 		return nil, fmt.Errorf("unsupported input type")
 	}
 }
+
+// parseInputItem 按条目类型映射单个 input 条目
+func parseInputItem(itemMap map[string]interface{}) types.ResponsesItem {
+	field := func(key string) string {
+		value, _ := itemMap[key].(string)
+		return value
+	}
+
+	item := types.ResponsesItem{ID: field("id"), Type: field("type"), Status: field("status")}
+	switch item.Type {
+	case "function_call":
+		item.CallID = field("call_id")
+		item.Name = field("name")
+		item.Arguments = field("arguments")
+	case "function_call_output":
+		item.CallID = field("call_id")
+		item.Output = itemMap["output"]
+	default:
+		// 简写形式 {"role": "user", "content": "..."} 等同于 message
+		if item.Type == "" {
+			item.Type = "message"
+		}
+		item.Role = field("role")
+		item.Content = itemMap["content"]
+		if text, ok := item.Content.(string); ok && item.Type == "message" {
+			blockType := "input_text"
+			if item.Role == "assistant" {
+				blockType = "output_text"
+			}
+			item.Content = []types.ContentBlock{{Type: blockType, Text: text}}
+		}
+	}
+	return item
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/converters"
+	"github.com/BenedictKing/claude-proxy/internal/session"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ============== Responses 后台模式（background: true） ==============
+// 创建时立即返回 queued 响应，由后台任务以非流式方式经 Responses 渠道池执行；
+// 客户端通过 GET /v1/responses/{id} 轮询结果，POST /v1/responses/{id}/cancel 取消执行中的上游请求
+
+// backgroundResponseIDKey 后台任务内部请求的 context 键，值为创建时分配的响应 ID
+type backgroundResponseIDKey struct{}
+
+// startBackgroundResponse 登记后台响应并启动后台任务
+func startBackgroundResponse(
+	c *gin.Context,
+	sessionManager *session.SessionManager,
+	execute internalExecutor,
+	bodyBytes []byte,
+	req *types.ResponsesRequest,
+) {
+	if req.Store != nil && !*req.Store {
+		c.JSON(400, openAIError("background 模式需要 store 为 true"))
+		return
+	}
+
+	// 后台任务以非流式执行，结果写入会话
+	var body map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		c.JSON(400, openAIError("Invalid JSON body"))
+		return
+	}
+	delete(body, "background")
+	body["stream"] = false
+	execBody, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(500, openAIError(err.Error()))
+		return
+	}
+
+	queued := types.ResponsesResponse{
+		ID:         converters.GenerateResponseID(),
+		Model:      req.Model,
+		Output:     []types.ResponsesItem{},
+		Status:     "queued",
+		Created:    time.Now().Unix(),
+		Background: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sessionManager.StoreBackgroundResponse(queued, cancel)
+	log.Printf("📥 创建后台响应: %s", queued.ID)
+
+	go runBackgroundResponse(ctx, sessionManager, execute, queued.ID, execBody, c.Request.Header.Clone())
+
+	c.JSON(200, queued)
+}
+
+// runBackgroundResponse 以内部请求的方式执行 Responses 代理流程，并更新后台响应状态
+func runBackgroundResponse(
+	ctx context.Context,
+	sessionManager *session.SessionManager,
+	execute internalExecutor,
+	responseID string,
+	body []byte,
+	headers http.Header,
+) {
+	sessionManager.UpdateBackgroundResponse(responseID, "in_progress", nil)
+
+	// 成功结果以创建时分配的 ID 写入会话
+	status, respBody := execute(context.WithValue(ctx, backgroundResponseIDKey{}, responseID), body, headers)
+
+	switch {
+	case ctx.Err() != nil:
+		log.Printf("🛑 后台响应已取消: %s", responseID)
+	case status >= 200 && status < 300:
+		// 成功时 proxyResponses 已将结果写入会话（替换 queued 记录）
+		log.Printf("✅ 后台响应完成: %s", responseID)
+	default:
+		log.Printf("⚠️ 后台响应失败: %s (状态: %d)", responseID, status)
+		sessionManager.UpdateBackgroundResponse(responseID, "failed", backgroundResponseError(respBody))
+	}
+}
+
+// backgroundResponseError 从失败的响应体中提取错误信息
+func backgroundResponseError(body []byte) *types.ResponsesError {
+	var parsed struct {
+		Error interface{} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		switch e := parsed.Error.(type) {
+		case map[string]interface{}:
+			code, _ := e["type"].(string)
+			if c, ok := e["code"].(string); ok && c != "" {
+				code = c
+			}
+			message, _ := e["message"].(string)
+			if code == "" {
+				code = "server_error"
+			}
+			return &types.ResponsesError{Code: code, Message: message}
+		case string:
+			return &types.ResponsesError{Code: "server_error", Message: e}
+		}
+	}
+	return &types.ResponsesError{Code: "server_error", Message: strings.TrimSpace(string(body))}
+}
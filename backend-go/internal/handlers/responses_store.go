@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/middleware"
+	"github.com/BenedictKing/claude-proxy/internal/session"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ============== 已存储响应的查询与删除（/v1/responses/{id}） ==============
+// 数据来自会话管理器：store 不为 false 的响应在完成后保存，随所属会话一起过期清理；
+// 后台响应在创建时即保存（queued），完成后替换为最终结果
+
+const (
+	defaultInputItemsLimit = 20
+	maxInputItemsLimit     = 100
+)
+
+// GetResponseHandler 获取已存储的响应（GET /v1/responses/:id）
+func GetResponseHandler(envCfg *config.EnvConfig, sessionManager *session.SessionManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		stored, ok := sessionManager.GetResponse(c.Param("id"))
+		if !ok {
+			c.JSON(404, responseNotFoundError(c.Param("id")))
+			return
+		}
+		c.JSON(200, stored.Response)
+	})
+}
+
+// DeleteResponseHandler 删除已存储的响应（DELETE /v1/responses/:id）
+func DeleteResponseHandler(envCfg *config.EnvConfig, sessionManager *session.SessionManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		id := c.Param("id")
+		if !sessionManager.DeleteResponse(id) {
+			c.JSON(404, responseNotFoundError(id))
+			return
+		}
+		c.JSON(200, gin.H{"id": id, "object": "response", "deleted": true})
+	})
+}
+
+// CancelResponseHandler 取消后台响应（POST /v1/responses/:id/cancel）
+func CancelResponseHandler(envCfg *config.EnvConfig, sessionManager *session.SessionManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		resp, err := sessionManager.CancelBackgroundResponse(c.Param("id"))
+		switch err {
+		case nil:
+			c.JSON(200, resp)
+		case session.ErrResponseNotFound:
+			c.JSON(404, responseNotFoundError(c.Param("id")))
+		default:
+			c.JSON(400, openAIError(err.Error()))
+		}
+	})
+}
+
+// ListInputItemsHandler 分页列出响应的输入条目（GET /v1/responses/:id/input_items）
+// 支持 limit（1-100）、order（asc/desc，默认 desc）、after、before
+func ListInputItemsHandler(envCfg *config.EnvConfig, sessionManager *session.SessionManager) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		// 先进行认证
+		middleware.ProxyAuthMiddleware(envCfg)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		stored, ok := sessionManager.GetResponse(c.Param("id"))
+		if !ok {
+			c.JSON(404, responseNotFoundError(c.Param("id")))
+			return
+		}
+
+		limit := defaultInputItemsLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxInputItemsLimit {
+				c.JSON(400, openAIError("limit 必须在 1-100 之间"))
+				return
+			}
+			limit = n
+		}
+
+		order := c.DefaultQuery("order", "desc")
+		if order != "asc" && order != "desc" {
+			c.JSON(400, openAIError("order 必须为 asc 或 desc"))
+			return
+		}
+
+		items := make([]types.ResponsesItem, len(stored.InputItems))
+		copy(items, stored.InputItems)
+		if order == "desc" {
+			for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+				items[i], items[j] = items[j], items[i]
+			}
+		}
+
+		start, end := 0, len(items)
+		if after := c.Query("after"); after != "" {
+			start = indexOfInputItem(items, after) + 1
+			if start == 0 {
+				start = end
+			}
+		}
+		if before := c.Query("before"); before != "" {
+			if idx := indexOfInputItem(items, before); idx >= 0 && idx < end {
+				end = idx
+			}
+		}
+		if start > end {
+			start = end
+		}
+		hasMore := end-start > limit
+		if hasMore {
+			end = start + limit
+		}
+
+		page := items[start:end]
+		resp := gin.H{
+			"object":   "list",
+			"data":     page,
+			"has_more": hasMore,
+			"first_id": nil,
+			"last_id":  nil,
+		}
+		if len(page) > 0 {
+			resp["first_id"] = page[0].ID
+			resp["last_id"] = page[len(page)-1].ID
+		}
+		c.JSON(200, resp)
+	})
+}
+
+// indexOfInputItem 查找条目位置，不存在时返回 -1
+func indexOfInputItem(items []types.ResponsesItem, id string) int {
+	for i, item := range items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// responseNotFoundError 构造 OpenAI 格式的响应不存在错误
+func responseNotFoundError(id string) gin.H {
+	return openAIError(fmt.Sprintf("Response with id '%s' not found.", id))
+}
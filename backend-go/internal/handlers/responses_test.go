@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/providers"
+	"github.com/BenedictKing/claude-proxy/internal/session"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+func TestParseInputToItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "字符串输入",
+			input:    `"你好"`,
+			expected: `[{"type":"message","role":"user","content":[{"type":"input_text","text":"你好"}]}]`,
+		},
+		{
+			name:     "简写消息",
+			input:    `[{"role":"user","content":"你好"},{"role":"assistant","content":"在"}]`,
+			expected: `[{"type":"message","role":"user","content":[{"type":"input_text","text":"你好"}]},{"type":"message","role":"assistant","content":[{"type":"output_text","text":"在"}]}]`,
+		},
+		{
+			name:     "消息数组",
+			input:    `[{"type":"message","id":"msg_1","role":"user","content":[{"type":"input_text","text":"查天气"}]}]`,
+			expected: `[{"id":"msg_1","type":"message","role":"user","content":[{"text":"查天气","type":"input_text"}]}]`,
+		},
+		{
+			name: "函数调用与输出",
+			input: `[{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"北京\"}","status":"completed"},` +
+				`{"type":"function_call_output","call_id":"call_1","output":"晴"}]`,
+			expected: `[{"id":"fc_1","type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"北京\"}","status":"completed"},` +
+				`{"type":"function_call_output","call_id":"call_1","output":"晴"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input interface{}
+			if err := json.Unmarshal([]byte(tt.input), &input); err != nil {
+				t.Fatalf("解析输入失败: %v", err)
+			}
+			items, err := parseInputToItems(input)
+			if err != nil {
+				t.Fatalf("parseInputToItems 失败: %v", err)
+			}
+			if got := marshalValue(items); got != tt.expected {
+				t.Errorf("条目 = %s", got)
+			}
+		})
+	}
+}
+
+// disconnectedWriter 模拟已断开的客户端：所有写入均失败
+type disconnectedWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w disconnectedWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write: broken pipe")
+}
+
+func TestHandleResponsesSuccess_StreamRecordsAfterClientGone(t *testing.T) {
+	upstream := `data: {"type":"message_start","message":{"model":"claude-x","usage":{"input_tokens":10}}}
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"你好"}}
+data: {"type":"content_block_stop","index":0}
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}
+data: {"type":"message_stop"}
+`
+
+	for _, background := range []bool{false, true} {
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(disconnectedWriter{httptest.NewRecorder()})
+		ctx := context.Background()
+		if background {
+			ctx = context.WithValue(ctx, backgroundResponseIDKey{}, "resp_background")
+		}
+		c.Request = httptest.NewRequest("POST", "/v1/responses", nil).WithContext(ctx)
+
+		sessionManager := session.NewSessionManager(time.Hour, 100, 100000)
+		req := &types.ResponsesRequest{Model: "claude-x", Input: "hi", Stream: true}
+		resp := &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(upstream))}
+
+		provider := &providers.ResponsesProvider{SessionManager: sessionManager}
+		handleResponsesSuccess(c, resp, provider, "claude", &config.EnvConfig{}, sessionManager, time.Now(), req, "")
+
+		// 客户端断开不影响会话记录；后台响应使用创建时分配的 ID
+		stats := sessionManager.GetStats()
+		if stats["total_responses"] != 1 {
+			t.Errorf("background=%v: 会话统计 = %v", background, stats)
+		}
+		if _, ok := sessionManager.GetResponse("resp_background"); ok != background {
+			t.Errorf("background=%v: 后台响应 ID 记录 = %v", background, ok)
+		}
+	}
+}
+
+func TestResponsesStickySessionID_FollowsPreviousResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfgManager := newTestConfigManager(t, config.Config{})
+	sessionManager := session.NewSessionManager(time.Hour, 100, 100000)
+
+	stickyID := func(body, previousResponseID string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/responses", strings.NewReader(body))
+		return responsesStickySessionID(c, cfgManager, sessionManager, []byte(body), previousResponseID)
+	}
+
+	// 首轮：按指令和首个输入项哈希
+	first := stickyID(`{"model":"m","instructions":"sys","input":"第一轮"}`, "")
+	if !strings.HasPrefix(first, "hash:") {
+		t.Fatalf("首轮会话标识 = %q", first)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/responses", nil)
+	recordResponsesResult(c, sessionManager, &types.ResponsesRequest{Input: "第一轮"}, &types.ResponsesResponse{ID: "resp_1", Status: "completed"}, first)
+
+	// 后续轮次只带新增输入，请求体哈希不同，但应沿用首轮的标识
+	second := stickyID(`{"model":"m","instructions":"sys","previous_response_id":"resp_1","input":"第二轮"}`, "resp_1")
+	if second != first {
+		t.Errorf("链式请求会话标识 = %q, 期望 %q", second, first)
+	}
+	recordResponsesResult(c, sessionManager, &types.ResponsesRequest{PreviousResponseID: "resp_1", Input: "第二轮"}, &types.ResponsesResponse{ID: "resp_2", Status: "completed"}, second)
+	if third := stickyID(`{"model":"m","previous_response_id":"resp_2","input":"第三轮"}`, "resp_2"); third != first {
+		t.Errorf("第三轮会话标识 = %q, 期望 %q", third, first)
+	}
+
+	// 未知的 previous_response_id 回退到请求体提取
+	if got := stickyID(`{"model":"m","user":"u1","previous_response_id":"resp_x","input":"x"}`, "resp_x"); got != "user:u1" {
+		t.Errorf("未知响应的会话标识 = %q", got)
+	}
+}
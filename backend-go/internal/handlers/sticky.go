@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/session"
+)
+
+// stickySessionID 提取用于会话粘性路由的会话标识
+// 优先级：客户端请求头 > metadata.user_id / prompt_cache_key / user > 系统提示词与首条消息的哈希
+func stickySessionID(c *gin.Context, cfgManager *config.ConfigManager, bodyBytes []byte) string {
+	cfg := cfgManager.GetStickySessionConfig()
+	if !cfg.IsEnabled() {
+		return ""
+	}
+
+	if id := c.GetHeader(cfg.Header); id != "" {
+		return "header:" + id
+	}
+
+	var req struct {
+		Metadata struct {
+			UserID string `json:"user_id"`
+		} `json:"metadata"`
+		PromptCacheKey string            `json:"prompt_cache_key"`
+		User           string            `json:"user"`
+		System         json.RawMessage   `json:"system"`
+		Instructions   json.RawMessage   `json:"instructions"`
+		Messages       []json.RawMessage `json:"messages"`
+		Input          json.RawMessage   `json:"input"`
+	}
+	if len(bodyBytes) == 0 || json.Unmarshal(bodyBytes, &req) != nil {
+		return ""
+	}
+
+	switch {
+	case req.Metadata.UserID != "":
+		return "user:" + req.Metadata.UserID
+	case req.PromptCacheKey != "":
+		return "cache:" + req.PromptCacheKey
+	case req.User != "":
+		return "user:" + req.User
+	}
+
+	// 同一会话的系统提示词和首条消息在后续轮次中保持不变
+	h := sha256.New()
+	h.Write(req.System)
+	h.Write(req.Instructions)
+	if len(req.Messages) > 0 {
+		h.Write(req.Messages[0])
+	} else if first := firstResponsesInput(req.Input); first != nil {
+		h.Write(first)
+	} else {
+		return ""
+	}
+	return "hash:" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// responsesStickySessionID 提取 Responses 请求的会话标识
+// 带 previous_response_id 的后续轮次只包含新增输入，请求体哈希每轮都不同，
+// 因此沿用该响应所属会话首轮记录的标识；会话不存在或未记录时按 stickySessionID 提取
+func responsesStickySessionID(c *gin.Context, cfgManager *config.ConfigManager, sessionManager *session.SessionManager, bodyBytes []byte, previousResponseID string) string {
+	if previousResponseID != "" && cfgManager.GetStickySessionConfig().IsEnabled() {
+		if id := sessionManager.StickyID(previousResponseID); id != "" {
+			return id
+		}
+	}
+	return stickySessionID(c, cfgManager, bodyBytes)
+}
+
+// firstResponsesInput 获取 Responses 请求的首个输入项（input 为字符串时返回整个字符串）
+func firstResponsesInput(input json.RawMessage) json.RawMessage {
+	if len(input) == 0 {
+		return nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(input, &items); err != nil {
+		return input
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return items[0]
+}
@@ -28,7 +28,7 @@ func WebAuthMiddleware(envCfg *config.EnvConfig, cfgManager *config.ConfigManage
 		}
 
 		// API 代理端点后续处理
-		if strings.HasPrefix(path, "/v1/") {
+		if strings.HasPrefix(path, "/v1/") || strings.HasPrefix(path, "/v1beta/") {
 			c.Next()
 			return
 		}
@@ -94,6 +94,11 @@ func getAPIKey(c *gin.Context) string {
 		return key
 	}
 
+	// Gemini 客户端使用 x-goog-api-key
+	if key := c.GetHeader("x-goog-api-key"); key != "" {
+		return key
+	}
+
 	if auth := c.GetHeader("Authorization"); auth != "" {
 		// 移除 Bearer 前缀
 		return strings.TrimPrefix(auth, "Bearer ")
@@ -31,15 +31,19 @@ func (p *ClaudeProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 		}
 		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes)) // 恢复body
 
-		var claudeReq types.ClaudeRequest
+		var claudeReq struct {
+			Model string `json:"model"`
+		}
 		if err := json.Unmarshal(bodyBytes, &claudeReq); err != nil {
 			return nil, bodyBytes, err
 		}
-		claudeReq.Model = config.RedirectModel(claudeReq.Model, upstream)
 
-		bodyBytes, err = json.Marshal(claudeReq)
-		if err != nil {
-			return nil, nil, err
+		// 只改写 model 字段，其余内容（含未知字段）原样透传
+		if model := config.RedirectModel(claudeReq.Model, upstream); model != claudeReq.Model {
+			bodyBytes, err = utils.ReplaceJSONField(bodyBytes, "model", model)
+			if err != nil {
+				return nil, nil, err
+			}
 		}
 	} else {
 		// 如果不需要模型重定向，则直接从原始请求中读取body用于日志和请求转发
@@ -50,11 +54,20 @@ func (p *ClaudeProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes)) // 恢复body
 	}
 
+	// 故障转移自其他渠道的 thinking 块签名 Claude 无法校验，需移除
+	bodyBytes = dropForeignThinking(bodyBytes)
+
 	// 构建目标URL
 	// 智能拼接逻辑：
 	// 1. 如果 baseURL 已包含版本号后缀（如 /v1, /v2, /v3），直接拼接端点路径
 	// 2. 如果 baseURL 不包含版本号后缀，自动添加 /v1 再拼接端点路径
-	endpoint := strings.TrimPrefix(c.Request.URL.Path, "/v1")
+	// 3. 来自其他协议入口（如 /v1/chat/completions）的请求已转换为 Claude 格式，统一发往 /messages
+	endpoint := "/messages"
+	rawQuery := ""
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/messages") {
+		endpoint = strings.TrimPrefix(c.Request.URL.Path, "/v1")
+		rawQuery = c.Request.URL.RawQuery
+	}
 	baseURL := strings.TrimSuffix(upstream.BaseURL, "/")
 
 	// 使用正则表达式检测 baseURL 是否以版本号结尾（/v1, /v2, /v1beta, /v2alpha等）
@@ -69,8 +82,8 @@ func (p *ClaudeProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 		targetURL = baseURL + "/v1" + endpoint
 	}
 
-	if c.Request.URL.RawQuery != "" {
-		targetURL += "?" + c.Request.URL.RawQuery
+	if rawQuery != "" {
+		targetURL += "?" + rawQuery
 	}
 
 	// 创建请求
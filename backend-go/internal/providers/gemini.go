@@ -3,10 +3,13 @@ package providers
 import (
 	"bufio"
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -63,6 +66,41 @@ func (p *GeminiProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 	return req, originalBodyBytes, nil
 }
 
+// ConvertToCountTokensRequest 构建 countTokens 请求（用于 /v1/messages/count_tokens）
+// 使用 generateContentRequest 形式，使系统指令和工具定义也计入 token 数
+func (p *GeminiProvider) ConvertToCountTokensRequest(c *gin.Context, upstream *config.UpstreamConfig, apiKey string) (*http.Request, error) {
+	originalBodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取请求体失败: %w", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(originalBodyBytes))
+
+	var claudeReq types.ClaudeRequest
+	if err := json.Unmarshal(originalBodyBytes, &claudeReq); err != nil {
+		return nil, fmt.Errorf("解析Claude请求体失败: %w", err)
+	}
+
+	model := config.RedirectModel(claudeReq.Model, upstream)
+	geminiReq := p.convertToGeminiRequest(&claudeReq, upstream)
+	geminiReq["model"] = "models/" + model
+
+	reqBodyBytes, err := json.Marshal(map[string]interface{}{"generateContentRequest": geminiReq})
+	if err != nil {
+		return nil, fmt.Errorf("序列化Gemini请求体失败: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:countTokens", strings.TrimSuffix(upstream.BaseURL, "/"), model)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("创建Gemini请求失败: %w", err)
+	}
+
+	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.SetGeminiAuthenticationHeader(req.Header, apiKey)
+
+	return req, nil
+}
+
 // convertToGeminiRequest 转换为 Gemini 请求体
 func (p *GeminiProvider) convertToGeminiRequest(claudeReq *types.ClaudeRequest, upstream *config.UpstreamConfig) map[string]interface{} {
 	req := map[string]interface{}{
@@ -88,8 +126,28 @@ func (p *GeminiProvider) convertToGeminiRequest(claudeReq *types.ClaudeRequest,
 		genConfig["maxOutputTokens"] = claudeReq.MaxTokens
 	}
 
-	if claudeReq.Temperature > 0 {
-		genConfig["temperature"] = claudeReq.Temperature
+	if claudeReq.Temperature != nil {
+		genConfig["temperature"] = *claudeReq.Temperature
+	}
+
+	if claudeReq.TopP != nil {
+		genConfig["topP"] = *claudeReq.TopP
+	}
+
+	if claudeReq.TopK != nil {
+		genConfig["topK"] = *claudeReq.TopK
+	}
+
+	if len(claudeReq.StopSequences) > 0 {
+		genConfig["stopSequences"] = claudeReq.StopSequences
+	}
+
+	// 扩展思考：返回思考摘要，便于转换为 Claude thinking 块
+	if budget := thinkingBudget(claudeReq.Thinking); budget > 0 {
+		genConfig["thinkingConfig"] = map[string]interface{}{
+			"thinkingBudget":  budget,
+			"includeThoughts": true,
+		}
 	}
 
 	if len(genConfig) > 0 {
@@ -103,17 +161,28 @@ func (p *GeminiProvider) convertToGeminiRequest(claudeReq *types.ClaudeRequest,
 				"functionDeclarations": p.convertTools(claudeReq.Tools),
 			},
 		}
+
+		if toolConfig := p.convertToolConfig(claudeReq.ToolChoice); toolConfig != nil {
+			req["toolConfig"] = toolConfig
+		}
 	}
 
 	return req
 }
 
+// geminiToolCall 历史消息中的工具调用，用于为 tool_result 找回函数名和调用顺序
+type geminiToolCall struct {
+	Name  string
+	Order int // 在所属 assistant 消息中的顺序（并行调用时 functionResponse 需按此顺序排列）
+}
+
 // convertMessages 转换消息
 func (p *GeminiProvider) convertMessages(claudeMessages []types.ClaudeMessage) []map[string]interface{} {
 	messages := []map[string]interface{}{}
+	toolCalls := collectToolCalls(claudeMessages)
 
 	for _, msg := range claudeMessages {
-		geminiMsg := p.convertMessage(msg)
+		geminiMsg := p.convertMessage(msg, toolCalls)
 		if geminiMsg != nil {
 			messages = append(messages, geminiMsg)
 		}
@@ -122,14 +191,42 @@ func (p *GeminiProvider) convertMessages(claudeMessages []types.ClaudeMessage) [
 	return messages
 }
 
+// collectToolCalls 按 tool_use ID 索引历史消息中的工具调用
+func collectToolCalls(claudeMessages []types.ClaudeMessage) map[string]geminiToolCall {
+	toolCalls := map[string]geminiToolCall{}
+	for _, msg := range claudeMessages {
+		contents, _ := msg.Content.([]interface{})
+		order := 0
+		for _, c := range contents {
+			content, ok := c.(map[string]interface{})
+			if !ok || content["type"] != "tool_use" {
+				continue
+			}
+			id, _ := content["id"].(string)
+			name, _ := content["name"].(string)
+			toolCalls[id] = geminiToolCall{Name: name, Order: order}
+			order++
+		}
+	}
+	return toolCalls
+}
+
 // convertMessage 转换单个消息
-func (p *GeminiProvider) convertMessage(msg types.ClaudeMessage) map[string]interface{} {
+func (p *GeminiProvider) convertMessage(msg types.ClaudeMessage, toolCalls map[string]geminiToolCall) map[string]interface{} {
 	role := msg.Role
 	if role == "assistant" {
 		role = "model"
 	}
 
 	parts := []interface{}{}
+	// 思考签名需回传到其后的函数调用上，否则多轮工具调用会被上游拒绝
+	pendingSignature := ""
+	// 工具结果（functionResponse 及其后的图片 / 文档）按调用顺序排列在消息开头
+	type toolResultParts struct {
+		order int
+		parts []interface{}
+	}
+	toolResults := []toolResultParts{}
 
 	// 处理字符串内容
 	if str, ok := msg.Content.(string); ok {
@@ -164,37 +261,85 @@ func (p *GeminiProvider) convertMessage(msg types.ClaudeMessage) map[string]inte
 				})
 			}
 
+		case "image", "document":
+			if part, ok := geminiMediaPart(content); ok {
+				parts = append(parts, part)
+			}
+
+		case "thinking":
+			// 思考摘要无需回传，只保留 Gemini 签发的签名（其他渠道的签名 Gemini 无法校验）
+			if signature, ok := geminiSignature(content["signature"]); ok {
+				pendingSignature = signature
+			}
+
 		case "tool_use":
+			id, _ := content["id"].(string)
 			name, _ := content["name"].(string)
 			input := content["input"]
 
-			parts = append(parts, map[string]interface{}{
-				"functionCall": map[string]interface{}{
-					"name": name,
-					"args": input,
-				},
-			})
+			functionCall := map[string]interface{}{
+				"name": name,
+				"args": input,
+			}
+			// 回传调用 ID，供上游将 functionResponse 与对应的 functionCall 配对
+			if id != "" {
+				functionCall["id"] = id
+			}
+			part := map[string]interface{}{
+				"functionCall": functionCall,
+			}
+			if pendingSignature != "" {
+				part["thoughtSignature"] = pendingSignature
+				pendingSignature = ""
+			}
+			parts = append(parts, part)
 
 		case "tool_result":
 			toolUseID, _ := content["tool_use_id"].(string)
-			resultContent := content["content"]
+			resultText, media := splitToolResultContent(content["content"])
 
-			var response interface{}
-			if str, ok := resultContent.(string); ok {
-				response = map[string]string{"result": str}
-			} else {
-				response = resultContent
+			// functionResponse.name 必须是函数名；找不到对应的 tool_use 时退回使用 ID
+			call, found := toolCalls[toolUseID]
+			if !found {
+				call = geminiToolCall{Name: toolUseID, Order: len(toolCalls)}
 			}
 
-			parts = append(parts, map[string]interface{}{
-				"functionResponse": map[string]interface{}{
-					"name":     toolUseID,
-					"response": response,
-				},
+			response := map[string]string{"result": resultText}
+			if isError, _ := content["is_error"].(bool); isError {
+				response = map[string]string{"error": resultText}
+			}
+
+			functionResponse := map[string]interface{}{
+				"name":     call.Name,
+				"response": response,
+			}
+			if toolUseID != "" {
+				functionResponse["id"] = toolUseID
+			}
+			result := toolResultParts{order: call.Order}
+			result.parts = append(result.parts, map[string]interface{}{
+				"functionResponse": functionResponse,
 			})
+
+			// 结果中的图片 / 文档紧随 functionResponse 发送
+			for _, block := range media {
+				if part, ok := geminiMediaPart(block); ok {
+					result.parts = append(result.parts, part)
+				}
+			}
+			toolResults = append(toolResults, result)
 		}
 	}
 
+	if len(toolResults) > 0 {
+		sort.SliceStable(toolResults, func(i, j int) bool { return toolResults[i].order < toolResults[j].order })
+		resultParts := []interface{}{}
+		for _, result := range toolResults {
+			resultParts = append(resultParts, result.parts...)
+		}
+		parts = append(resultParts, parts...)
+	}
+
 	if len(parts) == 0 {
 		return nil
 	}
@@ -205,6 +350,30 @@ func (p *GeminiProvider) convertMessage(msg types.ClaudeMessage) map[string]inte
 	}
 }
 
+// convertToolConfig 转换工具选择为 functionCallingConfig（Gemini 不支持禁用并行调用，忽略 disable_parallel_tool_use）
+func (p *GeminiProvider) convertToolConfig(choice *types.ClaudeToolChoice) map[string]interface{} {
+	if choice == nil {
+		return nil
+	}
+
+	callingConfig := map[string]interface{}{}
+	switch choice.Type {
+	case "any":
+		callingConfig["mode"] = "ANY"
+	case "tool":
+		callingConfig["mode"] = "ANY"
+		callingConfig["allowedFunctionNames"] = []string{choice.Name}
+	case "none":
+		callingConfig["mode"] = "NONE"
+	case "auto":
+		callingConfig["mode"] = "AUTO"
+	default:
+		return nil
+	}
+
+	return map[string]interface{}{"functionCallingConfig": callingConfig}
+}
+
 // convertTools 转换工具
 func (p *GeminiProvider) convertTools(claudeTools []types.ClaudeTool) []map[string]interface{} {
 	tools := []map[string]interface{}{}
@@ -236,6 +405,10 @@ func (p *GeminiProvider) ConvertToClaudeResponse(providerResp *types.ProviderRes
 
 	candidates, ok := geminiResp["candidates"].([]interface{})
 	if !ok || len(candidates) == 0 {
+		// 提示词被安全策略拦截时没有候选结果
+		if geminiBlockReason(geminiResp) != "" {
+			claudeResp.StopReason = "refusal"
+		}
 		return claudeResp, nil
 	}
 
@@ -244,15 +417,9 @@ func (p *GeminiProvider) ConvertToClaudeResponse(providerResp *types.ProviderRes
 		return claudeResp, nil
 	}
 
-	content, ok := candidate["content"].(map[string]interface{})
-	if !ok {
-		return claudeResp, nil
-	}
-
-	parts, ok := content["parts"].([]interface{})
-	if !ok {
-		return claudeResp, nil
-	}
+	// 被安全策略拦截的候选结果没有 content，仍需设置停止原因
+	content, _ := candidate["content"].(map[string]interface{})
+	parts, _ := content["parts"].([]interface{})
 
 	// 处理各个部分
 	for _, p := range parts {
@@ -261,6 +428,20 @@ func (p *GeminiProvider) ConvertToClaudeResponse(providerResp *types.ProviderRes
 			continue
 		}
 
+		// 思考摘要（连续的思考部分合并为一个 thinking 块）
+		if thought, _ := part["thought"].(bool); thought {
+			text, _ := part["text"].(string)
+			if last := len(claudeResp.Content) - 1; last >= 0 && claudeResp.Content[last].Type == "thinking" && claudeResp.Content[last].Signature == "" {
+				claudeResp.Content[last].Thinking += text
+			} else {
+				claudeResp.Content = append(claudeResp.Content, types.ClaudeContent{
+					Type:     "thinking",
+					Thinking: text,
+				})
+			}
+			continue
+		}
+
 		// 文本内容
 		if text, ok := part["text"].(string); ok {
 			claudeResp.Content = append(claudeResp.Content, types.ClaudeContent{
@@ -271,49 +452,48 @@ func (p *GeminiProvider) ConvertToClaudeResponse(providerResp *types.ProviderRes
 
 		// 函数调用
 		if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+			// 函数调用携带的思考签名放入其前的 thinking 块
+			if signature, ok := part["thoughtSignature"].(string); ok && signature != "" {
+				signature = geminiSignaturePrefix + signature
+				if last := len(claudeResp.Content) - 1; last >= 0 && claudeResp.Content[last].Type == "thinking" && claudeResp.Content[last].Signature == "" {
+					claudeResp.Content[last].Signature = signature
+				} else {
+					claudeResp.Content = append(claudeResp.Content, types.ClaudeContent{
+						Type:      "thinking",
+						Signature: signature,
+					})
+				}
+			}
+
 			name, _ := fc["name"].(string)
 			args := fc["args"]
 
 			claudeResp.Content = append(claudeResp.Content, types.ClaudeContent{
 				Type:  "tool_use",
-				ID:    fmt.Sprintf("toolu_%d", len(claudeResp.Content)),
+				ID:    geminiToolUseID(fc),
 				Name:  name,
 				Input: args,
 			})
 		}
 	}
 
-	// 设置停止原因
+	// 设置停止原因（上游未给出时按正常结束处理）
 	finishReason, _ := candidate["finishReason"].(string)
-	if strings.Contains(strings.ToLower(finishReason), "stop") {
-		// 检查是否有工具调用
-		hasToolCall := false
-		for _, c := range claudeResp.Content {
-			if c.Type == "tool_use" {
-				hasToolCall = true
-				break
-			}
-		}
-
-		if hasToolCall {
-			claudeResp.StopReason = "tool_use"
-		} else {
-			claudeResp.StopReason = "end_turn"
+	if finishReason == "" {
+		finishReason = "STOP"
+	}
+	hasToolUse := false
+	for _, c := range claudeResp.Content {
+		if c.Type == "tool_use" {
+			hasToolUse = true
+			break
 		}
-	} else if strings.Contains(strings.ToLower(finishReason), "length") {
-		claudeResp.StopReason = "max_tokens"
 	}
+	claudeResp.StopReason, claudeResp.StopSequence = mapStopReason(geminiStopReasons, finishReason, hasToolUse, "")
 
 	// 使用统计
 	if usageMetadata, ok := geminiResp["usageMetadata"].(map[string]interface{}); ok {
-		usage := &types.Usage{}
-		if promptTokens, ok := usageMetadata["promptTokenCount"].(float64); ok {
-			usage.InputTokens = int(promptTokens)
-		}
-		if candidatesTokens, ok := usageMetadata["candidatesTokenCount"].(float64); ok {
-			usage.OutputTokens = int(candidatesTokens)
-		}
-		claudeResp.Usage = usage
+		claudeResp.Usage = geminiUsage(usageMetadata)
 	}
 
 	return claudeResp, nil
@@ -330,11 +510,12 @@ func (p *GeminiProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string
 		defer body.Close()
 
 		scanner := bufio.NewScanner(body)
-		toolUseBlockIndex := 0
 
-		// 文本块状态跟踪
-		textBlockStarted := false
-		textBlockIndex := 0
+		// 内容块索引跟踪（思考、文本、工具调用共用索引）
+		blocks := &contentBlockWriter{events: eventChan}
+		var usage *types.Usage
+		stopReasonEmitted := false
+		hasToolUse := false
 
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -355,122 +536,89 @@ func (p *GeminiProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string
 				continue
 			}
 
-			candidates, ok := chunk["candidates"].([]interface{})
-			if !ok || len(candidates) == 0 {
-				continue
+			// 用量为累计值，保留最后一次出现的
+			if usageMetadata, ok := chunk["usageMetadata"].(map[string]interface{}); ok {
+				usage = geminiUsage(usageMetadata)
 			}
 
-			candidate, ok := candidates[0].(map[string]interface{})
-			if !ok {
-				continue
+			// 提示词被安全策略拦截
+			if geminiBlockReason(chunk) != "" && !stopReasonEmitted {
+				eventChan <- stopReasonEvent("refusal", nil)
+				stopReasonEmitted = true
 			}
 
-			content, ok := candidate["content"].(map[string]interface{})
-			if !ok {
+			candidates, ok := chunk["candidates"].([]interface{})
+			if !ok || len(candidates) == 0 {
 				continue
 			}
 
-			parts, ok := content["parts"].([]interface{})
+			candidate, ok := candidates[0].(map[string]interface{})
 			if !ok {
 				continue
 			}
 
+			content, _ := candidate["content"].(map[string]interface{})
+			parts, _ := content["parts"].([]interface{})
+
 			for _, p := range parts {
 				part, ok := p.(map[string]interface{})
 				if !ok {
 					continue
 				}
 
-				// 处理文本
-				if text, ok := part["text"].(string); ok {
-					// 如果是第一个文本块,发送 content_block_start
-					if !textBlockStarted {
-						startEvent := map[string]interface{}{
-							"type":  "content_block_start",
-							"index": textBlockIndex,
-							"content_block": map[string]string{
-								"type": "text",
-								"text": "",
-							},
-						}
-						startJSON, _ := json.Marshal(startEvent)
-						eventChan <- fmt.Sprintf("event: content_block_start\ndata: %s\n\n", startJSON)
-						textBlockStarted = true
+				// 处理思考摘要
+				if thought, _ := part["thought"].(bool); thought {
+					if text, ok := part["text"].(string); ok && text != "" {
+						blocks.thinkingDelta(text)
 					}
+					continue
+				}
 
-					// 发送 content_block_delta
-					deltaEvent := map[string]interface{}{
-						"type":  "content_block_delta",
-						"index": textBlockIndex,
-						"delta": map[string]string{
-							"type": "text_delta",
-							"text": text,
-						},
-					}
-					deltaJSON, _ := json.Marshal(deltaEvent)
-					eventChan <- fmt.Sprintf("event: content_block_delta\ndata: %s\n\n", deltaJSON)
+				// 处理文本
+				if text, ok := part["text"].(string); ok && text != "" {
+					blocks.textDelta(text)
 				}
 
 				// 处理函数调用
 				if fc, ok := part["functionCall"].(map[string]interface{}); ok {
-					// 如果有文本块正在进行,先关闭它
-					if textBlockStarted {
-						stopEvent := map[string]interface{}{
-							"type":  "content_block_stop",
-							"index": textBlockIndex,
-						}
-						stopJSON, _ := json.Marshal(stopEvent)
-						eventChan <- fmt.Sprintf("event: content_block_stop\ndata: %s\n\n", stopJSON)
-						textBlockStarted = false
-						textBlockIndex++
+					// 函数调用携带的思考签名作为 signature_delta 发送
+					if signature, ok := part["thoughtSignature"].(string); ok && signature != "" {
+						blocks.signatureDelta(geminiSignaturePrefix + signature)
 					}
 
 					name, _ := fc["name"].(string)
 					args := fc["args"]
-					id := fmt.Sprintf("toolu_%d", toolUseBlockIndex)
-
-					events := processToolUsePart(id, name, args, toolUseBlockIndex)
-					for _, event := range events {
-						eventChan <- event
-					}
-					toolUseBlockIndex++
+					blocks.toolUse(geminiToolUseID(fc), name, args)
+					hasToolUse = true
 				}
 			}
 
 			// 处理结束原因
 			if finishReason, ok := candidate["finishReason"].(string); ok {
 				// 如果有未关闭的文本块,先关闭它
-				if textBlockStarted {
-					stopEvent := map[string]interface{}{
-						"type":  "content_block_stop",
-						"index": textBlockIndex,
-					}
-					stopJSON, _ := json.Marshal(stopEvent)
-					eventChan <- fmt.Sprintf("event: content_block_stop\ndata: %s\n\n", stopJSON)
-					textBlockStarted = false
-				}
+				blocks.close()
 
-				if strings.Contains(strings.ToLower(finishReason), "stop") {
-					event := map[string]interface{}{
-						"type": "message_delta",
-						"delta": map[string]string{
-							"stop_reason": "end_turn",
-						},
-					}
-					eventJSON, _ := json.Marshal(event)
-					eventChan <- fmt.Sprintf("event: message_delta\ndata: %s\n\n", eventJSON)
+				if stopReason, seq := mapStopReason(geminiStopReasons, finishReason, hasToolUse, ""); stopReason != "" && !stopReasonEmitted {
+					eventChan <- stopReasonEvent(stopReason, seq)
+					stopReasonEmitted = true
 				}
 			}
 		}
 
 		// 确保流结束时关闭任何未关闭的文本块
-		if textBlockStarted {
-			stopEvent := map[string]interface{}{
-				"type":  "content_block_stop",
-				"index": textBlockIndex,
+		blocks.close()
+
+		if usage != nil {
+			event := map[string]interface{}{
+				"type":  "message_delta",
+				"delta": map[string]interface{}{},
+				"usage": map[string]interface{}{
+					"input_tokens":  usage.InputTokens,
+					"output_tokens": usage.OutputTokens,
+				},
 			}
-			stopJSON, _ := json.Marshal(stopEvent)
-			eventChan <- fmt.Sprintf("event: content_block_stop\ndata: %s\n\n", stopJSON)
+			eventJSON, _ := json.Marshal(event)
+			eventChan <- fmt.Sprintf("event: message_delta\ndata: %s\n\n", eventJSON)
 		}
 
 		if err := scanner.Err(); err != nil {
@@ -480,3 +628,40 @@ func (p *GeminiProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string
 
 	return eventChan, errChan, nil
 }
+
+// geminiToolUseID 返回函数调用的 tool_use ID：优先使用上游提供的 id，否则生成全局唯一的 ID
+func geminiToolUseID(fc map[string]interface{}) string {
+	if id, ok := fc["id"].(string); ok && id != "" {
+		return id
+	}
+	return generateToolUseID()
+}
+
+// generateToolUseID 生成全局唯一的 tool_use ID（上游未提供调用 ID 时使用）
+func generateToolUseID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "toolu_" + hex.EncodeToString(b)
+}
+
+// geminiBlockReason 返回提示词被拦截的原因（promptFeedback.blockReason），未拦截时返回空字符串
+func geminiBlockReason(resp map[string]interface{}) string {
+	feedback, _ := resp["promptFeedback"].(map[string]interface{})
+	reason, _ := feedback["blockReason"].(string)
+	return reason
+}
+
+// geminiUsage 转换 usageMetadata（Claude 的 output_tokens 包含思考消耗）
+func geminiUsage(usageMetadata map[string]interface{}) *types.Usage {
+	usage := &types.Usage{}
+	if promptTokens, ok := usageMetadata["promptTokenCount"].(float64); ok {
+		usage.InputTokens = int(promptTokens)
+	}
+	if candidatesTokens, ok := usageMetadata["candidatesTokenCount"].(float64); ok {
+		usage.OutputTokens = int(candidatesTokens)
+	}
+	if thoughtsTokens, ok := usageMetadata["thoughtsTokenCount"].(float64); ok {
+		usage.OutputTokens += int(thoughtsTokens)
+	}
+	return usage
+}
@@ -0,0 +1,169 @@
+package providers
+
+import (
+	"encoding/json"
+	"mime"
+	"path"
+	"strings"
+)
+
+// ============== 图片与文档内容块转换 ==============
+// Claude image / document 块 → OpenAI image_url / file 部分、Gemini inlineData / fileData 部分
+
+// mediaSource 解析后的 Claude 图片 / 文档来源
+type mediaSource struct {
+	Kind      string // base64, url, text
+	MediaType string
+	Data      string // base64 数据或纯文本内容
+	URL       string
+}
+
+// parseMediaSource 解析 image / document 块的 source，不支持的来源返回 false
+func parseMediaSource(block map[string]interface{}) (mediaSource, bool) {
+	source, ok := block["source"].(map[string]interface{})
+	if !ok {
+		return mediaSource{}, false
+	}
+
+	kind, _ := source["type"].(string)
+	mediaType, _ := source["media_type"].(string)
+	switch kind {
+	case "base64", "text":
+		data, _ := source["data"].(string)
+		if data == "" {
+			return mediaSource{}, false
+		}
+		if mediaType == "" && kind == "base64" && block["type"] == "document" {
+			mediaType = "application/pdf"
+		}
+		return mediaSource{Kind: kind, MediaType: mediaType, Data: data}, true
+	case "url":
+		url, _ := source["url"].(string)
+		if url == "" {
+			return mediaSource{}, false
+		}
+		if mediaType == "" {
+			mediaType = mediaTypeFromURL(url, block["type"] == "document")
+		}
+		return mediaSource{Kind: kind, MediaType: mediaType, URL: url}, true
+	}
+	return mediaSource{}, false
+}
+
+// mediaTypeFromURL 根据 URL 扩展名推断媒体类型
+func mediaTypeFromURL(url string, isDocument bool) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	if mediaType := mime.TypeByExtension(strings.ToLower(path.Ext(url))); mediaType != "" {
+		mediaType, _, _ = strings.Cut(mediaType, ";")
+		return mediaType
+	}
+	if isDocument {
+		return "application/pdf"
+	}
+	return "image/jpeg"
+}
+
+// isMediaBlock 判断内容块是否为图片或文档
+func isMediaBlock(contentType string) bool {
+	return contentType == "image" || contentType == "document"
+}
+
+// openAIMediaPart 将图片 / 文档块转换为 OpenAI content part
+// 文本文档转换为 text 部分；PDF 仅支持 base64（file 部分），URL 形式的文档上游不支持，返回 false
+func openAIMediaPart(block map[string]interface{}) (map[string]interface{}, bool) {
+	source, ok := parseMediaSource(block)
+	if !ok {
+		return nil, false
+	}
+
+	if source.Kind == "text" {
+		return map[string]interface{}{"type": "text", "text": source.Data}, true
+	}
+
+	if block["type"] == "image" {
+		url := source.URL
+		if source.Kind == "base64" {
+			url = "data:" + source.MediaType + ";base64," + source.Data
+		}
+		return map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": url},
+		}, true
+	}
+
+	if source.Kind != "base64" {
+		return nil, false
+	}
+	filename, _ := block["title"].(string)
+	if filename == "" {
+		filename = "document.pdf"
+	}
+	return map[string]interface{}{
+		"type": "file",
+		"file": map[string]interface{}{
+			"filename":  filename,
+			"file_data": "data:" + source.MediaType + ";base64," + source.Data,
+		},
+	}, true
+}
+
+// geminiMediaPart 将图片 / 文档块转换为 Gemini part
+func geminiMediaPart(block map[string]interface{}) (map[string]interface{}, bool) {
+	source, ok := parseMediaSource(block)
+	if !ok {
+		return nil, false
+	}
+
+	switch source.Kind {
+	case "text":
+		return map[string]interface{}{"text": source.Data}, true
+	case "url":
+		return map[string]interface{}{
+			"fileData": map[string]interface{}{
+				"mimeType": source.MediaType,
+				"fileUri":  source.URL,
+			},
+		}, true
+	default:
+		return map[string]interface{}{
+			"inlineData": map[string]interface{}{
+				"mimeType": source.MediaType,
+				"data":     source.Data,
+			},
+		}, true
+	}
+}
+
+// splitToolResultContent 拆分 tool_result 的内容：文本合并为字符串，图片 / 文档块单独返回
+func splitToolResultContent(content interface{}) (string, []map[string]interface{}) {
+	switch v := content.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		var texts []string
+		var media []map[string]interface{}
+		for _, item := range v {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			blockType, _ := block["type"].(string)
+			switch {
+			case blockType == "text":
+				if text, _ := block["text"].(string); text != "" {
+					texts = append(texts, text)
+				}
+			case isMediaBlock(blockType):
+				media = append(media, block)
+			}
+		}
+		return strings.Join(texts, "\n"), media
+	default:
+		data, _ := json.Marshal(v)
+		return string(data), nil
+	}
+}
@@ -0,0 +1,98 @@
+package providers
+
+import "testing"
+
+// mediaRequest 包含图片、文档和带截图的工具结果的 Claude 请求
+const mediaRequest = `{
+	"model": "claude-sonnet",
+	"max_tokens": 1024,
+	"messages": [
+		{"role": "user", "content": [
+			{"type": "text", "text": "看图"},
+			{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBOR"}},
+			{"type": "image", "source": {"type": "url", "url": "https://example.com/a.webp?x=1"}},
+			{"type": "document", "title": "report.pdf", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBER"}},
+			{"type": "document", "source": {"type": "text", "media_type": "text/plain", "data": "纯文本文档"}}
+		]},
+		{"role": "assistant", "content": [
+			{"type": "tool_use", "id": "call_1", "name": "screenshot", "input": {}}
+		]},
+		{"role": "user", "content": [
+			{"type": "tool_result", "tool_use_id": "call_1", "content": [
+				{"type": "text", "text": "截图如下"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/jpeg", "data": "/9j/4"}}
+			]}
+		]}
+	]
+}`
+
+func TestOpenAIProvider_MediaBlocks(t *testing.T) {
+	req := convertRequest(t, &OpenAIProvider{}, mediaRequest)
+	messages := req["messages"].([]interface{})
+	if len(messages) != 4 {
+		t.Fatalf("messages = %v", messages)
+	}
+
+	parts := messages[0].(map[string]interface{})["content"].([]interface{})
+	if len(parts) != 5 {
+		t.Fatalf("parts = %v", parts)
+	}
+	imageURL := func(i int) interface{} {
+		return parts[i].(map[string]interface{})["image_url"].(map[string]interface{})["url"]
+	}
+	if imageURL(1) != "data:image/png;base64,iVBOR" || imageURL(2) != "https://example.com/a.webp?x=1" {
+		t.Errorf("image_url 部分 = %v, %v", parts[1], parts[2])
+	}
+	file := parts[3].(map[string]interface{})["file"].(map[string]interface{})
+	if file["filename"] != "report.pdf" || file["file_data"] != "data:application/pdf;base64,JVBER" {
+		t.Errorf("file 部分 = %v", file)
+	}
+	if text := parts[4].(map[string]interface{}); text["type"] != "text" || text["text"] != "纯文本文档" {
+		t.Errorf("文本文档 = %v", text)
+	}
+
+	// 工具结果：tool 消息保留文本，图片随后以 user 消息发送
+	tool := messages[2].(map[string]interface{})
+	if tool["role"] != "tool" || tool["content"] != "截图如下" {
+		t.Errorf("tool 消息 = %v", tool)
+	}
+	user := messages[3].(map[string]interface{})
+	userParts := user["content"].([]interface{})
+	if user["role"] != "user" || len(userParts) != 1 || userParts[0].(map[string]interface{})["type"] != "image_url" {
+		t.Errorf("工具结果图片消息 = %v", user)
+	}
+}
+
+func TestGeminiProvider_MediaBlocks(t *testing.T) {
+	req := convertRequest(t, &GeminiProvider{}, mediaRequest)
+	contents := req["contents"].([]interface{})
+
+	parts := contents[0].(map[string]interface{})["parts"].([]interface{})
+	if len(parts) != 5 {
+		t.Fatalf("parts = %v", parts)
+	}
+	inline := parts[1].(map[string]interface{})["inlineData"].(map[string]interface{})
+	if inline["mimeType"] != "image/png" || inline["data"] != "iVBOR" {
+		t.Errorf("inlineData = %v", inline)
+	}
+	fileData := parts[2].(map[string]interface{})["fileData"].(map[string]interface{})
+	if fileData["mimeType"] != "image/webp" || fileData["fileUri"] != "https://example.com/a.webp?x=1" {
+		t.Errorf("fileData = %v", fileData)
+	}
+	if pdf := parts[3].(map[string]interface{})["inlineData"].(map[string]interface{}); pdf["mimeType"] != "application/pdf" {
+		t.Errorf("PDF = %v", pdf)
+	}
+
+	// 工具结果：图片紧随 functionResponse
+	resultParts := contents[2].(map[string]interface{})["parts"].([]interface{})
+	if len(resultParts) != 2 {
+		t.Fatalf("工具结果 parts = %v", resultParts)
+	}
+	response := resultParts[0].(map[string]interface{})["functionResponse"].(map[string]interface{})["response"].(map[string]interface{})
+	if response["result"] != "截图如下" {
+		t.Errorf("functionResponse = %v", response)
+	}
+	if resultParts[1].(map[string]interface{})["inlineData"] == nil {
+		t.Errorf("工具结果图片 = %v", resultParts[1])
+	}
+}
@@ -41,6 +41,22 @@ func (p *OpenAIProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 		Messages:    p.convertMessages(&claudeReq),
 		Stream:      claudeReq.Stream,
 		Temperature: claudeReq.Temperature,
+		TopP:        claudeReq.TopP,
+	}
+
+	// OpenAI 最多支持 4 个停止序列
+	if stop := claudeReq.StopSequences; len(stop) > 0 {
+		openaiReq.Stop = stop[:min(len(stop), 4)]
+	}
+
+	// top_k 没有对应字段；metadata.user_id 映射为 user
+	if claudeReq.Metadata != nil {
+		openaiReq.User = claudeReq.Metadata.UserID
+	}
+
+	// 流式请求要求上游在结束前返回用量，用于 message_delta.usage
+	if claudeReq.Stream {
+		openaiReq.StreamOptions = &types.OpenAIStreamOptions{IncludeUsage: true}
 	}
 
 	if claudeReq.MaxTokens > 0 {
@@ -52,7 +68,16 @@ func (p *OpenAIProvider) ConvertToProviderRequest(c *gin.Context, upstream *conf
 	// 转换工具
 	if len(claudeReq.Tools) > 0 {
 		openaiReq.Tools = p.convertTools(claudeReq.Tools)
-		openaiReq.ToolChoice = "auto"
+		openaiReq.ToolChoice = p.convertToolChoice(claudeReq.ToolChoice)
+		if claudeReq.ToolChoice != nil && claudeReq.ToolChoice.DisableParallelToolUse {
+			parallel := false
+			openaiReq.ParallelToolCalls = &parallel
+		}
+	}
+
+	// 扩展思考预算映射为推理强度（非推理模型会拒绝该参数，仅对推理模型或渠道显式开启时发送）
+	if upstream.ReasoningEffort || isReasoningModel(openaiReq.Model) {
+		openaiReq.ReasoningEffort = reasoningEffortForBudget(thinkingBudget(claudeReq.Thinking))
 	}
 	// --- 转换逻辑结束 ---
 
@@ -135,7 +160,10 @@ func (p *OpenAIProvider) convertMessage(msg types.ClaudeMessage) []types.OpenAIM
 		return messages
 	}
 
-	textContents := []string{}
+	// 文本与图片 / 文档按原顺序保存；没有图片和文档时合并为字符串，兼容只接受字符串内容的上游
+	contentParts := []map[string]interface{}{}
+	hasMedia := false
+	reasoningContents := []string{}
 	toolCalls := []types.OpenAIToolCall{}
 	toolResults := []types.OpenAIMessage{}
 
@@ -150,7 +178,18 @@ func (p *OpenAIProvider) convertMessage(msg types.ClaudeMessage) []types.OpenAIM
 		switch contentType {
 		case "text":
 			if text, ok := content["text"].(string); ok {
-				textContents = append(textContents, text)
+				contentParts = append(contentParts, map[string]interface{}{"type": "text", "text": text})
+			}
+
+		case "image", "document":
+			if part, ok := openAIMediaPart(content); ok {
+				contentParts = append(contentParts, part)
+				hasMedia = hasMedia || part["type"] != "text"
+			}
+
+		case "thinking":
+			if thinking, ok := content["thinking"].(string); ok && thinking != "" {
+				reasoningContents = append(reasoningContents, thinking)
 			}
 
 		case "tool_use":
@@ -170,21 +209,21 @@ func (p *OpenAIProvider) convertMessage(msg types.ClaudeMessage) []types.OpenAIM
 
 		case "tool_result":
 			toolUseID, _ := content["tool_use_id"].(string)
-			resultContent := content["content"]
-
-			var contentStr string
-			if str, ok := resultContent.(string); ok {
-				contentStr = str
-			} else {
-				contentJSON, _ := json.Marshal(resultContent)
-				contentStr = string(contentJSON)
-			}
+			contentStr, media := splitToolResultContent(content["content"])
 
 			toolResults = append(toolResults, types.OpenAIMessage{
 				Role:       "tool",
 				ToolCallID: toolUseID,
 				Content:    contentStr,
 			})
+
+			// tool 消息只支持文本，结果中的图片 / 文档随后以 user 消息发送
+			for _, block := range media {
+				if part, ok := openAIMediaPart(block); ok {
+					contentParts = append(contentParts, part)
+					hasMedia = hasMedia || part["type"] != "text"
+				}
+			}
 		}
 	}
 
@@ -192,21 +231,31 @@ func (p *OpenAIProvider) convertMessage(msg types.ClaudeMessage) []types.OpenAIM
 	messages = append(messages, toolResults...)
 
 	// 添加文本和工具调用
-	if len(textContents) > 0 || len(toolCalls) > 0 {
+	if len(contentParts) > 0 || len(toolCalls) > 0 {
 		role := normalizeRole(msg.Role)
 		if role != "tool" {
 			openaiMsg := types.OpenAIMessage{
 				Role: role,
 			}
 
-			if len(textContents) > 0 {
-				openaiMsg.Content = strings.Join(textContents, "\n")
+			if hasMedia {
+				openaiMsg.Content = contentParts
+			} else if len(contentParts) > 0 {
+				texts := make([]string, 0, len(contentParts))
+				for _, part := range contentParts {
+					texts = append(texts, part["text"].(string))
+				}
+				openaiMsg.Content = strings.Join(texts, "\n")
 			} else {
 				openaiMsg.Content = nil
 			}
 
 			if len(toolCalls) > 0 {
 				openaiMsg.ToolCalls = toolCalls
+				// 推理模型在工具调用回合需要回传推理内容，否则无法继续多轮工具调用
+				if role == "assistant" && len(reasoningContents) > 0 {
+					openaiMsg.ReasoningContent = strings.Join(reasoningContents, "\n")
+				}
 			}
 
 			messages = append(messages, openaiMsg)
@@ -234,6 +283,27 @@ func (p *OpenAIProvider) convertTools(claudeTools []types.ClaudeTool) []types.Op
 	return tools
 }
 
+// convertToolChoice 转换工具选择：any → required，tool → 指定函数，none → none，其余为 auto
+func (p *OpenAIProvider) convertToolChoice(choice *types.ClaudeToolChoice) interface{} {
+	if choice == nil {
+		return "auto"
+	}
+
+	switch choice.Type {
+	case "any":
+		return "required"
+	case "tool":
+		return map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": choice.Name},
+		}
+	case "none":
+		return "none"
+	default:
+		return "auto"
+	}
+}
+
 // cleanJsonSchema 清理 JSON Schema，移除某些上游不支持的字段
 func cleanJsonSchema(schema interface{}) interface{} {
 	if schema == nil {
@@ -305,6 +375,14 @@ func (p *OpenAIProvider) ConvertToClaudeResponse(providerResp *types.ProviderRes
 		choice := openaiResp.Choices[0]
 		msg := choice.Message
 
+		// 添加推理内容
+		if reasoning := reasoningText(msg.ReasoningContent, msg.Reasoning); reasoning != "" {
+			claudeResp.Content = append(claudeResp.Content, types.ClaudeContent{
+				Type:     "thinking",
+				Thinking: reasoning,
+			})
+		}
+
 		// 添加文本内容
 		if str, ok := msg.Content.(string); ok && str != "" {
 			claudeResp.Content = append(claudeResp.Content, types.ClaudeContent{
@@ -326,13 +404,10 @@ func (p *OpenAIProvider) ConvertToClaudeResponse(providerResp *types.ProviderRes
 			})
 		}
 
-		// 设置停止原因
-		if len(msg.ToolCalls) > 0 {
-			claudeResp.StopReason = "tool_use"
-		} else if choice.FinishReason == "length" {
-			claudeResp.StopReason = "max_tokens"
-		} else {
-			claudeResp.StopReason = "end_turn"
+		// 设置停止原因（上游未给出任何停止信息时按正常结束处理）
+		claudeResp.StopReason, claudeResp.StopSequence = openAIChoiceStopReason(choice.FinishReason, len(msg.ToolCalls) > 0, choice.StopReason, choice.MatchedStop, choice.StopSequence)
+		if claudeResp.StopReason == "" {
+			claudeResp.StopReason, claudeResp.StopSequence = mapStopReason(openAIStopReasons, "stop", len(msg.ToolCalls) > 0, "")
 		}
 	}
 
@@ -358,13 +433,13 @@ func (p *OpenAIProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string
 		defer body.Close()
 
 		scanner := bufio.NewScanner(body)
-		toolUseBlockIndex := 0
 		toolCallAccumulator := make(map[int]*ToolCallAccumulator)
 		toolUseStopEmitted := false
+		stopReasonEmitted := false
+		hasToolUse := false
 
-		// 文本块状态跟踪
-		textBlockStarted := false
-		textBlockIndex := 0
+		// 内容块索引跟踪（思考、文本、工具调用共用索引）
+		blocks := &contentBlockWriter{events: eventChan}
 
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -391,6 +466,20 @@ func (p *OpenAIProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string
 				return
 			}
 
+			// 用量数据块（stream_options.include_usage）转换为 message_delta.usage
+			if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+				event := map[string]interface{}{
+					"type":  "message_delta",
+					"delta": map[string]interface{}{},
+					"usage": map[string]interface{}{
+						"input_tokens":  usage["prompt_tokens"],
+						"output_tokens": usage["completion_tokens"],
+					},
+				}
+				eventJSON, _ := json.Marshal(event)
+				eventChan <- fmt.Sprintf("event: message_delta\ndata: %s\n\n", eventJSON)
+			}
+
 			choices, ok := chunk["choices"].([]interface{})
 			if !ok || len(choices) == 0 {
 				continue
@@ -406,49 +495,23 @@ func (p *OpenAIProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string
 				continue
 			}
 
+			// 处理推理内容
+			reasoningContent, _ := delta["reasoning_content"].(string)
+			reasoning, _ := delta["reasoning"].(string)
+			if text := reasoningText(reasoningContent, reasoning); text != "" {
+				blocks.thinkingDelta(text)
+			}
+
 			// 处理文本内容
 			if content, ok := delta["content"].(string); ok && content != "" {
-				// 如果是第一个文本块,发送 content_block_start
-				if !textBlockStarted {
-					startEvent := map[string]interface{}{
-						"type":  "content_block_start",
-						"index": textBlockIndex,
-						"content_block": map[string]string{
-							"type": "text",
-							"text": "",
-						},
-					}
-					startJSON, _ := json.Marshal(startEvent)
-					eventChan <- fmt.Sprintf("event: content_block_start\ndata: %s\n\n", startJSON)
-					textBlockStarted = true
-				}
-
-				// 发送 content_block_delta
-				deltaEvent := map[string]interface{}{
-					"type":  "content_block_delta",
-					"index": textBlockIndex,
-					"delta": map[string]string{
-						"type": "text_delta",
-						"text": content,
-					},
-				}
-				deltaJSON, _ := json.Marshal(deltaEvent)
-				eventChan <- fmt.Sprintf("event: content_block_delta\ndata: %s\n\n", deltaJSON)
+				blocks.textDelta(content)
 			}
 
 			// 处理工具调用
 			if toolCalls, ok := delta["tool_calls"].([]interface{}); ok {
 				// 如果有文本块正在进行,先关闭它
-				if textBlockStarted {
-					stopEvent := map[string]interface{}{
-						"type":  "content_block_stop",
-						"index": textBlockIndex,
-					}
-					stopJSON, _ := json.Marshal(stopEvent)
-					eventChan <- fmt.Sprintf("event: content_block_stop\ndata: %s\n\n", stopJSON)
-					textBlockStarted = false
-					textBlockIndex++
-				}
+				blocks.close()
+				hasToolUse = true
 
 				for _, tc := range toolCalls {
 					toolCall, ok := tc.(map[string]interface{})
@@ -485,53 +548,29 @@ func (p *OpenAIProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string
 					if acc.ID != "" && acc.Name != "" && acc.Arguments != "" {
 						var args interface{}
 						if err := json.Unmarshal([]byte(acc.Arguments), &args); err == nil {
-							events := processToolUsePart(acc.ID, acc.Name, args, toolUseBlockIndex)
-							for _, event := range events {
-								eventChan <- event
-							}
-							toolUseBlockIndex++
+							blocks.toolUse(acc.ID, acc.Name, args)
 							delete(toolCallAccumulator, index)
 						}
 					}
 				}
 			}
 
-			// 处理结束原因
-			if finishReason, ok := choice["finish_reason"].(string); ok {
+			// 处理结束原因（finish_reason 之外同样读取 stop_reason / matched_stop / stop_sequence）
+			finishReason, _ := choice["finish_reason"].(string)
+			if stopReason, seq := openAIChoiceStopReason(finishReason, hasToolUse, choice["stop_reason"], choice["matched_stop"], choice["stop_sequence"]); stopReason != "" {
 				// 如果有未关闭的文本块,先关闭它
-				if textBlockStarted {
-					stopEvent := map[string]interface{}{
-						"type":  "content_block_stop",
-						"index": textBlockIndex,
-					}
-					stopJSON, _ := json.Marshal(stopEvent)
-					eventChan <- fmt.Sprintf("event: content_block_stop\ndata: %s\n\n", stopJSON)
-					textBlockStarted = false
-				}
+				blocks.close()
 
-				if !toolUseStopEmitted && (finishReason == "tool_calls" || finishReason == "function_call") {
-					event := map[string]interface{}{
-						"type": "message_delta",
-						"delta": map[string]string{
-							"stop_reason": "tool_use",
-						},
-					}
-					eventJSON, _ := json.Marshal(event)
-					eventChan <- fmt.Sprintf("event: message_delta\ndata: %s\n\n", eventJSON)
-					toolUseStopEmitted = true
+				if !stopReasonEmitted {
+					eventChan <- stopReasonEvent(stopReason, seq)
+					stopReasonEmitted = true
+					toolUseStopEmitted = stopReason == "tool_use"
 				}
 			}
 		}
 
 		// 确保流结束时关闭任何未关闭的文本块
-		if textBlockStarted {
-			stopEvent := map[string]interface{}{
-				"type":  "content_block_stop",
-				"index": textBlockIndex,
-			}
-			stopJSON, _ := json.Marshal(stopEvent)
-			eventChan <- fmt.Sprintf("event: content_block_stop\ndata: %s\n\n", stopJSON)
-		}
+		blocks.close()
 
 		if err := scanner.Err(); err != nil {
 			// 在 tool_use 场景下，客户端主动断开是正常行为
@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+	"github.com/BenedictKing/claude-proxy/internal/utils"
+)
+
+// ChatCompletionsPassthroughProvider 将 /v1/chat/completions 请求原样透传给 openai 渠道（仅做模型重定向）
+type ChatCompletionsPassthroughProvider struct {
+	Body []byte // 客户端的原始 Chat Completions 请求体
+}
+
+// ConvertToProviderRequest 构建透传请求
+func (p *ChatCompletionsPassthroughProvider) ConvertToProviderRequest(c *gin.Context, upstream *config.UpstreamConfig, apiKey string) (*http.Request, []byte, error) {
+	var chatReq struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(p.Body, &chatReq); err != nil {
+		return nil, p.Body, fmt.Errorf("解析 Chat Completions 请求失败: %w", err)
+	}
+
+	// 只改写 model 字段，其余内容（数字精度、字段顺序、未知字段）原样透传
+	reqBodyBytes := p.Body
+	if model := config.RedirectModel(chatReq.Model, upstream); model != chatReq.Model {
+		var err error
+		reqBodyBytes, err = utils.ReplaceJSONField(p.Body, "model", model)
+		if err != nil {
+			return nil, p.Body, fmt.Errorf("改写 Chat Completions 请求模型失败: %w", err)
+		}
+	}
+
+	// baseURL 已包含版本号（/v1、/v4、/v1beta 等）时直接拼接，否则补充 /v1
+	baseURL := strings.TrimSuffix(upstream.BaseURL, "/")
+	url := baseURL + "/v1/chat/completions"
+	if regexp.MustCompile(`/v\d+[a-z]*$`).MatchString(baseURL) {
+		url = baseURL + "/chat/completions"
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(reqBodyBytes))
+	if err != nil {
+		return nil, p.Body, fmt.Errorf("创建 Chat Completions 请求失败: %w", err)
+	}
+
+	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.SetAuthenticationHeader(req.Header, apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, p.Body, nil
+}
+
+// ConvertToClaudeResponse 透传模式下不转换响应
+func (p *ChatCompletionsPassthroughProvider) ConvertToClaudeResponse(providerResp *types.ProviderResponse) (*types.ClaudeResponse, error) {
+	return nil, fmt.Errorf("透传模式不支持转换为 Claude 响应")
+}
+
+// HandleStreamResponse 逐行原样转发上游 SSE（与 Claude 透传相同）
+func (p *ChatCompletionsPassthroughProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string, <-chan error, error) {
+	return (&ClaudeProvider{}).HandleStreamResponse(body)
+}
+
+// GeminiPassthroughProvider 将 Gemini generateContent 请求原样透传给 gemini 渠道（仅做模型重定向）
+type GeminiPassthroughProvider struct {
+	Body   []byte // 客户端的原始 Gemini 请求体
+	Model  string // 请求路径中的模型名称
+	Stream bool
+}
+
+// ConvertToProviderRequest 构建透传请求
+func (p *GeminiPassthroughProvider) ConvertToProviderRequest(c *gin.Context, upstream *config.UpstreamConfig, apiKey string) (*http.Request, []byte, error) {
+	model := config.RedirectModel(p.Model, upstream)
+	action := "generateContent"
+	if p.Stream {
+		action = "streamGenerateContent?alt=sse"
+	}
+
+	url := fmt.Sprintf("%s/models/%s:%s", strings.TrimSuffix(upstream.BaseURL, "/"), model, action)
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(p.Body))
+	if err != nil {
+		return nil, p.Body, fmt.Errorf("创建 Gemini 请求失败: %w", err)
+	}
+
+	req.Header = utils.PrepareUpstreamHeaders(c, req.URL.Host)
+	utils.SetGeminiAuthenticationHeader(req.Header, apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, p.Body, nil
+}
+
+// ConvertToClaudeResponse 透传模式下不转换响应
+func (p *GeminiPassthroughProvider) ConvertToClaudeResponse(providerResp *types.ProviderResponse) (*types.ClaudeResponse, error) {
+	return nil, fmt.Errorf("透传模式不支持转换为 Claude 响应")
+}
+
+// HandleStreamResponse 逐行原样转发上游 SSE（与 Claude 透传相同）
+func (p *GeminiPassthroughProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string, <-chan error, error) {
+	return (&ClaudeProvider{}).HandleStreamResponse(body)
+}
@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+)
+
+// paramsRequest 带采样参数、停止序列和元数据的 Claude 请求
+const paramsRequest = `{"model":"claude-sonnet","max_tokens":1024,"temperature":0,"top_p":0.9,"top_k":40,` +
+	`"stop_sequences":["a","b","c","d","e"],"metadata":{"user_id":"u_1"},` +
+	`"system":[{"type":"text","text":"sys","cache_control":{"type":"ephemeral"}}],` +
+	`"messages":[{"role":"user","content":"hi"}],"service_tier":"auto"}`
+
+func TestClaudeProvider_ModelMappingIsLossless(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/messages", strings.NewReader(paramsRequest))
+
+	upstream := &config.UpstreamConfig{
+		BaseURL:      "https://api.anthropic.com",
+		ModelMapping: map[string]string{"claude-sonnet": "claude-sonnet-4-5"},
+	}
+	req, _, err := (&ClaudeProvider{}).ConvertToProviderRequest(c, upstream, "key")
+	if err != nil {
+		t.Fatalf("转换请求失败: %v", err)
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	expected := strings.Replace(paramsRequest, `"claude-sonnet"`, `"claude-sonnet-4-5"`, 1)
+	if string(body) != expected {
+		t.Errorf("请求体 = %s", body)
+	}
+}
+
+func TestChatCompletionsPassthrough_ModelMappingIsLossless(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+
+	body := `{"seed":9007199254740993,"model":"gpt","logit_bias":{"50256":-100},"messages":[{"role":"user","content":"hi"}]}`
+	upstream := &config.UpstreamConfig{
+		BaseURL:      "https://api.openai.com/v1",
+		ModelMapping: map[string]string{"gpt": "gpt-4o"},
+	}
+	req, _, err := (&ChatCompletionsPassthroughProvider{Body: []byte(body)}).ConvertToProviderRequest(c, upstream, "key")
+	if err != nil {
+		t.Fatalf("转换请求失败: %v", err)
+	}
+
+	reqBody, _ := io.ReadAll(req.Body)
+	if expected := strings.Replace(body, `"gpt"`, `"gpt-4o"`, 1); string(reqBody) != expected {
+		t.Errorf("请求体 = %s", reqBody)
+	}
+}
+
+func TestOpenAIProvider_RequestParams(t *testing.T) {
+	req := convertRequest(t, &OpenAIProvider{}, paramsRequest)
+
+	if req["temperature"] != 0.0 || req["top_p"] != 0.9 || req["user"] != "u_1" {
+		t.Errorf("请求参数 = %v", req)
+	}
+	if stop := req["stop"].([]interface{}); len(stop) != 4 {
+		t.Errorf("stop = %v", stop)
+	}
+	if _, ok := req["top_k"]; ok {
+		t.Error("OpenAI 不支持 top_k")
+	}
+}
+
+func TestGeminiProvider_RequestParams(t *testing.T) {
+	req := convertRequest(t, &GeminiProvider{}, paramsRequest)
+
+	genConfig := req["generationConfig"].(map[string]interface{})
+	if genConfig["temperature"] != 0.0 || genConfig["topP"] != 0.9 || genConfig["topK"] != 40.0 {
+		t.Errorf("generationConfig = %v", genConfig)
+	}
+	if stop := genConfig["stopSequences"].([]interface{}); len(stop) != 5 {
+		t.Errorf("stopSequences = %v", stop)
+	}
+}
+
+func TestToolChoiceConversion(t *testing.T) {
+	tests := []struct {
+		name           string
+		toolChoice     string
+		openAIChoice   string
+		openAIParallel string
+		geminiConfig   string
+	}{
+		{name: "未指定", toolChoice: "", openAIChoice: `"auto"`, openAIParallel: "<nil>", geminiConfig: "<nil>"},
+		{name: "auto", toolChoice: `{"type":"auto"}`, openAIChoice: `"auto"`, openAIParallel: "<nil>", geminiConfig: `{"functionCallingConfig":{"mode":"AUTO"}}`},
+		{name: "any", toolChoice: `{"type":"any","disable_parallel_tool_use":true}`, openAIChoice: `"required"`, openAIParallel: "false", geminiConfig: `{"functionCallingConfig":{"mode":"ANY"}}`},
+		{name: "tool", toolChoice: `{"type":"tool","name":"extract"}`, openAIChoice: `{"function":{"name":"extract"},"type":"function"}`, openAIParallel: "<nil>", geminiConfig: `{"functionCallingConfig":{"allowedFunctionNames":["extract"],"mode":"ANY"}}`},
+		{name: "none", toolChoice: `{"type":"none"}`, openAIChoice: `"none"`, openAIParallel: "<nil>", geminiConfig: `{"functionCallingConfig":{"mode":"NONE"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"model":"m","max_tokens":10,"messages":[{"role":"user","content":"hi"}],` +
+				`"tools":[{"name":"extract","input_schema":{"type":"object"}}]`
+			if tt.toolChoice != "" {
+				body += `,"tool_choice":` + tt.toolChoice
+			}
+			body += "}"
+
+			openaiReq := convertRequest(t, &OpenAIProvider{}, body)
+			if got := marshalValue(openaiReq["tool_choice"]); got != tt.openAIChoice {
+				t.Errorf("OpenAI tool_choice = %s, want %s", got, tt.openAIChoice)
+			}
+			if got := marshalValue(openaiReq["parallel_tool_calls"]); got != tt.openAIParallel {
+				t.Errorf("OpenAI parallel_tool_calls = %s, want %s", got, tt.openAIParallel)
+			}
+
+			geminiReq := convertRequest(t, &GeminiProvider{}, body)
+			if got := marshalValue(geminiReq["toolConfig"]); got != tt.geminiConfig {
+				t.Errorf("Gemini toolConfig = %s, want %s", got, tt.geminiConfig)
+			}
+		})
+	}
+}
+
+// marshalValue 将值序列化为 JSON 字符串，nil 返回 "<nil>"
+func marshalValue(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
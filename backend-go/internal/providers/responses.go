@@ -169,6 +169,11 @@ func (p *ResponsesProvider) ConvertToResponsesResponse(
 	return converter.FromProviderResponse(respMap, sessionID)
 }
 
+// NewStreamConverter 创建将上游流式响应转换为 Responses 事件的转换器
+func (p *ResponsesProvider) NewStreamConverter(upstreamType string, req *types.ResponsesRequest) converters.ResponsesStreamConverter {
+	return converters.NewConverter(upstreamType).NewStreamConverter(req.Model, req.PreviousResponseID)
+}
+
 // HandleStreamResponse 处理流式响应（暂不实现）
 func (p *ResponsesProvider) HandleStreamResponse(body io.ReadCloser) (<-chan string, <-chan error, error) {
 	return nil, nil, fmt.Errorf("Responses Provider 暂不支持流式响应")
@@ -0,0 +1,125 @@
+package providers
+
+import "strings"
+
+// ============== 停止原因映射 ==============
+// OpenAI finish_reason / Gemini finishReason → Claude stop_reason
+// Claude 取值：end_turn、max_tokens、stop_sequence、tool_use、pause_turn、refusal
+
+// openAIStopReasons OpenAI finish_reason 映射表
+var openAIStopReasons = map[string]string{
+	"stop":           "end_turn",
+	"length":         "max_tokens",
+	"tool_calls":     "tool_use",
+	"function_call":  "tool_use",
+	"content_filter": "refusal",
+}
+
+// geminiStopReasons Gemini finishReason 映射表，安全类拦截均视为 refusal
+var geminiStopReasons = map[string]string{
+	"STOP":                      "end_turn",
+	"MAX_TOKENS":                "max_tokens",
+	"SAFETY":                    "refusal",
+	"RECITATION":                "refusal",
+	"LANGUAGE":                  "refusal",
+	"BLOCKLIST":                 "refusal",
+	"PROHIBITED_CONTENT":        "refusal",
+	"SPII":                      "refusal",
+	"IMAGE_SAFETY":              "refusal",
+	"MALFORMED_FUNCTION_CALL":   "end_turn",
+	"UNEXPECTED_TOOL_CALL":      "end_turn",
+	"FINISH_REASON_UNSPECIFIED": "end_turn",
+	"OTHER":                     "end_turn",
+}
+
+// claudeStopReasons 兼容网关直接返回的 Claude 停止原因，原样保留
+var claudeStopReasons = map[string]bool{
+	"end_turn":      true,
+	"max_tokens":    true,
+	"stop_sequence": true,
+	"tool_use":      true,
+	"pause_turn":    true,
+	"refusal":       true,
+}
+
+// mapStopReason 按映射表转换停止原因
+// hasToolUse 为响应中是否包含工具调用（正常结束时改为 tool_use）；stopSequence 为上游报告的命中的停止序列
+// 上游未给出结束原因时返回空字符串，由调用方决定是否补全
+//
+// 上游未报告命中的停止序列时（官方 OpenAI、Gemini），即使请求带有 stop_sequences 也只能返回 end_turn：
+// 停止序列不会出现在输出中，"stop"/"STOP" 无法区分自然结束和命中停止序列，也无法判断命中的是哪一个，
+// 因此不根据请求推测，避免客户端收到错误的 stop_sequence
+func mapStopReason(table map[string]string, finishReason string, hasToolUse bool, stopSequence string) (string, *string) {
+	if finishReason == "" {
+		return "", nil
+	}
+
+	reason, ok := table[finishReason]
+	if !ok {
+		reason, ok = table[strings.ToUpper(finishReason)]
+	}
+	if !ok {
+		reason = "end_turn"
+		if claudeStopReasons[finishReason] {
+			reason = finishReason
+		}
+	}
+
+	switch {
+	case reason == "end_turn" && hasToolUse:
+		reason = "tool_use"
+	case reason == "end_turn" && stopSequence != "":
+		reason = "stop_sequence"
+	}
+	if reason == "stop_sequence" && stopSequence != "" {
+		return reason, &stopSequence
+	}
+	return reason, nil
+}
+
+// openAIChoiceStopReason 按 OpenAI 兼容响应的 choice 转换停止原因（流式和非流式共用）
+// 除 finish_reason 外，choice 上的以下字段存在时优先使用，上游均未给出时才按 finish_reason 映射：
+//   - stop_reason：vLLM 为命中的停止序列（整数为停止 token，忽略）；部分兼容网关直接给出 Claude 停止原因
+//   - matched_stop：SGLang 命中的停止序列
+//   - stop_sequence：兼容网关给出的命中的停止序列
+//
+// 上游只给出停止序列而未给出 finish_reason 时按正常结束处理；全部缺失时返回空字符串
+func openAIChoiceStopReason(finishReason string, hasToolUse bool, stopReason, matchedStop, stopSequence interface{}) (string, *string) {
+	sequence := ""
+	if reason, ok := stopReason.(string); ok && claudeStopReasons[reason] {
+		// Claude 停止原因比 OpenAI 的 "stop" 更具体
+		if finishReason == "" || finishReason == "stop" {
+			finishReason = reason
+		}
+	} else {
+		sequence = matchedStopSequence(stopReason)
+	}
+	if sequence == "" {
+		sequence = matchedStopSequence(matchedStop, stopSequence)
+	}
+	if finishReason == "" && sequence != "" {
+		finishReason = "stop"
+	}
+	return mapStopReason(openAIStopReasons, finishReason, hasToolUse, sequence)
+}
+
+// matchedStopSequence 返回第一个非空的字符串停止序列（停止 token 等非字符串取值忽略）
+func matchedStopSequence(values ...interface{}) string {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// stopReasonEvent 生成携带停止原因的 message_delta 事件
+func stopReasonEvent(reason string, stopSequence *string) string {
+	return formatSSE("message_delta", map[string]interface{}{
+		"type": "message_delta",
+		"delta": map[string]interface{}{
+			"stop_reason":   reason,
+			"stop_sequence": stopSequence,
+		},
+	})
+}
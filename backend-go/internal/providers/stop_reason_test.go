@@ -0,0 +1,117 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+func TestOpenAIProvider_StopReasons(t *testing.T) {
+	tests := []struct {
+		name         string
+		choice       string
+		stopReason   string
+		stopSequence string // 为空表示 stop_sequence 为 null
+	}{
+		{name: "stop", choice: `"finish_reason":"stop"`, stopReason: "end_turn"},
+		{name: "length", choice: `"finish_reason":"length"`, stopReason: "max_tokens"},
+		{name: "tool_calls", choice: `"finish_reason":"tool_calls"`, stopReason: "tool_use"},
+		{name: "content_filter", choice: `"finish_reason":"content_filter"`, stopReason: "refusal"},
+		{name: "vLLM 停止序列", choice: `"finish_reason":"stop","stop_reason":"END"`, stopReason: "stop_sequence", stopSequence: "END"},
+		{name: "SGLang 停止序列", choice: `"finish_reason":"stop","matched_stop":"END"`, stopReason: "stop_sequence", stopSequence: "END"},
+		{name: "停止 token", choice: `"finish_reason":"stop","stop_reason":128001`, stopReason: "end_turn"},
+		{name: "Claude 停止原因", choice: `"finish_reason":"pause_turn"`, stopReason: "pause_turn"},
+		{name: "兼容网关 stop_reason", choice: `"finish_reason":"stop","stop_reason":"end_turn"`, stopReason: "end_turn"},
+		{name: "兼容网关 stop_sequence", choice: `"finish_reason":"stop","stop_reason":"stop_sequence","stop_sequence":"END"`, stopReason: "stop_sequence", stopSequence: "END"},
+		{name: "兼容网关 max_tokens", choice: `"finish_reason":null,"stop_reason":"max_tokens"`, stopReason: "max_tokens"},
+		{name: "length 优先于 stop_reason", choice: `"finish_reason":"length","stop_reason":"end_turn"`, stopReason: "max_tokens"},
+		{name: "仅停止序列", choice: `"matched_stop":"END"`, stopReason: "stop_sequence", stopSequence: "END"},
+		{name: "未给出", choice: `"finish_reason":null`, stopReason: "end_turn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"choices":[{"message":{"role":"assistant","content":"hi"},` + tt.choice + `}]}`
+			resp, err := (&OpenAIProvider{}).ConvertToClaudeResponse(&types.ProviderResponse{Body: []byte(body)})
+			if err != nil {
+				t.Fatalf("转换响应失败: %v", err)
+			}
+			stopSequence := ""
+			if resp.StopSequence != nil {
+				stopSequence = *resp.StopSequence
+			}
+			if resp.StopReason != tt.stopReason || stopSequence != tt.stopSequence {
+				t.Errorf("stop_reason = %s, stop_sequence = %q", resp.StopReason, stopSequence)
+			}
+
+			if tt.choice == `"finish_reason":null` {
+				return
+			}
+			stream := `data: {"choices":[{"delta":{"content":"hi"},` + tt.choice + `}]}`
+			delta := lastMessageDelta(t, collectStream(t, &OpenAIProvider{}, stream))
+			if seq, _ := delta["stop_sequence"].(string); delta["stop_reason"] != tt.stopReason || seq != tt.stopSequence {
+				t.Errorf("流式 message_delta = %v", delta)
+			}
+		})
+	}
+}
+
+func TestGeminiProvider_StopReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidate  string
+		stopReason string
+	}{
+		{name: "STOP", candidate: `{"content":{"parts":[{"text":"hi"}]},"finishReason":"STOP"}`, stopReason: "end_turn"},
+		{name: "STOP 带工具调用", candidate: `{"content":{"parts":[{"functionCall":{"name":"f","args":{}}}]},"finishReason":"STOP"}`, stopReason: "tool_use"},
+		{name: "MAX_TOKENS", candidate: `{"content":{"parts":[{"text":"hi"}]},"finishReason":"MAX_TOKENS"}`, stopReason: "max_tokens"},
+		{name: "SAFETY", candidate: `{"finishReason":"SAFETY","safetyRatings":[]}`, stopReason: "refusal"},
+		{name: "RECITATION", candidate: `{"content":{"parts":[{"text":"hi"}]},"finishReason":"RECITATION"}`, stopReason: "refusal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"candidates":[` + tt.candidate + `]}`
+			resp, err := (&GeminiProvider{}).ConvertToClaudeResponse(&types.ProviderResponse{Body: []byte(body)})
+			if err != nil {
+				t.Fatalf("转换响应失败: %v", err)
+			}
+			if resp.StopReason != tt.stopReason || resp.StopSequence != nil {
+				t.Errorf("stop_reason = %s, stop_sequence = %s", resp.StopReason, marshalValue(resp.StopSequence))
+			}
+
+			delta := lastMessageDelta(t, collectStream(t, &GeminiProvider{}, "data: "+body))
+			if delta["stop_reason"] != tt.stopReason {
+				t.Errorf("流式 message_delta = %v", delta)
+			}
+		})
+	}
+
+	// 提示词被拦截时没有候选结果
+	blocked := `{"promptFeedback":{"blockReason":"SAFETY"}}`
+	resp, err := (&GeminiProvider{}).ConvertToClaudeResponse(&types.ProviderResponse{Body: []byte(blocked)})
+	if err != nil || resp.StopReason != "refusal" {
+		t.Errorf("promptFeedback 拦截: stop_reason = %v, err = %v", resp, err)
+	}
+	if delta := lastMessageDelta(t, collectStream(t, &GeminiProvider{}, "data: "+blocked)); delta["stop_reason"] != "refusal" {
+		t.Errorf("流式 promptFeedback 拦截 = %v", delta)
+	}
+}
+
+// lastMessageDelta 返回最后一个带 stop_reason 的 message_delta.delta
+func lastMessageDelta(t *testing.T, events []map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	var last map[string]interface{}
+	for _, event := range events {
+		if event["type"] != "message_delta" {
+			continue
+		}
+		if delta, _ := event["delta"].(map[string]interface{}); delta["stop_reason"] != nil {
+			last = delta
+		}
+	}
+	if last == nil {
+		t.Fatalf("缺少带 stop_reason 的 message_delta: %v", events)
+	}
+	return last
+}
@@ -0,0 +1,313 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ============== Claude 流式事件规范化 ==============
+// 位于各提供商的流式事件与客户端之间，保证输出完整的 Anthropic SSE 事件序列：
+// message_start → (content_block_start → content_block_delta* → content_block_stop)* → message_delta → message_stop
+// 缺失的事件会被补发，内容块索引按输出顺序重新编号；已符合规范的事件原样输出
+
+// StreamNormalizer Claude 流式事件规范化器
+type StreamNormalizer struct {
+	model string
+
+	// 正在组装的 SSE 事件（Claude 透传时上游按行输出）
+	lines   []string
+	hasData bool
+
+	started bool
+	stopped bool // 已输出 message_stop 或 error
+
+	// 内容块状态：同一时刻最多只有一个打开的块
+	blockOpen    bool
+	openUpstream int // 打开的块在上游事件中的索引
+	openIndex    int // 打开的块在输出中的索引
+	nextIndex    int
+	sawToolUse   bool
+	deltaEmitted bool
+	pendingDelta map[string]interface{} // 尚未输出的 message_delta.delta
+	pendingUsage map[string]interface{} // 尚未输出的 message_delta.usage
+	outputTokens interface{}            // message_start 中的 output_tokens，用于补全用量
+}
+
+// NewStreamNormalizer 创建规范化器，model 用于补发的 message_start
+func NewStreamNormalizer(model string) *StreamNormalizer {
+	return &StreamNormalizer{
+		model:        model,
+		pendingDelta: map[string]interface{}{},
+		pendingUsage: map[string]interface{}{},
+	}
+}
+
+// Process 处理提供商输出的一段数据（完整事件或单行），返回需要发送给客户端的事件
+func (n *StreamNormalizer) Process(chunk string) []string {
+	var out []string
+	for _, line := range strings.Split(strings.TrimSuffix(chunk, "\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case line == "":
+			out = append(out, n.flush()...)
+		case strings.HasPrefix(line, "event:"):
+			if n.hasData {
+				out = append(out, n.flush()...)
+			}
+			n.lines = append(n.lines, line)
+		case strings.HasPrefix(line, "data:"):
+			if n.hasData {
+				out = append(out, n.flush()...)
+			}
+			n.lines = append(n.lines, line)
+			n.hasData = true
+		}
+	}
+	return out
+}
+
+// Finish 上游流结束时调用，补发缺失的内容块结束、message_delta 和 message_stop
+func (n *StreamNormalizer) Finish() []string {
+	out := n.flush()
+	if n.stopped || !n.started {
+		return out
+	}
+	out = append(out, n.closeBlock()...)
+	out = append(out, n.emitPendingDelta()...)
+	n.stopped = true
+	return append(out, formatSSE("message_stop", map[string]interface{}{"type": "message_stop"}))
+}
+
+// flush 处理已组装的事件
+func (n *StreamNormalizer) flush() []string {
+	lines, hasData := n.lines, n.hasData
+	n.lines, n.hasData = nil, false
+	if !hasData {
+		return nil
+	}
+
+	raw := strings.Join(lines, "\n") + "\n\n"
+	var data string
+	for _, line := range lines {
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimSpace(value)
+		}
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		if n.stopped {
+			return nil
+		}
+		return []string{raw}
+	}
+	return n.handle(payload, raw)
+}
+
+// handle 按事件类型规范化
+func (n *StreamNormalizer) handle(payload map[string]interface{}, raw string) []string {
+	if n.stopped {
+		return nil
+	}
+
+	eventType, _ := payload["type"].(string)
+	switch eventType {
+	case "message_start":
+		if n.started {
+			return nil
+		}
+		n.started = true
+		if message, ok := payload["message"].(map[string]interface{}); ok {
+			if usage, ok := message["usage"].(map[string]interface{}); ok {
+				n.outputTokens = usage["output_tokens"]
+			}
+		}
+		return []string{raw}
+
+	case "content_block_start":
+		out := n.ensureStarted()
+		out = append(out, n.closeBlock()...)
+		block, _ := payload["content_block"].(map[string]interface{})
+		if block["type"] == "tool_use" {
+			n.sawToolUse = true
+		}
+		return append(out, n.openBlock(jsonIndex(payload), payload, raw))
+
+	case "content_block_delta":
+		out := n.ensureStarted()
+		upstream := jsonIndex(payload)
+		if !n.blockOpen || n.openUpstream != upstream {
+			// 上游缺少 content_block_start：文本和思考块可以补发，工具调用缺少 ID 和名称时丢弃
+			delta, _ := payload["delta"].(map[string]interface{})
+			block := deltaBlockType(delta["type"])
+			if block == nil {
+				return out
+			}
+			out = append(out, n.closeBlock()...)
+			start := map[string]interface{}{"type": "content_block_start", "index": upstream, "content_block": block}
+			out = append(out, n.openBlock(upstream, start, ""))
+		}
+		return append(out, n.reindex(payload, raw, n.openIndex))
+
+	case "content_block_stop":
+		if !n.blockOpen || n.openUpstream != jsonIndex(payload) {
+			return nil
+		}
+		n.blockOpen = false
+		return []string{n.reindex(payload, raw, n.openIndex)}
+
+	case "message_delta":
+		out := n.ensureStarted()
+		out = append(out, n.closeBlock()...)
+		if n.deltaEmitted {
+			return append(out, raw)
+		}
+
+		delta, _ := payload["delta"].(map[string]interface{})
+		usage, _ := payload["usage"].(map[string]interface{})
+		complete := len(n.pendingDelta) == 0 && len(n.pendingUsage) == 0 &&
+			delta["stop_reason"] != nil && usage["output_tokens"] != nil
+		for k, v := range delta {
+			n.pendingDelta[k] = v
+		}
+		for k, v := range usage {
+			n.pendingUsage[k] = v
+		}
+		if complete {
+			// 上游的 message_delta 已完整，原样输出
+			n.deltaEmitted = true
+			return append(out, raw)
+		}
+		if n.pendingDelta["stop_reason"] != nil && n.pendingUsage["output_tokens"] != nil {
+			out = append(out, n.emitPendingDelta()...)
+		}
+		return out
+
+	case "message_stop":
+		out := n.ensureStarted()
+		out = append(out, n.closeBlock()...)
+		out = append(out, n.emitPendingDelta()...)
+		n.stopped = true
+		return append(out, raw)
+
+	case "error":
+		n.stopped = true
+		return []string{raw}
+
+	default:
+		// ping 及未知事件原样输出
+		return []string{raw}
+	}
+}
+
+// ensureStarted 上游未发送 message_start 时补发（随后补发一个 ping，与 Anthropic 一致）
+func (n *StreamNormalizer) ensureStarted() []string {
+	if n.started {
+		return nil
+	}
+	n.started = true
+	return []string{
+		formatSSE("message_start", map[string]interface{}{
+			"type": "message_start",
+			"message": map[string]interface{}{
+				"id":            generateID(),
+				"type":          "message",
+				"role":          "assistant",
+				"model":         n.model,
+				"content":       []interface{}{},
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+				"usage":         map[string]interface{}{"input_tokens": 0, "output_tokens": 0},
+			},
+		}),
+		formatSSE("ping", map[string]interface{}{"type": "ping"}),
+	}
+}
+
+// openBlock 打开新内容块并分配输出索引，raw 为空时重新序列化 payload
+func (n *StreamNormalizer) openBlock(upstream int, payload map[string]interface{}, raw string) string {
+	n.blockOpen = true
+	n.openUpstream = upstream
+	n.openIndex = n.nextIndex
+	n.nextIndex++
+	return n.reindex(payload, raw, n.openIndex)
+}
+
+// closeBlock 补发当前打开块的 content_block_stop
+func (n *StreamNormalizer) closeBlock() []string {
+	if !n.blockOpen {
+		return nil
+	}
+	n.blockOpen = false
+	return []string{formatSSE("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": n.openIndex,
+	})}
+}
+
+// emitPendingDelta 输出合并后的 message_delta，缺失的 stop_reason 和用量按已知信息补全
+func (n *StreamNormalizer) emitPendingDelta() []string {
+	if n.deltaEmitted {
+		return nil
+	}
+	n.deltaEmitted = true
+
+	delta := n.pendingDelta
+	if delta["stop_reason"] == nil {
+		delta["stop_reason"] = "end_turn"
+		if n.sawToolUse {
+			delta["stop_reason"] = "tool_use"
+		}
+	}
+	if _, ok := delta["stop_sequence"]; !ok {
+		delta["stop_sequence"] = nil
+	}
+
+	usage := n.pendingUsage
+	if usage["output_tokens"] == nil {
+		usage["output_tokens"] = n.outputTokens
+		if usage["output_tokens"] == nil {
+			usage["output_tokens"] = 0
+		}
+	}
+
+	return []string{formatSSE("message_delta", map[string]interface{}{
+		"type":  "message_delta",
+		"delta": delta,
+		"usage": usage,
+	})}
+}
+
+// reindex 以输出索引重新生成事件；索引未变化且有原始事件时原样返回
+func (n *StreamNormalizer) reindex(payload map[string]interface{}, raw string, index int) string {
+	if raw != "" && jsonIndex(payload) == index {
+		return raw
+	}
+	payload["index"] = index
+	eventType, _ := payload["type"].(string)
+	return formatSSE(eventType, payload)
+}
+
+// deltaBlockType 根据增量类型推断需要补发的内容块，无法补发时返回 nil
+func deltaBlockType(deltaType interface{}) map[string]interface{} {
+	switch deltaType {
+	case "text_delta":
+		return map[string]interface{}{"type": "text", "text": ""}
+	case "thinking_delta", "signature_delta":
+		return map[string]interface{}{"type": "thinking", "thinking": "", "signature": ""}
+	}
+	return nil
+}
+
+// jsonIndex 读取事件中的 index 字段
+func jsonIndex(payload map[string]interface{}) int {
+	index, _ := payload["index"].(float64)
+	return int(index)
+}
+
+// formatSSE 格式化为 SSE 事件
+func formatSSE(eventType string, payload interface{}) string {
+	data, _ := json.Marshal(payload)
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, data)
+}
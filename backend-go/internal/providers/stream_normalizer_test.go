@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// normalizeStream 将事件依次交给规范化器，返回解析后的事件
+func normalizeStream(chunks ...string) []map[string]interface{} {
+	n := NewStreamNormalizer("gemini-2.5-pro")
+	var out []string
+	for _, chunk := range chunks {
+		out = append(out, n.Process(chunk)...)
+	}
+	out = append(out, n.Finish()...)
+
+	var events []map[string]interface{}
+	for _, event := range out {
+		for _, line := range strings.Split(event, "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var parsed map[string]interface{}
+				json.Unmarshal([]byte(data), &parsed)
+				events = append(events, parsed)
+			}
+		}
+	}
+	return events
+}
+
+// eventTypes 返回事件类型序列
+func eventTypes(events []map[string]interface{}) string {
+	var types []string
+	for _, event := range events {
+		types = append(types, event["type"].(string))
+	}
+	return strings.Join(types, ",")
+}
+
+func TestStreamNormalizer_SynthesizesMissingEvents(t *testing.T) {
+	// 只有内容块事件、没有 message_start / message_stop 的上游流
+	events := normalizeStream(
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"你好\"}}\n\n",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"input_tokens\":12,\"output_tokens\":3}}\n\n",
+	)
+
+	expected := "message_start,ping,content_block_start,content_block_delta,content_block_stop,message_delta,message_stop"
+	if got := eventTypes(events); got != expected {
+		t.Fatalf("事件序列 = %s", got)
+	}
+	if model := events[0]["message"].(map[string]interface{})["model"]; model != "gemini-2.5-pro" {
+		t.Errorf("message_start.model = %v", model)
+	}
+	messageDelta := events[5]
+	if got := marshalValue(messageDelta["delta"]); got != `{"stop_reason":"end_turn","stop_sequence":null}` {
+		t.Errorf("message_delta.delta = %s", got)
+	}
+	if got := marshalValue(messageDelta["usage"]); got != `{"input_tokens":12,"output_tokens":3}` {
+		t.Errorf("message_delta.usage = %s", got)
+	}
+}
+
+func TestStreamNormalizer_ReindexesBlocks(t *testing.T) {
+	// 索引不连续、缺少 content_block_stop、增量没有对应的 start
+	events := normalizeStream(
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":5,\"output_tokens\":1}}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"想\"}}\n\n",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":3,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"f\",\"input\":{}}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":3,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{}\"}}\n\n",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":3}\n\n",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":3}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":5,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{}\"}}\n\n",
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{}}\n\n",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+	)
+
+	expected := "start:thinking@0,thinking_delta@0,stop@0,start:tool_use@1,input_json_delta@1,stop@1"
+	if got := describeBlocks(events); got != expected {
+		t.Errorf("内容块序列 = %s", got)
+	}
+	if got := eventTypes(events); strings.Count(got, "message_start") != 1 || !strings.HasSuffix(got, "message_delta,message_stop") {
+		t.Errorf("事件序列 = %s", got)
+	}
+	messageDelta := events[len(events)-2]
+	if messageDelta["delta"].(map[string]interface{})["stop_reason"] != "tool_use" {
+		t.Errorf("stop_reason = %v", messageDelta["delta"])
+	}
+	if messageDelta["usage"].(map[string]interface{})["output_tokens"] != 1.0 {
+		t.Errorf("usage = %v", messageDelta["usage"])
+	}
+}
+
+func TestStreamNormalizer_PassesThroughCompleteStream(t *testing.T) {
+	// Claude 渠道按行输出的完整事件序列原样输出
+	upstream := strings.Join([]string{
+		"event: message_start",
+		`data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":5,"output_tokens":1}}}`,
+		"",
+		"event: content_block_start",
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"",
+		"event: content_block_stop",
+		`data: {"type":"content_block_stop","index":0}`,
+		"",
+		"event: message_delta",
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":2}}`,
+		"",
+		"event: message_stop",
+		`data: {"type":"message_stop"}`,
+		"",
+	}, "\n")
+
+	n := NewStreamNormalizer("claude-sonnet")
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(upstream, "\n") {
+		if line != "" {
+			out.WriteString(strings.Join(n.Process(line), ""))
+		}
+	}
+	out.WriteString(strings.Join(n.Finish(), ""))
+
+	if out.String() != upstream+"\n" {
+		t.Errorf("输出 = %q", out.String())
+	}
+}
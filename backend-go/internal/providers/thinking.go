@@ -0,0 +1,201 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// ============== 扩展思考（thinking）转换 ==============
+// 请求：Claude thinking.budget_tokens → OpenAI reasoning_effort（仅推理模型）/ Gemini thinkingConfig.thinkingBudget
+// 响应：OpenAI reasoning_content、Gemini thought 部分 → Claude thinking 块（流式为 thinking_delta）
+// 签名：Gemini 签名带 geminiSignaturePrefix 前缀，跨渠道故障转移时只回传给签发它的上游
+
+// thinkingBudget 返回启用扩展思考时的预算，未启用时返回 0
+func thinkingBudget(thinking *types.ClaudeThinking) int {
+	if thinking == nil || thinking.Type != "enabled" || thinking.BudgetTokens <= 0 {
+		return 0
+	}
+	return thinking.BudgetTokens
+}
+
+// reasoningEffortForBudget 将思考预算映射为 OpenAI reasoning_effort
+func reasoningEffortForBudget(budget int) string {
+	switch {
+	case budget <= 0:
+		return ""
+	case budget < 4096:
+		return "low"
+	case budget < 16384:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// isReasoningModel 判断 OpenAI 模型是否支持 reasoning_effort（o 系列和 gpt-5 系列，兼容 openai/o3 形式的模型名）
+func isReasoningModel(model string) bool {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if len(name) >= 2 && name[0] == 'o' && name[1] >= '0' && name[1] <= '9' {
+		return true
+	}
+	return strings.HasPrefix(name, "gpt-5")
+}
+
+// geminiSignaturePrefix Gemini 思考签名（thoughtSignature）转换为 Claude thinking 块签名时附加的前缀
+// 签名只能由签发它的上游校验：故障转移到其他渠道时，Gemini 只回传带此前缀的签名，Claude 渠道丢弃带此前缀的 thinking 块
+// 签名为 base64 编码，不含冒号，前缀不会与原生签名混淆
+const geminiSignaturePrefix = "gemini:"
+
+// geminiSignature 返回 Gemini 签发的原始思考签名，其他上游签发的签名返回 false
+func geminiSignature(value interface{}) (string, bool) {
+	signature, _ := value.(string)
+	signature, ok := strings.CutPrefix(signature, geminiSignaturePrefix)
+	return signature, ok && signature != ""
+}
+
+// dropForeignThinking 移除 Claude 请求中由其他上游生成的 thinking 块（Gemini 签名或没有签名），
+// Claude 无法校验这些签名，原样转发会返回 400；没有此类块时原样返回请求体
+func dropForeignThinking(body []byte) []byte {
+	if !bytes.Contains(body, []byte("thinking")) {
+		return body
+	}
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return body
+	}
+	messages, _ := req["messages"].([]interface{})
+
+	dropped := false
+	for _, m := range messages {
+		msg, _ := m.(map[string]interface{})
+		contents, ok := msg["content"].([]interface{})
+		if !ok {
+			continue
+		}
+		kept := contents[:0:0]
+		for _, c := range contents {
+			if block, _ := c.(map[string]interface{}); block["type"] == "thinking" {
+				if signature, _ := block["signature"].(string); signature == "" || strings.HasPrefix(signature, geminiSignaturePrefix) {
+					dropped = true
+					continue
+				}
+			}
+			kept = append(kept, c)
+		}
+		msg["content"] = kept
+	}
+	if !dropped {
+		return body
+	}
+
+	rewritten, err := json.Marshal(req)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// contentBlockWriter 为转换后的流式响应分配内容块索引
+// thinking、text、tool_use 块共用同一个递增索引，切换块类型时自动关闭上一个块
+type contentBlockWriter struct {
+	events   chan<- string
+	index    int
+	openType string // 当前打开的块类型（thinking 或 text），空表示没有打开的块
+}
+
+// thinkingDelta 发送思考内容增量
+func (w *contentBlockWriter) thinkingDelta(text string) {
+	w.ensureOpen("thinking")
+	w.send("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": w.index,
+		"delta": map[string]string{"type": "thinking_delta", "thinking": text},
+	})
+}
+
+// signatureDelta 为当前思考块补充签名并结束该块；没有打开的思考块时单独生成一个只含签名的思考块
+func (w *contentBlockWriter) signatureDelta(signature string) {
+	w.ensureOpen("thinking")
+	w.send("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": w.index,
+		"delta": map[string]string{"type": "signature_delta", "signature": signature},
+	})
+	w.close()
+}
+
+// textDelta 发送文本增量
+func (w *contentBlockWriter) textDelta(text string) {
+	w.ensureOpen("text")
+	w.send("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": w.index,
+		"delta": map[string]string{"type": "text_delta", "text": text},
+	})
+}
+
+// toolUse 发送完整的工具调用块
+func (w *contentBlockWriter) toolUse(id, name string, input interface{}) {
+	w.close()
+	for _, event := range processToolUsePart(id, name, input, w.index) {
+		w.events <- event
+	}
+	w.index++
+}
+
+// close 关闭当前打开的块
+func (w *contentBlockWriter) close() {
+	if w.openType == "" {
+		return
+	}
+	w.send("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": w.index,
+	})
+	w.openType = ""
+	w.index++
+}
+
+// ensureOpen 确保当前打开的是指定类型的块
+func (w *contentBlockWriter) ensureOpen(blockType string) {
+	if w.openType == blockType {
+		return
+	}
+	w.close()
+
+	block := map[string]string{"type": blockType}
+	if blockType == "thinking" {
+		block["thinking"] = ""
+		block["signature"] = ""
+	} else {
+		block["text"] = ""
+	}
+	w.send("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         w.index,
+		"content_block": block,
+	})
+	w.openType = blockType
+}
+
+func (w *contentBlockWriter) send(eventType string, payload map[string]interface{}) {
+	data, _ := json.Marshal(payload)
+	w.events <- fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, data)
+}
+
+// reasoningText 返回第一个非空的推理内容（不同上游使用的字段名不同）
+func reasoningText(candidates ...string) string {
+	for _, text := range candidates {
+		if text != "" {
+			return text
+		}
+	}
+	return ""
+}
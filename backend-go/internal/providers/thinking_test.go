@@ -0,0 +1,281 @@
+package providers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/BenedictKing/claude-proxy/internal/config"
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+// thinkingRequest 带扩展思考和工具调用历史的 Claude 请求
+const thinkingRequest = `{
+	"model": "claude-sonnet",
+	"max_tokens": 32000,
+	"thinking": {"type": "enabled", "budget_tokens": 10000},
+	"messages": [
+		{"role": "user", "content": "查天气"},
+		{"role": "assistant", "content": [
+			{"type": "thinking", "thinking": "需要调用工具", "signature": "sig_abc"},
+			{"type": "tool_use", "id": "call_1", "name": "get_weather", "input": {"city": "北京"}}
+		]},
+		{"role": "user", "content": [
+			{"type": "tool_result", "tool_use_id": "call_1", "content": "晴"}
+		]}
+	]
+}`
+
+// convertRequest 调用 provider 转换请求并解析上游请求体
+func convertRequest(t *testing.T, p Provider, body string) map[string]interface{} {
+	t.Helper()
+	return convertRequestFor(t, p, &config.UpstreamConfig{BaseURL: "https://upstream.example.com"}, body)
+}
+
+// convertRequestFor 使用指定渠道配置转换请求
+func convertRequestFor(t *testing.T, p Provider, upstream *config.UpstreamConfig, body string) map[string]interface{} {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+
+	req, _, err := p.ConvertToProviderRequest(c, upstream, "key")
+	if err != nil {
+		t.Fatalf("转换请求失败: %v", err)
+	}
+	reqBody, _ := io.ReadAll(req.Body)
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(reqBody, &parsed); err != nil {
+		t.Fatalf("解析上游请求体失败: %v", err)
+	}
+	return parsed
+}
+
+// collectStream 将 SSE 文本交给 provider 处理，返回解析后的事件
+func collectStream(t *testing.T, p Provider, upstream string) []map[string]interface{} {
+	t.Helper()
+	eventChan, _, err := p.HandleStreamResponse(io.NopCloser(strings.NewReader(upstream)))
+	if err != nil {
+		t.Fatalf("处理流失败: %v", err)
+	}
+	var events []map[string]interface{}
+	for event := range eventChan {
+		for _, line := range strings.Split(event, "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var parsed map[string]interface{}
+				json.Unmarshal([]byte(data), &parsed)
+				events = append(events, parsed)
+			}
+		}
+	}
+	return events
+}
+
+// describeBlocks 将内容块事件概括为 "类型@索引" 序列
+func describeBlocks(events []map[string]interface{}) string {
+	var parts []string
+	for _, event := range events {
+		index, _ := event["index"].(float64)
+		at := "@" + strconv.Itoa(int(index))
+		switch event["type"] {
+		case "content_block_start":
+			block := event["content_block"].(map[string]interface{})
+			parts = append(parts, "start:"+block["type"].(string)+at)
+		case "content_block_delta":
+			delta := event["delta"].(map[string]interface{})
+			parts = append(parts, delta["type"].(string)+at)
+		case "content_block_stop":
+			parts = append(parts, "stop"+at)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestOpenAIProvider_ThinkingRequest(t *testing.T) {
+	req := convertRequestFor(t, &OpenAIProvider{}, &config.UpstreamConfig{
+		BaseURL:      "https://upstream.example.com",
+		ModelMapping: map[string]string{"claude-sonnet": "o3-mini"},
+	}, thinkingRequest)
+
+	if req["reasoning_effort"] != "medium" {
+		t.Errorf("reasoning_effort = %v", req["reasoning_effort"])
+	}
+	assistant := req["messages"].([]interface{})[1].(map[string]interface{})
+	if assistant["reasoning_content"] != "需要调用工具" {
+		t.Errorf("工具调用回合应回传推理内容: %v", assistant)
+	}
+
+	for budget, effort := range map[int]string{0: "", 1024: "low", 8192: "medium", 32000: "high"} {
+		if got := reasoningEffortForBudget(budget); got != effort {
+			t.Errorf("reasoningEffortForBudget(%d) = %q, want %q", budget, got, effort)
+		}
+	}
+}
+
+func TestOpenAIProvider_ReasoningEffortModels(t *testing.T) {
+	tests := []struct {
+		model    string
+		optIn    bool
+		expected interface{}
+	}{
+		{model: "gpt-4o", expected: nil},
+		{model: "gpt-4o", optIn: true, expected: "medium"},
+		{model: "o1", expected: "medium"},
+		{model: "o4-mini", expected: "medium"},
+		{model: "openai/o3", expected: "medium"},
+		{model: "gpt-5-mini", expected: "medium"},
+		{model: "openrouter-model", expected: nil},
+	}
+
+	for _, tt := range tests {
+		upstream := &config.UpstreamConfig{
+			BaseURL:         "https://upstream.example.com",
+			ModelMapping:    map[string]string{"claude-sonnet": tt.model},
+			ReasoningEffort: tt.optIn,
+		}
+		req := convertRequestFor(t, &OpenAIProvider{}, upstream, thinkingRequest)
+		if req["reasoning_effort"] != tt.expected {
+			t.Errorf("%s (optIn=%v): reasoning_effort = %v", tt.model, tt.optIn, req["reasoning_effort"])
+		}
+	}
+}
+
+func TestOpenAIProvider_ReasoningResponse(t *testing.T) {
+	body := `{"choices":[{"message":{"role":"assistant","reasoning_content":"想一想","content":"答案"},"finish_reason":"stop"}]}`
+	resp, err := (&OpenAIProvider{}).ConvertToClaudeResponse(&types.ProviderResponse{Body: []byte(body)})
+	if err != nil {
+		t.Fatalf("转换响应失败: %v", err)
+	}
+	if len(resp.Content) != 2 || resp.Content[0].Type != "thinking" || resp.Content[0].Thinking != "想一想" || resp.Content[1].Text != "答案" {
+		t.Fatalf("content = %+v", resp.Content)
+	}
+
+	// thinking 块始终带 signature 字段
+	data, _ := json.Marshal(resp.Content[0])
+	if !strings.Contains(string(data), `"signature":""`) {
+		t.Errorf("thinking 块序列化 = %s", data)
+	}
+}
+
+func TestOpenAIProvider_ReasoningStream(t *testing.T) {
+	upstream := `data: {"choices":[{"delta":{"reasoning_content":"想"}}]}
+data: {"choices":[{"delta":{"reasoning_content":"一想"}}]}
+data: {"choices":[{"delta":{"content":"好的"}}]}
+data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"f","arguments":"{}"}}]}}]}
+data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}
+data: [DONE]`
+
+	events := collectStream(t, &OpenAIProvider{}, upstream)
+	expected := "start:thinking@0,thinking_delta@0,thinking_delta@0,stop@0," +
+		"start:text@1,text_delta@1,stop@1," +
+		"start:tool_use@2,input_json_delta@2,stop@2"
+	if got := describeBlocks(events); got != expected {
+		t.Errorf("事件序列 = %s", got)
+	}
+}
+
+func TestGeminiProvider_ThinkingRequest(t *testing.T) {
+	req := convertRequest(t, &GeminiProvider{}, strings.Replace(thinkingRequest, "sig_abc", "gemini:sig_abc", 1))
+
+	thinkingConfig := req["generationConfig"].(map[string]interface{})["thinkingConfig"].(map[string]interface{})
+	if thinkingConfig["thinkingBudget"] != 10000.0 || thinkingConfig["includeThoughts"] != true {
+		t.Errorf("thinkingConfig = %v", thinkingConfig)
+	}
+
+	// 思考摘要不回传，签名附加到函数调用上
+	model := req["contents"].([]interface{})[1].(map[string]interface{})
+	parts := model["parts"].([]interface{})
+	if len(parts) != 1 {
+		t.Fatalf("parts = %v", parts)
+	}
+	if part := parts[0].(map[string]interface{}); part["thoughtSignature"] != "sig_abc" || part["functionCall"] == nil {
+		t.Errorf("part = %v", part)
+	}
+
+	// 其他上游签发的签名（故障转移自 Claude 渠道）不回传给 Gemini
+	req = convertRequest(t, &GeminiProvider{}, thinkingRequest)
+	part := req["contents"].([]interface{})[1].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+	if _, ok := part["thoughtSignature"]; ok || part["functionCall"] == nil {
+		t.Errorf("Claude 签名不应回传给 Gemini: %v", part)
+	}
+}
+
+func TestClaudeProvider_DropsForeignThinking(t *testing.T) {
+	// 故障转移自 Gemini 渠道：Gemini 签名和没有签名的 thinking 块需移除，Claude 签名原样保留
+	body := `{"model":"claude-sonnet","messages":[
+		{"role":"user","content":"查天气"},
+		{"role":"assistant","content":[
+			{"type":"thinking","thinking":"","signature":"gemini:sig_xyz"},
+			{"type":"tool_use","id":"call_1","name":"get_weather","input":{}}
+		]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"call_1","content":"晴"}]},
+		{"role":"assistant","content":[
+			{"type":"thinking","thinking":"推理","signature":""},
+			{"type":"thinking","thinking":"原生","signature":"sig_claude"},
+			{"type":"text","text":"晴天"}
+		]}
+	]}`
+	req := convertRequest(t, &ClaudeProvider{}, body)
+	messages := req["messages"].([]interface{})
+	if got := marshalValue(messages[1].(map[string]interface{})["content"]); got != `[{"id":"call_1","input":{},"name":"get_weather","type":"tool_use"}]` {
+		t.Errorf("第一条 assistant 消息 = %s", got)
+	}
+	if got := marshalValue(messages[3].(map[string]interface{})["content"]); got != `[{"signature":"sig_claude","thinking":"原生","type":"thinking"},{"text":"晴天","type":"text"}]` {
+		t.Errorf("第二条 assistant 消息 = %s", got)
+	}
+
+	// 没有外来 thinking 块时请求体原样透传
+	if got := string(dropForeignThinking([]byte(thinkingRequest))); got != thinkingRequest {
+		t.Errorf("请求体被改写: %s", got)
+	}
+}
+
+func TestGeminiProvider_ThoughtResponse(t *testing.T) {
+	body := `{
+		"candidates": [{"content": {"parts": [
+			{"text": "先分析", "thought": true},
+			{"text": "再调用", "thought": true},
+			{"functionCall": {"name": "get_weather", "args": {"city": "北京"}}, "thoughtSignature": "sig_xyz"}
+		]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 5, "candidatesTokenCount": 7, "thoughtsTokenCount": 20}
+	}`
+	resp, err := (&GeminiProvider{}).ConvertToClaudeResponse(&types.ProviderResponse{Body: []byte(body)})
+	if err != nil {
+		t.Fatalf("转换响应失败: %v", err)
+	}
+	if len(resp.Content) != 2 {
+		t.Fatalf("content = %+v", resp.Content)
+	}
+	if thinking := resp.Content[0]; thinking.Type != "thinking" || thinking.Thinking != "先分析再调用" || thinking.Signature != "gemini:sig_xyz" {
+		t.Errorf("thinking 块 = %+v", thinking)
+	}
+	if resp.Content[1].Type != "tool_use" || resp.StopReason != "tool_use" {
+		t.Errorf("响应 = %+v", resp)
+	}
+	if resp.Usage.OutputTokens != 27 {
+		t.Errorf("output_tokens = %d", resp.Usage.OutputTokens)
+	}
+}
+
+func TestGeminiProvider_ThoughtStream(t *testing.T) {
+	upstream := `data: {"candidates":[{"content":{"parts":[{"text":"思考中","thought":true}]}}]}
+data: {"candidates":[{"content":{"parts":[{"functionCall":{"name":"f","args":{}},"thoughtSignature":"sig_1"}]}}]}
+data: {"candidates":[{"content":{"parts":[{"text":"完成"}]},"finishReason":"STOP"}]}`
+
+	events := collectStream(t, &GeminiProvider{}, upstream)
+	expected := "start:thinking@0,thinking_delta@0,signature_delta@0,stop@0," +
+		"start:tool_use@1,input_json_delta@1,stop@1," +
+		"start:text@2,text_delta@2,stop@2"
+	if got := describeBlocks(events); got != expected {
+		t.Errorf("事件序列 = %s", got)
+	}
+	for _, event := range events {
+		if delta, _ := event["delta"].(map[string]interface{}); delta["type"] == "signature_delta" && delta["signature"] != "gemini:sig_1" {
+			t.Errorf("signature_delta = %v", delta)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+func TestGeminiProvider_ParallelToolResults(t *testing.T) {
+	body := `{
+		"model": "gemini-2.5-pro",
+		"max_tokens": 1024,
+		"messages": [
+			{"role": "user", "content": "查两个城市的天气并换算温度"},
+			{"role": "assistant", "content": [
+				{"type": "tool_use", "id": "toolu_a", "name": "get_weather", "input": {"city": "北京"}},
+				{"type": "tool_use", "id": "toolu_b", "name": "get_weather", "input": {"city": "上海"}},
+				{"type": "tool_use", "id": "toolu_c", "name": "convert", "input": {}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_c", "content": "失败", "is_error": true},
+				{"type": "tool_result", "tool_use_id": "toolu_a", "content": "晴"},
+				{"type": "tool_result", "tool_use_id": "toolu_b", "content": "雨"},
+				{"type": "text", "text": "继续"}
+			]}
+		]
+	}`
+
+	req := convertRequest(t, &GeminiProvider{}, body)
+	parts := req["contents"].([]interface{})[2].(map[string]interface{})["parts"].([]interface{})
+	if len(parts) != 4 {
+		t.Fatalf("parts = %v", parts)
+	}
+
+	// functionResponse 使用函数名和对应 functionCall 的 id，并按调用顺序排列
+	expected := []string{`toolu_a get_weather {"result":"晴"}`, `toolu_b get_weather {"result":"雨"}`, `toolu_c convert {"error":"失败"}`}
+	for i, want := range expected {
+		fr := parts[i].(map[string]interface{})["functionResponse"].(map[string]interface{})
+		if got := fr["id"].(string) + " " + fr["name"].(string) + " " + marshalValue(fr["response"]); got != want {
+			t.Errorf("functionResponse[%d] = %s, want %s", i, got, want)
+		}
+	}
+	if parts[3].(map[string]interface{})["text"] != "继续" {
+		t.Errorf("文本部分 = %v", parts[3])
+	}
+
+	calls := req["contents"].([]interface{})[1].(map[string]interface{})["parts"].([]interface{})
+	if fc := calls[1].(map[string]interface{})["functionCall"].(map[string]interface{}); fc["id"] != "toolu_b" {
+		t.Errorf("functionCall = %v", fc)
+	}
+}
+
+func TestGeminiProvider_UniqueToolUseIDs(t *testing.T) {
+	body := `{"candidates":[{"content":{"parts":[
+		{"functionCall":{"name":"get_weather","args":{"city":"北京"}}},
+		{"functionCall":{"name":"get_weather","args":{"city":"上海"}}},
+		{"functionCall":{"id":"call_upstream","name":"convert","args":{}}}
+	]},"finishReason":"STOP"}]}`
+
+	seen := map[string]bool{}
+	for round := 0; round < 2; round++ {
+		resp, err := (&GeminiProvider{}).ConvertToClaudeResponse(&types.ProviderResponse{Body: []byte(body)})
+		if err != nil {
+			t.Fatalf("转换响应失败: %v", err)
+		}
+		if len(resp.Content) != 3 {
+			t.Fatalf("content = %+v", resp.Content)
+		}
+		for _, block := range resp.Content[:2] {
+			if !strings.HasPrefix(block.ID, "toolu_") || seen[block.ID] {
+				t.Errorf("tool_use ID 重复或格式错误: %s", block.ID)
+			}
+			seen[block.ID] = true
+		}
+		if resp.Content[2].ID != "call_upstream" {
+			t.Errorf("应使用上游提供的调用 ID: %s", resp.Content[2].ID)
+		}
+	}
+
+	// 流式响应同样生成唯一 ID
+	stream := `data: {"candidates":[{"content":{"parts":[{"functionCall":{"name":"f","args":{}}},{"functionCall":{"name":"f","args":{}}}]},"finishReason":"STOP"}]}`
+	var ids []string
+	for _, event := range collectStream(t, &GeminiProvider{}, stream) {
+		if block, ok := event["content_block"].(map[string]interface{}); ok {
+			ids = append(ids, block["id"].(string))
+		}
+	}
+	if len(ids) != 2 || ids[0] == ids[1] || seen[ids[0]] || seen[ids[1]] {
+		t.Errorf("流式 tool_use ID = %v", ids)
+	}
+}
@@ -1,8 +1,10 @@
 package session
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -19,12 +21,43 @@ type Session struct {
 	CreatedAt      time.Time
 	LastAccessAt   time.Time
 	TotalTokens    int
+	StickyID       string // 首轮请求的会话粘性路由标识，后续轮次沿用以固定渠道和密钥
+
+	turns []sessionTurn // 每轮对话写入的消息，按写入顺序排列
+}
+
+// sessionTurn 一轮对话（一个响应）写入会话的消息数和 token 数，用于删除响应时移除该轮消息
+type sessionTurn struct {
+	responseID string
+	messages   int
+	tokens     int
 }
 
+// StoredResponse 已存储的响应及其对应的输入条目
+// 记录不会被原地修改（更新时整体替换），调用方可安全持有 GetResponse 返回的指针
+type StoredResponse struct {
+	Response   types.ResponsesResponse
+	InputItems []types.ResponsesItem
+	SessionID  string // 后台响应完成前为空
+	CreatedAt  time.Time
+
+	cancel context.CancelFunc // 执行中的后台响应的取消函数
+}
+
+var (
+	// ErrResponseNotFound 响应不存在
+	ErrResponseNotFound = errors.New("response not found")
+	// ErrNotBackground 响应不是以 background 模式创建的
+	ErrNotBackground = errors.New("只有 background 模式创建的响应可以取消")
+	// ErrResponseFinished 后台响应已结束（已取消、失败或完成），不再接受新的结果
+	ErrResponseFinished = errors.New("后台响应已结束")
+)
+
 // SessionManager 会话管理器
 type SessionManager struct {
-	sessions        map[string]*Session // sessionID → Session
-	responseMapping map[string]string   // responseID → sessionID
+	sessions        map[string]*Session        // sessionID → Session
+	responseMapping map[string]string          // responseID → sessionID
+	responses       map[string]*StoredResponse // responseID → StoredResponse
 	mu              sync.RWMutex
 
 	// 清理配置
@@ -38,6 +71,7 @@ func NewSessionManager(maxAge time.Duration, maxMessages int, maxTokens int) *Se
 	sm := &SessionManager{
 		sessions:        make(map[string]*Session),
 		responseMapping: make(map[string]string),
+		responses:       make(map[string]*StoredResponse),
 		maxAge:          maxAge,
 		maxMessages:     maxMessages,
 		maxTokens:       maxTokens,
@@ -54,6 +88,11 @@ func (sm *SessionManager) GetOrCreateSession(previousResponseID string) (*Sessio
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	return sm.getOrCreateSessionLocked(previousResponseID)
+}
+
+// getOrCreateSessionLocked 获取或创建会话（调用方需持有写锁）
+func (sm *SessionManager) getOrCreateSessionLocked(previousResponseID string) (*Session, error) {
 	// 如果提供了 previousResponseID，尝试查找对应的会话
 	if previousResponseID != "" {
 		if sessionID, ok := sm.responseMapping[previousResponseID]; ok {
@@ -104,6 +143,7 @@ func (sm *SessionManager) AppendMessage(sessionID string, item types.ResponsesIt
 	session.Messages = append(session.Messages, item)
 	session.TotalTokens += tokensUsed
 	session.LastAccessAt = time.Now()
+	session.turns = append(session.turns, sessionTurn{messages: 1, tokens: tokensUsed}) // 不属于任何响应
 
 	return nil
 }
@@ -122,6 +162,244 @@ func (sm *SessionManager) UpdateLastResponseID(sessionID, responseID string) err
 	return nil
 }
 
+// RecordResponse 将本轮输入和响应追加到会话，记录映射并存储响应（整个过程持有写锁）
+// previousResponseID 为空时创建新会话；返回的响应的 previous_response_id 为会话的上一个响应
+// 已结束的后台响应（例如执行期间被取消）不会被覆盖，会话也不会被修改，此时返回 ErrResponseFinished
+func (sm *SessionManager) RecordResponse(previousResponseID string, resp types.ResponsesResponse, inputItems []types.ResponsesItem) (types.ResponsesResponse, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.isFinishedBackgroundLocked(resp.ID) {
+		return resp, ErrResponseFinished
+	}
+
+	session, err := sm.getOrCreateSessionLocked(previousResponseID)
+	if err != nil {
+		return resp, err
+	}
+	if session.LastResponseID != "" {
+		resp.PreviousID = session.LastResponseID
+	}
+
+	items := withItemIDs(inputItems)
+	session.Messages = append(session.Messages, items...)
+	session.Messages = append(session.Messages, resp.Output...)
+	session.TotalTokens += resp.Usage.TotalTokens
+	session.LastResponseID = resp.ID
+	session.LastAccessAt = time.Now()
+	session.turns = append(session.turns, sessionTurn{
+		responseID: resp.ID,
+		messages:   len(items) + len(resp.Output),
+		tokens:     resp.Usage.TotalTokens,
+	})
+
+	sm.responseMapping[resp.ID] = session.ID
+	sm.storeResponseLocked(session.ID, resp, items)
+	return resp, nil
+}
+
+// StoreResponse 存储响应及本轮输入条目（未带 ID 的输入条目会分配 ID）
+// 已结束的后台响应不会被覆盖，此时返回 false
+func (sm *SessionManager) StoreResponse(sessionID string, resp types.ResponsesResponse, inputItems []types.ResponsesItem) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.isFinishedBackgroundLocked(resp.ID) {
+		return false
+	}
+	sm.storeResponseLocked(sessionID, resp, withItemIDs(inputItems))
+	return true
+}
+
+// storeResponseLocked 存储响应（调用方需持有写锁）
+func (sm *SessionManager) storeResponseLocked(sessionID string, resp types.ResponsesResponse, items []types.ResponsesItem) {
+	// 后台响应完成：保留 background 标记
+	if existing, ok := sm.responses[resp.ID]; ok && existing.Response.Background {
+		resp.Background = true
+	}
+
+	sm.responses[resp.ID] = &StoredResponse{
+		Response:   resp,
+		InputItems: items,
+		SessionID:  sessionID,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// isFinishedBackgroundLocked 判断响应是否为已结束的后台响应（调用方需持有锁）
+func (sm *SessionManager) isFinishedBackgroundLocked(responseID string) bool {
+	existing, ok := sm.responses[responseID]
+	return ok && existing.Response.Background && !isPendingStatus(existing.Response.Status)
+}
+
+// withItemIDs 复制输入条目，并为未带 ID 的条目分配 ID
+func withItemIDs(inputItems []types.ResponsesItem) []types.ResponsesItem {
+	items := make([]types.ResponsesItem, len(inputItems))
+	for i, item := range inputItems {
+		if item.ID == "" {
+			item.ID = generateID("msg")
+		}
+		items[i] = item
+	}
+	return items
+}
+
+// StoreBackgroundResponse 存储后台响应的初始状态，cancel 用于取消执行中的上游请求
+func (sm *SessionManager) StoreBackgroundResponse(resp types.ResponsesResponse, cancel context.CancelFunc) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	resp.Background = true
+	sm.responses[resp.ID] = &StoredResponse{
+		Response:  resp,
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+	}
+}
+
+// UpdateBackgroundResponse 更新尚未结束的后台响应的状态（in_progress 或 failed）
+func (sm *SessionManager) UpdateBackgroundResponse(responseID, status string, respErr *types.ResponsesError) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	stored, exists := sm.responses[responseID]
+	if !exists || !isPendingStatus(stored.Response.Status) {
+		return
+	}
+
+	updated := *stored
+	updated.Response.Status = status
+	updated.Response.Error = respErr
+	if !isPendingStatus(status) {
+		updated.cancel = nil
+	}
+	sm.responses[responseID] = &updated
+}
+
+// CancelBackgroundResponse 取消后台响应：中断上游请求并标记为 cancelled（已结束的响应保持原状态）
+func (sm *SessionManager) CancelBackgroundResponse(responseID string) (types.ResponsesResponse, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	stored, exists := sm.responses[responseID]
+	if !exists {
+		return types.ResponsesResponse{}, ErrResponseNotFound
+	}
+	if !stored.Response.Background {
+		return types.ResponsesResponse{}, ErrNotBackground
+	}
+	if !isPendingStatus(stored.Response.Status) {
+		return stored.Response, nil
+	}
+
+	if stored.cancel != nil {
+		stored.cancel()
+	}
+	updated := *stored
+	updated.Response.Status = "cancelled"
+	updated.cancel = nil
+	sm.responses[responseID] = &updated
+	log.Printf("🛑 取消后台响应: %s", responseID)
+	return updated.Response, nil
+}
+
+// isPendingStatus 判断后台响应是否仍在排队或执行中
+func isPendingStatus(status string) bool {
+	return status == "queued" || status == "in_progress"
+}
+
+// GetResponse 获取已存储的响应
+func (sm *SessionManager) GetResponse(responseID string) (*StoredResponse, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	stored, exists := sm.responses[responseID]
+	return stored, exists
+}
+
+// DeleteResponse 删除已存储的响应，之后不能再作为 previous_response_id 使用
+// 该轮的输入和输出从会话历史中移除，后续轮次不再携带；会话中已没有任何响应引用时一并删除会话
+func (sm *SessionManager) DeleteResponse(responseID string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	stored, exists := sm.responses[responseID]
+	if !exists {
+		return false
+	}
+	if stored.cancel != nil {
+		stored.cancel()
+	}
+	delete(sm.responses, responseID)
+	delete(sm.responseMapping, responseID)
+	if session, ok := sm.sessions[stored.SessionID]; ok {
+		session.removeTurn(responseID)
+	}
+
+	for _, sessionID := range sm.responseMapping {
+		if sessionID == stored.SessionID {
+			log.Printf("🗑️ 删除响应: %s", responseID)
+			return true
+		}
+	}
+	delete(sm.sessions, stored.SessionID)
+	log.Printf("🗑️ 删除响应: %s (会话 %s 已无引用，一并删除)", responseID, stored.SessionID)
+	return true
+}
+
+// removeTurn 从会话历史中移除指定响应所在轮次的消息（调用方需持有写锁）
+// 消息切片整体替换而非原地修改，已取得旧切片的读取方不受影响
+func (session *Session) removeTurn(responseID string) {
+	offset := 0
+	for i, turn := range session.turns {
+		if turn.responseID != responseID {
+			offset += turn.messages
+			continue
+		}
+
+		end := min(offset+turn.messages, len(session.Messages))
+		messages := make([]types.ResponsesItem, 0, len(session.Messages)-(end-offset))
+		messages = append(messages, session.Messages[:offset]...)
+		session.Messages = append(messages, session.Messages[end:]...)
+		session.TotalTokens -= turn.tokens
+		session.turns = append(session.turns[:i:i], session.turns[i+1:]...)
+
+		// 删除的是最后一轮时，previous_id 回退到剩余的最后一个响应
+		if session.LastResponseID == responseID {
+			session.LastResponseID = ""
+			if len(session.turns) > 0 {
+				session.LastResponseID = session.turns[len(session.turns)-1].responseID
+			}
+		}
+		return
+	}
+}
+
+// BindStickyID 为响应所属的会话记录粘性路由标识（会话已有标识时保持不变）
+func (sm *SessionManager) BindStickyID(responseID, stickyID string) {
+	if stickyID == "" {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, ok := sm.sessions[sm.responseMapping[responseID]]; ok && session.StickyID == "" {
+		session.StickyID = stickyID
+	}
+}
+
+// StickyID 获取响应所属会话的粘性路由标识，未记录时返回空字符串
+func (sm *SessionManager) StickyID(responseID string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if session, ok := sm.sessions[sm.responseMapping[responseID]]; ok {
+		return session.StickyID
+	}
+	return ""
+}
+
 // GetSession 获取会话（只读）
 func (sm *SessionManager) GetSession(sessionID string) (*Session, error) {
 	sm.mu.RLock()
@@ -182,13 +460,28 @@ func (sm *SessionManager) cleanup() {
 		}
 	}
 
-	// 清理孤立的 responseID 映射
+	// 清理孤立的 responseID 映射和已存储的响应
 	for responseID, sessionID := range sm.responseMapping {
 		if _, exists := sm.sessions[sessionID]; !exists {
 			delete(sm.responseMapping, responseID)
 			removedMappings++
 		}
 	}
+	for responseID, stored := range sm.responses {
+		if stored.SessionID == "" {
+			// 未关联会话的后台响应（执行中、失败或已取消）按创建时间过期
+			if now.Sub(stored.CreatedAt) > sm.maxAge {
+				if stored.cancel != nil {
+					stored.cancel()
+				}
+				delete(sm.responses, responseID)
+			}
+			continue
+		}
+		if _, exists := sm.sessions[stored.SessionID]; !exists {
+			delete(sm.responses, responseID)
+		}
+	}
 
 	if removedSessions > 0 || removedMappings > 0 {
 		log.Printf("🧹 清理完成: 删除 %d 个会话, %d 个映射", removedSessions, removedMappings)
@@ -202,8 +495,9 @@ func (sm *SessionManager) GetStats() map[string]interface{} {
 	defer sm.mu.RUnlock()
 
 	return map[string]interface{}{
-		"total_sessions": len(sm.sessions),
-		"total_mappings": len(sm.responseMapping),
+		"total_sessions":  len(sm.sessions),
+		"total_mappings":  len(sm.responseMapping),
+		"total_responses": len(sm.responses),
 	}
 }
 
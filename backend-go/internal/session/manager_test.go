@@ -0,0 +1,200 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BenedictKing/claude-proxy/internal/types"
+)
+
+func TestSessionManager_StoreAndDeleteResponse(t *testing.T) {
+	sm := NewSessionManager(time.Hour, 100, 100000)
+
+	sess, err := sm.GetOrCreateSession("")
+	if err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+
+	inputs := []types.ResponsesItem{
+		{Type: "message", Role: "user", Content: "hi"},
+		{ID: "msg_given", Type: "message", Role: "user", Content: "again"},
+	}
+	for i, id := range []string{"resp_1", "resp_2"} {
+		sm.RecordResponseMapping(id, sess.ID)
+		sm.StoreResponse(sess.ID, types.ResponsesResponse{ID: id}, inputs[i:i+1])
+	}
+
+	stored, ok := sm.GetResponse("resp_1")
+	if !ok {
+		t.Fatal("未找到已存储的响应")
+	}
+	if stored.InputItems[0].ID == "" || stored.InputItems[0].Role != "user" {
+		t.Errorf("输入条目 = %+v", stored.InputItems[0])
+	}
+	if stored, _ := sm.GetResponse("resp_2"); stored.InputItems[0].ID != "msg_given" {
+		t.Errorf("已有 ID 不应被覆盖: %+v", stored.InputItems[0])
+	}
+
+	// 删除第一个响应：会话仍被 resp_2 引用
+	if !sm.DeleteResponse("resp_1") {
+		t.Fatal("删除失败")
+	}
+	if _, ok := sm.GetResponse("resp_1"); ok {
+		t.Error("删除后仍能获取响应")
+	}
+	if _, err := sm.GetOrCreateSession("resp_1"); err == nil {
+		t.Error("已删除的响应不应再作为 previous_response_id")
+	}
+	if _, err := sm.GetSession(sess.ID); err != nil {
+		t.Errorf("会话仍被引用，不应删除: %v", err)
+	}
+
+	// 删除最后一个响应：会话一并删除
+	sm.DeleteResponse("resp_2")
+	if _, err := sm.GetSession(sess.ID); err == nil {
+		t.Error("会话已无引用，应被删除")
+	}
+	if sm.DeleteResponse("resp_2") {
+		t.Error("重复删除应返回 false")
+	}
+}
+
+func TestSessionManager_BackgroundResponse(t *testing.T) {
+	sm := NewSessionManager(time.Hour, 100, 100000)
+
+	canceled := false
+	sm.StoreBackgroundResponse(types.ResponsesResponse{ID: "resp_bg", Status: "queued"}, func() { canceled = true })
+	sm.UpdateBackgroundResponse("resp_bg", "in_progress", nil)
+
+	if stored, _ := sm.GetResponse("resp_bg"); stored.Response.Status != "in_progress" || !stored.Response.Background {
+		t.Errorf("后台响应 = %+v", stored.Response)
+	}
+
+	resp, err := sm.CancelBackgroundResponse("resp_bg")
+	if err != nil || resp.Status != "cancelled" || !canceled {
+		t.Fatalf("取消结果 = %+v, %v, 已调用取消函数: %v", resp, err, canceled)
+	}
+
+	// 已结束的后台响应不再更新状态
+	sm.UpdateBackgroundResponse("resp_bg", "failed", &types.ResponsesError{Code: "server_error"})
+	if stored, _ := sm.GetResponse("resp_bg"); stored.Response.Status != "cancelled" {
+		t.Errorf("取消后状态被覆盖: %+v", stored.Response)
+	}
+
+	// 完成结果替换 queued 记录并保留 background 标记
+	sm.StoreBackgroundResponse(types.ResponsesResponse{ID: "resp_done", Status: "queued"}, func() {})
+	sess, _ := sm.GetOrCreateSession("")
+	sm.StoreResponse(sess.ID, types.ResponsesResponse{ID: "resp_done", Status: "completed"}, nil)
+	if stored, _ := sm.GetResponse("resp_done"); stored.Response.Status != "completed" || !stored.Response.Background {
+		t.Errorf("完成后 = %+v", stored.Response)
+	}
+	if resp, err := sm.CancelBackgroundResponse("resp_done"); err != nil || resp.Status != "completed" {
+		t.Errorf("取消已完成的响应 = %+v, %v", resp, err)
+	}
+
+	// 非后台响应不能取消
+	sm.StoreResponse(sess.ID, types.ResponsesResponse{ID: "resp_fg", Status: "completed"}, nil)
+	if _, err := sm.CancelBackgroundResponse("resp_fg"); err != ErrNotBackground {
+		t.Errorf("期望 ErrNotBackground，实际: %v", err)
+	}
+	if _, err := sm.CancelBackgroundResponse("resp_missing"); err != ErrResponseNotFound {
+		t.Errorf("期望 ErrResponseNotFound，实际: %v", err)
+	}
+}
+
+func TestSessionManager_RecordResponseAfterCancel(t *testing.T) {
+	sm := NewSessionManager(time.Hour, 100, 100000)
+
+	// 后台任务执行期间被取消，随后上游结果返回
+	sm.StoreBackgroundResponse(types.ResponsesResponse{ID: "resp_bg", Status: "queued"}, func() {})
+	sm.UpdateBackgroundResponse("resp_bg", "in_progress", nil)
+	sm.CancelBackgroundResponse("resp_bg")
+
+	result := types.ResponsesResponse{ID: "resp_bg", Status: "completed", Output: []types.ResponsesItem{{Type: "message", Role: "assistant"}}}
+	if _, err := sm.RecordResponse("", result, nil); err != ErrResponseFinished {
+		t.Errorf("期望 ErrResponseFinished，实际: %v", err)
+	}
+	if sm.StoreResponse("sess_any", result, nil) {
+		t.Error("StoreResponse 不应覆盖已取消的后台响应")
+	}
+	if stored, _ := sm.GetResponse("resp_bg"); stored.Response.Status != "cancelled" {
+		t.Errorf("取消后状态被覆盖: %+v", stored.Response)
+	}
+	if _, err := sm.GetOrCreateSession("resp_bg"); err == nil {
+		t.Error("已取消的后台响应不应写入会话")
+	}
+
+	// 失败的后台响应同样保持原状态
+	sm.StoreBackgroundResponse(types.ResponsesResponse{ID: "resp_failed", Status: "queued"}, func() {})
+	sm.UpdateBackgroundResponse("resp_failed", "failed", &types.ResponsesError{Code: "server_error"})
+	if _, err := sm.RecordResponse("", types.ResponsesResponse{ID: "resp_failed", Status: "completed"}, nil); err != ErrResponseFinished {
+		t.Errorf("期望 ErrResponseFinished，实际: %v", err)
+	}
+
+	// 执行中的后台响应正常完成，并可作为下一轮的 previous_response_id
+	sm.StoreBackgroundResponse(types.ResponsesResponse{ID: "resp_ok", Status: "queued"}, func() {})
+	first, err := sm.RecordResponse("", types.ResponsesResponse{ID: "resp_ok", Status: "completed"}, []types.ResponsesItem{{Type: "message", Role: "user", Content: "hi"}})
+	if err != nil || first.PreviousID != "" {
+		t.Fatalf("记录结果 = %+v, %v", first, err)
+	}
+	if stored, _ := sm.GetResponse("resp_ok"); stored.Response.Status != "completed" || !stored.Response.Background || stored.InputItems[0].ID == "" {
+		t.Errorf("完成后 = %+v", stored)
+	}
+	second, err := sm.RecordResponse("resp_ok", types.ResponsesResponse{ID: "resp_next", Status: "completed"}, nil)
+	if err != nil || second.PreviousID != "resp_ok" {
+		t.Errorf("下一轮 = %+v, %v", second, err)
+	}
+}
+
+func TestSessionManager_DeleteResponseRemovesTurn(t *testing.T) {
+	sm := NewSessionManager(time.Hour, 100, 100000)
+
+	turn := func(previousID, id, text string) {
+		t.Helper()
+		resp := types.ResponsesResponse{
+			ID:     id,
+			Status: "completed",
+			Output: []types.ResponsesItem{{Type: "text", Role: "assistant", Content: "答" + text}},
+			Usage:  types.ResponsesUsage{TotalTokens: 10},
+		}
+		if _, err := sm.RecordResponse(previousID, resp, []types.ResponsesItem{{Type: "text", Content: "问" + text}}); err != nil {
+			t.Fatalf("记录 %s 失败: %v", id, err)
+		}
+	}
+	turn("", "resp_1", "1")
+	turn("resp_1", "resp_2", "2")
+	turn("resp_2", "resp_3", "3")
+
+	history := func(sessionID string) string {
+		sess, err := sm.GetSession(sessionID)
+		if err != nil {
+			t.Fatalf("获取会话失败: %v", err)
+		}
+		var texts []string
+		for _, item := range sess.Messages {
+			texts = append(texts, item.Content.(string))
+		}
+		return strings.Join(texts, ",")
+	}
+	sess, _ := sm.GetOrCreateSession("resp_3")
+
+	// 删除中间一轮：该轮的输入和输出不再出现在历史中
+	sm.DeleteResponse("resp_2")
+	if got := history(sess.ID); got != "问1,答1,问3,答3" {
+		t.Errorf("删除 resp_2 后历史 = %s", got)
+	}
+	if sess.TotalTokens != 20 {
+		t.Errorf("TotalTokens = %d", sess.TotalTokens)
+	}
+
+	// 删除最后一轮：下一轮的 previous_id 回退到剩余的最后一个响应
+	sm.DeleteResponse("resp_3")
+	if got := history(sess.ID); got != "问1,答1" || sess.LastResponseID != "resp_1" {
+		t.Errorf("删除 resp_3 后历史 = %s, LastResponseID = %s", got, sess.LastResponseID)
+	}
+	next, err := sm.RecordResponse("resp_1", types.ResponsesResponse{ID: "resp_4", Status: "completed"}, nil)
+	if err != nil || next.PreviousID != "resp_1" {
+		t.Errorf("下一轮 = %+v, %v", next, err)
+	}
+}
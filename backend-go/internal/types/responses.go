@@ -18,14 +18,21 @@ type ResponsesRequest struct {
 	Stop               interface{} `json:"stop,omitempty"`               // 停止序列 (string 或 []string)
 	User               string      `json:"user,omitempty"`               // 用户标识
 	StreamOptions      interface{} `json:"stream_options,omitempty"`     // 流式选项
+	Background         bool        `json:"background,omitempty"`         // 后台执行（立即返回 queued 响应，通过 GET 轮询结果）
 }
 
 // ResponsesItem Responses API 消息项
 type ResponsesItem struct {
-	Type    string      `json:"type"`               // message, text, tool_call, tool_result
-	Role    string      `json:"role,omitempty"`     // user, assistant (用于 type=message)
-	Content interface{} `json:"content"`            // string 或 []ContentBlock
-	ToolUse *ToolUse    `json:"tool_use,omitempty"`
+	ID        string      `json:"id,omitempty"`      // 存储后分配的条目 ID（用于 input_items 分页）
+	Type      string      `json:"type"`              // message, text, function_call, function_call_output, tool_call, tool_result
+	Role      string      `json:"role,omitempty"`    // user, assistant (用于 type=message)
+	Content   interface{} `json:"content,omitempty"` // string 或 []ContentBlock
+	ToolUse   *ToolUse    `json:"tool_use,omitempty"`
+	CallID    string      `json:"call_id,omitempty"`   // 函数调用 ID（function_call / function_call_output）
+	Name      string      `json:"name,omitempty"`      // 函数名（function_call）
+	Arguments string      `json:"arguments,omitempty"` // 函数参数 JSON 字符串（function_call）
+	Output    interface{} `json:"output,omitempty"`    // 函数输出（function_call_output）
+	Status    string      `json:"status,omitempty"`    // 条目状态（completed 等）
 }
 
 // ContentBlock 内容块（用于嵌套 content 数组）
@@ -46,10 +53,18 @@ type ResponsesResponse struct {
 	ID         string          `json:"id"`
 	Model      string          `json:"model"`
 	Output     []ResponsesItem `json:"output"`
-	Status     string          `json:"status"` // completed, failed
+	Status     string          `json:"status"` // queued, in_progress, completed, failed, cancelled
 	PreviousID string          `json:"previous_id,omitempty"`
 	Usage      ResponsesUsage  `json:"usage"`
 	Created    int64           `json:"created,omitempty"`
+	Background bool            `json:"background,omitempty"`
+	Error      *ResponsesError `json:"error,omitempty"`
+}
+
+// ResponsesError 响应失败原因（后台响应执行失败时填写）
+type ResponsesError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // ResponsesUsage Responses API 使用统计
@@ -1,14 +1,60 @@
 package types
 
+import "encoding/json"
+
 // ClaudeRequest Claude 请求结构
+// 未声明的字段保存在 Extra 中，序列化时原样写回，保证解析后再序列化不丢失字段
 type ClaudeRequest struct {
-	Model       string          `json:"model"`
-	Messages    []ClaudeMessage `json:"messages"`
-	System      interface{}     `json:"system,omitempty"` // string 或 content 数组
-	MaxTokens   int             `json:"max_tokens,omitempty"`
-	Temperature float64         `json:"temperature,omitempty"`
-	Stream      bool            `json:"stream,omitempty"`
-	Tools       []ClaudeTool    `json:"tools,omitempty"`
+	Model         string            `json:"model"`
+	Messages      []ClaudeMessage   `json:"messages"`
+	System        interface{}       `json:"system,omitempty"` // string 或 content 数组
+	MaxTokens     int               `json:"max_tokens,omitempty"`
+	Temperature   *float64          `json:"temperature,omitempty"`
+	TopP          *float64          `json:"top_p,omitempty"`
+	TopK          *int              `json:"top_k,omitempty"`
+	StopSequences []string          `json:"stop_sequences,omitempty"`
+	Stream        bool              `json:"stream,omitempty"`
+	Tools         []ClaudeTool      `json:"tools,omitempty"`
+	ToolChoice    *ClaudeToolChoice `json:"tool_choice,omitempty"`
+	Metadata      *ClaudeMetadata   `json:"metadata,omitempty"`
+	Thinking      *ClaudeThinking   `json:"thinking,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON 解析请求并保留未声明的字段
+func (r *ClaudeRequest) UnmarshalJSON(data []byte) error {
+	type plain ClaudeRequest
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, r)
+	r.Extra = extra
+	return err
+}
+
+// MarshalJSON 序列化请求并写回未声明的字段
+func (r ClaudeRequest) MarshalJSON() ([]byte, error) {
+	type plain ClaudeRequest
+	return marshalWithExtra(plain(r), r.Extra)
+}
+
+// ClaudeToolChoice 工具选择
+type ClaudeToolChoice struct {
+	Type                   string `json:"type"` // auto, any, tool, none
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+// ClaudeMetadata 请求元数据
+type ClaudeMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// ClaudeThinking 扩展思考配置
+type ClaudeThinking struct {
+	Type         string `json:"type"` // enabled, disabled
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
 }
 
 // ClaudeMessage Claude 消息
@@ -19,40 +65,87 @@ type ClaudeMessage struct {
 
 // ClaudeContent Claude 内容块
 type ClaudeContent struct {
-	Type      string      `json:"type"` // text, tool_use, tool_result
+	Type      string      `json:"type"` // text, thinking, tool_use, tool_result
 	Text      string      `json:"text,omitempty"`
+	Thinking  string      `json:"thinking,omitempty"`
+	Signature string      `json:"signature,omitempty"`
 	ID        string      `json:"id,omitempty"`
 	Name      string      `json:"name,omitempty"`
 	Input     interface{} `json:"input,omitempty"`
 	ToolUseID string      `json:"tool_use_id,omitempty"`
 }
 
+// MarshalJSON thinking 块始终输出 thinking 和 signature 字段（严格的 SDK 会校验这两个字段）
+func (c ClaudeContent) MarshalJSON() ([]byte, error) {
+	type plain ClaudeContent
+	if c.Type != "thinking" {
+		return json.Marshal(plain(c))
+	}
+	return json.Marshal(struct {
+		plain
+		Thinking  string `json:"thinking"`
+		Signature string `json:"signature"`
+	}{plain(c), c.Thinking, c.Signature})
+}
+
 // ClaudeTool Claude 工具定义
+// 服务端工具（web_search 等）的 type 及 cache_control 等其余字段保存在 Extra 中
 type ClaudeTool struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description,omitempty"`
-	InputSchema interface{} `json:"input_schema"`
+	InputSchema interface{} `json:"input_schema,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON 解析工具定义并保留未声明的字段
+func (t *ClaudeTool) UnmarshalJSON(data []byte) error {
+	type plain ClaudeTool
+	if err := json.Unmarshal(data, (*plain)(t)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, t)
+	t.Extra = extra
+	return err
+}
+
+// MarshalJSON 序列化工具定义并写回未声明的字段
+func (t ClaudeTool) MarshalJSON() ([]byte, error) {
+	type plain ClaudeTool
+	return marshalWithExtra(plain(t), t.Extra)
 }
 
 // ClaudeResponse Claude 响应
 type ClaudeResponse struct {
-	ID         string          `json:"id"`
-	Type       string          `json:"type"`
-	Role       string          `json:"role"`
-	Content    []ClaudeContent `json:"content"`
-	StopReason string          `json:"stop_reason,omitempty"`
-	Usage      *Usage          `json:"usage,omitempty"`
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	Role         string          `json:"role"`
+	Content      []ClaudeContent `json:"content"`
+	StopReason   string          `json:"stop_reason,omitempty"`
+	StopSequence *string         `json:"stop_sequence"`
+	Usage        *Usage          `json:"usage,omitempty"`
 }
 
 // OpenAIRequest OpenAI 请求结构
 type OpenAIRequest struct {
-	Model               string          `json:"model"`
-	Messages            []OpenAIMessage `json:"messages"`
-	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
-	Temperature         float64         `json:"temperature,omitempty"`
-	Stream              bool            `json:"stream,omitempty"`
-	Tools               []OpenAITool    `json:"tools,omitempty"`
-	ToolChoice          string          `json:"tool_choice,omitempty"`
+	Model               string               `json:"model"`
+	Messages            []OpenAIMessage      `json:"messages"`
+	MaxCompletionTokens int                  `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64             `json:"temperature,omitempty"`
+	TopP                *float64             `json:"top_p,omitempty"`
+	Stop                []string             `json:"stop,omitempty"`
+	User                string               `json:"user,omitempty"`
+	Stream              bool                 `json:"stream,omitempty"`
+	Tools               []OpenAITool         `json:"tools,omitempty"`
+	ToolChoice          interface{}          `json:"tool_choice,omitempty"` // string 或 {"type":"function","function":{"name":...}}
+	ParallelToolCalls   *bool                `json:"parallel_tool_calls,omitempty"`
+	StreamOptions       *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	ReasoningEffort     string               `json:"reasoning_effort,omitempty"`
+}
+
+// OpenAIStreamOptions OpenAI 流式选项
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // OpenAIMessage OpenAI 消息
@@ -61,6 +154,9 @@ type OpenAIMessage struct {
 	Content    interface{}     `json:"content"` // string 或 null
 	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string          `json:"tool_call_id,omitempty"`
+	// 推理内容：DeepSeek、vLLM 等使用 reasoning_content，OpenRouter 等使用 reasoning
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
 }
 
 // OpenAIToolCall OpenAI 工具调用
@@ -100,6 +196,9 @@ type OpenAIResponse struct {
 type OpenAIChoice struct {
 	Message      OpenAIMessage `json:"message"`
 	FinishReason string        `json:"finish_reason,omitempty"`
+	StopReason   interface{}   `json:"stop_reason,omitempty"`   // vLLM：命中的停止序列或停止 token；兼容网关：Claude 停止原因
+	MatchedStop  interface{}   `json:"matched_stop,omitempty"`  // SGLang：命中的停止序列或停止 token
+	StopSequence interface{}   `json:"stop_sequence,omitempty"` // 兼容网关：命中的停止序列
 }
 
 // Usage 使用情况统计
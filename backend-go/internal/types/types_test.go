@@ -0,0 +1,47 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestClaudeRequest_PreservesUnknownFields(t *testing.T) {
+	body := `{
+		"model": "claude-a",
+		"max_tokens": 1024,
+		"temperature": 0,
+		"top_k": 5,
+		"system": [{"type": "text", "text": "sys", "cache_control": {"type": "ephemeral"}}],
+		"messages": [{"role": "user", "content": "hi"}],
+		"tools": [
+			{"name": "f", "input_schema": {"type": "object"}, "cache_control": {"type": "ephemeral"}},
+			{"type": "web_search_20250305", "name": "web_search", "max_uses": 3}
+		],
+		"service_tier": "auto",
+		"container": null
+	}`
+
+	var req ClaudeRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if req.TopK == nil || *req.TopK != 5 || req.Temperature == nil || *req.Temperature != 0 {
+		t.Errorf("采样参数 = top_k %v, temperature %v", req.TopK, req.Temperature)
+	}
+	if len(req.Extra) != 2 {
+		t.Errorf("未知字段 = %v", req.Extra)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	var original, roundTrip interface{}
+	json.Unmarshal([]byte(body), &original)
+	json.Unmarshal(data, &roundTrip)
+	originalJSON, _ := json.Marshal(original)
+	roundTripJSON, _ := json.Marshal(roundTrip)
+	if string(originalJSON) != string(roundTripJSON) {
+		t.Errorf("往返序列化丢失字段:\n原始: %s\n结果: %s", originalJSON, roundTripJSON)
+	}
+}
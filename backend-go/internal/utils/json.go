@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"strings"
 )
 
@@ -277,6 +279,46 @@ func SimplifyToolsInJSON(jsonData []byte) []byte {
 	return simplifiedBytes
 }
 
+// ReplaceJSONField 替换JSON对象顶层字段的值
+// 只改写该字段值所在的字节,其余内容(字段顺序、空白、数字精度、未知字段)保持不变
+func ReplaceJSONField(jsonData []byte, key string, value interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("请求体不是JSON对象")
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if tok != key {
+			continue
+		}
+
+		// RawMessage 不含值前的冒号和空白,由结束位置倒推起始位置
+		valueEnd := int(dec.InputOffset())
+		valueStart := valueEnd - len(raw)
+
+		result := make([]byte, 0, len(jsonData)-len(raw)+len(encoded))
+		result = append(result, jsonData[:valueStart]...)
+		result = append(result, encoded...)
+		result = append(result, jsonData[valueEnd:]...)
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("字段 %s 不存在", key)
+}
+
 // FormatJSONForLog 格式化JSON用于日志输出
 // 先简化tools,再截断长文本,最后美化格式
 func FormatJSONForLog(data interface{}, maxTextLength int) string {
@@ -206,3 +206,46 @@ func TestMaskAPIKey(t *testing.T) {
 		})
 	}
 }
+
+func TestReplaceJSONField(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "保留字段顺序和格式",
+			input:    `{"max_tokens": 1024, "model" : "claude-a", "top_k":5}`,
+			expected: `{"max_tokens": 1024, "model" : "claude-b", "top_k":5}`,
+		},
+		{
+			name:     "跳过嵌套对象中的同名字段",
+			input:    `{"metadata":{"model":"x"},"model":"claude-a"}`,
+			expected: `{"metadata":{"model":"x"},"model":"claude-b"}`,
+		},
+		{
+			name:     "保留大整数精度",
+			input:    `{"model":"claude-a","seed":12345678901234567890}`,
+			expected: `{"model":"claude-b","seed":12345678901234567890}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ReplaceJSONField([]byte(tt.input), "model", "claude-b")
+			if err != nil {
+				t.Fatalf("ReplaceJSONField() error = %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("ReplaceJSONField() = %s, want %s", result, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ReplaceJSONField([]byte(`{"other":1}`), "model", "x"); err == nil {
+		t.Error("字段不存在时应返回错误")
+	}
+	if _, err := ReplaceJSONField([]byte(`[1]`), "model", "x"); err == nil {
+		t.Error("非对象时应返回错误")
+	}
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryAfter 上游要求的冷却时长上限，防止异常值让密钥长期不可用
+const maxRetryAfter = 24 * time.Hour
+
+// RateLimitInfo 从上游响应中解析出的限流信息
+// 剩余额度与上限为 -1 表示上游未提供
+type RateLimitInfo struct {
+	RetryAfter        time.Duration // Retry-After / retry-after-ms / Gemini RetryInfo
+	RequestsLimit     int64
+	RequestsRemaining int64
+	RequestsReset     time.Time
+	TokensLimit       int64
+	TokensRemaining   int64
+	TokensReset       time.Time
+}
+
+// HasBudget 是否包含剩余额度信息
+func (r RateLimitInfo) HasBudget() bool {
+	return r.RequestsRemaining >= 0 || r.TokensRemaining >= 0
+}
+
+// Cooldown 计算密钥应冷却的时长（用于 429 等限流响应）
+// 优先使用 Retry-After；否则使用已耗尽额度的重置时间；都没有时返回 0，由调用方使用默认冷却时间
+func (r RateLimitInfo) Cooldown(now time.Time) time.Duration {
+	if r.RetryAfter > 0 {
+		return capRetryAfter(r.RetryAfter)
+	}
+
+	var until time.Time
+	if r.RequestsRemaining == 0 && r.RequestsReset.After(until) {
+		until = r.RequestsReset
+	}
+	if r.TokensRemaining == 0 && r.TokensReset.After(until) {
+		until = r.TokensReset
+	}
+	// 未提供剩余额度时无法判断是哪一项耗尽，取最晚的重置时间
+	if until.IsZero() && !r.HasBudget() {
+		if r.RequestsReset.After(until) {
+			until = r.RequestsReset
+		}
+		if r.TokensReset.After(until) {
+			until = r.TokensReset
+		}
+	}
+
+	if until.IsZero() || !until.After(now) {
+		return 0
+	}
+	return capRetryAfter(until.Sub(now))
+}
+
+// ParseRateLimitInfo 解析上游响应中的限流信息
+// 支持 Retry-After、retry-after-ms、anthropic-ratelimit-*、x-ratelimit-* 响应头，以及 Gemini 错误体中的 RetryInfo
+func ParseRateLimitInfo(header http.Header, body []byte) RateLimitInfo {
+	now := time.Now()
+	info := RateLimitInfo{
+		RequestsLimit:     -1,
+		RequestsRemaining: -1,
+		TokensLimit:       -1,
+		TokensRemaining:   -1,
+	}
+
+	// Retry-After：秒数或 HTTP 日期
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds > 0 {
+			info.RetryAfter = time.Duration(seconds * float64(time.Second))
+		} else if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			info.RetryAfter = t.Sub(now)
+		}
+	}
+	// OpenAI 额外提供毫秒精度的 retry-after-ms
+	if v := header.Get("retry-after-ms"); v != "" && info.RetryAfter == 0 {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			info.RetryAfter = time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	// Anthropic：anthropic-ratelimit-{requests,tokens}-{limit,remaining,reset}，reset 为 RFC 3339 时间
+	info.RequestsLimit = parseHeaderInt(header, "anthropic-ratelimit-requests-limit", info.RequestsLimit)
+	info.RequestsRemaining = parseHeaderInt(header, "anthropic-ratelimit-requests-remaining", info.RequestsRemaining)
+	info.RequestsReset = parseResetTime(header.Get("anthropic-ratelimit-requests-reset"), now)
+	info.TokensLimit = parseHeaderInt(header, "anthropic-ratelimit-tokens-limit", info.TokensLimit)
+	info.TokensRemaining = parseHeaderInt(header, "anthropic-ratelimit-tokens-remaining", info.TokensRemaining)
+	info.TokensReset = parseResetTime(header.Get("anthropic-ratelimit-tokens-reset"), now)
+
+	// 未提供总 token 限额时，使用输入/输出 token 中更紧张的一项
+	if info.TokensRemaining < 0 {
+		for _, kind := range []string{"input-tokens", "output-tokens"} {
+			remaining := parseHeaderInt(header, "anthropic-ratelimit-"+kind+"-remaining", -1)
+			if remaining < 0 || (info.TokensRemaining >= 0 && remaining >= info.TokensRemaining) {
+				continue
+			}
+			info.TokensRemaining = remaining
+			info.TokensLimit = parseHeaderInt(header, "anthropic-ratelimit-"+kind+"-limit", -1)
+			info.TokensReset = parseResetTime(header.Get("anthropic-ratelimit-"+kind+"-reset"), now)
+		}
+	}
+
+	// OpenAI 及兼容服务：x-ratelimit-{limit,remaining,reset}-{requests,tokens}，reset 为时长（如 1s、6m0s）
+	if info.RequestsRemaining < 0 {
+		info.RequestsLimit = parseHeaderInt(header, "x-ratelimit-limit-requests", info.RequestsLimit)
+		info.RequestsRemaining = parseHeaderInt(header, "x-ratelimit-remaining-requests", info.RequestsRemaining)
+	}
+	if info.RequestsReset.IsZero() {
+		info.RequestsReset = parseResetTime(header.Get("x-ratelimit-reset-requests"), now)
+	}
+	if info.TokensRemaining < 0 {
+		info.TokensLimit = parseHeaderInt(header, "x-ratelimit-limit-tokens", info.TokensLimit)
+		info.TokensRemaining = parseHeaderInt(header, "x-ratelimit-remaining-tokens", info.TokensRemaining)
+	}
+	if info.TokensReset.IsZero() {
+		info.TokensReset = parseResetTime(header.Get("x-ratelimit-reset-tokens"), now)
+	}
+
+	// Gemini：错误体 error.details 中的 google.rpc.RetryInfo
+	if info.RetryAfter == 0 && len(body) > 0 {
+		info.RetryAfter = parseGeminiRetryDelay(body)
+	}
+
+	return info
+}
+
+// parseHeaderInt 解析整数响应头，缺失或无效时返回默认值
+func parseHeaderInt(header http.Header, name string, fallback int64) int64 {
+	v := strings.TrimSpace(header.Get(name))
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return fallback
+	}
+	return n
+}
+
+// parseResetTime 解析额度重置时间，支持 RFC 3339 时间、Go 时长字符串（1m30s、20ms）和秒数
+func parseResetTime(v string, now time.Time) time.Time {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return now.Add(d)
+	}
+	if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return time.Time{}
+}
+
+// parseGeminiRetryDelay 从 Gemini 错误体中提取 RetryInfo.retryDelay
+func parseGeminiRetryDelay(body []byte) time.Duration {
+	type geminiError struct {
+		Error struct {
+			Details []struct {
+				Type       string `json:"@type"`
+				RetryDelay string `json:"retryDelay"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+
+	// Gemini 部分接口以数组形式返回错误
+	var errs []geminiError
+	var single geminiError
+	if err := json.Unmarshal(body, &single); err == nil {
+		errs = append(errs, single)
+	} else if err := json.Unmarshal(body, &errs); err != nil {
+		return 0
+	}
+
+	for _, e := range errs {
+		for _, detail := range e.Error.Details {
+			if !strings.HasSuffix(detail.Type, "google.rpc.RetryInfo") || detail.RetryDelay == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(detail.RetryDelay); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// capRetryAfter 限制冷却时长上限
+func capRetryAfter(d time.Duration) time.Duration {
+	if d > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return d
+}
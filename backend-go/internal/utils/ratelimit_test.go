@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitInfo(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		body         string
+		wantCooldown time.Duration
+		wantReqLeft  int64
+		wantTokLeft  int64
+	}{
+		{
+			name:         "Retry-After 秒数",
+			headers:      map[string]string{"Retry-After": "2"},
+			wantCooldown: 2 * time.Second,
+			wantReqLeft:  -1,
+			wantTokLeft:  -1,
+		},
+		{
+			name:         "Retry-After HTTP 日期",
+			headers:      map[string]string{"Retry-After": now.Add(2 * time.Hour).UTC().Format(http.TimeFormat)},
+			wantCooldown: 2 * time.Hour,
+			wantReqLeft:  -1,
+			wantTokLeft:  -1,
+		},
+		{
+			name:         "retry-after-ms",
+			headers:      map[string]string{"retry-after-ms": "1500"},
+			wantCooldown: 1500 * time.Millisecond,
+			wantReqLeft:  -1,
+			wantTokLeft:  -1,
+		},
+		{
+			name: "Anthropic 请求额度耗尽",
+			headers: map[string]string{
+				"anthropic-ratelimit-requests-limit":     "50",
+				"anthropic-ratelimit-requests-remaining": "0",
+				"anthropic-ratelimit-requests-reset":     now.Add(30 * time.Second).UTC().Format(time.RFC3339),
+				"anthropic-ratelimit-tokens-limit":       "40000",
+				"anthropic-ratelimit-tokens-remaining":   "38000",
+				"anthropic-ratelimit-tokens-reset":       now.Add(5 * time.Second).UTC().Format(time.RFC3339),
+			},
+			wantCooldown: 30 * time.Second,
+			wantReqLeft:  0,
+			wantTokLeft:  38000,
+		},
+		{
+			name: "OpenAI token 额度耗尽",
+			headers: map[string]string{
+				"x-ratelimit-limit-requests":     "500",
+				"x-ratelimit-remaining-requests": "499",
+				"x-ratelimit-reset-requests":     "120ms",
+				"x-ratelimit-limit-tokens":       "30000",
+				"x-ratelimit-remaining-tokens":   "0",
+				"x-ratelimit-reset-tokens":       "6m0s",
+			},
+			wantCooldown: 6 * time.Minute,
+			wantReqLeft:  499,
+			wantTokLeft:  0,
+		},
+		{
+			name: "Gemini RetryInfo",
+			body: `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[
+				{"@type":"type.googleapis.com/google.rpc.QuotaFailure"},
+				{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"37s"}]}}`,
+			wantCooldown: 37 * time.Second,
+			wantReqLeft:  -1,
+			wantTokLeft:  -1,
+		},
+		{
+			name:         "Gemini 数组形式错误",
+			body:         `[{"error":{"details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"1.5s"}]}}]`,
+			wantCooldown: 1500 * time.Millisecond,
+			wantReqLeft:  -1,
+			wantTokLeft:  -1,
+		},
+		{
+			name:         "异常值限制上限",
+			headers:      map[string]string{"Retry-After": "9999999"},
+			wantCooldown: maxRetryAfter,
+			wantReqLeft:  -1,
+			wantTokLeft:  -1,
+		},
+		{
+			name:         "无限流信息",
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"error":{"message":"rate limited"}}`,
+			wantCooldown: 0,
+			wantReqLeft:  -1,
+			wantTokLeft:  -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+
+			info := ParseRateLimitInfo(header, []byte(tt.body))
+
+			// 时间相关的结果允许少量误差
+			got := info.Cooldown(time.Now())
+			if diff := got - tt.wantCooldown; diff > 2*time.Second || diff < -2*time.Second {
+				t.Errorf("Cooldown() = %v, want %v", got, tt.wantCooldown)
+			}
+			if info.RequestsRemaining != tt.wantReqLeft {
+				t.Errorf("RequestsRemaining = %d, want %d", info.RequestsRemaining, tt.wantReqLeft)
+			}
+			if info.TokensRemaining != tt.wantTokLeft {
+				t.Errorf("TokensRemaining = %d, want %d", info.TokensRemaining, tt.wantTokLeft)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/json"
+	"unicode"
+)
+
+// 本地 token 估算，用于上游不提供计数接口的渠道（如 OpenAI 兼容中转）
+// 无需分词器：CJK 字符按 1 token/字，其余文本按单词和标点切分，英文单词约 4 字符/token
+const (
+	messageOverheadTokens     = 3    // 每条消息的角色、分隔符开销
+	toolUseSystemPromptTokens = 346  // 带工具的请求上游注入的工具使用提示词
+	estimatedMediaTokens      = 1600 // 图片 / 二进制文档（最大约 1092x1092 像素的图片）
+)
+
+// EstimateTokens 估算文本的 token 数
+func EstimateTokens(text string) int {
+	tokens := 0
+	wordLen := 0
+	flush := func() {
+		if wordLen > 0 {
+			tokens += (wordLen + 3) / 4
+			wordLen = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			wordLen++
+		default:
+			// 标点符号和其他字符单独计数
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// EstimateClaudeRequestTokens 估算 Claude Messages 请求的输入 token 数（system、messages 和 tools）
+func EstimateClaudeRequestTokens(body []byte) (int, error) {
+	var req struct {
+		System   interface{} `json:"system"`
+		Messages []struct {
+			Content interface{} `json:"content"`
+		} `json:"messages"`
+		Tools []json.RawMessage `json:"tools"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return 0, err
+	}
+
+	total := estimateContentTokens(req.System)
+	for _, msg := range req.Messages {
+		total += messageOverheadTokens + estimateContentTokens(msg.Content)
+	}
+	if len(req.Tools) > 0 {
+		total += toolUseSystemPromptTokens
+		for _, tool := range req.Tools {
+			total += EstimateTokens(string(tool))
+		}
+	}
+	return total, nil
+}
+
+// estimateContentTokens 估算字符串或内容块数组的 token 数
+func estimateContentTokens(content interface{}) int {
+	switch v := content.(type) {
+	case nil:
+		return 0
+	case string:
+		return EstimateTokens(v)
+	case []interface{}:
+		total := 0
+		for _, item := range v {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			total += estimateBlockTokens(block)
+		}
+		return total
+	}
+
+	data, _ := json.Marshal(content)
+	return EstimateTokens(string(data))
+}
+
+// estimateBlockTokens 估算单个内容块的 token 数
+func estimateBlockTokens(block map[string]interface{}) int {
+	switch block["type"] {
+	case "text":
+		text, _ := block["text"].(string)
+		return EstimateTokens(text)
+	case "thinking":
+		thinking, _ := block["thinking"].(string)
+		return EstimateTokens(thinking)
+	case "image":
+		return estimatedMediaTokens
+	case "document":
+		// 纯文本文档按内容估算，PDF 等二进制文档无法获取页数，按固定值估算
+		if source, ok := block["source"].(map[string]interface{}); ok && source["type"] == "text" {
+			data, _ := source["data"].(string)
+			return EstimateTokens(data)
+		}
+		return estimatedMediaTokens
+	case "tool_use":
+		name, _ := block["name"].(string)
+		input, _ := json.Marshal(block["input"])
+		return EstimateTokens(name) + EstimateTokens(string(input))
+	case "tool_result":
+		return estimateContentTokens(block["content"])
+	}
+
+	data, _ := json.Marshal(block)
+	return EstimateTokens(string(data))
+}
@@ -0,0 +1,55 @@
+package utils
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"空文本", "", 0},
+		{"英文单词", "Hello world", 4},
+		{"长单词按4字符切分", "internationalization", 5},
+		{"标点单独计数", "Hi, there!", 5},
+		{"中文按字计数", "你好世界", 4},
+		{"中英混合", "Go 语言", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateTokens(tt.text); got != tt.want {
+				t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateClaudeRequestTokens(t *testing.T) {
+	base := `{"model":"m","system":"Be brief.","messages":[{"role":"user","content":"Hello world"}]}`
+	got, err := EstimateClaudeRequestTokens([]byte(base))
+	if err != nil {
+		t.Fatalf("估算失败: %v", err)
+	}
+	// system 4 + 消息开销 3 + 内容 4
+	if got != 11 {
+		t.Errorf("基础请求估算 = %d, want 11", got)
+	}
+
+	withMedia := `{"messages":[{"role":"user","content":[
+		{"type":"text","text":"Hello world"},
+		{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}}
+	]}]}`
+	if got, _ := EstimateClaudeRequestTokens([]byte(withMedia)); got != messageOverheadTokens+4+estimatedMediaTokens {
+		t.Errorf("含图片请求估算 = %d", got)
+	}
+
+	withTools := `{"messages":[{"role":"user","content":"Hi"}],"tools":[{"name":"lookup","input_schema":{"type":"object"}}]}`
+	if got, _ := EstimateClaudeRequestTokens([]byte(withTools)); got <= toolUseSystemPromptTokens {
+		t.Errorf("含工具请求估算 = %d, 应包含工具提示词开销", got)
+	}
+
+	if _, err := EstimateClaudeRequestTokens([]byte(`{`)); err == nil {
+		t.Error("非法JSON应返回错误")
+	}
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// UsageTracker 从上游响应中统计 token 用量，兼容 Claude、OpenAI、Gemini 和 Responses 格式
+// 各协议流式事件中的用量均为累计值，因此每项取最近一次出现的非零值
+type UsageTracker struct {
+	InputTokens  int64 // 含缓存读写的输入 token
+	OutputTokens int64
+}
+
+// usageFields 各协议的用量字段
+type usageFields struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	PromptTokens             int64 `json:"prompt_tokens"`
+	CompletionTokens         int64 `json:"completion_tokens"`
+	PromptTokenCount         int64 `json:"promptTokenCount"`
+	CandidatesTokenCount     int64 `json:"candidatesTokenCount"`
+}
+
+// usageEnvelope 用量字段可能出现的位置
+type usageEnvelope struct {
+	Usage         *usageFields `json:"usage"`         // Claude message_delta / 非流式响应、OpenAI
+	UsageMetadata *usageFields `json:"usageMetadata"` // Gemini
+	Message       *struct {
+		Usage *usageFields `json:"usage"`
+	} `json:"message"` // Claude message_start
+	Response *struct {
+		Usage *usageFields `json:"usage"`
+	} `json:"response"` // Responses response.completed
+}
+
+// ProcessLine 处理一行 SSE 数据（仅解析包含用量信息的 data 行）
+func (t *UsageTracker) ProcessLine(line string) {
+	data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+	if !ok || !strings.Contains(data, "sage") {
+		return
+	}
+	t.ProcessJSON([]byte(strings.TrimSpace(data)))
+}
+
+// ProcessJSON 处理完整的 JSON 响应体或单个流式事件
+func (t *UsageTracker) ProcessJSON(data []byte) {
+	var envelope usageEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return
+	}
+
+	for _, usage := range []*usageFields{
+		envelope.Usage,
+		envelope.UsageMetadata,
+		messageUsage(envelope),
+		responseUsage(envelope),
+	} {
+		if usage != nil {
+			t.merge(usage)
+		}
+	}
+}
+
+// merge 合并一组用量字段
+func (t *UsageTracker) merge(u *usageFields) {
+	input := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	if input == 0 {
+		input = u.PromptTokens + u.PromptTokenCount
+	}
+	output := u.OutputTokens
+	if output == 0 {
+		output = u.CompletionTokens + u.CandidatesTokenCount
+	}
+
+	if input > 0 {
+		t.InputTokens = input
+	}
+	if output > 0 {
+		t.OutputTokens = output
+	}
+}
+
+// TotalTokens 输入与输出 token 合计
+func (t *UsageTracker) TotalTokens() int64 {
+	return t.InputTokens + t.OutputTokens
+}
+
+func messageUsage(e usageEnvelope) *usageFields {
+	if e.Message == nil {
+		return nil
+	}
+	return e.Message.Usage
+}
+
+func responseUsage(e usageEnvelope) *usageFields {
+	if e.Response == nil {
+		return nil
+	}
+	return e.Response.Usage
+}
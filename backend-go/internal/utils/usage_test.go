@@ -0,0 +1,70 @@
+package utils
+
+import "testing"
+
+func TestUsageTracker(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantInput  int64
+		wantOutput int64
+	}{
+		{
+			name: "Claude 流式事件",
+			lines: []string{
+				`event: message_start`,
+				`data: {"type":"message_start","message":{"usage":{"input_tokens":10,"cache_read_input_tokens":90,"output_tokens":1}}}`,
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"usage"}}`,
+				`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":42}}`,
+			},
+			wantInput:  100,
+			wantOutput: 42,
+		},
+		{
+			name: "OpenAI 流式最后一块",
+			lines: []string{
+				`data: {"choices":[{"delta":{"content":"hi"}}]}`,
+				`data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`,
+				`data: [DONE]`,
+			},
+			wantInput:  12,
+			wantOutput: 3,
+		},
+		{
+			name: "Gemini 累计用量",
+			lines: []string{
+				`data: {"candidates":[],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":2}}`,
+				`data: {"candidates":[],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":9}}`,
+			},
+			wantInput:  8,
+			wantOutput: 9,
+		},
+		{
+			name: "Responses 完成事件",
+			lines: []string{
+				`data: {"type":"response.completed","response":{"usage":{"input_tokens":20,"output_tokens":5,"total_tokens":25}}}`,
+			},
+			wantInput:  20,
+			wantOutput: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracker UsageTracker
+			for _, line := range tt.lines {
+				tracker.ProcessLine(line)
+			}
+			if tracker.InputTokens != tt.wantInput || tracker.OutputTokens != tt.wantOutput {
+				t.Errorf("got input=%d output=%d, want input=%d output=%d",
+					tracker.InputTokens, tracker.OutputTokens, tt.wantInput, tt.wantOutput)
+			}
+		})
+	}
+
+	var tracker UsageTracker
+	tracker.ProcessJSON([]byte(`{"id":"msg_1","usage":{"input_tokens":7,"output_tokens":4}}`))
+	if tracker.TotalTokens() != 11 {
+		t.Errorf("非流式响应 TotalTokens = %d, want 11", tracker.TotalTokens())
+	}
+}
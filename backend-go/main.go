@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/BenedictKing/claude-proxy/internal/batch"
 	"github.com/BenedictKing/claude-proxy/internal/config"
 	"github.com/BenedictKing/claude-proxy/internal/handlers"
 	"github.com/BenedictKing/claude-proxy/internal/middleware"
@@ -41,11 +42,23 @@ func main() {
 	)
 	log.Printf("✅ 会话管理器已初始化")
 
-	// 设置 Gin 模式
+	// 设置 Gin 模式（批处理和后台响应的内部请求引擎同样遵循该模式）
 	if envCfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// 初始化批处理管理器（/v1/messages/batches，经 Messages 渠道池执行）
+	batchManager, err := batch.NewManager(
+		".config/batches",
+		handlers.NewBatchExecutor(envCfg, cfgManager),
+		func() int { return cfgManager.GetBatchConfig().Concurrency },
+	)
+	if err != nil {
+		log.Fatalf("初始化批处理管理器失败: %v", err)
+	}
+	// 并发上限随配置热重载生效，需唤醒正在等待的调度循环
+	cfgManager.OnReload(batchManager.Wake)
+
 	// 创建路由器
 	r := gin.Default()
 
@@ -77,6 +90,8 @@ func main() {
 		apiGroup.POST("/channels/:id/keys", handlers.AddApiKey(cfgManager))
 		apiGroup.DELETE("/channels/:id/keys/:apiKey", handlers.DeleteApiKey(cfgManager))
 		apiGroup.POST("/channels/:id/current", handlers.SetCurrentUpstream(cfgManager))
+		apiGroup.POST("/channels/:id/circuit/reset", handlers.ResetCircuitBreaker(cfgManager))
+		apiGroup.PUT("/channels/:id/keys/:apiKey/state", handlers.SetKeyState(cfgManager))
 
 		// Responses 渠道管理
 		apiGroup.GET("/responses/channels", handlers.GetResponsesUpstreams(cfgManager))
@@ -86,10 +101,20 @@ func main() {
 		apiGroup.POST("/responses/channels/:id/keys", handlers.AddResponsesApiKey(cfgManager))
 		apiGroup.DELETE("/responses/channels/:id/keys/:apiKey", handlers.DeleteResponsesApiKey(cfgManager))
 		apiGroup.POST("/responses/channels/:id/current", handlers.SetCurrentResponsesUpstream(cfgManager))
+		apiGroup.POST("/responses/channels/:id/circuit/reset", handlers.ResetResponsesCircuitBreaker(cfgManager))
+		apiGroup.PUT("/responses/channels/:id/keys/:apiKey/state", handlers.SetResponsesKeyState(cfgManager))
+
+		// 模型路由表
+		apiGroup.GET("/routing", handlers.GetRoutingTable(cfgManager))
+		apiGroup.GET("/responses/routing", handlers.GetResponsesRoutingTable(cfgManager))
 
 		// 负载均衡
+		apiGroup.GET("/loadbalance", handlers.GetLoadBalance(cfgManager))
 		apiGroup.PUT("/loadbalance", handlers.UpdateLoadBalance(cfgManager))
 
+		// 内置故障转移规则
+		apiGroup.GET("/failover/rules", handlers.GetDefaultFailoverRules())
+
 		// Ping测试
 		apiGroup.GET("/ping/:id", handlers.PingChannel(cfgManager))
 		apiGroup.GET("/ping", handlers.PingAllChannels(cfgManager))
@@ -97,9 +122,34 @@ func main() {
 
 	// 代理端点 - 统一入口
 	r.POST("/v1/messages", handlers.ProxyHandler(envCfg, cfgManager))
+	r.POST("/v1/messages/count_tokens", handlers.CountTokensHandler(envCfg, cfgManager))
+
+	// Message Batches 端点（本地队列模拟）
+	r.POST("/v1/messages/batches", handlers.CreateBatch(envCfg, batchManager))
+	r.GET("/v1/messages/batches", handlers.ListBatches(envCfg, batchManager))
+	r.GET("/v1/messages/batches/:id", handlers.GetBatch(envCfg, batchManager))
+	r.DELETE("/v1/messages/batches/:id", handlers.DeleteBatch(envCfg, batchManager))
+	r.POST("/v1/messages/batches/:id/cancel", handlers.CancelBatch(envCfg, batchManager))
+	r.GET("/v1/messages/batches/:id/results", handlers.GetBatchResults(envCfg, batchManager))
 
 	// Responses API 端点
 	r.POST("/v1/responses", handlers.ResponsesHandler(envCfg, cfgManager, sessionManager))
+	r.GET("/v1/responses/:id", handlers.GetResponseHandler(envCfg, sessionManager))
+	r.DELETE("/v1/responses/:id", handlers.DeleteResponseHandler(envCfg, sessionManager))
+	r.GET("/v1/responses/:id/input_items", handlers.ListInputItemsHandler(envCfg, sessionManager))
+	r.POST("/v1/responses/:id/cancel", handlers.CancelResponseHandler(envCfg, sessionManager))
+
+	// OpenAI Chat Completions 端点（使用 Messages 渠道）
+	r.POST("/v1/chat/completions", handlers.ChatCompletionsHandler(envCfg, cfgManager))
+
+	// Gemini generateContent 端点（使用 Messages 渠道）
+	r.POST("/v1beta/models/:modelAction", handlers.GeminiHandler(envCfg, cfgManager))
+
+	// 模型列表端点（汇总所有渠道，按请求格式返回 Anthropic / OpenAI / Gemini 格式）
+	r.GET("/v1/models", handlers.ModelsHandler(envCfg, cfgManager))
+	r.GET("/v1/models/:model", handlers.ModelHandler(envCfg, cfgManager))
+	r.GET("/v1beta/models", handlers.ModelsHandler(envCfg, cfgManager))
+	r.GET("/v1beta/models/:model", handlers.ModelHandler(envCfg, cfgManager))
 
 	// 静态文件服务 (嵌入的前端)
 	if envCfg.EnableWebUI {
@@ -134,7 +184,13 @@ func main() {
 	fmt.Printf("📍 本地地址: http://localhost:%d\n", envCfg.Port)
 	fmt.Printf("🌐 管理界面: http://localhost:%d\n", envCfg.Port)
 	fmt.Printf("📋 Claude Messages: POST /v1/messages\n")
+	fmt.Printf("📋 Token 计数: POST /v1/messages/count_tokens\n")
+	fmt.Printf("📋 Message Batches: /v1/messages/batches\n")
 	fmt.Printf("📋 Codex Responses: POST /v1/responses\n")
+	fmt.Printf("📋 已存储响应: GET/DELETE /v1/responses/:id, GET /v1/responses/:id/input_items, POST /v1/responses/:id/cancel\n")
+	fmt.Printf("📋 OpenAI Chat Completions: POST /v1/chat/completions\n")
+	fmt.Printf("📋 Gemini: POST /v1beta/models/{model}:generateContent\n")
+	fmt.Printf("📋 模型列表: GET /v1/models\n")
 	fmt.Printf("💚 健康检查: GET %s\n", envCfg.HealthCheckPath)
 	fmt.Printf("📊 环境: %s\n\n", envCfg.Env)
 